@@ -0,0 +1,260 @@
+// Package auth authenticates API callers, with API keys kept in the store, an admin key from the
+// environment or HS256 JWTs, and checks their role against the one a route requires. Requests to
+// operator and admin routes are written to the audit log in the store.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/types"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// How a caller authenticated, see Principal.
+const (
+	MethodAPIKey    = "api_key"
+	MethodAdminKey  = "admin_key"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous"
+)
+
+const (
+	// APIKeyHeader carries an API key, Authorization: Bearer works for keys and JWTs alike.
+	APIKeyHeader = "X-API-Key"
+	// TokenParam is the query parameter for clients that can't set headers, like EventSource.
+	TokenParam = "access_token"
+
+	principalKey = "auth.principal"
+	// auditTimeFormat has fixed width so the entries sort by At as strings.
+	auditTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+var (
+	ErrNoCredentials      = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Config struct {
+	AdminKey    string     // accepted as an admin API key, to create the first keys
+	JWTSecret   []byte     // HS256 key of the JWTs, JWTs aren't accepted without one
+	JWTIssuer   string     // iss the JWTs need, any when empty
+	JWTAudience string     // aud the JWTs need, any when empty
+	Anonymous   types.Role // role of requests without credentials, none when empty
+}
+
+// ConfigFromEnv reads AUTH_ADMIN_KEY, AUTH_JWT_SECRET, AUTH_JWT_ISSUER, AUTH_JWT_AUDIENCE and
+// AUTH_ANONYMOUS_ROLE (none by default, every route needs credentials).
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		AdminKey:    os.Getenv("AUTH_ADMIN_KEY"),
+		JWTSecret:   []byte(os.Getenv("AUTH_JWT_SECRET")),
+		JWTIssuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		JWTAudience: os.Getenv("AUTH_JWT_AUDIENCE"),
+	}
+	switch role := types.Role(os.Getenv("AUTH_ANONYMOUS_ROLE")); {
+	case role == "" || role == "none":
+	case role.Rank() == 0:
+		return cfg, fmt.Errorf("AUTH_ANONYMOUS_ROLE must be none, reader, operator or admin, got %q", role)
+	default:
+		log.Printf("Warning: AUTH_ANONYMOUS_ROLE=%s, requests without credentials can use every %s route", role, role)
+		cfg.Anonymous = role
+	}
+	if cfg.AdminKey != "" && len(cfg.AdminKey) < 16 {
+		return cfg, errors.New("AUTH_ADMIN_KEY must be at least 16 characters")
+	}
+	return cfg, nil
+}
+
+// Principal is who made a request.
+type Principal struct {
+	ID     string     `json:"id"` // API key ID, JWT subject, "admin-key" or "anonymous"
+	Name   string     `json:"name,omitempty"`
+	Role   types.Role `json:"role"`
+	Method string     `json:"method"`
+}
+
+type Authenticator struct {
+	store db.Store
+	cfg   Config
+}
+
+func New(store db.Store, cfg Config) *Authenticator {
+	return &Authenticator{store: store, cfg: cfg}
+}
+
+// Authenticate finds the principal of a request from its X-API-Key header, Authorization bearer
+// token or access_token parameter. Requests without any are anonymous when Config.Anonymous is set.
+// Bad credentials are an error even then, they don't fall back to anonymous.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	token := credentials(r)
+	if token == "" {
+		if a.cfg.Anonymous == "" {
+			return Principal{}, ErrNoCredentials
+		}
+		return Principal{ID: MethodAnonymous, Role: a.cfg.Anonymous, Method: MethodAnonymous}, nil
+	}
+
+	if a.cfg.AdminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.AdminKey)) == 1 {
+		return Principal{ID: "admin-key", Name: "AUTH_ADMIN_KEY", Role: types.RoleAdmin, Method: MethodAdminKey}, nil
+	}
+	if id, ok := keyID(token); ok {
+		return a.apiKey(id, token)
+	}
+	if len(a.cfg.JWTSecret) > 0 && strings.Count(token, ".") == 2 {
+		claims, err := ParseJWT(token, a.cfg.JWTSecret, a.cfg.JWTIssuer, a.cfg.JWTAudience, time.Now())
+		if err != nil {
+			return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		return Principal{ID: claims.Subject, Name: claims.Name, Role: claims.Role, Method: MethodJWT}, nil
+	}
+	return Principal{}, ErrInvalidCredentials
+}
+
+func (a *Authenticator) apiKey(id, token string) (Principal, error) {
+	key, err := a.store.GetAPIKey(id)
+	if errors.Is(err, db.ErrNotFound) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashKey(token)), []byte(key.Hash)) != 1 {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if key.RevokedAt != "" {
+		return Principal{}, fmt.Errorf("%w: key %s is revoked", ErrInvalidCredentials, key.ID)
+	}
+	if expires, err := time.Parse(time.RFC3339, key.ExpiresAt); err == nil && time.Now().After(expires) {
+		return Principal{}, fmt.Errorf("%w: key %s expired", ErrInvalidCredentials, key.ID)
+	}
+	return Principal{ID: key.ID, Name: key.Name, Role: key.Role, Method: MethodAPIKey}, nil
+}
+
+func credentials(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return strings.TrimSpace(key)
+	}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get(TokenParam)
+}
+
+// Require lets through requests whose principal has at least the role, others get a 401 without
+// (valid) credentials or a 403. Requests to operator and admin routes are audited, denied ones too.
+func (a *Authenticator) Require(role types.Role) gin.HandlerFunc {
+	audited := role.Rank() >= types.RoleOperator.Rank()
+	return func(c *gin.Context) {
+		principal, err := a.Authenticate(c.Request)
+		switch {
+		case err != nil && !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidCredentials):
+			log.Printf("Error authenticating %s %s: %v", c.Request.Method, c.FullPath(), err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check credentials"})
+		case err != nil:
+			log.Printf("Denied %s %s from %s: %v", c.Request.Method, c.FullPath(), c.ClientIP(), err)
+			message := ErrInvalidCredentials.Error() // the details stay in the log
+			if errors.Is(err, ErrNoCredentials) {
+				message = err.Error()
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="firebird"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+		case principal.Role.Rank() < role.Rank():
+			log.Printf("Denied %s %s to %s (%s): needs %s", c.Request.Method, c.FullPath(), principal.ID, principal.Role, role)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("requires the %s role", role)})
+		default:
+			c.Set(principalKey, principal)
+			c.Next()
+		}
+		if audited {
+			a.audit(c, principal)
+		}
+	}
+}
+
+// PrincipalFrom returns the principal Require found for the request.
+func PrincipalFrom(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := v.(Principal)
+	return principal, ok
+}
+
+func (a *Authenticator) audit(c *gin.Context, principal Principal) {
+	entry := types.AuditEntry{
+		ID:         uuid.NewString(),
+		At:         time.Now().UTC().Format(auditTimeFormat),
+		Actor:      principal.ID,
+		ActorName:  principal.Name,
+		AuthMethod: principal.Method,
+		Role:       principal.Role,
+		Method:     c.Request.Method,
+		Route:      c.FullPath(),
+		Path:       c.Request.URL.Path,
+		Status:     c.Writer.Status(),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if entry.Actor == "" {
+		entry.Actor = "unknown" // bad credentials
+	}
+	// The query is kept without the token
+	if query := c.Request.URL.Query(); len(query) > 0 {
+		query.Del(TokenParam)
+		if encoded := query.Encode(); encoded != "" {
+			entry.Path += "?" + encoded
+		}
+	}
+	if err := a.store.SaveAuditEntry(entry); err != nil {
+		log.Printf("Error saving audit entry for %s %s by %s: %v", entry.Method, entry.Path, entry.Actor, err)
+	}
+}
+
+// ListKeys returns the API keys, oldest first, revoked ones included.
+func (a *Authenticator) ListKeys() ([]types.APIKey, error) {
+	keys, err := a.store.GetAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt < keys[j].CreatedAt })
+	return keys, nil
+}
+
+// CreateKey saves a new key and returns it, the only time the key itself is available.
+func (a *Authenticator) CreateKey(req KeyRequest, createdBy string) (string, types.APIKey, error) {
+	plain, key, err := NewKey(req, createdBy, time.Now())
+	if err != nil {
+		return "", key, err
+	}
+	if err := a.store.SaveAPIKey(key); err != nil {
+		return "", key, fmt.Errorf("saving API key: %w", err)
+	}
+	return plain, key, nil
+}
+
+// RevokeKey stops a key from working. Revoked keys stay in the store so the audit log can name them.
+func (a *Authenticator) RevokeKey(id string) (types.APIKey, error) {
+	key, err := a.store.GetAPIKey(id)
+	if errors.Is(err, db.ErrNotFound) {
+		return key, ErrKeyNotFound
+	}
+	if err != nil || key.RevokedAt != "" {
+		return key, err
+	}
+	key.RevokedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := a.store.SaveAPIKey(key); err != nil {
+		return key, fmt.Errorf("revoking API key %s: %w", id, err)
+	}
+	return key, nil
+}
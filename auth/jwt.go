@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-firebird/types"
+	"strings"
+	"time"
+)
+
+// jwtLeeway is the clock skew allowed on exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+// Claims are the JWT claims the API reads. Tokens are HS256 signed with AUTH_JWT_SECRET by whoever
+// issues them, they need a subject, a role and an expiry.
+type Claims struct {
+	Subject   string     `json:"sub"`
+	Name      string     `json:"name,omitempty"`
+	Role      types.Role `json:"role"`
+	Issuer    string     `json:"iss,omitempty"`
+	Audience  audience   `json:"aud,omitempty"`
+	ExpiresAt int64      `json:"exp"`
+	NotBefore int64      `json:"nbf,omitempty"`
+	IssuedAt  int64      `json:"iat,omitempty"`
+}
+
+// audience is the aud claim, a string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("aud must be a string or a list of strings")
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// SignJWT returns an HS256 token of the claims, for services and scripts issuing tokens to the API.
+func SignJWT(secret []byte, claims Claims) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(secret, signed)), nil
+}
+
+// ParseJWT checks an HS256 token's signature, expiry and, when they are set, issuer and audience,
+// and returns its claims. Any other algorithm, "none" included, is rejected.
+func ParseJWT(token string, secret []byte, issuer, aud string, now time.Time) (Claims, error) {
+	var claims Claims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, fmt.Errorf("bad token header: %w", err)
+	}
+	if header.Alg != "HS256" {
+		return claims, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("bad token signature: %w", err)
+	}
+	if !hmac.Equal(signature, jwtSignature(secret, parts[0]+"."+parts[1])) {
+		return claims, errors.New("invalid token signature")
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, fmt.Errorf("bad token claims: %w", err)
+	}
+
+	switch {
+	case claims.ExpiresAt == 0:
+		return claims, errors.New("token has no expiry")
+	case now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtLeeway)):
+		return claims, errors.New("token expired")
+	case claims.NotBefore != 0 && now.Add(jwtLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return claims, errors.New("token not valid yet")
+	case claims.Subject == "":
+		return claims, errors.New("token has no subject")
+	case claims.Role.Rank() == 0:
+		return claims, fmt.Errorf("token has unknown role %q", claims.Role)
+	case issuer != "" && claims.Issuer != issuer:
+		return claims, fmt.Errorf("token issuer %q not accepted", claims.Issuer)
+	case aud != "" && !claims.Audience.contains(aud):
+		return claims, errors.New("token is not for this audience")
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func jwtSignature(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
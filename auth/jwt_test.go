@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go-firebird/db"
+	"go-firebird/types"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	jwtSecret = []byte("0123456789abcdef0123456789abcdef")
+	jwtNow    = time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+)
+
+// rawJWT builds a token from any header and claims, signed with secret.
+func rawJWT(header, claims interface{}, secret []byte) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(secret, signed))
+}
+
+func validClaims() Claims {
+	return Claims{
+		Subject:   "svc-ingest",
+		Name:      "Ingest service",
+		Role:      types.RoleOperator,
+		Issuer:    "firebird-auth",
+		Audience:  audience{"firebird-api"},
+		ExpiresAt: jwtNow.Add(time.Hour).Unix(),
+		IssuedAt:  jwtNow.Unix(),
+	}
+}
+
+func TestParseJWT(t *testing.T) {
+	sign := func(edit func(*Claims)) string {
+		claims := validClaims()
+		if edit != nil {
+			edit(&claims)
+		}
+		token, err := SignJWT(jwtSecret, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(nil)
+	parts := strings.Split(valid, ".")
+
+	cases := []struct {
+		name    string
+		token   string
+		wantErr string // empty for a valid token
+	}{
+		{"valid", valid, ""},
+		{"expired", sign(func(c *Claims) { c.ExpiresAt = jwtNow.Add(-time.Minute).Unix() }), "token expired"},
+		{"expired within the leeway", sign(func(c *Claims) { c.ExpiresAt = jwtNow.Add(-10 * time.Second).Unix() }), ""},
+		{"missing exp", sign(func(c *Claims) { c.ExpiresAt = 0 }), "token has no expiry"},
+		{"not valid yet", sign(func(c *Claims) { c.NotBefore = jwtNow.Add(time.Minute).Unix() }), "token not valid yet"},
+		{"no subject", sign(func(c *Claims) { c.Subject = "" }), "token has no subject"},
+		{"unknown role", sign(func(c *Claims) { c.Role = "root" }), "unknown role"},
+		{"other issuer", sign(func(c *Claims) { c.Issuer = "someone-else" }), "issuer"},
+		{"other audience", sign(func(c *Claims) { c.Audience = audience{"other-api"} }), "audience"},
+		{"audience in a list", sign(func(c *Claims) { c.Audience = audience{"other-api", "firebird-api"} }), ""},
+		{"wrong alg", rawJWT(map[string]string{"alg": "HS512", "typ": "JWT"}, validClaims(), jwtSecret), "unsupported token algorithm"},
+		{"alg none", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", "unsupported token algorithm"},
+		{"signed with another secret", rawJWT(map[string]string{"alg": "HS256"}, validClaims(), []byte("another secret")), "invalid token signature"},
+		{"claims changed after signing", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"svc-ingest","role":"admin","exp":9999999999}`)) + "." + parts[2], "invalid token signature"},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!", "bad token signature"},
+		{"malformed", "a.b", "malformed token"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			claims, err := ParseJWT(c.token, jwtSecret, "firebird-auth", "firebird-api", jwtNow)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ParseJWT: %v", err)
+				}
+				if claims.Subject != "svc-ingest" || claims.Role != types.RoleOperator {
+					t.Errorf("claims = %+v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("ParseJWT error = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	cases := []struct {
+		role    string
+		want    types.Role
+		wantErr bool
+	}{
+		{"", "", false},
+		{"none", "", false},
+		{"reader", types.RoleReader, false},
+		{"operator", types.RoleOperator, false},
+		{"root", "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.role, func(t *testing.T) {
+			t.Setenv("AUTH_ANONYMOUS_ROLE", c.role)
+			t.Setenv("AUTH_ADMIN_KEY", "")
+			cfg, err := ConfigFromEnv()
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ConfigFromEnv error = %v", err)
+			}
+			if !c.wantErr && cfg.Anonymous != c.want {
+				t.Errorf("anonymous role = %q, want %q", cfg.Anonymous, c.want)
+			}
+		})
+	}
+
+	t.Setenv("AUTH_ANONYMOUS_ROLE", "")
+	t.Setenv("AUTH_ADMIN_KEY", "short")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("a short admin key was accepted")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	token, err := SignJWT(jwtSecret, Claims{Subject: "alice", Role: types.RoleReader, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := SignJWT(jwtSecret, Claims{Subject: "alice", Role: types.RoleReader, ExpiresAt: time.Now().Add(-time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		anonymous types.Role
+		header    string
+		wantID    string
+		wantErr   error
+	}{
+		{"no credentials by default", "", "", "", ErrNoCredentials},
+		{"anonymous reader", types.RoleReader, "", MethodAnonymous, nil},
+		{"jwt", "", "Bearer " + token, "alice", nil},
+		{"expired jwt", "", "Bearer " + expired, "", ErrInvalidCredentials},
+		{"bad credentials don't fall back to anonymous", types.RoleReader, "Bearer " + expired, "", ErrInvalidCredentials},
+		{"admin key", "", "Bearer admin-key-0123456789", "admin-key", nil},
+		{"unknown token", "", "Bearer nonsense", "", ErrInvalidCredentials},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := New(db.NewMemoryStore(), Config{AdminKey: "admin-key-0123456789", JWTSecret: jwtSecret, Anonymous: c.anonymous})
+			r := httptest.NewRequest("GET", "/api/firebird/disasters", nil)
+			if c.header != "" {
+				r.Header.Set("Authorization", c.header)
+			}
+			principal, err := a.Authenticate(r)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("Authenticate error = %v, want %v", err, c.wantErr)
+			}
+			if principal.ID != c.wantID {
+				t.Errorf("principal = %+v, want %s", principal, c.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-firebird/types"
+	"strings"
+	"time"
+)
+
+// API keys look like fbk_<id>_<secret>: the ID finds the stored key, the whole key is compared by hash.
+const keyPrefix = "fbk_"
+
+var ErrKeyNotFound = errors.New("API key not found")
+
+// KeyRequest is what an admin gives to create a key.
+type KeyRequest struct {
+	Name      string     `json:"name"`
+	Role      types.Role `json:"role"`
+	ExpiresAt string     `json:"expiresAt,omitempty"` // RFC3339, never when empty
+}
+
+// NewKey makes a key and what is stored of it. The key itself is only in the returned string.
+func NewKey(req KeyRequest, createdBy string, now time.Time) (string, types.APIKey, error) {
+	var key types.APIKey
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "", key, errors.New("name is required")
+	}
+	if req.Role.Rank() == 0 {
+		return "", key, fmt.Errorf("role must be reader, operator or admin, got %q", req.Role)
+	}
+	if req.ExpiresAt != "" {
+		expires, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return "", key, fmt.Errorf("expiresAt must be an RFC3339 timestamp: %w", err)
+		}
+		if !expires.After(now) {
+			return "", key, errors.New("expiresAt is in the past")
+		}
+		req.ExpiresAt = expires.UTC().Format(time.RFC3339)
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return "", key, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", key, err
+	}
+	plain := keyPrefix + id + "_" + secret
+	key = types.APIKey{
+		ID:        id,
+		Name:      req.Name,
+		Role:      req.Role,
+		Hash:      hashKey(plain),
+		Prefix:    plain[:len(keyPrefix)+len(id)+5],
+		CreatedAt: now.UTC().Format(time.RFC3339),
+		CreatedBy: createdBy,
+		ExpiresAt: req.ExpiresAt,
+	}
+	return plain, key, nil
+}
+
+// keyID is the ID part of a key, false for anything that doesn't look like one.
+func keyID(plain string) (string, bool) {
+	if !strings.HasPrefix(plain, keyPrefix) {
+		return "", false
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(plain, keyPrefix), "_")
+	return id, ok && id != "" && secret != ""
+}
+
+func hashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,271 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-firebird/bluesky"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"log"
+	"sync"
+	"time"
+)
+
+// Reasons a backfill stopped, saved as BackfillProgress.StopReason.
+const (
+	StopEndOfFeed   = "end of feed"
+	StopReachedSeen = "reached already saved posts"
+	StopReachedTime = "reached posts older than since"
+	StopMaxPages    = "max pages reached"
+	StopCancelled   = "cancelled"
+	StopBudget      = "daily budget exhausted"
+)
+
+var ErrAlreadyRunning = errors.New("a backfill is already running for this feed")
+
+// FetchFunc fetches one page of a feed, bluesky.GetFeed unless replaced.
+type FetchFunc func(ctx context.Context, feedURI string, limit int, cursor string) (types.FeedResponse, error)
+
+type Options struct {
+	FeedURI string
+	// stop once a whole page is older than this, zero walks back to the start of the feed
+	Since time.Time
+	// stop once a whole page was already saved (by the cron, the ingest or an earlier backfill)
+	StopAtSeen bool
+	// ignore unfinished saved progress and start again from the newest posts
+	Restart bool
+	// stop after this many pages in this run, it can be resumed later. 0 means no limit
+	MaxPages  int
+	PageSize  int
+	PageDelay time.Duration // pause between pages to go easy on the public API
+	Fetch     FetchFunc
+}
+
+var (
+	runningMu sync.Mutex
+	running   = make(map[string]bool)
+	runs      sync.WaitGroup
+)
+
+// IsRunning reports whether a backfill of feedURI is running in this process.
+func IsRunning(feedURI string) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	return running[feedURI]
+}
+
+// claim marks a backfill of feedURI as running, false if one already is.
+func claim(feedURI string) bool {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+	if running[feedURI] {
+		return false
+	}
+	running[feedURI] = true
+	runs.Add(1)
+	return true
+}
+
+func release(feedURI string) {
+	runningMu.Lock()
+	delete(running, feedURI)
+	runningMu.Unlock()
+	runs.Done()
+}
+
+// Start runs a backfill in the background and returns right away, ErrAlreadyRunning if the feed is
+// being backfilled. Cancelling ctx stops it after the current page, Wait waits for that.
+func Start(ctx context.Context, p *processor.Pipeline, opts Options) error {
+	if opts.FeedURI == "" {
+		return fmt.Errorf("no feed URI")
+	}
+	if !claim(opts.FeedURI) {
+		return ErrAlreadyRunning
+	}
+	go func() {
+		defer release(opts.FeedURI)
+		if _, err := run(ctx, p, opts); err != nil {
+			log.Printf("Backfill of %s failed: %v", opts.FeedURI, err)
+		}
+	}()
+	return nil
+}
+
+// Wait waits for the backfills running in this process to return and save their progress.
+func Wait() {
+	runs.Wait()
+}
+
+// Run walks a feed's cursor chain from the newest posts back, saving every post through processor.SaveFeed,
+// until the feed ends, it reaches posts older than opts.Since or, with opts.StopAtSeen, posts already saved.
+// Progress is saved after every page, so an interrupted backfill resumes from the page it was on. When the
+// NLP budget runs out it stops without moving past the page, the skipped posts are saved when it resumes.
+func Run(ctx context.Context, p *processor.Pipeline, opts Options) (types.BackfillProgress, error) {
+	if opts.FeedURI == "" {
+		return types.BackfillProgress{}, fmt.Errorf("no feed URI")
+	}
+	if !claim(opts.FeedURI) {
+		return types.BackfillProgress{}, ErrAlreadyRunning
+	}
+	defer release(opts.FeedURI)
+	return run(ctx, p, opts)
+}
+
+func run(ctx context.Context, p *processor.Pipeline, opts Options) (types.BackfillProgress, error) {
+	if opts.PageSize <= 0 || opts.PageSize > bluesky.MaxFeedLimit {
+		opts.PageSize = bluesky.MaxFeedLimit
+	}
+	if opts.Fetch == nil {
+		opts.Fetch = bluesky.GetFeed
+	}
+
+	progress, err := startingProgress(p, opts)
+	if err != nil {
+		return progress, err
+	}
+
+	save := func() {
+		progress.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		if err := p.Store.SaveBackfillProgress(progress); err != nil {
+			log.Printf("Backfill: failed to save progress for %s: %v", opts.FeedURI, err)
+		}
+	}
+	stop := func(reason string) (types.BackfillProgress, error) {
+		progress.StopReason = reason
+		progress.Done = reason != StopMaxPages && reason != StopCancelled && reason != StopBudget
+		save()
+		log.Printf("Backfill of %s stopped: %s (%d pages, %d saved)", opts.FeedURI, reason, progress.Pages, progress.Saved)
+		return progress, nil
+	}
+
+	var since time.Time
+	if progress.Since != "" {
+		since, _ = time.Parse(time.RFC3339, progress.Since)
+	}
+
+	for pagesThisRun := 0; ; pagesThisRun++ {
+		if opts.MaxPages > 0 && pagesThisRun >= opts.MaxPages {
+			return stop(StopMaxPages)
+		}
+		if ctx.Err() != nil {
+			return stop(StopCancelled)
+		}
+
+		out, err := opts.Fetch(ctx, opts.FeedURI, opts.PageSize, progress.Cursor)
+		if err != nil {
+			progress.StopReason = "error: " + err.Error()
+			save()
+			return progress, err
+		}
+		progress.Pages++
+
+		// Only save posts inside the time window
+		page := types.FeedResponse{Cursor: out.Cursor}
+		for _, entry := range out.Feed {
+			postTime := postTimestamp(entry.Post)
+			if postTime != "" && (progress.OldestPost == "" || before(postTime, progress.OldestPost)) {
+				progress.OldestPost = postTime
+			}
+			if !since.IsZero() && olderThan(postTime, since) {
+				progress.Skipped++
+				continue
+			}
+			page.Feed = append(page.Feed, entry)
+		}
+
+		seen, budgetExhausted := 0, false
+		for _, result := range processor.SaveFeed(page, p) {
+			switch {
+			case result.BudgetExhausted:
+				budgetExhausted = true
+			case result.ErrorSaving:
+				progress.Failed++
+			case result.AlreadyExist:
+				progress.AlreadyExist++
+				seen++
+			default:
+				progress.Saved++
+			}
+		}
+
+		// The posts the budget skipped are on this page, stay on it so the resumed backfill reads it again
+		if budgetExhausted {
+			return stop(StopBudget)
+		}
+		progress.Cursor = out.Cursor
+		progress.StopReason = ""
+		save()
+
+		switch {
+		case len(out.Feed) > 0 && len(page.Feed) == 0:
+			return stop(StopReachedTime)
+		case opts.StopAtSeen && len(page.Feed) > 0 && seen == len(page.Feed):
+			return stop(StopReachedSeen)
+		case out.Cursor == "" || len(out.Feed) == 0:
+			return stop(StopEndOfFeed)
+		}
+
+		if opts.PageDelay > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(opts.PageDelay):
+			}
+		}
+	}
+}
+
+// startingProgress resumes an unfinished backfill or starts a new one.
+func startingProgress(p *processor.Pipeline, opts Options) (types.BackfillProgress, error) {
+	saved, ok, err := p.Store.GetBackfillProgress(opts.FeedURI)
+	if err != nil {
+		return saved, err
+	}
+	if ok && !saved.Done && !opts.Restart {
+		if !opts.Since.IsZero() {
+			saved.Since = opts.Since.UTC().Format(time.RFC3339)
+		}
+		log.Printf("Backfill: resuming %s at page %d", opts.FeedURI, saved.Pages)
+		return saved, nil
+	}
+
+	progress := types.BackfillProgress{
+		FeedURI:   opts.FeedURI,
+		StartedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if !opts.Since.IsZero() {
+		progress.Since = opts.Since.UTC().Format(time.RFC3339)
+	}
+	return progress, nil
+}
+
+// GetProgress returns the saved progress of a feed and whether a backfill is running for it right now.
+func GetProgress(p *processor.Pipeline, feedURI string) (types.BackfillProgress, bool, error) {
+	progress, _, err := p.Store.GetBackfillProgress(feedURI)
+	return progress, IsRunning(feedURI), err
+}
+
+func postTimestamp(post types.Post) string {
+	if post.Record.CreatedAt != "" {
+		return post.Record.CreatedAt
+	}
+	return post.IndexedAt
+}
+
+func before(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return ta.Before(tb)
+}
+
+// olderThan is false for timestamps that don't parse, those posts are kept.
+func olderThan(timestamp string, since time.Time) bool {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return false
+	}
+	return t.Before(since)
+}
@@ -0,0 +1,283 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"go-firebird/limits"
+	"go-firebird/nlp"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testFeed = "at://did:plc:test/app.bsky.feed.generator/disasters"
+
+var newest = time.Date(2025, 1, 7, 18, 0, 0, 0, time.UTC)
+
+// fakeFeed serves pages of posts an hour apart, newest first. The cursor is the index of the next page.
+type fakeFeed struct {
+	mu      sync.Mutex
+	pages   [][]types.FeedEntry
+	fetched []string // the cursors asked for
+}
+
+func newFakeFeed(pages, perPage int) *fakeFeed {
+	f := &fakeFeed{}
+	for i := 0; i < pages; i++ {
+		var page []types.FeedEntry
+		for j := 0; j < perPage; j++ {
+			page = append(page, entry(fmt.Sprintf("post%d", i*perPage+j), newest.Add(-time.Duration(i*perPage+j)*time.Hour)))
+		}
+		f.pages = append(f.pages, page)
+	}
+	return f
+}
+
+func entry(id string, createdAt time.Time) types.FeedEntry {
+	return types.FeedEntry{Post: types.Post{
+		URI:    "at://did:plc:reporter/app.bsky.feed.post/" + id,
+		Author: types.Author{Handle: "reporter.bsky.social", DisplayName: "Reporter"},
+		Record: types.Record{Text: "Flooding on the road out of town, " + id, CreatedAt: createdAt.Format(time.RFC3339)},
+	}}
+}
+
+func (f *fakeFeed) fetch(ctx context.Context, feedURI string, limit int, cursor string) (types.FeedResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetched = append(f.fetched, cursor)
+	i := 0
+	if cursor != "" {
+		var err error
+		if i, err = strconv.Atoi(cursor); err != nil || i >= len(f.pages) {
+			return types.FeedResponse{}, fmt.Errorf("bad cursor %q", cursor)
+		}
+	}
+	out := types.FeedResponse{Feed: f.pages[i]}
+	if i+1 < len(f.pages) {
+		out.Cursor = strconv.Itoa(i + 1)
+	}
+	return out, nil
+}
+
+func (f *fakeFeed) takeFetched() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fetched := f.fetched
+	f.fetched = nil
+	return fetched
+}
+
+// budgetSentiment fails like a spent daily budget while exhausted is set.
+type budgetSentiment struct {
+	nlp.SentimentAnalyzer
+	mu        sync.Mutex
+	exhausted bool
+}
+
+func (s *budgetSentiment) set(exhausted bool) {
+	s.mu.Lock()
+	s.exhausted = exhausted
+	s.mu.Unlock()
+}
+
+func (s *budgetSentiment) AnalyzeSentiment(text string) (types.Sentiment, error) {
+	s.mu.Lock()
+	exhausted := s.exhausted
+	s.mu.Unlock()
+	if exhausted {
+		return types.Sentiment{}, limits.ErrBudgetExhausted
+	}
+	return s.SentimentAnalyzer.AnalyzeSentiment(text)
+}
+
+// testPipeline runs the pipeline on STORE=memory with the local backends and the bundled data files.
+func testPipeline(t *testing.T) *processor.Pipeline {
+	t.Helper()
+	t.Setenv("STORE", "memory")
+	t.Setenv("NLP", "local")
+	t.Setenv("GEOCODER", "offline")
+	t.Setenv("CLASSIFIER", "local")
+	t.Setenv("GAZETTEER_PATH", "../data/gazetteer.tsv")
+	t.Setenv("SENTIMENT_LEXICON_PATH", "../data/sentiment_lexicon.tsv")
+	t.Setenv("CLASSIFIER_TRAINING_DATA", "../demo_data.csv")
+	t.Setenv("DETECTION_CONFIG", "../data/detection.yaml")
+	t.Setenv("DETECTION_PROFILE", "")
+	t.Setenv("LIMITS_CONFIG", "../data/limits.yaml")
+
+	p, err := processor.InitPipeline()
+	if err != nil {
+		t.Fatalf("InitPipeline: %v", err)
+	}
+	return p
+}
+
+func runBackfill(t *testing.T, p *processor.Pipeline, feed *fakeFeed, opts Options) types.BackfillProgress {
+	t.Helper()
+	opts.FeedURI = testFeed
+	opts.Fetch = feed.fetch
+	progress, err := Run(context.Background(), p, opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	saved, _, err := GetProgress(p, testFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Cursor != progress.Cursor || saved.Pages != progress.Pages || saved.Done != progress.Done || saved.StopReason != progress.StopReason {
+		t.Fatalf("saved progress %+v, returned %+v", saved, progress)
+	}
+	return progress
+}
+
+func checkProgress(t *testing.T, name string, got types.BackfillProgress, want types.BackfillProgress) {
+	t.Helper()
+	if got.StopReason != want.StopReason || got.Done != want.Done || got.Cursor != want.Cursor || got.Pages != want.Pages ||
+		got.Saved != want.Saved || got.AlreadyExist != want.AlreadyExist || got.Failed != want.Failed || got.Skipped != want.Skipped {
+		t.Errorf("%s: progress = %+v\nwant stop %q, done %v, cursor %q, %d pages, %d saved, %d already saved, %d failed, %d skipped",
+			name, got, want.StopReason, want.Done, want.Cursor, want.Pages, want.Saved, want.AlreadyExist, want.Failed, want.Skipped)
+	}
+}
+
+func checkFetched(t *testing.T, name string, feed *fakeFeed, want ...string) {
+	t.Helper()
+	if got := feed.takeFetched(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("%s: fetched cursors %q, want %q", name, got, want)
+	}
+}
+
+func TestRunStopsAtEndOfFeed(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(3, 2)
+	progress := runBackfill(t, p, feed, Options{})
+	checkProgress(t, "whole feed", progress, types.BackfillProgress{StopReason: StopEndOfFeed, Done: true, Pages: 3, Saved: 6})
+	checkFetched(t, "whole feed", feed, "", "1", "2")
+	if progress.OldestPost != newest.Add(-5*time.Hour).Format(time.RFC3339) {
+		t.Errorf("oldest post = %s", progress.OldestPost)
+	}
+}
+
+func TestRunStopsAtSince(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(4, 2)
+	// The second page is half inside the window, the third is the first one wholly older
+	progress := runBackfill(t, p, feed, Options{Since: newest.Add(-150 * time.Minute)})
+	checkProgress(t, "since", progress, types.BackfillProgress{StopReason: StopReachedTime, Done: true, Cursor: "3", Pages: 3, Saved: 3, Skipped: 3})
+	checkFetched(t, "since", feed, "", "1", "2")
+}
+
+func TestRunStopsAtSeenPosts(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(3, 2)
+	runBackfill(t, p, feed, Options{})
+	feed.takeFetched()
+
+	// A finished backfill starts again from the newest posts, which are new now
+	feed.pages = append([][]types.FeedEntry{{entry("new0", newest.Add(time.Hour)), entry("new1", newest.Add(2*time.Hour))}}, feed.pages...)
+	progress := runBackfill(t, p, feed, Options{StopAtSeen: true})
+	checkProgress(t, "seen", progress, types.BackfillProgress{StopReason: StopReachedSeen, Done: true, Cursor: "2", Pages: 2, Saved: 2, AlreadyExist: 2})
+	checkFetched(t, "seen", feed, "", "1")
+
+	// Without StopAtSeen it walks on to the end
+	progress = runBackfill(t, p, feed, Options{})
+	checkProgress(t, "past seen", progress, types.BackfillProgress{StopReason: StopEndOfFeed, Done: true, Pages: 4, AlreadyExist: 8})
+}
+
+func TestRunResumes(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(3, 2)
+	progress := runBackfill(t, p, feed, Options{MaxPages: 1})
+	checkProgress(t, "max pages", progress, types.BackfillProgress{StopReason: StopMaxPages, Cursor: "1", Pages: 1, Saved: 2})
+	checkFetched(t, "max pages", feed, "")
+
+	// Carries on from the saved cursor and saves nothing twice
+	progress = runBackfill(t, p, feed, Options{})
+	checkProgress(t, "resumed", progress, types.BackfillProgress{StopReason: StopEndOfFeed, Done: true, Pages: 3, Saved: 6})
+	checkFetched(t, "resumed", feed, "1", "2")
+}
+
+func TestRunRestarts(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(3, 2)
+	runBackfill(t, p, feed, Options{MaxPages: 2})
+	feed.takeFetched()
+
+	progress := runBackfill(t, p, feed, Options{Restart: true})
+	checkProgress(t, "restart", progress, types.BackfillProgress{StopReason: StopEndOfFeed, Done: true, Pages: 3, Saved: 2, AlreadyExist: 4})
+	checkFetched(t, "restart", feed, "", "1", "2")
+}
+
+func TestStartStopsOnCancel(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(3, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	fetch := func(c context.Context, feedURI string, limit int, cursor string) (types.FeedResponse, error) {
+		out, err := feed.fetch(c, feedURI, limit, cursor)
+		cancel()
+		return out, err
+	}
+
+	// Cancelled during the first page, like a shutdown, it stops in the pause before the next one
+	if err := Start(ctx, p, Options{FeedURI: testFeed, Fetch: fetch, PageDelay: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	Wait()
+	progress, running, err := GetProgress(p, testFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running {
+		t.Error("still running after Wait")
+	}
+	checkProgress(t, "cancelled", progress, types.BackfillProgress{StopReason: StopCancelled, Cursor: "1", Pages: 1, Saved: 2})
+	checkFetched(t, "cancelled", feed, "")
+}
+
+func TestRunStopsOnBudget(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(3, 2)
+	sentiment := &budgetSentiment{SentimentAnalyzer: p.Sentiment}
+	p.Sentiment = sentiment
+
+	runBackfill(t, p, feed, Options{MaxPages: 1})
+	feed.takeFetched()
+
+	// The page the budget ran out on is read again once it's back
+	sentiment.set(true)
+	progress := runBackfill(t, p, feed, Options{})
+	checkProgress(t, "budget", progress, types.BackfillProgress{StopReason: StopBudget, Cursor: "1", Pages: 2, Saved: 2})
+	checkFetched(t, "budget", feed, "1")
+
+	sentiment.set(false)
+	progress = runBackfill(t, p, feed, Options{})
+	checkProgress(t, "after the budget", progress, types.BackfillProgress{StopReason: StopEndOfFeed, Done: true, Pages: 4, Saved: 6})
+	checkFetched(t, "after the budget", feed, "1", "2")
+}
+
+func TestRunAlreadyRunning(t *testing.T) {
+	p, feed := testPipeline(t), newFakeFeed(1, 1)
+	started, release := make(chan struct{}), make(chan struct{})
+	fetch := func(ctx context.Context, feedURI string, limit int, cursor string) (types.FeedResponse, error) {
+		close(started)
+		<-release
+		return feed.fetch(ctx, feedURI, limit, cursor)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := Run(context.Background(), p, Options{FeedURI: testFeed, Fetch: fetch})
+		done <- err
+	}()
+
+	<-started
+	if !IsRunning(testFeed) {
+		t.Error("IsRunning is false during a backfill")
+	}
+	if _, err := Run(context.Background(), p, Options{FeedURI: testFeed, Fetch: feed.fetch}); err != ErrAlreadyRunning {
+		t.Errorf("second Run = %v, want ErrAlreadyRunning", err)
+	}
+	if err := Start(context.Background(), p, Options{FeedURI: testFeed, Fetch: feed.fetch}); err != ErrAlreadyRunning {
+		t.Errorf("Start = %v, want ErrAlreadyRunning", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if IsRunning(testFeed) {
+		t.Error("IsRunning is true after the backfill")
+	}
+}
@@ -0,0 +1,50 @@
+package bluesky
+
+import (
+	"context"
+	"go-firebird/types"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+// documentation: https://github.com/bluesky-social/indigo/blob/2503553ea604ea7f0bfa6a021b284b371ac2ac96/xrpc/xrpc.go#L114
+const (
+	feedMethod = "app.bsky.feed.getFeed"
+	publicHost = "https://public.api.bsky.app" // public endpoint for unauthenticated requests.
+
+	// MaxFeedLimit is the largest page getFeed accepts (min 1, max 100, default 50).
+	MaxFeedLimit = 100
+)
+
+func newClient() *xrpc.Client {
+	return &xrpc.Client{
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		Host:      publicHost,
+		UserAgent: nil,
+	}
+}
+
+// GetFeed fetches one page of a feed generator. An empty cursor starts from the newest posts,
+// the returned FeedResponse.Cursor points at the next (older) page and is empty at the end of the feed.
+func GetFeed(ctx context.Context, feedURI string, limit int, cursor string) (types.FeedResponse, error) {
+	params := map[string]interface{}{
+		"feed":  feedURI,
+		"limit": limit,
+	}
+	if cursor != "" {
+		params["cursor"] = cursor
+	}
+
+	log.Printf("Fetching feed with params: %+v", params)
+
+	var out types.FeedResponse
+	err := newClient().Do(ctx, xrpc.Query, "json", feedMethod, params, nil, &out)
+	if err != nil {
+		log.Printf("Error fetching feed via xrpc: %v", err)
+		return out, err
+	}
+	return out, nil
+}
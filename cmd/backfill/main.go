@@ -0,0 +1,80 @@
+// Command backfill walks a feed generator's history and saves every post, resuming unfinished runs.
+//
+//	go run ./cmd/backfill -feed e -since 2025-01-07T00:00:00Z
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-firebird/backfill"
+	"go-firebird/bluesky"
+	"go-firebird/feeds"
+	"go-firebird/processor"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	feed := flag.String("feed", "f", "registered feed ID or alias (see data/feeds.yaml) or a feed generator at:// URI")
+	since := flag.String("since", "", "stop at posts older than this RFC3339 timestamp")
+	maxPages := flag.Int("max-pages", 0, "stop after this many pages, 0 for no limit")
+	pageSize := flag.Int("page-size", bluesky.MaxFeedLimit, "posts per page, at most 100")
+	restart := flag.Bool("restart", false, "ignore unfinished progress and start from the newest posts")
+	stopAtSeen := flag.Bool("stop-at-seen", true, "stop at a page of posts that are all already saved")
+	delay := flag.Duration("delay", time.Second, "pause between pages")
+	flag.Parse()
+
+	// .env is optional here, the variables can also come from the shell
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env loaded: %v", err)
+	}
+
+	pipeline, err := processor.InitPipeline()
+	if err != nil {
+		log.Fatalf("Failed to initialize pipeline: %v", err)
+	}
+	defer processor.ClosePipeline()
+	defer pipeline.Limits.Close() // the spend of the last calls, before the store is closed
+
+	registry, err := feeds.InitRegistry(pipeline.Store)
+	if err != nil {
+		log.Fatalf("Failed to initialize feed registry: %v", err)
+	}
+	resolved, ok := registry.Resolve(*feed)
+	if !ok {
+		log.Fatalf("Unknown feed %q", *feed)
+	}
+
+	opts := backfill.Options{
+		FeedURI:    resolved.URI,
+		StopAtSeen: *stopAtSeen,
+		Restart:    *restart,
+		MaxPages:   *maxPages,
+		PageSize:   *pageSize,
+		PageDelay:  *delay,
+	}
+	if *since != "" {
+		t, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Fatalf("Bad -since: %v", err)
+		}
+		opts.Since = t
+	}
+
+	// Ctrl-C finishes the current page and saves progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	progress, err := backfill.Run(ctx, pipeline, opts)
+	out, _ := json.MarshalIndent(progress, "", "  ")
+	fmt.Println(string(out))
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Command exportskeets writes the saved skeets to a CSV or Parquet file, e.g. to retrain the classifier.
+//
+//	go run ./cmd/exportskeets -format parquet -o skeets.parquet -category wildfire,flood -since 2025-01-07T00:00:00Z
+package main
+
+import (
+	"bufio"
+	"flag"
+	"go-firebird/db"
+	"go-firebird/export"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	out := flag.String("o", "skeets.csv", "output file, - for stdout")
+	format := flag.String("format", "", "csv or parquet, from the -o extension by default")
+	category := flag.String("category", "", "comma separated categories, matched against each skeet's top category")
+	since := flag.String("since", "", "only skeets posted at or after this RFC3339 timestamp")
+	until := flag.String("until", "", "only skeets posted at or before this RFC3339 timestamp")
+	flag.Parse()
+
+	if *format == "" {
+		*format = export.FormatCSV
+		if strings.EqualFold(filepath.Ext(*out), ".parquet") {
+			*format = export.FormatParquet
+		}
+	}
+	filter, err := export.ParseFilter(url.Values{"category": {*category}, "since": {*since}, "until": {*until}})
+	if err != nil {
+		log.Fatalf("Bad filter: %v", err)
+	}
+
+	// .env is optional here, the variables can also come from the shell
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env loaded: %v", err)
+	}
+	client, err := db.InitFirestore()
+	if err != nil {
+		log.Fatalf("Failed to initialize Firestore: %v", err)
+	}
+	defer db.CloseFirestore()
+	store := db.NewFirestoreStore(client)
+
+	file := os.Stdout
+	if *out != "-" {
+		file, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *out, err)
+		}
+		defer file.Close()
+	}
+	w := bufio.NewWriter(file)
+
+	tw, err := export.NewTableWriter(*format, w, export.SkeetColumns())
+	if err != nil {
+		log.Fatal(err)
+	}
+	written, err := export.WriteSkeets(store, filter, tw)
+	if err != nil {
+		log.Fatalf("Export failed after %d skeets: %v", written, err)
+	}
+	if err := tw.Close(); err != nil {
+		log.Fatalf("Failed to finish %s: %v", *out, err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+	log.Printf("Exported %d skeets to %s", written, *out)
+}
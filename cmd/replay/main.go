@@ -0,0 +1,21 @@
+// Command replay serves a recorded Jetstream file over a websocket.
+// Point the ingest at it with JETSTREAM_URL=ws://localhost:6008/subscribe.
+package main
+
+import (
+	"flag"
+	"go-firebird/ingest"
+	"log"
+	"net/http"
+)
+
+func main() {
+	path := flag.String("file", "./data/jetstream_sample.jsonl", "recorded frames, one JSON event per line")
+	addr := flag.String("addr", ":6008", "listen address")
+	interval := flag.Duration("interval", 0, "pause between frames, e.g. 50ms")
+	flag.Parse()
+
+	http.Handle("/subscribe", ingest.NewReplayServer(*path, *interval))
+	log.Printf("Replaying %s on %s/subscribe", *path, *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
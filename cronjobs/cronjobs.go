@@ -1,29 +1,22 @@
 package cronjobs
 
 import (
-	"cloud.google.com/go/firestore"
-	language "cloud.google.com/go/language/apiv2"
-	"context"
-	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/robfig/cron/v3"
 	"go-firebird/db"
+	"go-firebird/events"
+	"go-firebird/feeds"
 	"go-firebird/processor"
 	"go-firebird/types"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 )
 
-const (
-	feedMethod = "app.bsky.feed.getFeed"
-)
-
 // scheduleLocationSentimentUpdate retrieves all valid locations, processes each in parallel,
-// and logs a summary of successes and failures.
-func scheduleLocationSentimentUpdate(firestoreClient *firestore.Client) {
+// and logs a summary of successes and failures. New sentiment entries are published on bus.
+func scheduleLocationSentimentUpdate(store db.Store, bus *events.Bus) {
 	// Fetch all valid locations.
-	validLocations, err := db.GetValidLocations(firestoreClient)
+	validLocations, err := store.GetValidLocations()
 	if err != nil {
 		log.Printf("Error fetching valid locations: %v", err)
 		return
@@ -43,7 +36,7 @@ func scheduleLocationSentimentUpdate(firestoreClient *firestore.Client) {
 
 			// Use a hash of the location name as the document ID.
 			docId := db.HashString(location.LocationName)
-			if err := processor.ProcessLocationAvgSentiment(firestoreClient, docId, locData); err != nil {
+			if err := processor.ProcessLocationAvgSentiment(store, bus, docId, locData); err != nil {
 				log.Printf("Error processing location %s: %v", docId, err)
 				mu.Lock()
 				failureSaving = append(failureSaving, docId)
@@ -63,96 +56,40 @@ func scheduleLocationSentimentUpdate(firestoreClient *firestore.Client) {
 	log.Printf("Failed updates: %v", failureSaving)
 }
 
-func callFeed(uri string) (types.FeedResponse, error) {
-	client := &xrpc.Client{
-		Client:    &http.Client{Timeout: 10 * time.Second},
-		Host:      "https://public.api.bsky.app", // public endpoint for unauthenticated requests.
-		UserAgent: nil,
-	}
-
-	feedAtURI := uri
-
-	// The limit can be adjusted (min 1, max 100, default 50).
-	params := map[string]interface{}{
-		"feed":  feedAtURI,
-		"limit": 50,
-	}
-
-	log.Printf("Fetching feed with params: %+v", params)
-
-	var out types.FeedResponse
-
-	// Call the Bluesky API using the xrpc client.
-	err := client.Do(context.Background(), xrpc.Query, "json", feedMethod, params, nil, &out)
-	if err != nil {
-		log.Printf("Error fetching feed via xrpc: %v", err)
-		return out, err
-	}
-	return out, nil
-
-}
-
-func InitCronJobs(firestoreClient *firestore.Client, nlpClient *language.Client) {
+// InitCronJobs starts the scheduled jobs. Feeds are polled on the schedules in the registry and the
+// jobs are rebuilt whenever a feed changes. pollFeeds is false when posts come from the streaming ingest,
+// then only the location sentiment update runs. Stop the returned scheduler on shutdown, its Stop waits for
+// the running jobs.
+func InitCronJobs(pipeline *processor.Pipeline, registry *feeds.Registry, pollFeeds bool) *cron.Cron {
 	log.Println("\nStarting Cron Jobs -------------------------------------------------------")
 
-	fireURI := "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejsyozb6iq"
-	earthQuakeURI := "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejxlobe474"
-	hurricaneURI := "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejwgffwqky"
-
 	c := cron.New()
-	// Fire Feed: Run every 4 hours starting at 0:00.
-	_, err := c.AddFunc("0 0-23/4 * * *", func() {
-		log.Println("\nCronJob: Fire Feed Running")
-		out, err := callFeed(fireURI)
-		if err != nil {
-			log.Println("Error getting Fire Feed", err)
-		} else {
-			processor.SaveFeed(out, firestoreClient, nlpClient)
-		}
-	})
-	if err != nil {
-		log.Println("Error scheduling Fire Feed", err)
+	if pollFeeds {
+		scheduler := &feedScheduler{cron: c, pipeline: pipeline, registry: registry}
+		scheduler.sync()
+		registry.OnChange(scheduler.sync)
 	}
 
-	// Earthquake Feed: Run every 4 hours starting at 1:00.
-	_, err = c.AddFunc("0 1-23/4 * * *", func() {
-		log.Println("\nCronJob: EarthQuake Feed Running")
-		out, err := callFeed(earthQuakeURI)
-		if err != nil {
-			log.Println("Error getting Earthquake Feed", err)
-		} else {
-			processor.SaveFeed(out, firestoreClient, nlpClient)
+	// Update location average sentiment and rolling windows every 3 hours, then run disaster detection
+	// on the new counts. Detection seeds from the 6h window, so this has to run more often than that.
+	_, locErr := c.AddFunc("0 */3 * * *", func() {
+		log.Println("\nCronJob: Updating average sentiment for all locations")
+		scheduleLocationSentimentUpdate(pipeline.Store, pipeline.Events)
 
+		log.Println("\nCronJob: Running disaster detection")
+		cfg, ok := pipeline.Detection.Get("") // default profile
+		if !ok {
+			log.Printf("Skipping disaster detection: default profile %q is missing", pipeline.Detection.Default)
+			return
 		}
-
-	})
-	if err != nil {
-		log.Println("Error scheduling EarthQuake Feed:", err)
-	}
-
-	// Hurricane Feed: Run every 4 hours starting at 2:00.
-	_, err = c.AddFunc("0 2-23/4 * * *", func() {
-		log.Println("\nCronJob: Hurricane Feed Running")
-		out, err := callFeed(hurricaneURI)
-		if err != nil {
-			log.Println("Error getting Hurricane Feed", err)
-		} else {
-			processor.SaveFeed(out, firestoreClient, nlpClient)
+		if _, _, err := processor.RunDisasterDetection(pipeline.Store, pipeline.Events, pipeline.Limits, "cron", cfg); err != nil {
+			log.Printf("Disaster detection failed: %v", err)
 		}
-
-	})
-	if err != nil {
-		log.Println("Error scheduling Hurricane Feed:", err)
-	}
-
-	// Update location average sentiment every 12 hours.
-	_, locErr := c.AddFunc("0 0,12 * * *", func() {
-		log.Println("\nCronJob: Updating average sentiment for all locations")
-		scheduleLocationSentimentUpdate(firestoreClient)
 	})
 	if locErr != nil {
-		log.Printf("Error scheduling Location Sentiment CronJob: %v", err)
+		log.Printf("Error scheduling Location Sentiment CronJob: %v", locErr)
 	}
 
 	c.Start()
+	return c
 }
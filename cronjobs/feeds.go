@@ -0,0 +1,55 @@
+package cronjobs
+
+import (
+	"context"
+	"go-firebird/bluesky"
+	"go-firebird/feeds"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"log"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+)
+
+// feedScheduler keeps one cron entry per enabled feed in the registry.
+type feedScheduler struct {
+	cron     *cron.Cron
+	pipeline *processor.Pipeline
+	registry *feeds.Registry
+
+	mu      sync.Mutex
+	entries []cron.EntryID
+}
+
+// sync replaces every feed job with the current registry.
+func (s *feedScheduler) sync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.entries {
+		s.cron.Remove(id)
+	}
+	s.entries = s.entries[:0]
+
+	for _, feed := range s.registry.Enabled() {
+		feed := feed
+		id, err := s.cron.AddFunc(feed.Schedule, func() { pollFeed(s.pipeline, feed) })
+		if err != nil {
+			log.Printf("Error scheduling %s Feed: %v", feed.Name, err)
+			continue
+		}
+		s.entries = append(s.entries, id)
+		log.Printf("Scheduled %s Feed (%s) at %q", feed.Name, feed.ID, feed.Schedule)
+	}
+}
+
+func pollFeed(pipeline *processor.Pipeline, feed types.FeedConfig) {
+	log.Printf("\nCronJob: %s Feed Running", feed.Name)
+	out, err := bluesky.GetFeed(context.Background(), feed.URI, feed.PageSize, "")
+	if err != nil {
+		log.Printf("Error getting %s Feed: %v", feed.Name, err)
+		return
+	}
+	processor.SaveFeed(out, pipeline)
+}
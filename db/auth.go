@@ -0,0 +1,106 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	apiKeysCollection      = "apiKeys"
+	auditEntriesCollection = "auditLog"
+)
+
+func GetAPIKeys(client *firestore.Client) ([]types.APIKey, error) {
+	ctx := context.Background()
+	var keys []types.APIKey
+
+	iter := client.Collection(apiKeysCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error iterating API keys: %w", err)
+		}
+
+		var key types.APIKey
+		if err := doc.DataTo(&key); err != nil {
+			return nil, fmt.Errorf("error converting API key %s: %w", doc.Ref.ID, err)
+		}
+		key.ID = doc.Ref.ID
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func GetAPIKey(client *firestore.Client, id string) (types.APIKey, error) {
+	ctx := context.Background()
+	var key types.APIKey
+
+	doc, err := client.Collection(apiKeysCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return key, fmt.Errorf("error getting API key %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return key, fmt.Errorf("error getting API key %s: %w", id, err)
+	}
+	if err := doc.DataTo(&key); err != nil {
+		return key, fmt.Errorf("error converting API key %s: %w", id, err)
+	}
+	key.ID = id
+	return key, nil
+}
+
+func SaveAPIKey(client *firestore.Client, key types.APIKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("API key has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(apiKeysCollection).Doc(key.ID).Set(ctx, key)
+	return err
+}
+
+func SaveAuditEntry(client *firestore.Client, entry types.AuditEntry) error {
+	if entry.ID == "" {
+		return fmt.Errorf("audit entry has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(auditEntriesCollection).Doc(entry.ID).Set(ctx, entry)
+	return err
+}
+
+// GetAuditEntries returns the most recent entries first. An empty actor doesn't filter, a negative
+// limit returns everything.
+func GetAuditEntries(client *firestore.Client, actor string, limit int) ([]types.AuditEntry, error) {
+	ctx := context.Background()
+	query := client.Collection(auditEntriesCollection).Query
+	if actor != "" {
+		query = query.Where("actor", "==", actor)
+	}
+	query = query.OrderBy("at", firestore.Desc)
+	if limit >= 0 {
+		query = query.Limit(limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error getting audit entries: %w", err)
+	}
+	entries := make([]types.AuditEntry, 0, len(docs))
+	for _, doc := range docs {
+		var entry types.AuditEntry
+		if err := doc.DataTo(&entry); err != nil {
+			return nil, fmt.Errorf("error converting audit entry %s: %w", doc.Ref.ID, err)
+		}
+		entry.ID = doc.Ref.ID
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
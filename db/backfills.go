@@ -0,0 +1,36 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const backfillsCollection = "backfills"
+
+// GetBackfillProgress returns the saved progress of a feed backfill, ok is false if the feed was never backfilled.
+func GetBackfillProgress(client *firestore.Client, feedURI string) (types.BackfillProgress, bool, error) {
+	ctx := context.Background()
+	var progress types.BackfillProgress
+
+	doc, err := client.Collection(backfillsCollection).Doc(HashString(feedURI)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return progress, false, nil
+		}
+		return progress, false, fmt.Errorf("error getting backfill progress for %s: %w", feedURI, err)
+	}
+	if err := doc.DataTo(&progress); err != nil {
+		return progress, false, fmt.Errorf("error converting backfill progress for %s: %w", feedURI, err)
+	}
+	return progress, true, nil
+}
+
+func SaveBackfillProgress(client *firestore.Client, progress types.BackfillProgress) error {
+	ctx := context.Background()
+	_, err := client.Collection(backfillsCollection).Doc(HashString(progress.FeedURI)).Set(ctx, progress)
+	return err
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const cursorsCollection = "cursors"
+
+// GetCursor returns the saved cursor with the given name, ok is false if it was never saved.
+func GetCursor(client *firestore.Client, name string) (types.Cursor, bool, error) {
+	ctx := context.Background()
+	cursor := types.Cursor{Name: name}
+
+	doc, err := client.Collection(cursorsCollection).Doc(name).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return cursor, false, nil
+		}
+		return cursor, false, fmt.Errorf("error getting cursor %s: %w", name, err)
+	}
+	if err := doc.DataTo(&cursor); err != nil {
+		return cursor, false, fmt.Errorf("error converting cursor %s: %w", name, err)
+	}
+	cursor.Name = name
+	return cursor, true, nil
+}
+
+func SaveCursor(client *firestore.Client, cursor types.Cursor) error {
+	if cursor.Name == "" {
+		return fmt.Errorf("cursor has no name")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(cursorsCollection).Doc(cursor.Name).Set(ctx, cursor)
+	return err
+}
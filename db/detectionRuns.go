@@ -0,0 +1,43 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+)
+
+const detectionRunsCollection = "detectionRuns"
+
+func SaveDetectionRun(client *firestore.Client, run types.DetectionRun) error {
+	if run.ID == "" {
+		return fmt.Errorf("detection run has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(detectionRunsCollection).Doc(run.ID).Set(ctx, run)
+	return err
+}
+
+// GetDetectionRuns returns the most recent runs first.
+func GetDetectionRuns(client *firestore.Client, limit int) ([]types.DetectionRun, error) {
+	ctx := context.Background()
+	docs, err := client.Collection(detectionRunsCollection).
+		OrderBy("startedAt", firestore.Desc).
+		Limit(limit).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error getting detection runs: %w", err)
+	}
+
+	runs := make([]types.DetectionRun, 0, len(docs))
+	for _, doc := range docs {
+		var run types.DetectionRun
+		if err := doc.DataTo(&run); err != nil {
+			return nil, fmt.Errorf("error converting detection run %s: %w", doc.Ref.ID, err)
+		}
+		run.ID = doc.Ref.ID
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"log"
+)
+
+// Disaster counts used to be a struct with fireCount/hurricaneCount/earthquakeCount/nonDisasterCount,
+// they are now a map keyed by category. Everything read from the store is converted, documents are
+// rewritten when the location sentiment update touches them or by MigrateDisasterCounts.
+
+func migrateLocations(locations []types.LocationData) []types.LocationData {
+	for i := range locations {
+		locations[i].MigrateDisasterCounts()
+	}
+	return locations
+}
+
+func migrateDisasters(disasters []types.DisasterData) []types.DisasterData {
+	for i := range disasters {
+		disasters[i].MigrateDisasterCounts()
+	}
+	return disasters
+}
+
+// ReplaceLocationDisasterCounts overwrites latestDisasterCount and avgSentimentList.
+// Update replaces the fields, Set with MergeAll would keep the legacy keys next to the new ones.
+func ReplaceLocationDisasterCounts(client *firestore.Client, locationID string, location types.LocationData) error {
+	ctx := context.Background()
+	locDocRef := client.Collection("locations").Doc(locationID)
+
+	updates := []firestore.Update{
+		{Path: "latestDisasterCount", Value: location.LatestDisasterCount},
+		{Path: "avgSentimentList", Value: location.AvgSentimentList},
+	}
+	if _, err := locDocRef.Update(ctx, updates); err != nil {
+		return fmt.Errorf("failed to replace disaster counts for %s: %w", locationID, err)
+	}
+	return nil
+}
+
+// MigrateDisasterCounts rewrites every location and disaster that still has legacy disaster counts.
+// It returns how many of each were rewritten.
+func MigrateDisasterCounts(store Store) (int, int, error) {
+	// Every location, the ones that were never geocoded have counts too
+	migratedLocations := 0
+	err := store.EachLocation(func(location types.LocationData) error {
+		if !location.CountsMigrated {
+			return nil
+		}
+		locationID := location.ID
+		if locationID == "" {
+			locationID = HashString(location.LocationName)
+		}
+		if err := store.ReplaceLocationDisasterCounts(locationID, location); err != nil {
+			return err
+		}
+		migratedLocations++
+		return nil
+	})
+	if err != nil {
+		return migratedLocations, 0, fmt.Errorf("error migrating locations: %w", err)
+	}
+
+	disasters, err := store.GetAllDisasters()
+	if err != nil {
+		return migratedLocations, 0, fmt.Errorf("error fetching disasters: %w", err)
+	}
+
+	// GetAllDisasters already converted the counts, saving replaces the whole document
+	var toSave []types.DisasterData
+	for _, disaster := range disasters {
+		if disaster.CountsMigrated {
+			toSave = append(toSave, disaster)
+		}
+	}
+	if err := store.SaveDisasters(toSave); err != nil {
+		return migratedLocations, 0, err
+	}
+
+	log.Printf("Migrated disaster counts of %d locations and %d disasters", migratedLocations, len(toSave))
+	return migratedLocations, len(toSave), nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"go-firebird/types"
+	"testing"
+)
+
+func TestMigrateDisasterCountsWithoutAddress(t *testing.T) {
+	s := NewMemoryStore()
+	legacy := map[string]interface{}{"fireCount": 3, "nonDisasterCount": 1}
+
+	// One geocoded location and one that never got a formatted address
+	for name, address := range map[string]string{"Paradise": "Paradise, CA, USA", "Nowhere": ""} {
+		if err := s.UpdateLocationFields(HashString(name), map[string]interface{}{
+			"locationName":        name,
+			"formattedAddress":    address,
+			"latestDisasterCount": legacy,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locations, _, err := MigrateDisasterCounts(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locations != 2 {
+		t.Errorf("migrated %d locations, want 2", locations)
+	}
+
+	for _, name := range []string{"Paradise", "Nowhere"} {
+		doc, _ := s.getDoc("locations", HashString(name))
+		counts, _ := doc["latestDisasterCount"].(map[string]interface{})
+		if _, ok := counts["fireCount"]; ok {
+			t.Errorf("%s still has legacy counts: %v", name, counts)
+		}
+		if counts[string(types.Wildfire)] != int64(3) {
+			t.Errorf("%s wildfire count = %v, want 3", name, counts[string(types.Wildfire)])
+		}
+	}
+
+	// Nothing left to migrate
+	if locations, _, err := MigrateDisasterCounts(s); err != nil || locations != 0 {
+		t.Errorf("second migration rewrote %d locations, %v", locations, err)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"go-firebird/types"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"log"
+	"time"
 )
 
 const disastersCollection = "disasters"
@@ -62,10 +65,27 @@ func SaveDisasters(client *firestore.Client, disasters []types.DisasterData) err
 
 // GetAllDisasters retrieves all documents from the 'disasters' collection.
 func GetAllDisasters(client *firestore.Client) ([]types.DisasterData, error) {
+	return queryDisasters(client.Collection(disastersCollection).Query)
+}
+
+// GetOpenDisasters retrieves the Active and Recovery disasters, the ones detection still tracks.
+func GetOpenDisasters(client *firestore.Client) ([]types.DisasterData, error) {
+	return queryDisasters(client.Collection(disastersCollection).
+		Where("status", "in", []string{string(types.Active), string(types.Recovery)}))
+}
+
+// GetDisastersEndedSince retrieves the Not_Active disasters that ended at or after since.
+func GetDisastersEndedSince(client *firestore.Client, since time.Time) ([]types.DisasterData, error) {
+	return queryDisasters(client.Collection(disastersCollection).
+		Where("status", "==", string(types.Not_Active)).
+		Where("endedAt", ">=", since.UTC().Format(time.RFC3339)))
+}
+
+func queryDisasters(query firestore.Query) ([]types.DisasterData, error) {
 	ctx := context.Background()
 	var allDisasters []types.DisasterData
 
-	iter := client.Collection(disastersCollection).Documents(ctx)
+	iter := query.Documents(ctx)
 	defer iter.Stop()
 
 	for {
@@ -95,6 +115,9 @@ func GetDisasterByID(client *firestore.Client, disasterID string) (types.Disaste
 	var disaster types.DisasterData
 
 	docSnap, err := client.Collection(disastersCollection).Doc(disasterID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return disaster, fmt.Errorf("error getting disaster %s: %w", disasterID, ErrNotFound)
+	}
 	if err != nil {
 		return disaster, fmt.Errorf("error getting disaster %s: %w", disasterID, err)
 	}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/api/iterator"
+)
+
+const feedsCollection = "feeds"
+
+func GetFeedConfigs(client *firestore.Client) ([]types.FeedConfig, error) {
+	ctx := context.Background()
+	var feeds []types.FeedConfig
+
+	iter := client.Collection(feedsCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error iterating feeds: %w", err)
+		}
+
+		var feed types.FeedConfig
+		if err := doc.DataTo(&feed); err != nil {
+			return nil, fmt.Errorf("error converting feed %s: %w", doc.Ref.ID, err)
+		}
+		feed.ID = doc.Ref.ID
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+func SaveFeedConfig(client *firestore.Client, feed types.FeedConfig) error {
+	if feed.ID == "" {
+		return fmt.Errorf("feed has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(feedsCollection).Doc(feed.ID).Set(ctx, feed)
+	return err
+}
+
+func DeleteFeedConfig(client *firestore.Client, id string) error {
+	ctx := context.Background()
+	_, err := client.Collection(feedsCollection).Doc(id).Delete(ctx)
+	return err
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"go-firebird/types"
+	"time"
+)
+
+// FirestoreStore implements Store on top of the package level Firestore functions.
+type FirestoreStore struct {
+	Client *firestore.Client
+}
+
+func NewFirestoreStore(client *firestore.Client) *FirestoreStore {
+	return &FirestoreStore{Client: client}
+}
+
+func (s *FirestoreStore) SkeetExists(hashedSkeetID string) (bool, error) {
+	return SkeetExists(s.Client, hashedSkeetID)
+}
+
+func (s *FirestoreStore) SaveCompleteSkeet(data types.SaveCompleteSkeetType) ([]string, error) {
+	return SaveCompleteSkeet(s.Client, data)
+}
+
+func (s *FirestoreStore) DeleteAllTestSkeets() (int, error) {
+	return DeleteAllTestSkeets(s.Client)
+}
+
+func (s *FirestoreStore) EachSkeet(since, until string, fn func(types.SkeetRecord) error) error {
+	return EachSkeet(s.Client, since, until, fn)
+}
+
+func (s *FirestoreStore) EachValidLocation(fn func(types.LocationData) error) error {
+	return EachValidLocation(s.Client, fn)
+}
+
+func (s *FirestoreStore) EachLocation(fn func(types.LocationData) error) error {
+	return EachLocation(s.Client, fn)
+}
+
+func (s *FirestoreStore) GetValidLocations() ([]types.LocationData, error) {
+	locations, err := GetValidLocations(s.Client)
+	return migrateLocations(locations), err
+}
+
+func (s *FirestoreStore) GetLocationsByIDs(locationDocIDs []string) ([]types.LocationData, error) {
+	locations, err := GetLocationsByIDs(s.Client, locationDocIDs)
+	return migrateLocations(locations), err
+}
+
+func (s *FirestoreStore) GetValidLocation(locationDocID string) (types.LocationData, error) {
+	location, err := GetValidLocation(s.Client, locationDocID)
+	location.MigrateDisasterCounts()
+	return location, err
+}
+
+func (s *FirestoreStore) GetNewLocations() ([]types.LocationData, error) {
+	locations, err := GetNewLocations(s.Client)
+	return migrateLocations(locations), err
+}
+
+func (s *FirestoreStore) GetTopLocationsBySkeetAmount(limit int) ([]types.LocationData, error) {
+	locations, err := GetTopLocationsBySkeetAmount(s.Client, limit)
+	return migrateLocations(locations), err
+}
+
+func (s *FirestoreStore) GetLocationsForDisasterCheck(sentimentThreshold float32, regions []types.Region) ([]types.LocationData, error) {
+	locations, err := GetLocationsForDisasterCheck(s.Client, sentimentThreshold, regions)
+	return migrateLocations(locations), err
+}
+
+func (s *FirestoreStore) InitLocationSentiment(locationDocID string, newAvgSentiment types.AvgLocationSentiment) error {
+	return InitLocationSentiment(s.Client, locationDocID, newAvgSentiment)
+}
+
+func (s *FirestoreStore) UpdateLocSentimentTimestampWithData(locationData types.LocationData, locationDocID, newTime string) error {
+	return UpdateLocSentimentTimestampWithData(s.Client, locationData, locationDocID, newTime)
+}
+
+func (s *FirestoreStore) AddNewLocSentimentAvg(locationDocID string, updatedList []types.AvgLocationSentiment) error {
+	return AddNewLocSentimentAvg(s.Client, locationDocID, updatedList)
+}
+
+func (s *FirestoreStore) UpdateLocationSentimentList(locationID string, updatedList []types.AvgLocationSentiment) error {
+	return UpdateLocationSentimentList(s.Client, locationID, updatedList)
+}
+
+func (s *FirestoreStore) UpdateLocationFields(locationID string, fieldsToUpdate map[string]interface{}) error {
+	return UpdateLocationFields(s.Client, locationID, fieldsToUpdate)
+}
+
+func (s *FirestoreStore) UpdateLocationDoc(locationID string, locationData types.LocationData) error {
+	return UpdateLocationDoc(s.Client, locationID, locationData)
+}
+
+func (s *FirestoreStore) ReplaceLocationDisasterCounts(locationID string, location types.LocationData) error {
+	return ReplaceLocationDisasterCounts(s.Client, locationID, location)
+}
+
+func (s *FirestoreStore) UpdateLocationWindows(locationID string, windows map[string]types.LocationWindow) error {
+	return UpdateLocationWindows(s.Client, locationID, windows)
+}
+
+func (s *FirestoreStore) GetSkeetsSubCollection(locationDocID string, start, end string) ([]types.SkeetSubDoc, error) {
+	return GetSkeetsSubCollection(s.Client, locationDocID, start, end)
+}
+
+func (s *FirestoreStore) GetLatestSkeetInSubCollection(locationDocID string) (types.SkeetSubDoc, error) {
+	return GetLatestSkeetInSubCollection(s.Client, locationDocID)
+}
+
+func (s *FirestoreStore) SaveDisasters(disasters []types.DisasterData) error {
+	return SaveDisasters(s.Client, disasters)
+}
+
+func (s *FirestoreStore) EachDisaster(fn func(types.DisasterData) error) error {
+	return EachDisaster(s.Client, fn)
+}
+
+func (s *FirestoreStore) GetAllDisasters() ([]types.DisasterData, error) {
+	disasters, err := GetAllDisasters(s.Client)
+	return migrateDisasters(disasters), err
+}
+
+func (s *FirestoreStore) GetOpenDisasters() ([]types.DisasterData, error) {
+	disasters, err := GetOpenDisasters(s.Client)
+	return migrateDisasters(disasters), err
+}
+
+func (s *FirestoreStore) GetDisastersEndedSince(since time.Time) ([]types.DisasterData, error) {
+	disasters, err := GetDisastersEndedSince(s.Client, since)
+	return migrateDisasters(disasters), err
+}
+
+func (s *FirestoreStore) GetDisasterByID(disasterID string) (types.DisasterData, error) {
+	disaster, err := GetDisasterByID(s.Client, disasterID)
+	disaster.MigrateDisasterCounts()
+	return disaster, err
+}
+
+func (s *FirestoreStore) SaveDetectionRun(run types.DetectionRun) error {
+	return SaveDetectionRun(s.Client, run)
+}
+
+func (s *FirestoreStore) GetDetectionRuns(limit int) ([]types.DetectionRun, error) {
+	return GetDetectionRuns(s.Client, limit)
+}
+
+func (s *FirestoreStore) GetGeocode(key string) (types.GeocodeResult, bool, error) {
+	return GetGeocode(s.Client, key)
+}
+
+func (s *FirestoreStore) SaveGeocode(key string, result types.GeocodeResult) error {
+	return SaveGeocode(s.Client, key, result)
+}
+
+func (s *FirestoreStore) GetCursor(name string) (types.Cursor, bool, error) {
+	return GetCursor(s.Client, name)
+}
+
+func (s *FirestoreStore) SaveCursor(cursor types.Cursor) error {
+	return SaveCursor(s.Client, cursor)
+}
+
+func (s *FirestoreStore) GetBackfillProgress(feedURI string) (types.BackfillProgress, bool, error) {
+	return GetBackfillProgress(s.Client, feedURI)
+}
+
+func (s *FirestoreStore) SaveBackfillProgress(progress types.BackfillProgress) error {
+	return SaveBackfillProgress(s.Client, progress)
+}
+
+func (s *FirestoreStore) GetFeedConfigs() ([]types.FeedConfig, error) {
+	return GetFeedConfigs(s.Client)
+}
+
+func (s *FirestoreStore) SaveFeedConfig(feed types.FeedConfig) error {
+	return SaveFeedConfig(s.Client, feed)
+}
+
+func (s *FirestoreStore) DeleteFeedConfig(id string) error {
+	return DeleteFeedConfig(s.Client, id)
+}
+
+func (s *FirestoreStore) GetWebhooks() ([]types.Webhook, error) {
+	return GetWebhooks(s.Client)
+}
+
+func (s *FirestoreStore) GetWebhook(id string) (types.Webhook, error) {
+	return GetWebhook(s.Client, id)
+}
+
+func (s *FirestoreStore) SaveWebhook(webhook types.Webhook) error {
+	return SaveWebhook(s.Client, webhook)
+}
+
+func (s *FirestoreStore) DeleteWebhook(id string) error {
+	return DeleteWebhook(s.Client, id)
+}
+
+func (s *FirestoreStore) SaveWebhookDelivery(delivery types.WebhookDelivery) error {
+	return SaveWebhookDelivery(s.Client, delivery)
+}
+
+func (s *FirestoreStore) GetWebhookDelivery(id string) (types.WebhookDelivery, error) {
+	return GetWebhookDelivery(s.Client, id)
+}
+
+func (s *FirestoreStore) GetWebhookDeliveries(webhookID string, status types.DeliveryStatus, limit int) ([]types.WebhookDelivery, error) {
+	return GetWebhookDeliveries(s.Client, webhookID, status, limit)
+}
+
+func (s *FirestoreStore) GetAPIKeys() ([]types.APIKey, error) {
+	return GetAPIKeys(s.Client)
+}
+
+func (s *FirestoreStore) GetAPIKey(id string) (types.APIKey, error) {
+	return GetAPIKey(s.Client, id)
+}
+
+func (s *FirestoreStore) SaveAPIKey(key types.APIKey) error {
+	return SaveAPIKey(s.Client, key)
+}
+
+func (s *FirestoreStore) SaveAuditEntry(entry types.AuditEntry) error {
+	return SaveAuditEntry(s.Client, entry)
+}
+
+func (s *FirestoreStore) GetAuditEntries(actor string, limit int) ([]types.AuditEntry, error) {
+	return GetAuditEntries(s.Client, actor, limit)
+}
+
+func (s *FirestoreStore) GetProviderUsage(day string) ([]types.ProviderUsage, error) {
+	return GetProviderUsage(s.Client, day)
+}
+
+func (s *FirestoreStore) SaveProviderUsage(usage types.ProviderUsage) error {
+	return SaveProviderUsage(s.Client, usage)
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const geocodeCacheCollection = "geocodeCache"
+
+// GetGeocode returns the cached geocode result for a normalized location name, ok is false on a cache miss.
+func GetGeocode(client *firestore.Client, key string) (types.GeocodeResult, bool, error) {
+	ctx := context.Background()
+	var result types.GeocodeResult
+
+	doc, err := client.Collection(geocodeCacheCollection).Doc(HashString(key)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return result, false, nil
+		}
+		return result, false, fmt.Errorf("error getting geocode cache for %s: %w", key, err)
+	}
+	if err := doc.DataTo(&result); err != nil {
+		return result, false, fmt.Errorf("error converting geocode cache for %s: %w", key, err)
+	}
+	return result, true, nil
+}
+
+func SaveGeocode(client *firestore.Client, key string, result types.GeocodeResult) error {
+	ctx := context.Background()
+	_, err := client.Collection(geocodeCacheCollection).Doc(HashString(key)).Set(ctx, result)
+	return err
+}
@@ -7,6 +7,8 @@ import (
 	"go-firebird/geocode"
 	"go-firebird/types"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"log"
 )
 
@@ -31,6 +33,7 @@ func GetValidLocations(client *firestore.Client) ([]types.LocationData, error) {
 		if err := doc.DataTo(&location); err != nil {
 			return nil, err
 		}
+		location.ID = doc.Ref.ID
 		validLocations = append(validLocations, location)
 	}
 
@@ -44,6 +47,9 @@ func GetValidLocation(client *firestore.Client, locationDocID string) (types.Loc
 	var locationData types.LocationData
 
 	doc, err := client.Collection("locations").Doc(locationDocID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return locationData, fmt.Errorf("location %s: %w", locationDocID, ErrNotFound)
+	}
 	if err != nil {
 		return locationData, err
 	}
@@ -51,9 +57,41 @@ func GetValidLocation(client *firestore.Client, locationDocID string) (types.Loc
 	if err := doc.DataTo(&locationData); err != nil {
 		return locationData, err
 	}
+	locationData.ID = doc.Ref.ID
 	return locationData, nil
 }
 
+// GetLocationsByIDs gets the locations in one batch read, the ones that don't exist are left out.
+func GetLocationsByIDs(client *firestore.Client, locationDocIDs []string) ([]types.LocationData, error) {
+	if len(locationDocIDs) == 0 {
+		return nil, nil
+	}
+	ctx := context.Background()
+	refs := make([]*firestore.DocumentRef, len(locationDocIDs))
+	for i, id := range locationDocIDs {
+		refs[i] = client.Collection("locations").Doc(id)
+	}
+	docs, err := client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []types.LocationData
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var location types.LocationData
+		if err := doc.DataTo(&location); err != nil {
+			log.Printf("Warning: Error converting location %s: %v. Skipping.", doc.Ref.ID, err)
+			continue
+		}
+		location.ID = doc.Ref.ID
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
 // since its the first one, use set
 func InitLocationSentiment(client *firestore.Client, locationDocID string, newAvgSentiment types.AvgLocationSentiment) error {
 	ctx := context.Background()
@@ -175,6 +213,7 @@ func GetNewLocations(client *firestore.Client) ([]types.LocationData, error) {
 		if err := doc.DataTo(&location); err != nil {
 			return nil, err
 		}
+		location.ID = doc.Ref.ID
 		newLocations = append(newLocations, location)
 	}
 
@@ -243,47 +282,53 @@ func GetTopLocationsBySkeetAmount(client *firestore.Client, limit int) ([]types.
 		if err := doc.DataTo(&location); err != nil {
 			return nil, fmt.Errorf("error converting document %s to LocationData: %w", doc.Ref.ID, err)
 		}
+		location.ID = doc.Ref.ID
 		topLocations = append(topLocations, location)
 	}
 
 	return topLocations, nil
 }
 
-func UpdateLocationGeocoding(client *firestore.Client, locationName string) {
+// UpdateLocationWindows replaces the rolling window aggregates of a location.
+// Update replaces the field, Set with MergeAll would keep category counts that dropped out of a window.
+func UpdateLocationWindows(client *firestore.Client, locationID string, windows map[string]types.LocationWindow) error {
+	ctx := context.Background()
+	locDocRef := client.Collection("locations").Doc(locationID)
+
+	_, err := locDocRef.Update(ctx, []firestore.Update{{Path: "windows", Value: windows}})
+	if err != nil {
+		return fmt.Errorf("failed to update windows for %s: %w", locationID, err)
+	}
+	return nil
+}
+
+func UpdateLocationGeocoding(store Store, geocoder geocode.Geocoder, locationName string) {
 	hashedLocationID := HashString(locationName)
-	results, err := geocode.GeocodeAddress(locationName)
+	result, err := geocoder.Geocode(locationName)
 	if err != nil {
 		log.Printf("Failed to geocode %s: %v", locationName, err)
 		return
 	}
 
-	result := ""
-	if len(results) > 0 {
-		result = results[0].FormattedAddress
-
-	}
+	// uses newLocation flag to determine if an update is needed, a location with
+	// no formattedAddress and lat/long of 0 is treated as invalid from then on
 	geoData := map[string]interface{}{
-		"formattedAddress": result,
+		"formattedAddress": "",
 		"lat":              0,
 		"long":             0,
-		"newLocation":      false, // uses newLocation flag to determine if an update is needed
+		"newLocation":      false,
 	}
 
-	if len(results) == 0 {
+	if !result.Found {
 		log.Printf("No geocode results for %s", locationName)
 		log.Println("Setting result to null")
 	} else {
-		loc := results[0].Geometry.Location
-		geoData = map[string]interface{}{
-			"formattedAddress": results[0].FormattedAddress,
-			"lat":              loc.Lat,
-			"long":             loc.Lng,
-			"newLocation":      false,
-		}
+		geoData["formattedAddress"] = result.FormattedAddress
+		geoData["lat"] = result.Lat
+		geoData["long"] = result.Long
 	}
 
-	ctx := context.Background()
-	_, err = client.Collection("locations").Doc(hashedLocationID).Set(ctx, geoData, firestore.MergeAll)
+	err = store.UpdateLocationFields(hashedLocationID, geoData)
 	if err != nil {
 		log.Printf("\nFailed to update geocoding data for %s: %v", locationName, err)
 		return
@@ -291,42 +336,61 @@ func UpdateLocationGeocoding(client *firestore.Client, locationName string) {
 	log.Printf("\nSuccessfully updated geocoding data for %s", locationName)
 }
 
-func GetLocationsForDisasterCheck(client *firestore.Client, sentimentThreshold float32) ([]types.LocationData, error) {
+// GetLocationsForDisasterCheck returns the locations whose short window sentiment is at or below the
+// threshold, inside any of the regions, or anywhere when no region is given.
+func GetLocationsForDisasterCheck(client *firestore.Client, sentimentThreshold float32, regions []types.Region) ([]types.LocationData, error) {
 	ctx := context.Background()
 	var potentialDisasterLocations []types.LocationData
-	var minLat = 24.0
-	var maxLat = 50.0
-	var minLong = -125.0
-	var maxLong = -66.0
+	seen := make(map[string]bool)
 
-	query := client.Collection("locations").Where("latestSentiment", "<=", sentimentThreshold).Where("lat", ">=", minLat).Where("lat", "<=", maxLat).Where("long", ">=", minLong).Where("long", "<=", maxLong)
+	// Seed from the short rolling window, the all-time latestSentiment of a busy city hardly moves when something happens
+	windowPath := "windows." + types.ShortWindow.Name
+	base := client.Collection("locations").Where(windowPath+".averageSentiment", "<=", sentimentThreshold).Where(windowPath+".skeetsAmount", ">", 0)
 
 	// TODO: adding other filters if needed (e.g., lastSkeetTimestamp within a certain period?)
 
-	iter := query.Documents(ctx)
-	defer iter.Stop() // Ensure iterator resources are released
-
-	log.Printf("Fetching locations with sentiment <= %.2f for disaster check...", sentimentThreshold)
-
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break // End of results
-		}
-		if err != nil {
-			return nil, fmt.Errorf("error iterating locations for disaster check: %w", err)
+	queries := []firestore.Query{base}
+	if len(regions) > 0 {
+		queries = queries[:0]
+		for _, r := range regions {
+			query := base.Where("lat", ">=", r.MinLat).Where("lat", "<=", r.MaxLat)
+			if !r.CrossesAntimeridian() {
+				query = query.Where("long", ">=", r.MinLon).Where("long", "<=", r.MaxLon)
+			} // else the longitude is checked below, firestore has no OR on a range
+			queries = append(queries, query)
 		}
+	}
 
-		var location types.LocationData
-		if err := doc.DataTo(&location); err != nil {
-			log.Printf("Warning: Error converting document %s to LocationData: %v. Skipping.", doc.Ref.ID, err)
-			continue
+	log.Printf("Fetching locations with %s sentiment <= %.2f in %d region(s) for disaster check...", types.ShortWindow.Name, sentimentThreshold, len(regions))
+
+	for i, query := range queries {
+		iter := query.Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break // End of results
+			}
+			if err != nil {
+				iter.Stop()
+				return nil, fmt.Errorf("error iterating locations for disaster check: %w", err)
+			}
+
+			var location types.LocationData
+			if err := doc.DataTo(&location); err != nil {
+				log.Printf("Warning: Error converting document %s to LocationData: %v. Skipping.", doc.Ref.ID, err)
+				continue
+			}
+
+			// Manually add the Firestore Document ID to the struct
+			location.ID = doc.Ref.ID
+
+			if seen[location.ID] || (len(regions) > 0 && !regions[i].Contains(location.Lat, location.Long)) {
+				continue
+			}
+			seen[location.ID] = true
+			potentialDisasterLocations = append(potentialDisasterLocations, location)
 		}
-
-		// Manually add the Firestore Document ID to the struct
-		location.ID = doc.Ref.ID
-
-		potentialDisasterLocations = append(potentialDisasterLocations, location)
+		iter.Stop()
 	}
 
 	log.Printf("Found %d locations meeting disaster check criteria.", len(potentialDisasterLocations))
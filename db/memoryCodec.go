@@ -0,0 +1,347 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// The in-memory store keeps every document as a plain map[string]interface{} the same way Firestore does,
+// so merges, nested field paths (skeetData.timestamp) and partial updates behave like the real thing.
+// encodeDoc/decodeDoc convert between those maps and our structs using the `firestore` struct tags.
+
+func encodeDoc(v interface{}) (map[string]interface{}, error) {
+	encoded := encodeValue(reflect.ValueOf(v))
+	m, ok := encoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot store %T as a document", v)
+	}
+	return m, nil
+}
+
+func decodeDoc(doc map[string]interface{}, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("decodeDoc needs a non-nil pointer, got %T", out)
+	}
+	return decodeValue(doc, rv.Elem())
+}
+
+// firestoreFieldName returns the document key for a struct field and whether it should be skipped.
+func firestoreFieldName(f reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	if f.PkgPath != "" { // unexported
+		return "", false, true
+	}
+	tag := f.Tag.Get("firestore")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+func encodeValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return encodeValue(v.Elem())
+
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t
+		}
+		m := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, omitEmpty, skip := firestoreFieldName(t.Field(i))
+			if skip {
+				continue
+			}
+			fv := v.Field(i)
+			if omitEmpty && fv.IsZero() {
+				continue
+			}
+			m[name] = encodeValue(fv)
+		}
+		return m
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = encodeValue(iter.Value())
+		}
+		return m
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte(nil), v.Bytes()...)
+		}
+		fallthrough
+	case reflect.Array:
+		s := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s[i] = encodeValue(v.Index(i))
+		}
+		return s
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	}
+
+	return v.Interface()
+}
+
+func decodeValue(src interface{}, dst reflect.Value) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		dst.Set(reflect.ValueOf(src))
+		return nil
+
+	case reflect.Ptr:
+		elem := reflect.New(dst.Type().Elem())
+		if err := decodeValue(src, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+
+	case reflect.Struct:
+		if _, ok := dst.Interface().(time.Time); ok {
+			t, ok := src.(time.Time)
+			if !ok {
+				return fmt.Errorf("cannot decode %T into time.Time", src)
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		t := dst.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, skip := firestoreFieldName(t.Field(i))
+			if skip {
+				continue
+			}
+			fieldSrc, present := m[name]
+			if !present {
+				continue
+			}
+			if err := decodeValue(fieldSrc, dst.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		if dst.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map keys must be strings, got %s", dst.Type().Key())
+		}
+		out := reflect.MakeMapWithSize(dst.Type(), len(m))
+		for k, v := range m {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := decodeValue(v, elem); err != nil {
+				return fmt.Errorf("key %s: %w", k, err)
+			}
+			out.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(out)
+		return nil
+
+	case reflect.Slice:
+		if b, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		s, ok := src.([]interface{})
+		if !ok {
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		out := reflect.MakeSlice(dst.Type(), len(s), len(s))
+		for i, v := range s {
+			if err := decodeValue(v, out.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		dst.Set(out)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch n := src.(type) {
+		case int64:
+			dst.SetInt(n)
+		case float64:
+			dst.SetInt(int64(n))
+		default:
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch n := src.(type) {
+		case int64:
+			dst.SetUint(uint64(n))
+		case float64:
+			dst.SetUint(uint64(n))
+		default:
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch n := src.(type) {
+		case int64:
+			dst.SetFloat(float64(n))
+		case float64:
+			dst.SetFloat(n)
+		default:
+			return fmt.Errorf("cannot decode %T into %s", src, dst.Type())
+		}
+		return nil
+
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into bool", src)
+		}
+		dst.SetBool(b)
+		return nil
+
+	case reflect.String:
+		s, ok := src.(string)
+		if !ok {
+			return fmt.Errorf("cannot decode %T into string", src)
+		}
+		dst.SetString(s)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported destination type %s", dst.Type())
+}
+
+// mergeDoc applies src on top of dst the way Set(..., firestore.MergeAll) does:
+// nested maps are merged key by key, everything else is replaced.
+func mergeDoc(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeDoc(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// lookupPath resolves a dotted field path such as "skeetData.timestamp".
+func lookupPath(doc map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = doc
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// compareValues orders two encoded values of the same kind. ok is false when they can't be compared,
+// which is how Firestore treats mismatched types in range filters.
+func compareValues(a, b interface{}) (result int, ok bool) {
+	toFloat := func(v interface{}) (float64, bool) {
+		switch n := v.(type) {
+		case int64:
+			return float64(n), true
+		case float64:
+			return n, true
+		case int:
+			return float64(n), true
+		case float32:
+			return float64(n), true
+		}
+		return 0, false
+	}
+
+	if af, aok := toFloat(a); aok {
+		bf, bok := toFloat(b)
+		if !bok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if as, aok := a.(string); aok {
+		bs, bok := b.(string)
+		if !bok {
+			return 0, false
+		}
+		return strings.Compare(as, bs), true
+	}
+
+	if ab, aok := a.(bool); aok {
+		bb, bok := b.(bool)
+		if !bok {
+			return 0, false
+		}
+		switch {
+		case ab == bb:
+			return 0, true
+		case !ab:
+			return -1, true
+		}
+		return 1, true
+	}
+
+	return 0, false
+}
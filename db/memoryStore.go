@@ -0,0 +1,1035 @@
+package db
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a fully in-memory Store. Every method holds the store lock for its whole run,
+// so multi-document writes like SaveCompleteSkeet are atomic the same way a Firestore transaction is.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	collections map[string]map[string]map[string]interface{} // collection path -> doc ID -> doc
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		collections: make(map[string]map[string]map[string]interface{}),
+	}
+}
+
+// filter is a single Where clause.
+type filter struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+func (f filter) matches(doc map[string]interface{}) bool {
+	v, ok := lookupPath(doc, f.path)
+	if !ok {
+		return false // Firestore never matches documents that are missing the field
+	}
+	want := encodeValue(reflect.ValueOf(f.value))
+	if f.op == "in" {
+		values, _ := want.([]interface{})
+		for _, value := range values {
+			if cmp, ok := compareValues(v, value); ok && cmp == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	cmp, comparable := compareValues(v, want)
+	if !comparable {
+		// != still matches values of a different type
+		return f.op == "!=" && !reflect.DeepEqual(v, want)
+	}
+	switch f.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func subCollectionPath(locationDocID string) string {
+	return "locations/" + locationDocID + "/skeetIds"
+}
+
+// --- raw document helpers, callers must hold the lock ---
+
+func (s *MemoryStore) getDoc(collection, id string) (map[string]interface{}, bool) {
+	docs, ok := s.collections[collection]
+	if !ok {
+		return nil, false
+	}
+	doc, ok := docs[id]
+	return doc, ok
+}
+
+// setDoc writes data to collection/id. With merge it behaves like Set(..., firestore.MergeAll).
+func (s *MemoryStore) setDoc(collection, id string, data interface{}, merge bool) error {
+	encoded, err := encodeDoc(data)
+	if err != nil {
+		return err
+	}
+	docs, ok := s.collections[collection]
+	if !ok {
+		docs = make(map[string]map[string]interface{})
+		s.collections[collection] = docs
+	}
+	existing, exists := docs[id]
+	if !merge || !exists {
+		docs[id] = encoded
+		return nil
+	}
+	mergeDoc(existing, encoded)
+	return nil
+}
+
+// query returns the IDs and documents in a collection matching all filters, sorted by ID.
+func (s *MemoryStore) query(collection string, filters ...filter) ([]string, []map[string]interface{}) {
+	var ids []string
+	for id, doc := range s.collections[collection] {
+		matched := true
+		for _, f := range filters {
+			if !f.matches(doc) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	docs := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		docs[i] = s.collections[collection][id]
+	}
+	return ids, docs
+}
+
+func (s *MemoryStore) queryLocations(filters ...filter) ([]types.LocationData, error) {
+	ids, docs := s.query("locations", filters...)
+	locations := make([]types.LocationData, 0, len(docs))
+	for i, doc := range docs {
+		var location types.LocationData
+		if err := decodeDoc(doc, &location); err != nil {
+			return nil, fmt.Errorf("error converting location %s: %w", ids[i], err)
+		}
+		location.ID = ids[i]
+		location.MigrateDisasterCounts()
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// --- Skeets ---
+
+func (s *MemoryStore) SkeetExists(hashedSkeetID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.getDoc("skeets", hashedSkeetID)
+	return ok, nil
+}
+
+// SaveCompleteSkeet mirrors the Firestore transaction in skeets.go.
+func (s *MemoryStore) SaveCompleteSkeet(data types.SaveCompleteSkeetType) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var addresses, locations []types.Entity
+	for _, entity := range data.Entities {
+		if entity.Type == "ADDRESS" {
+			addresses = append(addresses, entity)
+		} else if entity.Type == "LOCATION" {
+			locations = append(locations, entity)
+		}
+	}
+
+	skeetData := map[string]interface{}{
+		"avatar":          data.NewSkeet.Avatar,
+		"content":         data.NewSkeet.Content,
+		"timestamp":       data.NewSkeet.Timestamp,
+		"handle":          data.NewSkeet.Handle,
+		"displayName":     data.NewSkeet.DisplayName,
+		"uid":             data.NewSkeet.UID,
+		"classification":  data.Classification,
+		"classifiedBy":    data.ClassifiedBy,
+		"entity_ADDRESS":  addresses,
+		"entity_LOCATION": locations,
+		"sentiment":       data.Sentiment,
+		"likeCount":       data.NewSkeet.LikeCount,
+		"repostCount":     data.NewSkeet.RepostCount,
+	}
+	hashedSkeetID := HashString(data.NewSkeet.UID)
+
+	// Work out which locations are new and which were already geocoded as invalid.
+	newLocationsData := []types.NewLocationMetaData{}
+	seenNew := make(map[string]bool)
+	invalidLocations := make(map[string]bool)
+	for _, entity := range data.Entities {
+		if entity.Type != "LOCATION" && entity.Type != "ADDRESS" {
+			continue
+		}
+		hashedLocationID := HashString(entity.Name)
+		locationDoc, exists := s.getDoc("locations", hashedLocationID)
+		if !exists {
+			if !seenNew[hashedLocationID] {
+				seenNew[hashedLocationID] = true
+				newLocationsData = append(newLocationsData, types.NewLocationMetaData{
+					LocationName: entity.Name,
+					Type:         entity.Type,
+					NewLocation:  true,
+				})
+			}
+			continue
+		}
+
+		var geo struct {
+			Lat         float64 `firestore:"lat"`
+			Long        float64 `firestore:"long"`
+			NewLocation *bool   `firestore:"newLocation"`
+		}
+		if err := decodeDoc(locationDoc, &geo); err != nil {
+			return nil, fmt.Errorf("error reading location doc for %s: %w", entity.Name, err)
+		}
+		if geo.NewLocation != nil && geo.Lat == 0 && geo.Long == 0 && !*geo.NewLocation {
+			invalidLocations[hashedLocationID] = true
+		}
+	}
+
+	if err := s.setDoc("skeets", hashedSkeetID, skeetData, true); err != nil {
+		return nil, fmt.Errorf("failed to set skeet document: %w", err)
+	}
+
+	for _, value := range newLocationsData {
+		locationDataMap := map[string]interface{}{
+			"locationName":     value.LocationName,
+			"type":             value.Type,
+			"newLocation":      value.NewLocation,
+			"avgSentimentList": []types.AvgLocationSentiment{},
+		}
+		if err := s.setDoc("locations", HashString(value.LocationName), locationDataMap, true); err != nil {
+			return nil, fmt.Errorf("failed to set location doc for %s: %w", value.LocationName, err)
+		}
+	}
+
+	for _, entity := range data.Entities {
+		if entity.Type != "LOCATION" && entity.Type != "ADDRESS" {
+			continue
+		}
+		hashedLocationID := HashString(entity.Name)
+		if invalidLocations[hashedLocationID] {
+			continue
+		}
+		subData := map[string]interface{}{
+			"mentions":     entity.Mentions,
+			"locationName": entity.Name,
+			"type":         entity.Type,
+			"skeetData":    skeetData,
+		}
+		if err := s.setDoc(subCollectionPath(hashedLocationID), hashedSkeetID, subData, true); err != nil {
+			return nil, fmt.Errorf("failed to set subcollection for %s: %w", entity.Name, err)
+		}
+	}
+
+	var newLocationNames []string
+	for _, locMeta := range newLocationsData {
+		newLocationNames = append(newLocationNames, locMeta.LocationName)
+	}
+	return newLocationNames, nil
+}
+
+// EachSkeet reads the matching skeets first, fn may use the store.
+func (s *MemoryStore) EachSkeet(since, until string, fn func(types.SkeetRecord) error) error {
+	filters := []filter{{"timestamp", ">=", since}}
+	if until != "" {
+		filters = append(filters, filter{"timestamp", "<", until})
+	}
+
+	s.mu.RLock()
+	ids, docs := s.query("skeets", filters...)
+	records := make([]types.SkeetRecord, 0, len(docs))
+	for i, doc := range docs {
+		record, err := decodeSkeetRecord(ids[i], doc)
+		if err != nil {
+			s.mu.RUnlock()
+			return err
+		}
+		records = append(records, record)
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Skeet.Timestamp < records[j].Skeet.Timestamp })
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeSkeetRecord(id string, doc map[string]interface{}) (types.SkeetRecord, error) {
+	record := types.SkeetRecord{ID: id}
+	var entities skeetEntities
+	if err := decodeDoc(doc, &record.Skeet); err != nil {
+		return record, fmt.Errorf("error converting skeet %s: %w", id, err)
+	}
+	if err := decodeDoc(doc, &entities); err != nil {
+		return record, fmt.Errorf("error converting entities of skeet %s: %w", id, err)
+	}
+	record.Addresses, record.Locations = entities.Addresses, entities.Locations
+	return record, nil
+}
+
+func (s *MemoryStore) DeleteAllTestSkeets() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids, _ := s.query("skeets", filter{"displayName", "==", displayNameTest})
+	for _, id := range ids {
+		delete(s.collections["skeets"], id)
+	}
+	log.Printf("Deleted %d test skeets from memory store", len(ids))
+	return len(ids), nil
+}
+
+// --- Locations ---
+
+func (s *MemoryStore) GetValidLocations() ([]types.LocationData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryLocations(filter{"formattedAddress", "!=", ""})
+}
+
+// EachValidLocation reads the locations first, fn may use the store.
+func (s *MemoryStore) EachValidLocation(fn func(types.LocationData) error) error {
+	locations, err := s.GetValidLocations()
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EachLocation reads the locations first, fn may use the store.
+func (s *MemoryStore) EachLocation(fn func(types.LocationData) error) error {
+	s.mu.RLock()
+	locations, err := s.queryLocations()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	for _, location := range locations {
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetValidLocation(locationDocID string) (types.LocationData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var location types.LocationData
+	doc, ok := s.getDoc("locations", locationDocID)
+	if !ok {
+		return location, fmt.Errorf("location %s: %w", locationDocID, ErrNotFound)
+	}
+	if err := decodeDoc(doc, &location); err != nil {
+		return location, err
+	}
+	location.ID = locationDocID
+	location.MigrateDisasterCounts()
+	return location, nil
+}
+
+func (s *MemoryStore) GetLocationsByIDs(locationDocIDs []string) ([]types.LocationData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var locations []types.LocationData
+	for _, id := range locationDocIDs {
+		doc, ok := s.getDoc("locations", id)
+		if !ok {
+			continue
+		}
+		var location types.LocationData
+		if err := decodeDoc(doc, &location); err != nil {
+			return nil, err
+		}
+		location.ID = id
+		location.MigrateDisasterCounts()
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+func (s *MemoryStore) GetNewLocations() ([]types.LocationData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryLocations(filter{"newLocation", "==", true})
+}
+
+func (s *MemoryStore) GetTopLocationsBySkeetAmount(limit int) ([]types.LocationData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	locations, err := s.queryLocations(filter{"latestSkeetsAmount", ">=", int64(0)})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(locations, func(i, j int) bool {
+		return locations[i].LatestSkeetsAmount > locations[j].LatestSkeetsAmount
+	})
+	if limit >= 0 && len(locations) > limit {
+		locations = locations[:limit]
+	}
+	return locations, nil
+}
+
+func (s *MemoryStore) GetLocationsForDisasterCheck(sentimentThreshold float32, regions []types.Region) ([]types.LocationData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	windowPath := "windows." + types.ShortWindow.Name
+	locations, err := s.queryLocations(
+		filter{windowPath + ".averageSentiment", "<=", sentimentThreshold},
+		filter{windowPath + ".skeetsAmount", ">", 0},
+	)
+	if err != nil || len(regions) == 0 {
+		return locations, err
+	}
+
+	inRegion := locations[:0]
+	for _, location := range locations {
+		for _, r := range regions {
+			if r.Contains(location.Lat, location.Long) {
+				inRegion = append(inRegion, location)
+				break
+			}
+		}
+	}
+	return inRegion, nil
+}
+
+func (s *MemoryStore) InitLocationSentiment(locationDocID string, newAvgSentiment types.AvgLocationSentiment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc("locations", locationDocID, map[string]interface{}{
+		"avgSentimentList": []types.AvgLocationSentiment{newAvgSentiment},
+	}, true)
+}
+
+func (s *MemoryStore) UpdateLocSentimentTimestampWithData(locationData types.LocationData, locationDocID, newTime string) error {
+	if len(locationData.AvgSentimentList) == 0 {
+		return fmt.Errorf("AvgSentimentList is empty, cannot update timestamp")
+	}
+	lastIndex := len(locationData.AvgSentimentList) - 1
+	locationData.AvgSentimentList[lastIndex].TimeStamp = newTime
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc("locations", locationDocID, map[string]interface{}{
+		"avgSentimentList": locationData.AvgSentimentList,
+	}, true)
+}
+
+// AddNewLocSentimentAvg behaves like firestore.ArrayUnion: entries already in the list are not added twice.
+func (s *MemoryStore) AddNewLocSentimentAvg(locationDocID string, updatedList []types.AvgLocationSentiment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var current []interface{}
+	if doc, ok := s.getDoc("locations", locationDocID); ok {
+		current, _ = doc["avgSentimentList"].([]interface{})
+	}
+	union := append([]interface{}{}, current...)
+	for _, item := range updatedList {
+		encoded := encodeValue(reflect.ValueOf(item))
+		found := false
+		for _, existing := range union {
+			if reflect.DeepEqual(existing, encoded) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			union = append(union, encoded)
+		}
+	}
+
+	return s.setDoc("locations", locationDocID, map[string]interface{}{
+		"avgSentimentList": union,
+	}, true)
+}
+
+func (s *MemoryStore) UpdateLocationSentimentList(locationID string, updatedList []types.AvgLocationSentiment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.getDoc("locations", locationID); !ok {
+		return fmt.Errorf("failed to update avgSentimentList for %s: document not found", locationID)
+	}
+	return s.setDoc("locations", locationID, map[string]interface{}{
+		"avgSentimentList": updatedList,
+	}, true)
+}
+
+func (s *MemoryStore) UpdateLocationFields(locationID string, fieldsToUpdate map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.setDoc("locations", locationID, fieldsToUpdate, true); err != nil {
+		return fmt.Errorf("failed to update fields for location %s: %w", locationID, err)
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateLocationDoc(locationID string, locationData types.LocationData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.setDoc("locations", locationID, locationData, true); err != nil {
+		return fmt.Errorf("failed to update location document %s: %w", locationID, err)
+	}
+	return nil
+}
+
+// ReplaceLocationDisasterCounts sets the fields directly, setDoc with merge would keep the legacy keys.
+func (s *MemoryStore) ReplaceLocationDisasterCounts(locationID string, location types.LocationData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.getDoc("locations", locationID)
+	if !ok {
+		return fmt.Errorf("failed to replace disaster counts for %s: document not found", locationID)
+	}
+	doc["latestDisasterCount"] = encodeValue(reflect.ValueOf(location.LatestDisasterCount))
+	doc["avgSentimentList"] = encodeValue(reflect.ValueOf(location.AvgSentimentList))
+	return nil
+}
+
+func (s *MemoryStore) UpdateLocationWindows(locationID string, windows map[string]types.LocationWindow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.getDoc("locations", locationID)
+	if !ok {
+		return fmt.Errorf("failed to update windows for %s: document not found", locationID)
+	}
+	doc["windows"] = encodeValue(reflect.ValueOf(windows))
+	return nil
+}
+
+// --- Location skeet subcollection ---
+
+func (s *MemoryStore) GetSkeetsSubCollection(locationDocID string, start, end string) ([]types.SkeetSubDoc, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, docs := s.query(subCollectionPath(locationDocID),
+		filter{"skeetData.timestamp", ">=", start},
+		filter{"skeetData.timestamp", "<=", end},
+	)
+
+	var skeets []types.SkeetSubDoc
+	for _, doc := range docs {
+		var sub types.SkeetSubDoc
+		if err := decodeDoc(doc, &sub); err != nil {
+			return nil, fmt.Errorf("error converting document to SubSkeet: %w", err)
+		}
+		skeets = append(skeets, sub)
+	}
+	return skeets, nil
+}
+
+func (s *MemoryStore) GetLatestSkeetInSubCollection(locationDocID string) (types.SkeetSubDoc, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latestSkeet types.SkeetSubDoc
+	var latestTimestamp string
+	found := false
+	for _, doc := range s.collections[subCollectionPath(locationDocID)] {
+		ts, ok := lookupPath(doc, "skeetData.timestamp")
+		if !ok {
+			continue
+		}
+		tsStr, _ := ts.(string)
+		if found && strings.Compare(tsStr, latestTimestamp) <= 0 {
+			continue
+		}
+		if err := decodeDoc(doc, &latestSkeet); err != nil {
+			return latestSkeet, fmt.Errorf("error converting document to SkeetSubDoc: %w", err)
+		}
+		latestTimestamp = tsStr
+		found = true
+	}
+
+	if !found {
+		return latestSkeet, fmt.Errorf("no skeets found in subcollection for location %s", locationDocID)
+	}
+	return latestSkeet, nil
+}
+
+// --- Disasters ---
+
+func (s *MemoryStore) SaveDisasters(disasters []types.DisasterData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, disaster := range disasters {
+		if disaster.ID == "" {
+			log.Printf("Warning: Skipping disaster with empty ID: %+v", disaster)
+			continue
+		}
+		if err := s.setDoc(disastersCollection, disaster.ID, disaster, false); err != nil {
+			log.Printf("Error saving disaster %s: %v", disaster.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetAllDisasters() ([]types.DisasterData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryDisasters()
+}
+
+func (s *MemoryStore) GetOpenDisasters() ([]types.DisasterData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryDisasters(filter{"status", "in", []types.Status{types.Active, types.Recovery}})
+}
+
+func (s *MemoryStore) GetDisastersEndedSince(since time.Time) ([]types.DisasterData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryDisasters(filter{"status", "==", types.Not_Active}, filter{"endedAt", ">=", since.UTC().Format(time.RFC3339)})
+}
+
+func (s *MemoryStore) queryDisasters(filters ...filter) ([]types.DisasterData, error) {
+	ids, docs := s.query(disastersCollection, filters...)
+	var allDisasters []types.DisasterData
+	for i, doc := range docs {
+		var disaster types.DisasterData
+		if err := decodeDoc(doc, &disaster); err != nil {
+			log.Printf("Warning: Error converting document %s to DisasterData: %v. Skipping.", ids[i], err)
+			continue
+		}
+		disaster.ID = ids[i]
+		disaster.MigrateDisasterCounts()
+		allDisasters = append(allDisasters, disaster)
+	}
+	return allDisasters, nil
+}
+
+// EachDisaster reads the disasters first, fn may use the store.
+func (s *MemoryStore) EachDisaster(fn func(types.DisasterData) error) error {
+	disasters, err := s.GetAllDisasters()
+	if err != nil {
+		return err
+	}
+	for _, disaster := range disasters {
+		if err := fn(disaster); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetDisasterByID(disasterID string) (types.DisasterData, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var disaster types.DisasterData
+	doc, ok := s.getDoc(disastersCollection, disasterID)
+	if !ok {
+		return disaster, fmt.Errorf("error getting disaster %s: %w", disasterID, ErrNotFound)
+	}
+	if err := decodeDoc(doc, &disaster); err != nil {
+		return disaster, fmt.Errorf("error converting document %s to DisasterData: %w", disasterID, err)
+	}
+	disaster.ID = disasterID
+	disaster.MigrateDisasterCounts()
+	return disaster, nil
+}
+
+// --- Detection runs ---
+
+func (s *MemoryStore) SaveDetectionRun(run types.DetectionRun) error {
+	if run.ID == "" {
+		return fmt.Errorf("detection run has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(detectionRunsCollection, run.ID, run, false)
+}
+
+func (s *MemoryStore) GetDetectionRuns(limit int) ([]types.DetectionRun, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, docs := s.query(detectionRunsCollection)
+	runs := make([]types.DetectionRun, 0, len(docs))
+	for i, doc := range docs {
+		var run types.DetectionRun
+		if err := decodeDoc(doc, &run); err != nil {
+			return nil, fmt.Errorf("error converting detection run %s: %w", ids[i], err)
+		}
+		run.ID = ids[i]
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt > runs[j].StartedAt
+	})
+	if limit >= 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+// --- Geocode cache ---
+
+func (s *MemoryStore) GetGeocode(key string) (types.GeocodeResult, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result types.GeocodeResult
+	doc, ok := s.getDoc(geocodeCacheCollection, HashString(key))
+	if !ok {
+		return result, false, nil
+	}
+	if err := decodeDoc(doc, &result); err != nil {
+		return result, false, fmt.Errorf("error converting geocode cache for %s: %w", key, err)
+	}
+	return result, true, nil
+}
+
+func (s *MemoryStore) SaveGeocode(key string, result types.GeocodeResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(geocodeCacheCollection, HashString(key), result, false)
+}
+
+// --- Cursors ---
+
+func (s *MemoryStore) GetCursor(name string) (types.Cursor, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cursor := types.Cursor{Name: name}
+	doc, ok := s.getDoc(cursorsCollection, name)
+	if !ok {
+		return cursor, false, nil
+	}
+	if err := decodeDoc(doc, &cursor); err != nil {
+		return cursor, false, fmt.Errorf("error converting cursor %s: %w", name, err)
+	}
+	cursor.Name = name
+	return cursor, true, nil
+}
+
+func (s *MemoryStore) SaveCursor(cursor types.Cursor) error {
+	if cursor.Name == "" {
+		return fmt.Errorf("cursor has no name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(cursorsCollection, cursor.Name, cursor, false)
+}
+
+// --- Backfill progress ---
+
+func (s *MemoryStore) GetBackfillProgress(feedURI string) (types.BackfillProgress, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var progress types.BackfillProgress
+	doc, ok := s.getDoc(backfillsCollection, HashString(feedURI))
+	if !ok {
+		return progress, false, nil
+	}
+	if err := decodeDoc(doc, &progress); err != nil {
+		return progress, false, fmt.Errorf("error converting backfill progress for %s: %w", feedURI, err)
+	}
+	return progress, true, nil
+}
+
+func (s *MemoryStore) SaveBackfillProgress(progress types.BackfillProgress) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(backfillsCollection, HashString(progress.FeedURI), progress, false)
+}
+
+// --- Feed registry ---
+
+func (s *MemoryStore) GetFeedConfigs() ([]types.FeedConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, docs := s.query(feedsCollection)
+	var feeds []types.FeedConfig
+	for i, doc := range docs {
+		var feed types.FeedConfig
+		if err := decodeDoc(doc, &feed); err != nil {
+			return nil, fmt.Errorf("error converting feed %s: %w", ids[i], err)
+		}
+		feed.ID = ids[i]
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+func (s *MemoryStore) SaveFeedConfig(feed types.FeedConfig) error {
+	if feed.ID == "" {
+		return fmt.Errorf("feed has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(feedsCollection, feed.ID, feed, false)
+}
+
+func (s *MemoryStore) DeleteFeedConfig(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections[feedsCollection], id)
+	return nil
+}
+
+// --- Webhooks ---
+
+func (s *MemoryStore) GetWebhooks() ([]types.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, docs := s.query(webhooksCollection)
+	var webhooks []types.Webhook
+	for i, doc := range docs {
+		var webhook types.Webhook
+		if err := decodeDoc(doc, &webhook); err != nil {
+			return nil, fmt.Errorf("error converting webhook %s: %w", ids[i], err)
+		}
+		webhook.ID = ids[i]
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func (s *MemoryStore) GetWebhook(id string) (types.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhook types.Webhook
+	doc, ok := s.getDoc(webhooksCollection, id)
+	if !ok {
+		return webhook, fmt.Errorf("error getting webhook %s: %w", id, ErrNotFound)
+	}
+	if err := decodeDoc(doc, &webhook); err != nil {
+		return webhook, fmt.Errorf("error converting webhook %s: %w", id, err)
+	}
+	webhook.ID = id
+	return webhook, nil
+}
+
+func (s *MemoryStore) SaveWebhook(webhook types.Webhook) error {
+	if webhook.ID == "" {
+		return fmt.Errorf("webhook has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(webhooksCollection, webhook.ID, webhook, false)
+}
+
+func (s *MemoryStore) DeleteWebhook(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections[webhooksCollection], id)
+	return nil
+}
+
+func (s *MemoryStore) SaveWebhookDelivery(delivery types.WebhookDelivery) error {
+	if delivery.ID == "" {
+		return fmt.Errorf("webhook delivery has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(webhookDeliveriesCollection, delivery.ID, delivery, false)
+}
+
+func (s *MemoryStore) GetWebhookDelivery(id string) (types.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var delivery types.WebhookDelivery
+	doc, ok := s.getDoc(webhookDeliveriesCollection, id)
+	if !ok {
+		return delivery, fmt.Errorf("error getting webhook delivery %s: %w", id, ErrNotFound)
+	}
+	if err := decodeDoc(doc, &delivery); err != nil {
+		return delivery, fmt.Errorf("error converting webhook delivery %s: %w", id, err)
+	}
+	delivery.ID = id
+	return delivery, nil
+}
+
+func (s *MemoryStore) GetWebhookDeliveries(webhookID string, status types.DeliveryStatus, limit int) ([]types.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var filters []filter
+	if webhookID != "" {
+		filters = append(filters, filter{"webhookId", "==", webhookID})
+	}
+	if status != "" {
+		filters = append(filters, filter{"status", "==", status})
+	}
+	ids, docs := s.query(webhookDeliveriesCollection, filters...)
+	deliveries := make([]types.WebhookDelivery, 0, len(docs))
+	for i, doc := range docs {
+		var delivery types.WebhookDelivery
+		if err := decodeDoc(doc, &delivery); err != nil {
+			return nil, fmt.Errorf("error converting webhook delivery %s: %w", ids[i], err)
+		}
+		delivery.ID = ids[i]
+		deliveries = append(deliveries, delivery)
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt > deliveries[j].CreatedAt
+	})
+	if limit >= 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// --- API keys and audit log ---
+
+func (s *MemoryStore) GetAPIKeys() ([]types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, docs := s.query(apiKeysCollection)
+	var keys []types.APIKey
+	for i, doc := range docs {
+		var key types.APIKey
+		if err := decodeDoc(doc, &key); err != nil {
+			return nil, fmt.Errorf("error converting API key %s: %w", ids[i], err)
+		}
+		key.ID = ids[i]
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) GetAPIKey(id string) (types.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var key types.APIKey
+	doc, ok := s.getDoc(apiKeysCollection, id)
+	if !ok {
+		return key, fmt.Errorf("error getting API key %s: %w", id, ErrNotFound)
+	}
+	if err := decodeDoc(doc, &key); err != nil {
+		return key, fmt.Errorf("error converting API key %s: %w", id, err)
+	}
+	key.ID = id
+	return key, nil
+}
+
+func (s *MemoryStore) SaveAPIKey(key types.APIKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("API key has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(apiKeysCollection, key.ID, key, false)
+}
+
+func (s *MemoryStore) SaveAuditEntry(entry types.AuditEntry) error {
+	if entry.ID == "" {
+		return fmt.Errorf("audit entry has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(auditEntriesCollection, entry.ID, entry, false)
+}
+
+func (s *MemoryStore) GetAuditEntries(actor string, limit int) ([]types.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var filters []filter
+	if actor != "" {
+		filters = append(filters, filter{"actor", "==", actor})
+	}
+	ids, docs := s.query(auditEntriesCollection, filters...)
+	entries := make([]types.AuditEntry, 0, len(docs))
+	for i, doc := range docs {
+		var entry types.AuditEntry
+		if err := decodeDoc(doc, &entry); err != nil {
+			return nil, fmt.Errorf("error converting audit entry %s: %w", ids[i], err)
+		}
+		entry.ID = ids[i]
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At > entries[j].At
+	})
+	if limit >= 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// --- Provider usage ---
+
+func (s *MemoryStore) GetProviderUsage(day string) ([]types.ProviderUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, docs := s.query(providerUsageCollection, filter{"day", "==", day})
+	usage := make([]types.ProviderUsage, 0, len(docs))
+	for i, doc := range docs {
+		var u types.ProviderUsage
+		if err := decodeDoc(doc, &u); err != nil {
+			return nil, fmt.Errorf("error converting provider usage %s: %w", ids[i], err)
+		}
+		u.ID = ids[i]
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+func (s *MemoryStore) SaveProviderUsage(usage types.ProviderUsage) error {
+	if usage.ID == "" {
+		return fmt.Errorf("provider usage has no id")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setDoc(providerUsageCollection, usage.ID, usage, false)
+}
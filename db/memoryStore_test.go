@@ -0,0 +1,269 @@
+package db
+
+import (
+	"errors"
+	"go-firebird/types"
+	"testing"
+	"time"
+)
+
+func locationEntity(name string) types.Entity {
+	return types.Entity{Name: name, Type: "LOCATION"}
+}
+
+func saveTestSkeet(t *testing.T, s *MemoryStore, uid, timestamp string, entities ...types.Entity) []string {
+	t.Helper()
+	newLocations, err := s.SaveCompleteSkeet(types.SaveCompleteSkeetType{
+		NewSkeet:       types.Skeet{UID: uid, Content: "post " + uid, Timestamp: timestamp},
+		Classification: []float64{0.9, 0, 0, 0.1},
+		Entities:       entities,
+		Sentiment:      types.Sentiment{Score: -0.5, Magnitude: 1},
+	})
+	if err != nil {
+		t.Fatalf("SaveCompleteSkeet(%s): %v", uid, err)
+	}
+	return newLocations
+}
+
+func TestSaveCompleteSkeetInvalidLocations(t *testing.T) {
+	s := NewMemoryStore()
+
+	// "Nowhere" was geocoded before and had no result, "Paradise" is valid
+	invalidID, validID := HashString("Nowhere"), HashString("Paradise")
+	if err := s.UpdateLocationFields(invalidID, map[string]interface{}{
+		"locationName": "Nowhere", "newLocation": false, "lat": 0.0, "long": 0.0,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateLocationFields(validID, map[string]interface{}{
+		"locationName": "Paradise", "newLocation": false, "lat": 39.76, "long": -121.62, "formattedAddress": "Paradise, CA, USA",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	newLocations := saveTestSkeet(t, s, "at://skeet/1", "2025-01-01T10:00:00Z",
+		locationEntity("Nowhere"), locationEntity("Paradise"), locationEntity("Chico"), locationEntity("Chico"))
+
+	if len(newLocations) != 1 || newLocations[0] != "Chico" {
+		t.Errorf("new locations = %v, want [Chico]", newLocations)
+	}
+	if ok, _ := s.SkeetExists(HashString("at://skeet/1")); !ok {
+		t.Error("skeet was not saved")
+	}
+
+	cases := []struct {
+		location string
+		want     int
+	}{
+		{"Nowhere", 0},
+		{"Paradise", 1},
+		{"Chico", 1},
+	}
+	for _, c := range cases {
+		skeets, err := s.GetSkeetsSubCollection(HashString(c.location), "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z")
+		if err != nil {
+			t.Fatalf("GetSkeetsSubCollection(%s): %v", c.location, err)
+		}
+		if len(skeets) != c.want {
+			t.Errorf("%s has %d skeets in its subcollection, want %d", c.location, len(skeets), c.want)
+		}
+	}
+
+	chico, err := s.GetValidLocation(HashString("Chico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chico.LocationName != "Chico" || chico.Type != "LOCATION" {
+		t.Errorf("new location doc = %+v", chico)
+	}
+}
+
+func TestGetSkeetsSubCollectionRange(t *testing.T) {
+	s := NewMemoryStore()
+	timestamps := []string{
+		"2025-01-01T09:00:00Z",
+		"2025-01-01T10:00:00Z",
+		"2025-01-01T11:00:00Z",
+		"2025-01-01T12:00:00Z",
+	}
+	for _, ts := range timestamps {
+		saveTestSkeet(t, s, "at://skeet/"+ts, ts, locationEntity("Paradise"))
+	}
+	locationID := HashString("Paradise")
+
+	cases := []struct {
+		name       string
+		start, end string
+		want       int
+	}{
+		{"bounds are inclusive", "2025-01-01T10:00:00Z", "2025-01-01T11:00:00Z", 2},
+		{"everything", "1970-01-01T00:00:00Z", "2025-01-02T00:00:00Z", 4},
+		{"before the first", "2024-12-31T00:00:00Z", "2025-01-01T08:59:59Z", 0},
+		{"after the last", "2025-01-01T12:00:01Z", "2025-01-02T00:00:00Z", 0},
+		{"empty range", "2025-01-01T11:00:00Z", "2025-01-01T10:00:00Z", 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			skeets, err := s.GetSkeetsSubCollection(locationID, c.start, c.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(skeets) != c.want {
+				t.Fatalf("got %d skeets, want %d", len(skeets), c.want)
+			}
+			for _, sub := range skeets {
+				if sub.SkeetData.Timestamp < c.start || sub.SkeetData.Timestamp > c.end {
+					t.Errorf("skeet at %s is outside [%s, %s]", sub.SkeetData.Timestamp, c.start, c.end)
+				}
+				if sub.LocationName != "Paradise" {
+					t.Errorf("locationName = %q", sub.LocationName)
+				}
+			}
+		})
+	}
+
+	if _, err := s.GetSkeetsSubCollection(HashString("Elsewhere"), "1970-01-01T00:00:00Z", "2030-01-01T00:00:00Z"); err != nil {
+		t.Errorf("empty subcollection: %v", err)
+	}
+}
+
+func TestUpdateLocationFieldsMerges(t *testing.T) {
+	s := NewMemoryStore()
+	id := HashString("Paradise")
+
+	if err := s.UpdateLocationFields(id, map[string]interface{}{
+		"locationName":        "Paradise",
+		"formattedAddress":    "Paradise, CA, USA",
+		"latestSkeetsAmount":  4,
+		"latestDisasterCount": types.DisasterCount{types.Wildfire: 3, types.NonDisaster: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the changed keys of the nested map are sent, the others must be kept
+	if err := s.UpdateLocationFields(id, map[string]interface{}{
+		"latestSkeetsAmount":  6,
+		"latestDisasterCount": map[string]interface{}{string(types.Wildfire): 5},
+		"anomaly":             types.LocationAnomaly{Window: "6h", Observed: 5, Score: 4.2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	location, err := s.GetValidLocation(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.LocationName != "Paradise" || location.FormattedAddress != "Paradise, CA, USA" {
+		t.Errorf("untouched fields changed: %+v", location)
+	}
+	if location.LatestSkeetsAmount != 6 {
+		t.Errorf("latestSkeetsAmount = %d, want 6", location.LatestSkeetsAmount)
+	}
+	if got := location.LatestDisasterCount[types.Wildfire]; got != 5 {
+		t.Errorf("wildfire count = %d, want 5", got)
+	}
+	if got := location.LatestDisasterCount[types.NonDisaster]; got != 1 {
+		t.Errorf("non-disaster count = %d, want 1 to be kept by the merge", got)
+	}
+	if location.Anomaly.Score != 4.2 || location.Anomaly.Observed != 5 {
+		t.Errorf("anomaly = %+v", location.Anomaly)
+	}
+
+	// Valid locations are found by their formatted address
+	valid, err := s.GetValidLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(valid) != 1 || valid[0].ID != id {
+		t.Errorf("valid locations = %+v", valid)
+	}
+}
+
+func TestErrNotFound(t *testing.T) {
+	s := NewMemoryStore()
+
+	cases := []struct {
+		name string
+		get  func() error
+	}{
+		{"location", func() error { _, err := s.GetValidLocation("missing"); return err }},
+		{"disaster", func() error { _, err := s.GetDisasterByID("missing"); return err }},
+		{"webhook", func() error { _, err := s.GetWebhook("missing"); return err }},
+		{"webhook delivery", func() error { _, err := s.GetWebhookDelivery("missing"); return err }},
+		{"API key", func() error { _, err := s.GetAPIKey("missing"); return err }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.get()
+			if !errors.Is(err, ErrNotFound) {
+				t.Fatalf("error %v does not wrap ErrNotFound", err)
+			}
+			if err.Error() == ErrNotFound.Error() {
+				t.Errorf("error %q does not say what was missing", err)
+			}
+		})
+	}
+
+	if err := s.SaveDisasters([]types.DisasterData{{ID: "d1", DisasterType: types.Wildfire}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDisasterByID("d1"); err != nil {
+		t.Errorf("saved disaster: %v", err)
+	}
+}
+
+func TestGetOpenDisasters(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.SaveDisasters([]types.DisasterData{
+		{ID: "active", Status: types.Active},
+		{ID: "recovery", Status: types.Recovery},
+		{ID: "over", Status: types.Not_Active},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := s.GetOpenDisasters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(open) != 2 || open[0].ID != "active" || open[1].ID != "recovery" {
+		t.Errorf("open disasters = %+v, want active and recovery", open)
+	}
+}
+
+func TestGetDisastersEndedSince(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.SaveDisasters([]types.DisasterData{
+		{ID: "active", Status: types.Active},
+		{ID: "ended-today", Status: types.Not_Active, EndedAt: "2025-01-07T06:00:00Z"},
+		{ID: "ended-at-since", Status: types.Not_Active, EndedAt: "2025-01-01T00:00:00Z"},
+		{ID: "ended-last-year", Status: types.Not_Active, EndedAt: "2024-12-01T00:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ended, err := s.GetDisastersEndedSince(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ended) != 2 || ended[0].ID != "ended-at-since" || ended[1].ID != "ended-today" {
+		t.Errorf("ended disasters = %+v, want ended-at-since and ended-today", ended)
+	}
+}
+
+func TestGetLocationsByIDs(t *testing.T) {
+	s := NewMemoryStore()
+	for _, name := range []string{"Paradise", "Chico"} {
+		if err := s.UpdateLocationFields(HashString(name), map[string]interface{}{"locationName": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	locations, err := s.GetLocationsByIDs([]string{HashString("Chico"), "missing", HashString("Paradise")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 2 || locations[0].LocationName != "Chico" || locations[0].ID != HashString("Chico") || locations[1].LocationName != "Paradise" {
+		t.Errorf("locations = %+v, want Chico and Paradise", locations)
+	}
+}
@@ -37,9 +37,12 @@ func SaveCompleteSkeet(client *firestore.Client, data types.SaveCompleteSkeetTyp
 		"displayName":     data.NewSkeet.DisplayName,
 		"uid":             data.NewSkeet.UID,
 		"classification":  data.Classification,
+		"classifiedBy":    data.ClassifiedBy,
 		"entity_ADDRESS":  addresses,
 		"entity_LOCATION": locations,
 		"sentiment":       data.Sentiment,
+		"likeCount":       data.NewSkeet.LikeCount,
+		"repostCount":     data.NewSkeet.RepostCount,
 	}
 
 	hashedSkeetID := HashString(data.NewSkeet.UID)
@@ -167,6 +170,19 @@ func SaveCompleteSkeet(client *firestore.Client, data types.SaveCompleteSkeetTyp
 	return newLocationNames, nil
 }
 
+// SkeetExists checks whether a skeet document with the given hashed ID is already saved.
+func SkeetExists(client *firestore.Client, hashedSkeetID string) (bool, error) {
+	ctx := context.Background()
+	_, err := client.Collection("skeets").Doc(hashedSkeetID).Get(ctx)
+	if err == nil {
+		return true, nil
+	}
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	return false, err
+}
+
 // ReadSkeets retrieves and prints all skeets from Firestore.
 func ReadSkeets(client *firestore.Client) {
 	ctx := context.Background()
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"errors"
+	"go-firebird/types"
+	"time"
+)
+
+// ErrNotFound is wrapped by lookups of a single document that doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Store is everything the rest of the service needs from the database:
+// skeets, locations, the skeetIds subcollection under each location, and disasters.
+// FirestoreStore is the production implementation, MemoryStore is used for tests and offline runs.
+type Store interface {
+	// Skeets
+	SkeetExists(hashedSkeetID string) (bool, error)
+	SaveCompleteSkeet(data types.SaveCompleteSkeetType) ([]string, error)
+	DeleteAllTestSkeets() (int, error)
+	EachSkeet(since, until string, fn func(types.SkeetRecord) error) error
+
+	// Locations
+	GetValidLocations() ([]types.LocationData, error)
+	EachValidLocation(fn func(types.LocationData) error) error
+	EachLocation(fn func(types.LocationData) error) error // every location, geocoded or not
+	GetValidLocation(locationDocID string) (types.LocationData, error)
+	GetLocationsByIDs(locationDocIDs []string) ([]types.LocationData, error) // in one read, missing ones are left out
+	GetNewLocations() ([]types.LocationData, error)
+	GetTopLocationsBySkeetAmount(limit int) ([]types.LocationData, error)
+	GetLocationsForDisasterCheck(sentimentThreshold float32, regions []types.Region) ([]types.LocationData, error)
+	InitLocationSentiment(locationDocID string, newAvgSentiment types.AvgLocationSentiment) error
+	UpdateLocSentimentTimestampWithData(locationData types.LocationData, locationDocID, newTime string) error
+	AddNewLocSentimentAvg(locationDocID string, updatedList []types.AvgLocationSentiment) error
+	UpdateLocationSentimentList(locationID string, updatedList []types.AvgLocationSentiment) error
+	UpdateLocationFields(locationID string, fieldsToUpdate map[string]interface{}) error
+	UpdateLocationDoc(locationID string, locationData types.LocationData) error
+	ReplaceLocationDisasterCounts(locationID string, location types.LocationData) error
+	UpdateLocationWindows(locationID string, windows map[string]types.LocationWindow) error
+
+	// Location skeet subcollection (locations/{id}/skeetIds)
+	GetSkeetsSubCollection(locationDocID string, start, end string) ([]types.SkeetSubDoc, error)
+	GetLatestSkeetInSubCollection(locationDocID string) (types.SkeetSubDoc, error)
+
+	// Disasters
+	SaveDisasters(disasters []types.DisasterData) error
+	GetAllDisasters() ([]types.DisasterData, error)
+	GetOpenDisasters() ([]types.DisasterData, error)                      // Active and Recovery only
+	GetDisastersEndedSince(since time.Time) ([]types.DisasterData, error) // Not_Active with EndedAt at or after since
+	EachDisaster(fn func(types.DisasterData) error) error
+	GetDisasterByID(disasterID string) (types.DisasterData, error)
+
+	// Detection runs, see processor.RunDisasterDetection
+	SaveDetectionRun(run types.DetectionRun) error
+	GetDetectionRuns(limit int) ([]types.DetectionRun, error)
+
+	// Geocode cache, keyed by normalized location name (see geocode.CachedGeocoder)
+	GetGeocode(key string) (types.GeocodeResult, bool, error)
+	SaveGeocode(key string, result types.GeocodeResult) error
+
+	// Cursors of long-running readers, keyed by name
+	GetCursor(name string) (types.Cursor, bool, error)
+	SaveCursor(cursor types.Cursor) error
+
+	// Feed backfill progress, keyed by feed URI
+	GetBackfillProgress(feedURI string) (types.BackfillProgress, bool, error)
+	SaveBackfillProgress(progress types.BackfillProgress) error
+
+	// Feed registry, keyed by feed ID
+	GetFeedConfigs() ([]types.FeedConfig, error)
+	SaveFeedConfig(feed types.FeedConfig) error
+	DeleteFeedConfig(id string) error
+
+	// Webhook subscriptions and their deliveries, see package webhooks
+	GetWebhooks() ([]types.Webhook, error)
+	GetWebhook(id string) (types.Webhook, error)
+	SaveWebhook(webhook types.Webhook) error
+	DeleteWebhook(id string) error
+	SaveWebhookDelivery(delivery types.WebhookDelivery) error
+	GetWebhookDelivery(id string) (types.WebhookDelivery, error)
+	GetWebhookDeliveries(webhookID string, status types.DeliveryStatus, limit int) ([]types.WebhookDelivery, error)
+
+	// API keys and the audit log of operator and admin requests, see package auth
+	GetAPIKeys() ([]types.APIKey, error)
+	GetAPIKey(id string) (types.APIKey, error)
+	SaveAPIKey(key types.APIKey) error
+	SaveAuditEntry(entry types.AuditEntry) error
+	GetAuditEntries(actor string, limit int) ([]types.AuditEntry, error)
+
+	// Daily usage of the paid upstream APIs, see package limits
+	GetProviderUsage(day string) ([]types.ProviderUsage, error)
+	SaveProviderUsage(usage types.ProviderUsage) error
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/api/iterator"
+	"log"
+)
+
+// EachValidLocation calls fn for every valid location (see GetValidLocations) as it is read, without
+// loading the whole collection. It stops at the first error fn returns.
+func EachValidLocation(client *firestore.Client, fn func(types.LocationData) error) error {
+	return eachLocation(client.Collection("locations").Where("formattedAddress", "!=", ""), fn)
+}
+
+// EachLocation is EachValidLocation over the whole collection, including locations that were never geocoded.
+func EachLocation(client *firestore.Client, fn func(types.LocationData) error) error {
+	return eachLocation(client.Collection("locations").Query, fn)
+}
+
+func eachLocation(query firestore.Query, fn func(types.LocationData) error) error {
+	iter := query.Documents(context.Background())
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error iterating locations: %w", err)
+		}
+
+		var location types.LocationData
+		if err := doc.DataTo(&location); err != nil {
+			log.Printf("Warning: Error converting document %s to LocationData: %v. Skipping.", doc.Ref.ID, err)
+			continue
+		}
+		location.ID = doc.Ref.ID
+		location.MigrateDisasterCounts()
+		if err := fn(location); err != nil {
+			return err
+		}
+	}
+}
+
+// EachDisaster calls fn for every disaster as it is read. It stops at the first error fn returns.
+func EachDisaster(client *firestore.Client, fn func(types.DisasterData) error) error {
+	iter := client.Collection(disastersCollection).Documents(context.Background())
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error iterating disasters collection: %w", err)
+		}
+
+		var disaster types.DisasterData
+		if err := doc.DataTo(&disaster); err != nil {
+			log.Printf("Warning: Error converting document %s to DisasterData: %v. Skipping.", doc.Ref.ID, err)
+			continue
+		}
+		disaster.ID = doc.Ref.ID
+		disaster.MigrateDisasterCounts()
+		if err := fn(disaster); err != nil {
+			return err
+		}
+	}
+}
+
+// skeetEntities are the entity fields of a skeet document, see SaveCompleteSkeet.
+type skeetEntities struct {
+	Addresses []types.Entity `firestore:"entity_ADDRESS"`
+	Locations []types.Entity `firestore:"entity_LOCATION"`
+}
+
+// EachSkeet calls fn for every skeet with a timestamp in [since, until), in timestamp order. Either bound
+// may be empty. Skeets are read queryChunkSize at a time, each page starting after the last document of
+// the previous one, so no query runs long on a large collection. It stops at the first error fn returns.
+func EachSkeet(client *firestore.Client, since, until string, fn func(types.SkeetRecord) error) error {
+	ctx := context.Background()
+	query := client.Collection("skeets").Where("timestamp", ">=", since)
+	if until != "" {
+		query = query.Where("timestamp", "<", until)
+	}
+	query = query.OrderBy("timestamp", firestore.Asc).Limit(queryChunkSize)
+
+	var last *firestore.DocumentSnapshot
+	for {
+		page := query
+		if last != nil {
+			page = query.StartAfter(last)
+		}
+		docs, err := page.Documents(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("error reading skeets: %w", err)
+		}
+
+		for _, doc := range docs {
+			record := types.SkeetRecord{ID: doc.Ref.ID}
+			var entities skeetEntities
+			if err := doc.DataTo(&record.Skeet); err != nil {
+				log.Printf("Warning: Error converting document %s to Skeet: %v. Skipping.", doc.Ref.ID, err)
+				continue
+			}
+			if err := doc.DataTo(&entities); err != nil {
+				log.Printf("Warning: Error reading entities of skeet %s: %v", doc.Ref.ID, err)
+			}
+			record.Addresses, record.Locations = entities.Addresses, entities.Locations
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if len(docs) < queryChunkSize {
+			return nil
+		}
+		last = docs[len(docs)-1]
+	}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+)
+
+const providerUsageCollection = "providerUsage"
+
+// GetProviderUsage returns every provider's usage on a day (2006-01-02).
+func GetProviderUsage(client *firestore.Client, day string) ([]types.ProviderUsage, error) {
+	ctx := context.Background()
+	docs, err := client.Collection(providerUsageCollection).Where("day", "==", day).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error getting provider usage of %s: %w", day, err)
+	}
+	usage := make([]types.ProviderUsage, 0, len(docs))
+	for _, doc := range docs {
+		var u types.ProviderUsage
+		if err := doc.DataTo(&u); err != nil {
+			return nil, fmt.Errorf("error converting provider usage %s: %w", doc.Ref.ID, err)
+		}
+		u.ID = doc.Ref.ID
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+func SaveProviderUsage(client *firestore.Client, usage types.ProviderUsage) error {
+	if usage.ID == "" {
+		return fmt.Errorf("provider usage has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(providerUsageCollection).Doc(usage.ID).Set(ctx, usage)
+	return err
+}
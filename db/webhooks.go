@@ -0,0 +1,134 @@
+package db
+
+import (
+	"cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"go-firebird/types"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	webhooksCollection          = "webhooks"
+	webhookDeliveriesCollection = "webhookDeliveries"
+)
+
+func GetWebhooks(client *firestore.Client) ([]types.Webhook, error) {
+	ctx := context.Background()
+	var webhooks []types.Webhook
+
+	iter := client.Collection(webhooksCollection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error iterating webhooks: %w", err)
+		}
+
+		var webhook types.Webhook
+		if err := doc.DataTo(&webhook); err != nil {
+			return nil, fmt.Errorf("error converting webhook %s: %w", doc.Ref.ID, err)
+		}
+		webhook.ID = doc.Ref.ID
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func GetWebhook(client *firestore.Client, id string) (types.Webhook, error) {
+	ctx := context.Background()
+	var webhook types.Webhook
+
+	doc, err := client.Collection(webhooksCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return webhook, fmt.Errorf("error getting webhook %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return webhook, fmt.Errorf("error getting webhook %s: %w", id, err)
+	}
+	if err := doc.DataTo(&webhook); err != nil {
+		return webhook, fmt.Errorf("error converting webhook %s: %w", id, err)
+	}
+	webhook.ID = id
+	return webhook, nil
+}
+
+func SaveWebhook(client *firestore.Client, webhook types.Webhook) error {
+	if webhook.ID == "" {
+		return fmt.Errorf("webhook has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(webhooksCollection).Doc(webhook.ID).Set(ctx, webhook)
+	return err
+}
+
+// DeleteWebhook deletes the webhook, its deliveries are kept.
+func DeleteWebhook(client *firestore.Client, id string) error {
+	ctx := context.Background()
+	_, err := client.Collection(webhooksCollection).Doc(id).Delete(ctx)
+	return err
+}
+
+func SaveWebhookDelivery(client *firestore.Client, delivery types.WebhookDelivery) error {
+	if delivery.ID == "" {
+		return fmt.Errorf("webhook delivery has no id")
+	}
+	ctx := context.Background()
+	_, err := client.Collection(webhookDeliveriesCollection).Doc(delivery.ID).Set(ctx, delivery)
+	return err
+}
+
+func GetWebhookDelivery(client *firestore.Client, id string) (types.WebhookDelivery, error) {
+	ctx := context.Background()
+	var delivery types.WebhookDelivery
+
+	doc, err := client.Collection(webhookDeliveriesCollection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return delivery, fmt.Errorf("error getting webhook delivery %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return delivery, fmt.Errorf("error getting webhook delivery %s: %w", id, err)
+	}
+	if err := doc.DataTo(&delivery); err != nil {
+		return delivery, fmt.Errorf("error converting webhook delivery %s: %w", id, err)
+	}
+	delivery.ID = id
+	return delivery, nil
+}
+
+// GetWebhookDeliveries returns the most recent deliveries first. An empty webhookID or deliveryStatus
+// doesn't filter, a negative limit returns everything.
+func GetWebhookDeliveries(client *firestore.Client, webhookID string, deliveryStatus types.DeliveryStatus, limit int) ([]types.WebhookDelivery, error) {
+	ctx := context.Background()
+	query := client.Collection(webhookDeliveriesCollection).Query
+	if webhookID != "" {
+		query = query.Where("webhookId", "==", webhookID)
+	}
+	if deliveryStatus != "" {
+		query = query.Where("status", "==", deliveryStatus)
+	}
+	query = query.OrderBy("createdAt", firestore.Desc)
+	if limit >= 0 {
+		query = query.Limit(limit)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook deliveries: %w", err)
+	}
+	deliveries := make([]types.WebhookDelivery, 0, len(docs))
+	for _, doc := range docs {
+		var delivery types.WebhookDelivery
+		if err := doc.DataTo(&delivery); err != nil {
+			return nil, fmt.Errorf("error converting webhook delivery %s: %w", doc.Ref.ID, err)
+		}
+		delivery.ID = doc.Ref.ID
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,94 @@
+package detection
+
+import (
+	"go-firebird/types"
+	"math"
+	"time"
+)
+
+const (
+	// Default DetectionConfig.AnomalyThreshold: a location is bursting when the chance of its short window
+	// volume under its baseline is below 10^-anomalyThreshold
+	anomalyThreshold = 3.0
+
+	// Older history counts less towards the baseline rate, halving every baselineHalfLife
+	baselineHalfLife = 7 * 24 * time.Hour
+
+	// Prior for the baseline, as if the location had been seen for priorHours with priorRate disaster
+	// skeets per hour. Keeps a small town with no history from bursting on a single post.
+	priorRate  = 0.02
+	priorHours = 24.0
+)
+
+// ScoreBurst compares the disaster skeets in a location's short window to the rate the location's own
+// history predicts, treating the count as Poisson.
+//
+// The baseline comes from the avgSentimentList history: every pair of consecutive entries gives the
+// disaster skeets posted between them. Intervals ending inside the window are left out so the burst
+// doesn't raise its own baseline, and older intervals are weighted down exponentially (EWMA).
+func ScoreBurst(history []types.AvgLocationSentiment, window types.LocationWindow, windowDuration time.Duration, now time.Time) types.LocationAnomaly {
+	windowStart := now.Add(-windowDuration)
+
+	weightedCount := priorRate * priorHours
+	weightedHours := priorHours
+	for i := 1; i < len(history); i++ {
+		start, err1 := time.Parse(time.RFC3339, history[i-1].TimeStamp)
+		end, err2 := time.Parse(time.RFC3339, history[i].TimeStamp)
+		if err1 != nil || err2 != nil || !end.After(start) || end.After(windowStart) {
+			continue
+		}
+		count := history[i].DisasterCount.DisasterTotal() - history[i-1].DisasterCount.DisasterTotal()
+		if count < 0 {
+			continue // counts were recomputed in between
+		}
+		age := now.Sub(end)
+		weight := math.Pow(0.5, float64(age)/float64(baselineHalfLife))
+		weightedCount += weight * float64(count)
+		weightedHours += weight * end.Sub(start).Hours()
+	}
+
+	rate := weightedCount / weightedHours
+	expected := rate * windowDuration.Hours()
+	observed := window.DisasterCount.DisasterTotal()
+
+	return types.LocationAnomaly{
+		Window:       types.ShortWindow.Name,
+		Observed:     observed,
+		Expected:     expected,
+		BaselineRate: rate,
+		Score:        poissonSurprise(observed, expected),
+		Category:     window.DisasterCount.Dominant(),
+		UpdatedAt:    now.UTC().Format(time.RFC3339),
+	}
+}
+
+// poissonSurprise is -log10 P(X >= k) for X ~ Poisson(mu), 0 when k is not above the mean.
+func poissonSurprise(k int, mu float64) float64 {
+	if mu <= 0 || float64(k) <= mu {
+		return 0
+	}
+
+	// Sum the upper tail in log space, the terms only shrink from k on since k > mu
+	logTerm := func(i int) float64 {
+		lg, _ := math.Lgamma(float64(i) + 1)
+		return -mu + float64(i)*math.Log(mu) - lg
+	}
+	logTail := logTerm(k)
+	for i := k + 1; i < k+10000; i++ {
+		t := logTerm(i)
+		if t < logTail-40 {
+			break
+		}
+		logTail += math.Log1p(math.Exp(t - logTail))
+	}
+	return -logTail / math.Ln10
+}
+
+// isBurst reports whether the location's anomaly score flags a burst. scored is false for locations
+// that were never scored, those fall back to the fixed count threshold.
+func isBurst(loc *types.LocationData, cfg DetectionConfig) (burst bool, scored bool) {
+	if loc.Anomaly.UpdatedAt == "" {
+		return false, false
+	}
+	return loc.Anomaly.Score >= cfg.AnomalyThreshold && loc.Anomaly.Observed >= cfg.MinDisasterCount, true
+}
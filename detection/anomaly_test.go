@@ -0,0 +1,171 @@
+package detection
+
+import (
+	"go-firebird/types"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPoissonSurprise(t *testing.T) {
+	// Reference values are -log10 of the upper tail summed term by term in log space
+	cases := []struct {
+		name string
+		k    int
+		mu   float64
+		want float64
+	}{
+		{"zero baseline", 5, 0, 0},
+		{"negative baseline", 5, -1, 0},
+		{"nothing observed", 0, 2, 0},
+		{"at the mean", 10, 10, 0},
+		{"below the mean", 4, 10, 0},
+		{"one post on the prior", 1, 0.12, 0.946616},
+		{"three posts on the prior", 3, 0.12, 3.579576},
+		{"five posts on the prior", 5, 0.12, 6.726642},
+		{"just above the mean", 11, 10, 0.379905},
+		{"twice the mean", 20, 10, 2.461635},
+		{"large mean, slightly above", 1100, 1000, 3.016540},
+		{"large mean, far above", 1500, 1000, 48.501403},
+		{"tiny mean, far above", 100, 0.001, 457.970434},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := poissonSurprise(c.k, c.mu)
+			if math.IsNaN(got) || math.IsInf(got, 0) {
+				t.Fatalf("poissonSurprise(%d, %v) = %v", c.k, c.mu, got)
+			}
+			if math.Abs(got-c.want) > 1e-4*math.Max(1, c.want) {
+				t.Errorf("poissonSurprise(%d, %v) = %.6f, want %.6f", c.k, c.mu, got, c.want)
+			}
+		})
+	}
+
+	// More posts are never less surprising
+	previous := 0.0
+	for k := 0; k <= 2000; k += 50 {
+		got := poissonSurprise(k, 1000)
+		if got < previous {
+			t.Fatalf("poissonSurprise(%d, 1000) = %v dropped below %v", k, got, previous)
+		}
+		previous = got
+	}
+}
+
+func TestScoreBurst(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	at := func(hoursAgo float64, wildfires int) types.AvgLocationSentiment {
+		return types.AvgLocationSentiment{
+			TimeStamp:     now.Add(-time.Duration(hoursAgo * float64(time.Hour))).Format(time.RFC3339),
+			DisasterCount: types.DisasterCount{types.Wildfire: wildfires, types.NonDisaster: 100},
+		}
+	}
+	window := func(wildfires int) types.LocationWindow {
+		return types.LocationWindow{DisasterCount: types.DisasterCount{types.Wildfire: wildfires, types.NonDisaster: 7}}
+	}
+
+	cases := []struct {
+		name         string
+		history      []types.AvgLocationSentiment
+		observed     int
+		wantExpected float64
+		wantScore    float64 // checked when >= 0
+	}{
+		{
+			name:         "no history, prior only",
+			observed:     5,
+			wantExpected: priorRate * 6,
+			wantScore:    6.726642,
+		},
+		{
+			name:         "single entry, no interval yet",
+			history:      []types.AvgLocationSentiment{at(30, 50)},
+			observed:     5,
+			wantExpected: priorRate * 6,
+			wantScore:    6.726642,
+		},
+		{
+			name:         "single interval",
+			history:      []types.AvgLocationSentiment{at(30, 0), at(6, 24)},
+			observed:     3,
+			wantExpected: 3.023612,
+			wantScore:    0,
+		},
+		{
+			name:         "zero baseline, quiet history",
+			history:      []types.AvgLocationSentiment{at(54, 10), at(6, 10)},
+			observed:     4,
+			wantExpected: 0.040663,
+			wantScore:    -1,
+		},
+		{
+			name:         "large baseline, current equal to it",
+			history:      []types.AvgLocationSentiment{at(54, 0), at(6, 4800)},
+			observed:     396,
+			wantExpected: 396.726457,
+			wantScore:    0,
+		},
+		{
+			name:         "large baseline, burst",
+			history:      []types.AvgLocationSentiment{at(54, 0), at(6, 4800)},
+			observed:     500,
+			wantExpected: 396.726457,
+			wantScore:    -1,
+		},
+		{
+			name:         "counts recomputed in between are skipped",
+			history:      []types.AvgLocationSentiment{at(54, 30), at(30, 0), at(6, 24)},
+			observed:     3,
+			wantExpected: 3.023612,
+			wantScore:    0,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ScoreBurst(c.history, window(c.observed), types.ShortWindow.Duration, now)
+			if got.Observed != c.observed {
+				t.Errorf("observed = %d, want %d (non-disaster skeets must not count)", got.Observed, c.observed)
+			}
+			if math.Abs(got.Expected-c.wantExpected) > 1e-4*math.Max(1, c.wantExpected) {
+				t.Errorf("expected = %.6f, want %.6f", got.Expected, c.wantExpected)
+			}
+			if want := poissonSurprise(c.observed, got.Expected); got.Score != want {
+				t.Errorf("score = %v, want poissonSurprise = %v", got.Score, want)
+			}
+			if c.wantScore >= 0 && math.Abs(got.Score-c.wantScore) > 1e-4*math.Max(1, c.wantScore) {
+				t.Errorf("score = %.6f, want %.6f", got.Score, c.wantScore)
+			}
+			if c.wantScore < 0 && got.Score <= 0 {
+				t.Errorf("score = %v, want a burst", got.Score)
+			}
+			if got.Category != types.Wildfire || got.Window != types.ShortWindow.Name {
+				t.Errorf("anomaly = %+v", got)
+			}
+		})
+	}
+}
+
+func TestScoreBurstExcludesCurrentWindow(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	entry := func(hoursAgo float64, wildfires int) types.AvgLocationSentiment {
+		return types.AvgLocationSentiment{
+			TimeStamp:     now.Add(-time.Duration(hoursAgo * float64(time.Hour))).Format(time.RFC3339),
+			DisasterCount: types.DisasterCount{types.Wildfire: wildfires},
+		}
+	}
+	baseline := []types.AvgLocationSentiment{entry(78, 0), entry(30, 24)}
+	window := types.LocationWindow{DisasterCount: types.DisasterCount{types.Wildfire: 40}}
+
+	without := ScoreBurst(baseline, window, types.ShortWindow.Duration, now)
+
+	// The burst itself was already recorded by updates inside the window, it must not raise the baseline
+	withBurst := append(append([]types.AvgLocationSentiment{}, baseline...), entry(4, 44), entry(1, 64))
+	with := ScoreBurst(withBurst, window, types.ShortWindow.Duration, now)
+
+	if with.BaselineRate != without.BaselineRate || with.Expected != without.Expected {
+		t.Errorf("intervals inside the window changed the baseline: %.4f/h -> %.4f/h", without.BaselineRate, with.BaselineRate)
+	}
+	if with.Score != without.Score || with.Score < anomalyThreshold {
+		t.Errorf("score = %v, want %v and a burst", with.Score, without.Score)
+	}
+}
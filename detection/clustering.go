@@ -0,0 +1,178 @@
+package detection
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"os"
+	"strconv"
+)
+
+// Clustering modes
+const (
+	// ModeSeeded grows a cluster from every seed to everything reachable in steps of at most EpsKM
+	ModeSeeded = "seeded"
+	// ModeDBSCAN only grows clusters through core locations (MinPts locations within EpsKM) and drops
+	// seeds that end up as noise
+	ModeDBSCAN = "dbscan"
+)
+
+// Neighbor lookups
+const (
+	IndexGrid = "grid" // SpatialIndex
+	IndexScan = "scan" // check every location, O(n²) overall
+)
+
+const defaultMinPts = 3
+
+// ClusterConfig selects how locations are grouped into disasters.
+type ClusterConfig struct {
+	Mode   string  `json:"mode" yaml:"mode"`
+	EpsKM  float64 `json:"epsKm" yaml:"epsKm"`   // neighbor radius
+	MinPts int     `json:"minPts" yaml:"minPts"` // DBSCAN only, locations within EpsKM (itself included) to be a core location
+	Index  string  `json:"index" yaml:"index"`
+}
+
+// DefaultClusterConfig is the seeded clustering detection always used, on the grid index.
+func DefaultClusterConfig() ClusterConfig {
+	return ClusterConfig{Mode: ModeSeeded, EpsKM: distanceThresholdKM, MinPts: defaultMinPts, Index: IndexGrid}
+}
+
+// ClusterConfigFromEnv reads DETECTION_CLUSTERING (seeded or dbscan), DETECTION_EPS_KM and DETECTION_MIN_PTS.
+func ClusterConfigFromEnv() ClusterConfig {
+	cfg := DefaultClusterConfig()
+	if v := os.Getenv("DETECTION_CLUSTERING"); v != "" {
+		cfg.Mode = v
+	}
+	if f, err := strconv.ParseFloat(os.Getenv("DETECTION_EPS_KM"), 64); err == nil && f > 0 {
+		cfg.EpsKM = f
+	}
+	if n, err := strconv.Atoi(os.Getenv("DETECTION_MIN_PTS")); err == nil && n > 0 {
+		cfg.MinPts = n
+	}
+	return cfg.withDefaults()
+}
+
+func (cfg ClusterConfig) withDefaults() ClusterConfig {
+	if cfg.Mode != ModeDBSCAN {
+		if cfg.Mode != "" && cfg.Mode != ModeSeeded {
+			fmt.Printf("Warning: unknown clustering mode %q, using %s.\n", cfg.Mode, ModeSeeded)
+		}
+		cfg.Mode = ModeSeeded
+	}
+	if cfg.EpsKM <= 0 {
+		cfg.EpsKM = distanceThresholdKM
+	}
+	if cfg.MinPts <= 0 {
+		cfg.MinPts = defaultMinPts
+	}
+	if cfg.Index != IndexScan {
+		cfg.Index = IndexGrid
+	}
+	return cfg
+}
+
+// clusterSeeded runs a breadth first search from every seed not yet in a cluster. Locations already
+// in an earlier cluster are walked through but not added again.
+func clusterSeeded(locations []types.LocationData, seeds []int, finder neighborFinder, cfg ClusterConfig) [][]int {
+	var clusters [][]int
+	processed := make(map[int]bool)
+
+	for _, seed := range seeds {
+		if processed[seed] {
+			continue
+		}
+
+		fmt.Printf("Starting cluster analysis for seed: %s (%s)\n", locations[seed].LocationName, locations[seed].ID)
+		var cluster []int
+		queue := []int{seed}
+		visited := map[int]bool{seed: true}
+
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			if !processed[current] {
+				cluster = append(cluster, current)
+				processed[current] = true
+			}
+
+			for _, neighbor := range finder.neighbors(current, cfg.EpsKM) {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+
+		if len(cluster) > 0 {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters
+}
+
+// clusterDBSCAN is DBSCAN started from the seeds: a seed that is a core location (or next to one)
+// grows a cluster through the core locations, border locations join the first cluster that reaches
+// them. Clusters without a seed are never built, and seeds with no core location nearby are noise.
+func clusterDBSCAN(locations []types.LocationData, seeds []int, finder neighborFinder, cfg ClusterConfig) [][]int {
+	var clusters [][]int
+	assigned := make(map[int]bool)
+	neighborCache := make(map[int][]int)
+	neighborsOf := func(i int) []int {
+		if n, ok := neighborCache[i]; ok {
+			return n
+		}
+		n := finder.neighbors(i, cfg.EpsKM)
+		neighborCache[i] = n
+		return n
+	}
+	isCore := func(i int) bool {
+		return len(neighborsOf(i))+1 >= cfg.MinPts
+	}
+
+	for _, seed := range seeds {
+		if assigned[seed] {
+			continue
+		}
+
+		// A border seed starts from its first core neighbor
+		start := -1
+		if isCore(seed) {
+			start = seed
+		} else {
+			for _, n := range neighborsOf(seed) {
+				if isCore(n) && !assigned[n] {
+					start = n
+					break
+				}
+			}
+		}
+		if start == -1 {
+			fmt.Printf("Seed %s (%s) is noise, fewer than %d locations within %.0f km.\n",
+				locations[seed].LocationName, locations[seed].ID, cfg.MinPts, cfg.EpsKM)
+			continue
+		}
+
+		fmt.Printf("Starting cluster analysis for seed: %s (%s)\n", locations[seed].LocationName, locations[seed].ID)
+		cluster := []int{start}
+		assigned[start] = true
+		queue := []int{start}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if !isCore(current) {
+				continue // border location, part of the cluster but doesn't extend it
+			}
+			for _, n := range neighborsOf(current) {
+				if assigned[n] {
+					continue
+				}
+				assigned[n] = true
+				cluster = append(cluster, n)
+				queue = append(queue, n)
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
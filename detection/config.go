@@ -0,0 +1,277 @@
+package detection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-firebird/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultProfilesPath = "./data/detection.yaml"
+	// DefaultProfile is the profile used when neither the config file nor DETECTION_PROFILE picks one,
+	// the thresholds detection always had, limited to the continental US
+	DefaultProfile = "us-conservative"
+)
+
+// SeverityThresholds are the values at which each severity factor reaches medium, high and critical,
+// and how much each factor weighs, see ScoreSeverity.
+type SeverityThresholds struct {
+	MediumSentiment    float32         `json:"mediumSentiment" yaml:"mediumSentiment"`
+	HighSentiment      float32         `json:"highSentiment" yaml:"highSentiment"`
+	CriticalSentiment  float32         `json:"criticalSentiment" yaml:"criticalSentiment"`
+	MediumSkeets       int             `json:"mediumSkeets" yaml:"mediumSkeets"`
+	HighSkeets         int             `json:"highSkeets" yaml:"highSkeets"`
+	CriticalSkeets     int             `json:"criticalSkeets" yaml:"criticalSkeets"`
+	MediumLocations    int             `json:"mediumLocations" yaml:"mediumLocations"`
+	HighLocations      int             `json:"highLocations" yaml:"highLocations"`
+	CriticalLocations  int             `json:"criticalLocations" yaml:"criticalLocations"`
+	MediumGrowth       float64         `json:"mediumGrowth" yaml:"mediumGrowth"`
+	HighGrowth         float64         `json:"highGrowth" yaml:"highGrowth"`
+	CriticalGrowth     float64         `json:"criticalGrowth" yaml:"criticalGrowth"`
+	MediumEngagement   int             `json:"mediumEngagement" yaml:"mediumEngagement"`
+	HighEngagement     int             `json:"highEngagement" yaml:"highEngagement"`
+	CriticalEngagement int             `json:"criticalEngagement" yaml:"criticalEngagement"`
+	Weights            SeverityWeights `json:"weights" yaml:"weights"`
+}
+
+// DetectionConfig holds every knob of a detection run.
+type DetectionConfig struct {
+	Profile     string `json:"profile" yaml:"-"`
+	Description string `json:"description,omitempty" yaml:"description"`
+
+	// Only locations inside one of the regions are checked, every location when empty
+	Regions []types.Region `json:"regions" yaml:"regions"`
+
+	// Locations whose short window sentiment is above this are not fetched
+	CandidateSentiment float32 `json:"candidateSentiment" yaml:"candidateSentiment"`
+	// A seed needs a short window sentiment at or below this...
+	SeedSentiment float32 `json:"seedSentiment" yaml:"seedSentiment"`
+	// ...and this many skeets in a disaster category (or disaster skeets in a burst)
+	MinDisasterCount int `json:"minDisasterCount" yaml:"minDisasterCount"`
+	// ...and, once the location has a burst score, a score of at least this, see ScoreBurst
+	AnomalyThreshold float64 `json:"anomalyThreshold" yaml:"anomalyThreshold"`
+
+	Clustering ClusterConfig      `json:"clustering" yaml:"clustering"`
+	Severity   SeverityThresholds `json:"severity" yaml:"severity"`
+}
+
+// ContinentalUS is the box detection was limited to before regions were configurable.
+var ContinentalUS = types.Region{Name: "continental-us", MinLat: 24, MaxLat: 50, MinLon: -125, MaxLon: -66}
+
+// baseConfig has the default thresholds and no region. Profiles start from it.
+func baseConfig() DetectionConfig {
+	return DetectionConfig{
+		CandidateSentiment: 0.0,
+		SeedSentiment:      sentimentThreshold,
+		MinDisasterCount:   minDisasterCount,
+		AnomalyThreshold:   anomalyThreshold,
+		Clustering:         ClusterConfigFromEnv(),
+		Severity: SeverityThresholds{
+			MediumSentiment:    mediumSentThreshold,
+			HighSentiment:      highSentThreshold,
+			CriticalSentiment:  critSentThreshold,
+			MediumSkeets:       mediumSkeetThreshold,
+			HighSkeets:         highSkeetThreshold,
+			CriticalSkeets:     critSkeetThreshold,
+			MediumLocations:    mediumLocCountThreshold,
+			HighLocations:      highLocCountThreshold,
+			CriticalLocations:  critLocCountThreshold,
+			MediumGrowth:       mediumGrowthThreshold,
+			HighGrowth:         highGrowthThreshold,
+			CriticalGrowth:     critGrowthThreshold,
+			MediumEngagement:   mediumEngagementThreshold,
+			HighEngagement:     highEngagementThreshold,
+			CriticalEngagement: critEngagementThreshold,
+			Weights:            defaultSeverityWeights(),
+		},
+	}
+}
+
+// DefaultConfig is the built in DefaultProfile.
+func DefaultConfig() DetectionConfig {
+	cfg := baseConfig()
+	cfg.Profile = DefaultProfile
+	cfg.Description = "Default thresholds, continental US only"
+	cfg.Regions = []types.Region{ContinentalUS}
+	return cfg
+}
+
+// Validate fills in defaults and checks the config is usable.
+func (cfg *DetectionConfig) Validate() error {
+	for _, r := range cfg.Regions {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.SeedSentiment < -1 || cfg.SeedSentiment > 1 || cfg.CandidateSentiment < -1 || cfg.CandidateSentiment > 1 {
+		return errors.New("sentiment thresholds must be between -1 and 1")
+	}
+	if cfg.SeedSentiment > cfg.CandidateSentiment {
+		return fmt.Errorf("seedSentiment %.2f is above candidateSentiment %.2f, seeds would never be fetched", cfg.SeedSentiment, cfg.CandidateSentiment)
+	}
+	if cfg.MinDisasterCount < 1 {
+		return errors.New("minDisasterCount must be at least 1")
+	}
+	if cfg.AnomalyThreshold <= 0 {
+		return errors.New("anomalyThreshold must be positive")
+	}
+	if err := cfg.Severity.validate(); err != nil {
+		return err
+	}
+	if cfg.Clustering.Mode != "" && cfg.Clustering.Mode != ModeSeeded && cfg.Clustering.Mode != ModeDBSCAN {
+		return fmt.Errorf("unknown clustering mode %q, expected %s or %s", cfg.Clustering.Mode, ModeSeeded, ModeDBSCAN)
+	}
+	if cfg.Clustering.EpsKM < 0 || cfg.Clustering.MinPts < 0 {
+		return errors.New("clustering epsKm and minPts can't be negative")
+	}
+	cfg.Clustering = cfg.Clustering.withDefaults()
+	return nil
+}
+
+// Profiles are the named detection configs.
+type Profiles struct {
+	Default string
+	byName  map[string]DetectionConfig
+}
+
+// ProfilesPath returns DETECTION_CONFIG or the bundled ./data/detection.yaml.
+func ProfilesPath() string {
+	if p := os.Getenv("DETECTION_CONFIG"); p != "" {
+		return p
+	}
+	return defaultProfilesPath
+}
+
+// InitProfiles loads the profiles from ProfilesPath and picks DETECTION_PROFILE as the default if set.
+// A missing file is not an error, only the built in DefaultProfile is available then.
+func InitProfiles() (*Profiles, error) {
+	path := ProfilesPath()
+	profiles, err := LoadProfiles(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("No detection profiles at %s, using %s\n", path, DefaultProfile)
+		profiles = &Profiles{Default: DefaultProfile, byName: map[string]DetectionConfig{DefaultProfile: DefaultConfig()}}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if name := os.Getenv("DETECTION_PROFILE"); name != "" {
+		if _, ok := profiles.byName[name]; !ok {
+			return nil, fmt.Errorf("DETECTION_PROFILE %q is not a profile in %s", name, path)
+		}
+		profiles.Default = name
+	}
+	return profiles, nil
+}
+
+// LoadProfiles reads a profiles file, JSON if the extension is .json and YAML otherwise. Every profile
+// starts from the default thresholds with no region, so it only lists what it changes.
+// The built in DefaultProfile is kept unless the file redefines it.
+func LoadProfiles(path string) (*Profiles, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make(map[string]DetectionConfig)
+	var defaultName string
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var file struct {
+			Default  string                     `json:"default"`
+			Profiles map[string]json.RawMessage `json:"profiles"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for name, raw := range file.Profiles {
+			cfg := baseConfig()
+			if err := json.Unmarshal(raw, &cfg); err != nil {
+				return nil, fmt.Errorf("%s: profile %s: %w", path, name, err)
+			}
+			decoded[name] = cfg
+		}
+		defaultName = file.Default
+	} else {
+		var file struct {
+			Default  string               `yaml:"default"`
+			Profiles map[string]yaml.Node `yaml:"profiles"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for name, node := range file.Profiles {
+			cfg := baseConfig()
+			if err := node.Decode(&cfg); err != nil {
+				return nil, fmt.Errorf("%s: profile %s: %w", path, name, err)
+			}
+			decoded[name] = cfg
+		}
+		defaultName = file.Default
+	}
+
+	profiles := &Profiles{Default: DefaultProfile, byName: map[string]DetectionConfig{DefaultProfile: DefaultConfig()}}
+	for name, cfg := range decoded {
+		cfg.Profile = name
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %s: %w", path, name, err)
+		}
+		profiles.byName[name] = cfg
+	}
+	if defaultName != "" {
+		if _, ok := profiles.byName[defaultName]; !ok {
+			return nil, fmt.Errorf("%s: default profile %q is not defined", path, defaultName)
+		}
+		profiles.Default = defaultName
+	}
+	return profiles, nil
+}
+
+// Get returns a copy of the named profile, the default one for "".
+func (p *Profiles) Get(name string) (DetectionConfig, bool) {
+	if name == "" {
+		name = p.Default
+	}
+	cfg, ok := p.byName[name]
+	if !ok {
+		return DetectionConfig{}, false
+	}
+	cfg.Regions = append([]types.Region(nil), cfg.Regions...)
+	return cfg, true
+}
+
+// List returns every profile sorted by name.
+func (p *Profiles) List() []DetectionConfig {
+	list := make([]DetectionConfig, 0, len(p.byName))
+	for name := range p.byName {
+		cfg, _ := p.Get(name)
+		list = append(list, cfg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Profile < list[j].Profile })
+	return list
+}
+
+// Resolve returns the named profile (the default one for "") with the JSON overrides applied on top,
+// e.g. {"regions": [...], "minDisasterCount": 5}. Fields not in overrides keep the profile's value.
+func (p *Profiles) Resolve(name string, overrides json.RawMessage) (DetectionConfig, error) {
+	cfg, ok := p.Get(name)
+	if !ok {
+		return DetectionConfig{}, fmt.Errorf("unknown detection profile %q", name)
+	}
+	if len(overrides) > 0 && string(overrides) != "null" {
+		profile := cfg.Profile
+		if err := json.Unmarshal(overrides, &cfg); err != nil {
+			return DetectionConfig{}, fmt.Errorf("invalid detection config: %w", err)
+		}
+		cfg.Profile = profile
+		if err := cfg.Validate(); err != nil {
+			return DetectionConfig{}, err
+		}
+	}
+	return cfg, nil
+}
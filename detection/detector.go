@@ -9,10 +9,11 @@ import (
 	"time"
 )
 
+// Defaults of the DetectionConfig thresholds, see baseConfig
 const (
 	sentimentThreshold  float32 = -0.05
-	minDisasterCount    int     = 3    // Min count in *any* disaster category to be a seed
-	distanceThresholdKM         = 50.0 // Max distance (km) to cluster locations
+	minDisasterCount    int     = 3    // Min count in *any* disaster category to be a seed, or disaster skeets in a burst
+	distanceThresholdKM         = 50.0 // Default max distance (km) to cluster locations, see ClusterConfig
 	earthRadiusKM               = 6371.0
 	// --- Severity Thresholds  ---
 
@@ -30,81 +31,78 @@ const (
 	mediumLocCountThreshold = 5
 	highLocCountThreshold   = 10
 	critLocCountThreshold   = 20
+
+	// Growth, disaster skeets per hour in the short window over the 24h average (at most 4)
+	mediumGrowthThreshold = 1.5
+	highGrowthThreshold   = 2.5
+	critGrowthThreshold   = 3.5
+
+	// Engagement, likes and reposts of the short window skeets
+	mediumEngagementThreshold = 50
+	highEngagementThreshold   = 250
+	critEngagementThreshold   = 1000
 )
 
+// DetectDisastersFromList finds disaster clusters with the DefaultConfig thresholds.
 func DetectDisastersFromList(locations []types.LocationData) ([]types.DisasterData, error) {
+	return DetectDisasters(locations, DefaultConfig())
+}
+
+// DetectDisasters finds the seed locations, groups them with their neighbors using cfg.Clustering and
+// turns every cluster with a dominant disaster type into a DisasterData.
+// The locations are expected to be in cfg.Regions already, see db.GetLocationsForDisasterCheck.
+func DetectDisasters(locations []types.LocationData, cfg DetectionConfig) ([]types.DisasterData, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid detection config: %w", err)
+	}
+	clustering := cfg.Clustering
 	var disasters []types.DisasterData
-	processedLocationIDs := make(map[string]bool)
 
-	// 1. Identify potential disaster "seeds"
-	var seeds []*types.LocationData
+	// Locations must have a ID to be processed
+	valid := make([]types.LocationData, 0, len(locations))
 	for i := range locations {
-		loc := &locations[i]
-
-		// --- Seed Criteria ---
-		// Location must have a ID to be processed
-		if loc.ID == "" {
-			fmt.Printf("Warning: Location at index %d (%s) missing ID, skipping.\n", i, loc.LocationName)
+		if locations[i].ID == "" {
+			fmt.Printf("Warning: Location at index %d (%s) missing ID, skipping.\n", i, locations[i].LocationName)
 			continue
 		}
+		valid = append(valid, locations[i])
+	}
 
-		// Check if sentiment is low AND at least one disaster count is significant
-		counts := loc.LatestDisasterCount
-		hasSignificantDisasterCount := counts.FireCount >= minDisasterCount ||
-			counts.HurricaneCount >= minDisasterCount ||
-			counts.EarthquakeCount >= minDisasterCount
-		// NOTE: exclude NonDisasterCount from seeding criteria
-
-		isPotentialSeed := loc.LatestSentiment <= sentimentThreshold && hasSignificantDisasterCount
-		if isPotentialSeed {
-			seeds = append(seeds, loc)
+	// 1. Identify potential disaster "seeds"
+	var seeds []int
+	for i := range valid {
+		if isSeed(&valid[i], cfg) {
+			seeds = append(seeds, i)
 		}
 	}
 
 	// 2. Cluster locations around seeds
-	for _, seed := range seeds {
-		if processedLocationIDs[seed.ID] {
-			continue
-		}
-
-		fmt.Printf("Starting cluster analysis for seed: %s (%s)\n", seed.LocationName, seed.ID)
-		clusterLocations := []*types.LocationData{}
-		queue := []*types.LocationData{seed}
-		clusterProcessedIDs := make(map[string]bool)
-		clusterProcessedIDs[seed.ID] = true
-
-		for len(queue) > 0 {
-			currentLoc := queue[0]
-			queue = queue[1:]
-
-			if !processedLocationIDs[currentLoc.ID] {
-				clusterLocations = append(clusterLocations, currentLoc)
-				processedLocationIDs[currentLoc.ID] = true
-			}
+	var finder neighborFinder
+	if clustering.Index == IndexScan {
+		finder = linearScan{locations: valid}
+	} else {
+		finder = NewSpatialIndex(valid, clustering.EpsKM)
+	}
+	var clusters [][]int
+	if clustering.Mode == ModeDBSCAN {
+		clusters = clusterDBSCAN(valid, seeds, finder, clustering)
+	} else {
+		clusters = clusterSeeded(valid, seeds, finder, clustering)
+	}
 
-			for i := range locations {
-				neighbor := &locations[i]
-				if neighbor.ID == "" || neighbor.ID == currentLoc.ID || clusterProcessedIDs[neighbor.ID] {
-					continue
-				}
-				dist := haversineDistance(currentLoc.Lat, currentLoc.Long, neighbor.Lat, neighbor.Long)
-				if dist <= distanceThresholdKM {
-					clusterProcessedIDs[neighbor.ID] = true
-					queue = append(queue, neighbor)
-				}
-			}
+	// 3. If cluster found, create DisasterData object
+	for _, members := range clusters {
+		clusterLocations := make([]*types.LocationData, len(members))
+		for i, m := range members {
+			clusterLocations[i] = &valid[m]
 		}
-
-		// 3. If cluster found, create DisasterData object
-		if len(clusterLocations) > 0 {
-			fmt.Printf("  Cluster found with %d locations.\n", len(clusterLocations))
-			clusterDisasterType := determineClusterDisasterType(clusterLocations)
-			if clusterDisasterType != types.NonDisaster {
-				disaster := createDisasterFromCluster(clusterLocations, clusterDisasterType)
-				disasters = append(disasters, disaster)
-			} else {
-				fmt.Printf("  Cluster around seed %s classified as NonDisaster based on counts, skipping disaster creation.\n", seed.ID)
-			}
+		fmt.Printf("  Cluster found with %d locations.\n", len(clusterLocations))
+		clusterDisasterType := determineClusterDisasterType(clusterLocations)
+		if clusterDisasterType != types.NonDisaster {
+			disaster := createDisasterFromCluster(clusterLocations, clusterDisasterType, cfg.Severity)
+			disasters = append(disasters, disaster)
+		} else {
+			fmt.Printf("  Cluster around seed %s classified as NonDisaster based on counts, skipping disaster creation.\n", clusterLocations[0].ID)
 		}
 	}
 
@@ -113,37 +111,46 @@ func DetectDisastersFromList(locations []types.LocationData) ([]types.DisasterDa
 	return disasters, nil
 }
 
+// isSeed checks the seed criteria: sentiment is low AND the disaster volume is significant, a burst
+// against the location's own baseline, or for locations that were never scored at least one count
+// above the minimum.
+func isSeed(loc *types.LocationData, cfg DetectionConfig) bool {
+	sentiment, counts := seedStats(loc)
+	hasSignificantDisasterCount, scored := isBurst(loc, cfg)
+	if !scored {
+		for category, count := range counts {
+			// NOTE: exclude NonDisaster from seeding criteria
+			if category != types.NonDisaster && count >= cfg.MinDisasterCount {
+				hasSignificantDisasterCount = true
+				break
+			}
+		}
+	}
+	return sentiment <= cfg.SeedSentiment && hasSignificantDisasterCount
+}
+
+// seedStats is the sentiment and counts a location is judged on: its short rolling window.
+// Locations get their windows on their first sentiment update, GetLocationsForDisasterCheck only
+// returns locations that have one.
+func seedStats(loc *types.LocationData) (float32, types.DisasterCount) {
+	window, _ := loc.Window(types.ShortWindow)
+	return window.AverageSentiment, window.DisasterCount
+}
+
 // analyzes the aggregated counts to find the dominant disaster type.
 func determineClusterDisasterType(cluster []*types.LocationData) types.Category {
 	var totalCounts types.DisasterCount
 	for _, loc := range cluster {
-		totalCounts.FireCount += loc.LatestDisasterCount.FireCount
-		totalCounts.HurricaneCount += loc.LatestDisasterCount.HurricaneCount
-		totalCounts.EarthquakeCount += loc.LatestDisasterCount.EarthquakeCount
-		// NOTE: don't need NonDisasterCount for determining the *disaster* type
+		_, counts := seedStats(loc)
+		totalCounts.Merge(counts)
 	}
 
-	// Find the category with the highest count
-	maxCount := 0
-	dominantType := types.NonDisaster // Default to non-disaster
-
-	if totalCounts.FireCount > maxCount {
-		maxCount = totalCounts.FireCount
-		dominantType = types.Wildfire
-	}
-	if totalCounts.HurricaneCount > maxCount {
-		maxCount = totalCounts.HurricaneCount
-		dominantType = types.Hurricane
-	}
-	if totalCounts.EarthquakeCount > maxCount {
-		dominantType = types.Earthquake
-	}
-
-	return dominantType
+	// Find the category with the highest count, NonDisaster is not a *disaster* type
+	return totalCounts.Dominant()
 }
 
 // aggregates data from clustered locations into a DisasterData object.
-func createDisasterFromCluster(cluster []*types.LocationData, clusterType types.Category) types.DisasterData {
+func createDisasterFromCluster(cluster []*types.LocationData, clusterType types.Category, severity SeverityThresholds) types.DisasterData {
 	if len(cluster) == 0 {
 		return types.DisasterData{}
 	}
@@ -155,7 +162,6 @@ func createDisasterFromCluster(cluster []*types.LocationData, clusterType types.
 		LocationCount: len(cluster),
 		DisasterType:  clusterType,
 		Status:        types.Active,
-		Severity:      types.Low,
 		BoundingBox: types.BoundingBox{
 			MinLat: cluster[0].Lat, MaxLat: cluster[0].Lat,
 			MinLon: cluster[0].Long, MaxLon: cluster[0].Long,
@@ -165,7 +171,7 @@ func createDisasterFromCluster(cluster []*types.LocationData, clusterType types.
 		ClusterCounts:     types.DisasterCount{},
 	}
 
-	var sumLat, sumLon, totalSentiment float64
+	var totalSentiment float64
 	var earliestFirstTime, latestLastTime time.Time
 	var firstTimestampSet, lastTimestampSet bool
 
@@ -192,27 +198,28 @@ func createDisasterFromCluster(cluster []*types.LocationData, clusterType types.
 			disaster.BoundingBox.MaxLon = loc.Long
 		}
 
-		// Sum for Centroid & Avg Sentiment
-		sumLat += loc.Lat
-		sumLon += loc.Long
-		totalSentiment += float64(loc.LatestSentiment)
+		// Sentiment and counts of the short window, what the locations were seeded and typed on
+		window, _ := loc.Window(types.ShortWindow)
+		totalSentiment += float64(window.AverageSentiment)
 
 		// Aggregate Counts
-		disaster.TotalSkeetsAmount += loc.LatestSkeetsAmount
-		disaster.ClusterCounts.FireCount += loc.LatestDisasterCount.FireCount
-		disaster.ClusterCounts.HurricaneCount += loc.LatestDisasterCount.HurricaneCount
-		disaster.ClusterCounts.EarthquakeCount += loc.LatestDisasterCount.EarthquakeCount
-		disaster.ClusterCounts.NonDisasterCount += loc.LatestDisasterCount.NonDisasterCount // Still aggregate this for info
+		disaster.TotalSkeetsAmount += window.SkeetsAmount
+		disaster.AllTimeSkeetsAmount += loc.LatestSkeetsAmount
+		disaster.AnomalyScore = math.Max(disaster.AnomalyScore, loc.Anomaly.Score)
+		disaster.ClusterCounts.Merge(window.DisasterCount) // NonDisaster is still aggregated for info
 
 		// Find earliest first timestamp and latest last timestamp (using robust parsing)
 		parseAndUpdateTimestamps(loc.FirstSkeetTimestamp, loc.LastSkeetTimestamp, loc.ID,
 			&earliestFirstTime, &latestLastTime, &firstTimestampSet, &lastTimestampSet)
 	}
 
-	// Calculate Centroid
+	// Calculate Centroid (weighted by skeets), hull and affected area
 	count := float64(len(cluster))
-	disaster.Lat = sumLat / count
-	disaster.Long = sumLon / count
+	centroid, hull, area := clusterGeometry(cluster)
+	disaster.Lat = centroid.Lat
+	disaster.Long = centroid.Long
+	disaster.Hull = hull
+	disaster.AreaKM2 = area
 
 	// Average Cluster Sentiment
 	if count > 0 {
@@ -227,18 +234,8 @@ func createDisasterFromCluster(cluster []*types.LocationData, clusterType types.
 		disaster.LastUpdate = latestLastTime.UTC().Format(time.RFC3339)
 	}
 
-	// set sev type
-	if disaster.ClusterSentiment < mediumSentThreshold {
-		disaster.Severity = types.Medium
-	}
-
-	if disaster.ClusterSentiment < highSentThreshold {
-		disaster.Severity = types.High
-	}
-
-	if disaster.ClusterSentiment < critSentThreshold {
-		disaster.Severity = types.Critical
-	}
+	// set sev type from sentiment, volume, spread, growth and engagement
+	ScoreSeverity(&disaster, cluster, severity)
 
 	// Sort LocationIDs for consistency
 	sort.Strings(disaster.LocationIDs)
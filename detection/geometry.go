@@ -0,0 +1,125 @@
+package detection
+
+import (
+	"go-firebird/types"
+	"math"
+	"sort"
+)
+
+// Every location is taken to affect the area within this many km, so a single location or a line of
+// them still has an area
+const affectedRadiusKM = 10.0
+
+// clusterGeometry returns the skeet weighted centroid, the convex hull and the approximate affected
+// area (km²) of a cluster.
+//
+// The area is the hull grown by affectedRadiusKM on every side: hull area + perimeter * r + πr².
+// Points are projected onto a flat plane around the centroid, fine at the scale of a cluster.
+// Longitudes are unwrapped around the first location so clusters across the antimeridian work.
+func clusterGeometry(cluster []*types.LocationData) (types.GeoPoint, []types.GeoPoint, float64) {
+	if len(cluster) == 0 {
+		return types.GeoPoint{}, nil, 0
+	}
+
+	// 1. Unwrap longitudes and compute the centroid, weighted by skeets (equal weights if none have any)
+	refLon := cluster[0].Long
+	lons := make([]float64, len(cluster))
+	var sumLat, sumLon, sumWeight float64
+	for i, loc := range cluster {
+		lons[i] = refLon + wrapLongitude(loc.Long-refLon)
+		sumWeight += float64(loc.LatestSkeetsAmount)
+	}
+	for i, loc := range cluster {
+		weight := float64(loc.LatestSkeetsAmount)
+		if sumWeight == 0 {
+			weight = 1
+		}
+		sumLat += loc.Lat * weight
+		sumLon += lons[i] * weight
+	}
+	if sumWeight == 0 {
+		sumWeight = float64(len(cluster))
+	}
+	centroid := types.GeoPoint{Lat: sumLat / sumWeight, Long: sumLon / sumWeight}
+
+	// 2. Project to km around the centroid
+	type point struct {
+		x, y float64
+		geo  types.GeoPoint
+	}
+	cosLat := math.Cos(centroid.Lat * math.Pi / 180)
+	points := make([]point, len(cluster))
+	for i, loc := range cluster {
+		points[i] = point{
+			x:   (lons[i] - centroid.Long) * kmPerDegree * cosLat,
+			y:   (loc.Lat - centroid.Lat) * kmPerDegree,
+			geo: types.GeoPoint{Lat: loc.Lat, Long: loc.Long},
+		}
+	}
+	centroid.Long = wrapLongitude(centroid.Long)
+
+	// 3. Convex hull, Andrew's monotone chain, counterclockwise
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].x != points[j].x {
+			return points[i].x < points[j].x
+		}
+		return points[i].y < points[j].y
+	})
+	unique := points[:1]
+	for _, p := range points[1:] {
+		last := unique[len(unique)-1]
+		if p.x != last.x || p.y != last.y {
+			unique = append(unique, p)
+		}
+	}
+	points = unique
+
+	cross := func(o, a, b point) float64 {
+		return (a.x-o.x)*(b.y-o.y) - (a.y-o.y)*(b.x-o.x)
+	}
+	var hull []point
+	if len(points) < 3 {
+		hull = points
+	} else {
+		for _, p := range points { // lower
+			for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		lower := len(hull) + 1
+		for i := len(points) - 2; i >= 0; i-- { // upper
+			p := points[i]
+			for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1] // the first point again
+	}
+
+	// 4. Area of the hull grown by affectedRadiusKM
+	// (a segment's perimeter is walked there and back, 2 * its length, which is what its buffer needs)
+	var area, perimeter float64
+	for i := range hull {
+		a, b := hull[i], hull[(i+1)%len(hull)]
+		area += a.x*b.y - b.x*a.y
+		perimeter += math.Hypot(b.x-a.x, b.y-a.y)
+	}
+	area = math.Abs(area)/2 + perimeter*affectedRadiusKM + math.Pi*affectedRadiusKM*affectedRadiusKM
+
+	geoHull := make([]types.GeoPoint, len(hull))
+	for i, p := range hull {
+		geoHull[i] = p.geo
+	}
+	return centroid, geoHull, math.Round(area*10) / 10
+}
+
+// wrapLongitude brings a longitude (or longitude difference) into [-180, 180).
+func wrapLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
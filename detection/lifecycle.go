@@ -0,0 +1,341 @@
+package detection
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// --- Matching a new cluster to an existing disaster ---
+
+	// Max distance (km) between bounding boxes when the clusters share no location
+	matchDistanceKM = distanceThresholdKM
+
+	// --- Status transitions ---
+
+	// Trends are measured over the detection runs in this window
+	trendWindow = 24 * time.Hour
+	// Active -> Recovery when the cluster was missed in this many runs in a row...
+	missesBeforeRecovery = 3
+	// ...or has not been detected for this long, so one noisy run doesn't wind a disaster down
+	undetectedBeforeRecovery = 12 * time.Hour
+	// Active -> Recovery when fewer new skeets than this came in during the trend window
+	minActiveGrowth = 5
+	// Recovery -> Active when at least this many new skeets came in during the trend window
+	reactivateGrowth = 15
+	// Active -> Recovery when the sentiment recovered this much from its worst point
+	sentimentRecovery float32 = 0.15
+	// Recovery -> Not_Active after this long without new skeets
+	inactiveAfter = 72 * time.Hour
+
+	// Observations kept on a disaster document
+	maxObservations = 60
+)
+
+// TrackDisasters matches the clusters found in this run against the disasters already stored,
+// updates matched disasters in place (keeping their ID, summary and history), and moves every
+// disaster through Active -> Recovery -> Not_Active. It returns every disaster that needs saving:
+// the detected ones, the ones whose status changed and the Active ones that were missed this run.
+//
+// existing are expected to be the open disasters, see db.Store.GetOpenDisasters.
+//
+// A cluster matches a disaster of the same type when they share a location, or when their bounding
+// boxes are within matchDistanceKM. Not_Active disasters are never matched, a flare up after that
+// is a new disaster.
+func TrackDisasters(existing []types.DisasterData, detected []types.DisasterData, now time.Time) []types.DisasterData {
+	nowStr := now.UTC().Format(time.RFC3339)
+
+	var open []*types.DisasterData
+	for i := range existing {
+		if existing[i].Status != types.Not_Active {
+			open = append(open, &existing[i])
+		}
+	}
+
+	// 1. Score every possible (cluster, disaster) pair and match greedily, best first
+	type candidate struct {
+		cluster, disaster int
+		overlap           float64
+		distance          float64
+	}
+	var candidates []candidate
+	for ci := range detected {
+		for di, d := range open {
+			if d.DisasterType != detected[ci].DisasterType {
+				continue
+			}
+			overlap := locationOverlap(detected[ci].LocationIDs, d.LocationIDs)
+			distance := boundingBoxDistance(detected[ci].BoundingBox, d.BoundingBox)
+			if overlap == 0 && distance > matchDistanceKM {
+				continue
+			}
+			candidates = append(candidates, candidate{ci, di, overlap, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].overlap != candidates[j].overlap {
+			return candidates[i].overlap > candidates[j].overlap
+		}
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	clusterMatched := make(map[int]bool)
+	disasterMatched := make(map[int]bool)
+	var out []types.DisasterData
+	for _, c := range candidates {
+		if clusterMatched[c.cluster] || disasterMatched[c.disaster] {
+			continue
+		}
+		clusterMatched[c.cluster] = true
+		disasterMatched[c.disaster] = true
+
+		d := open[c.disaster]
+		fmt.Printf("Cluster %d matched disaster %s (overlap %.2f, %.1f km apart)\n", c.cluster, d.ID, c.overlap, c.distance)
+		updated := mergeCluster(*d, detected[c.cluster], nowStr)
+		updateStatus(&updated, true, now)
+		out = append(out, updated)
+	}
+
+	// 2. Clusters that match nothing are new disasters
+	for ci := range detected {
+		if clusterMatched[ci] {
+			continue
+		}
+		d := detected[ci]
+		d.FirstDetected = nowStr
+		d.LastDetected = nowStr
+		d.LastActivity = nowStr
+		d.Observations = []types.ClusterSnapshot{snapshot(d, nowStr)}
+		d.Status = types.Active
+		d.StatusHistory = []types.StatusTransition{{To: types.Active, At: nowStr, Reason: "detected"}}
+		out = append(out, d)
+	}
+
+	// 3. Open disasters that were not detected again wind down
+	for di, d := range open {
+		if disasterMatched[di] {
+			continue
+		}
+		updated := *d
+		// Active disasters count their misses, the count has to be saved even when the status stays
+		if updateStatus(&updated, false, now) || updated.Status == types.Active {
+			out = append(out, updated)
+		}
+	}
+
+	return out
+}
+
+// mergeCluster copies the cluster found in this run onto the stored disaster.
+func mergeCluster(d, cluster types.DisasterData, nowStr string) types.DisasterData {
+	previousSkeets := d.SkeetsSeen()
+	if n := len(d.Observations); n > 0 {
+		previousSkeets = d.Observations[n-1].SkeetsSeen()
+	}
+
+	d.Lat = cluster.Lat
+	d.Long = cluster.Long
+	d.LocationIDs = cluster.LocationIDs
+	d.LocationCount = cluster.LocationCount
+	d.BoundingBox = cluster.BoundingBox
+	d.Hull = cluster.Hull
+	d.AreaKM2 = cluster.AreaKM2
+	d.Severity = cluster.Severity
+	d.SeverityScore = cluster.SeverityScore
+	d.SeverityFactors = cluster.SeverityFactors
+	d.TotalSkeetsAmount = cluster.TotalSkeetsAmount
+	d.AllTimeSkeetsAmount = cluster.AllTimeSkeetsAmount
+	d.ClusterSentiment = cluster.ClusterSentiment
+	d.ClusterCounts = cluster.ClusterCounts
+	d.AnomalyScore = cluster.AnomalyScore
+	if d.ReportedDate == "" || (cluster.ReportedDate != "" && cluster.ReportedDate < d.ReportedDate) {
+		d.ReportedDate = cluster.ReportedDate
+	}
+	if cluster.LastUpdate > d.LastUpdate {
+		d.LastUpdate = cluster.LastUpdate
+	}
+
+	if d.FirstDetected == "" {
+		// stored before lifecycle tracking
+		d.FirstDetected = d.ReportedDate
+	}
+	d.LastDetected = nowStr
+	d.MissedRuns = 0
+	if d.AllTimeSkeetsAmount > previousSkeets || d.LastActivity == "" {
+		d.LastActivity = nowStr
+	}
+
+	d.Observations = append(d.Observations, snapshot(d, nowStr))
+	if len(d.Observations) > maxObservations {
+		d.Observations = d.Observations[len(d.Observations)-maxObservations:]
+	}
+	return d
+}
+
+func snapshot(d types.DisasterData, nowStr string) types.ClusterSnapshot {
+	return types.ClusterSnapshot{
+		At:                  nowStr,
+		TotalSkeetsAmount:   d.TotalSkeetsAmount,
+		AllTimeSkeetsAmount: d.AllTimeSkeetsAmount,
+		ClusterSentiment:    d.ClusterSentiment,
+		LocationCount:       d.LocationCount,
+		SeverityScore:       d.SeverityScore,
+	}
+}
+
+// updateStatus applies the lifecycle rules and reports whether the status changed.
+func updateStatus(d *types.DisasterData, detected bool, now time.Time) bool {
+	if d.Status == "" {
+		d.Status = types.Active
+	}
+
+	if detected {
+		d.MissedRuns = 0
+	} else if d.Status == types.Active {
+		d.MissedRuns++
+	}
+
+	next, reason := d.Status, ""
+	growth, fullWindow := recentGrowth(d.Observations, now)
+	switch d.Status {
+	case types.Active:
+		switch {
+		case !detected && d.MissedRuns >= missesBeforeRecovery:
+			next, reason = types.Recovery, fmt.Sprintf("cluster not detected in %d runs", d.MissedRuns)
+		case !detected && undetectedFor(d, now) >= undetectedBeforeRecovery:
+			next, reason = types.Recovery, fmt.Sprintf("cluster not detected for %.0fh", undetectedBeforeRecovery.Hours())
+		case !detected:
+			// missed, but not for long enough to tell it from a noisy run
+		case fullWindow && growth < minActiveGrowth:
+			next, reason = types.Recovery, fmt.Sprintf("%d new skeets in the last %.0fh", growth, trendWindow.Hours())
+		case growth < reactivateGrowth && sentimentRecovered(d.Observations):
+			next, reason = types.Recovery, "sentiment recovered"
+		}
+	case types.Recovery:
+		switch {
+		case detected && growth >= reactivateGrowth:
+			next, reason = types.Active, fmt.Sprintf("%d new skeets in the last %.0fh", growth, trendWindow.Hours())
+		case quietFor(d, now) >= inactiveAfter:
+			next, reason = types.Not_Active, fmt.Sprintf("no new skeets for %.0fh", inactiveAfter.Hours())
+		}
+	}
+
+	if next == d.Status {
+		return false
+	}
+	fmt.Printf("Disaster %s: %s -> %s (%s)\n", d.ID, d.Status, next, reason)
+	d.StatusHistory = append(d.StatusHistory, types.StatusTransition{
+		From:   d.Status,
+		To:     next,
+		At:     now.UTC().Format(time.RFC3339),
+		Reason: reason,
+	})
+	d.Status = next
+	if next == types.Not_Active {
+		d.EndedAt = now.UTC().Format(time.RFC3339)
+	} else {
+		d.EndedAt = ""
+	}
+	return true
+}
+
+// recentGrowth is how many skeets the cluster gained over the trend window. fullWindow is false
+// while the observations don't go back that far yet.
+func recentGrowth(observations []types.ClusterSnapshot, now time.Time) (growth int, fullWindow bool) {
+	if len(observations) == 0 {
+		return 0, false
+	}
+	latest := observations[len(observations)-1]
+	baseline := latest
+	cutoff := now.Add(-trendWindow)
+	for i := len(observations) - 1; i >= 0; i-- {
+		baseline = observations[i]
+		if t, err := time.Parse(time.RFC3339, observations[i].At); err == nil && !t.After(cutoff) {
+			fullWindow = true
+			break
+		}
+	}
+	return latest.SkeetsSeen() - baseline.SkeetsSeen(), fullWindow
+}
+
+// sentimentRecovered reports whether the latest sentiment is well above the worst one seen.
+func sentimentRecovered(observations []types.ClusterSnapshot) bool {
+	if len(observations) < 2 {
+		return false
+	}
+	worst := observations[0].ClusterSentiment
+	for _, o := range observations {
+		if o.ClusterSentiment < worst {
+			worst = o.ClusterSentiment
+		}
+	}
+	return observations[len(observations)-1].ClusterSentiment-worst >= sentimentRecovery
+}
+
+// undetectedFor is how long it has been since the cluster was last detected.
+func undetectedFor(d *types.DisasterData, now time.Time) time.Duration {
+	last := d.LastDetected
+	if last == "" {
+		last = d.LastUpdate
+	}
+	t, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return 0
+	}
+	return now.Sub(t)
+}
+
+// quietFor is how long it has been since the disaster last gained skeets.
+func quietFor(d *types.DisasterData, now time.Time) time.Duration {
+	last := d.LastActivity
+	if last == "" {
+		last = d.LastDetected
+	}
+	if last == "" {
+		last = d.LastUpdate
+	}
+	t, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return 0
+	}
+	return now.Sub(t)
+}
+
+// locationOverlap is the share of the smaller cluster's locations that are also in the other one.
+func locationOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inA := make(map[string]bool, len(a))
+	for _, id := range a {
+		inA[id] = true
+	}
+	shared := 0
+	for _, id := range b {
+		if inA[id] {
+			shared++
+		}
+	}
+	return float64(shared) / math.Min(float64(len(a)), float64(len(b)))
+}
+
+// boundingBoxDistance is the distance in km between the closest points of two boxes, 0 if they overlap.
+func boundingBoxDistance(a, b types.BoundingBox) float64 {
+	closest := func(aMin, aMax, bMin, bMax float64) (float64, float64) {
+		switch {
+		case aMax < bMin:
+			return aMax, bMin
+		case bMax < aMin:
+			return aMin, bMax
+		default:
+			overlap := math.Max(aMin, bMin)
+			return overlap, overlap
+		}
+	}
+	latA, latB := closest(a.MinLat, a.MaxLat, b.MinLat, b.MaxLat)
+	lonA, lonB := closest(a.MinLon, a.MaxLon, b.MinLon, b.MaxLon)
+	return haversineDistance(latA, lonA, latB, lonB)
+}
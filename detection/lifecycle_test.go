@@ -0,0 +1,307 @@
+package detection
+
+import (
+	"go-firebird/types"
+	"math"
+	"testing"
+	"time"
+)
+
+var lifecycleNow = time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func ago(d time.Duration) string {
+	return lifecycleNow.Add(-d).Format(time.RFC3339)
+}
+
+func box(lat, lon float64) types.BoundingBox {
+	return types.BoundingBox{MinLat: lat, MaxLat: lat + 0.1, MinLon: lon, MaxLon: lon + 0.1}
+}
+
+func cluster(category types.Category, bb types.BoundingBox, skeets int, locationIDs ...string) types.DisasterData {
+	return types.DisasterData{
+		ID:                "new-" + locationIDs[0],
+		DisasterType:      category,
+		Status:            types.Active,
+		LocationIDs:       locationIDs,
+		LocationCount:     len(locationIDs),
+		BoundingBox:       bb,
+		TotalSkeetsAmount: skeets,
+	}
+}
+
+func stored(id string, status types.Status, category types.Category, bb types.BoundingBox, locationIDs ...string) types.DisasterData {
+	return types.DisasterData{
+		ID:                id,
+		DisasterType:      category,
+		Status:            status,
+		LocationIDs:       locationIDs,
+		LocationCount:     len(locationIDs),
+		BoundingBox:       bb,
+		TotalSkeetsAmount: 10,
+		FirstDetected:     ago(6 * time.Hour),
+		LastDetected:      ago(3 * time.Hour),
+		LastActivity:      ago(3 * time.Hour),
+		Observations:      []types.ClusterSnapshot{{At: ago(3 * time.Hour), TotalSkeetsAmount: 10}},
+	}
+}
+
+func byID(disasters []types.DisasterData) map[string]types.DisasterData {
+	m := make(map[string]types.DisasterData, len(disasters))
+	for _, d := range disasters {
+		m[d.ID] = d
+	}
+	return m
+}
+
+func TestBoundingBoxDistance(t *testing.T) {
+	cases := []struct {
+		name    string
+		a, b    types.BoundingBox
+		wantKM  float64
+		epsilon float64
+	}{
+		{"overlapping", types.BoundingBox{MinLat: 34, MaxLat: 35, MinLon: -119, MaxLon: -118}, types.BoundingBox{MinLat: 34.5, MaxLat: 36, MinLon: -118.5, MaxLon: -117}, 0, 0.001},
+		{"same box", box(34, -118), box(34, -118), 0, 0.001},
+		{"one degree of latitude apart", types.BoundingBox{MinLat: 34, MaxLat: 34, MinLon: -118, MaxLon: -118}, types.BoundingBox{MinLat: 35, MaxLat: 35, MinLon: -118, MaxLon: -118}, 111.19, 0.1},
+		{"order doesn't matter", types.BoundingBox{MinLat: 35, MaxLat: 35, MinLon: -118, MaxLon: -118}, types.BoundingBox{MinLat: 34, MaxLat: 34, MinLon: -118, MaxLon: -118}, 111.19, 0.1},
+		{"side by side", types.BoundingBox{MinLat: 0, MaxLat: 1, MinLon: 0, MaxLon: 1}, types.BoundingBox{MinLat: 0, MaxLat: 1, MinLon: 2, MaxLon: 3}, 111.19, 0.1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := boundingBoxDistance(c.a, c.b)
+			if math.Abs(got-c.wantKM) > c.epsilon {
+				t.Errorf("boundingBoxDistance = %.3f km, want %.3f", got, c.wantKM)
+			}
+		})
+	}
+}
+
+func TestTrackDisastersMatching(t *testing.T) {
+	la := box(34.0, -118.3)
+	cases := []struct {
+		name      string
+		existing  types.DisasterData
+		detected  types.DisasterData
+		wantMatch bool
+	}{
+		{"shared location", stored("d1", types.Active, types.Wildfire, box(40, -100), "a", "b"), cluster(types.Wildfire, la, 12, "b", "c"), true},
+		{"nearby box", stored("d1", types.Active, types.Wildfire, box(34.2, -118.3), "a"), cluster(types.Wildfire, la, 12, "c"), true},
+		{"far box", stored("d1", types.Active, types.Wildfire, box(36.0, -118.3), "a"), cluster(types.Wildfire, la, 12, "c"), false},
+		{"other type", stored("d1", types.Active, types.Wildfire, la, "a"), cluster(types.Flood, la, 12, "a"), false},
+		{"recovery is matched", stored("d1", types.Recovery, types.Wildfire, la, "a"), cluster(types.Wildfire, la, 12, "a"), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out := byID(TrackDisasters([]types.DisasterData{c.existing}, []types.DisasterData{c.detected}, lifecycleNow))
+			_, matched := out[c.existing.ID]
+			_, created := out[c.detected.ID]
+			if c.wantMatch {
+				if !matched || created {
+					t.Fatalf("cluster was not matched to %s: %v", c.existing.ID, out)
+				}
+				d := out[c.existing.ID]
+				if d.LastDetected != lifecycleNow.Format(time.RFC3339) || d.TotalSkeetsAmount != 12 {
+					t.Errorf("matched disaster was not updated: %+v", d)
+				}
+				if len(d.Observations) != 2 {
+					t.Errorf("observations = %d, want 2", len(d.Observations))
+				}
+				return
+			}
+			if !created {
+				t.Fatalf("cluster did not become a new disaster: %v", out)
+			}
+			if d := out[c.detected.ID]; d.FirstDetected == "" || len(d.StatusHistory) != 1 {
+				t.Errorf("new disaster = %+v", d)
+			}
+		})
+	}
+}
+
+func TestTrackDisastersBestMatchFirst(t *testing.T) {
+	// Both disasters are within reach, the one sharing more locations wins and the other winds down
+	existing := []types.DisasterData{
+		stored("near", types.Active, types.Wildfire, box(34.0, -118.3), "x"),
+		stored("shared", types.Active, types.Wildfire, box(34.5, -118.3), "a", "b"),
+	}
+	out := byID(TrackDisasters(existing, []types.DisasterData{cluster(types.Wildfire, box(34.0, -118.3), 12, "a", "b")}, lifecycleNow))
+
+	if out["shared"].LastDetected != lifecycleNow.Format(time.RFC3339) {
+		t.Errorf("cluster was not matched to the disaster sharing its locations")
+	}
+	if near, ok := out["near"]; !ok || near.MissedRuns != 1 || near.Status != types.Active {
+		t.Errorf("unmatched disaster = %+v, want one missed run and still active", near)
+	}
+}
+
+func TestTrackDisastersMissedRuns(t *testing.T) {
+	d := stored("d1", types.Active, types.Wildfire, box(34, -118), "a")
+	d.LastDetected = lifecycleNow.Format(time.RFC3339)
+
+	// Missed runs an hour apart: still active until the third one
+	for run := 1; run <= missesBeforeRecovery; run++ {
+		now := lifecycleNow.Add(time.Duration(run) * time.Hour)
+		out := TrackDisasters([]types.DisasterData{d}, nil, now)
+		if len(out) != 1 {
+			t.Fatalf("run %d returned %d disasters, want the missed one", run, len(out))
+		}
+		d = out[0]
+		if d.MissedRuns != run {
+			t.Errorf("run %d: missedRuns = %d", run, d.MissedRuns)
+		}
+		wantStatus := types.Active
+		if run == missesBeforeRecovery {
+			wantStatus = types.Recovery
+		}
+		if d.Status != wantStatus {
+			t.Errorf("run %d: status = %s, want %s", run, d.Status, wantStatus)
+		}
+	}
+
+	// Detecting it again resets the count
+	d = stored("d2", types.Active, types.Wildfire, box(34, -118), "a")
+	d.MissedRuns = 2
+	out := TrackDisasters([]types.DisasterData{d}, []types.DisasterData{cluster(types.Wildfire, box(34, -118), 12, "a")}, lifecycleNow)
+	if len(out) != 1 || out[0].MissedRuns != 0 || out[0].Status != types.Active {
+		t.Errorf("detected disaster = %+v, want active with no missed runs", out)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	cases := []struct {
+		name     string
+		disaster func() types.DisasterData
+		detected bool
+		want     types.Status
+	}{
+		{
+			name: "active, missed once",
+			disaster: func() types.DisasterData {
+				return stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+			},
+			want: types.Active,
+		},
+		{
+			name: "active, missed for longer than the threshold",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+				d.LastDetected = ago(undetectedBeforeRecovery + time.Hour)
+				return d
+			},
+			want: types.Recovery,
+		},
+		{
+			name: "active, missed as many runs as allowed",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+				d.MissedRuns = missesBeforeRecovery - 1
+				return d
+			},
+			want: types.Recovery,
+		},
+		{
+			name: "active, detected and growing",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+				d.Observations = []types.ClusterSnapshot{{At: ago(25 * time.Hour), TotalSkeetsAmount: 10}, {At: ago(0), TotalSkeetsAmount: 30}}
+				return d
+			},
+			detected: true,
+			want:     types.Active,
+		},
+		{
+			name: "active, detected with a steady window and new skeets",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+				d.Observations = []types.ClusterSnapshot{
+					{At: ago(25 * time.Hour), TotalSkeetsAmount: 20, AllTimeSkeetsAmount: 100},
+					{At: ago(0), TotalSkeetsAmount: 20, AllTimeSkeetsAmount: 160},
+				}
+				return d
+			},
+			detected: true,
+			want:     types.Active,
+		},
+		{
+			name: "active, detected but stalled over the trend window",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+				d.Observations = []types.ClusterSnapshot{{At: ago(25 * time.Hour), TotalSkeetsAmount: 10}, {At: ago(0), TotalSkeetsAmount: 12}}
+				return d
+			},
+			detected: true,
+			want:     types.Recovery,
+		},
+		{
+			name: "active, sentiment recovered",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Active, types.Wildfire, box(0, 0), "a")
+				d.Observations = []types.ClusterSnapshot{
+					{At: ago(6 * time.Hour), TotalSkeetsAmount: 10, ClusterSentiment: -0.6},
+					{At: ago(0), TotalSkeetsAmount: 12, ClusterSentiment: -0.3},
+				}
+				return d
+			},
+			detected: true,
+			want:     types.Recovery,
+		},
+		{
+			name: "recovery, detected with a new surge",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Recovery, types.Wildfire, box(0, 0), "a")
+				d.Observations = []types.ClusterSnapshot{{At: ago(12 * time.Hour), TotalSkeetsAmount: 10}, {At: ago(0), TotalSkeetsAmount: 40}}
+				return d
+			},
+			detected: true,
+			want:     types.Active,
+		},
+		{
+			name: "recovery, quiet for long enough",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Recovery, types.Wildfire, box(0, 0), "a")
+				d.LastActivity = ago(inactiveAfter + time.Hour)
+				return d
+			},
+			want: types.Not_Active,
+		},
+		{
+			name: "recovery, recently active",
+			disaster: func() types.DisasterData {
+				return stored("d", types.Recovery, types.Wildfire, box(0, 0), "a")
+			},
+			want: types.Recovery,
+		},
+		{
+			name: "not active stays",
+			disaster: func() types.DisasterData {
+				d := stored("d", types.Not_Active, types.Wildfire, box(0, 0), "a")
+				d.Observations = []types.ClusterSnapshot{{At: ago(12 * time.Hour), TotalSkeetsAmount: 10}, {At: ago(0), TotalSkeetsAmount: 40}}
+				return d
+			},
+			detected: true,
+			want:     types.Not_Active,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := c.disaster()
+			before := d.Status
+			changed := updateStatus(&d, c.detected, lifecycleNow)
+			if d.Status != c.want {
+				t.Fatalf("status = %s, want %s", d.Status, c.want)
+			}
+			if changed != (before != c.want) {
+				t.Errorf("updateStatus reported changed = %v", changed)
+			}
+			if changed {
+				last := d.StatusHistory[len(d.StatusHistory)-1]
+				if last.From != before || last.To != c.want || last.Reason == "" {
+					t.Errorf("transition = %+v", last)
+				}
+			}
+			if ended := c.want == types.Not_Active && changed; (d.EndedAt != "") != ended {
+				t.Errorf("endedAt = %q after %s -> %s", d.EndedAt, before, d.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package detection
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"math"
+)
+
+// Overall severity level (weighted average of the factor levels) at which a disaster gets each Severity
+const (
+	mediumSeverityLevel   = 1.0
+	highSeverityLevel     = 1.75
+	criticalSeverityLevel = 2.5
+)
+
+// SeverityWeights is how much each factor counts towards the severity score. They don't need to sum
+// to 1, a factor with no data (e.g. growth for locations without rolling windows) is left out.
+type SeverityWeights struct {
+	Sentiment  float64 `json:"sentiment" yaml:"sentiment"`
+	Volume     float64 `json:"volume" yaml:"volume"`
+	Spread     float64 `json:"spread" yaml:"spread"`
+	Growth     float64 `json:"growth" yaml:"growth"`
+	Engagement float64 `json:"engagement" yaml:"engagement"`
+}
+
+func defaultSeverityWeights() SeverityWeights {
+	return SeverityWeights{Sentiment: 0.35, Volume: 0.25, Spread: 0.15, Growth: 0.15, Engagement: 0.10}
+}
+
+// ScoreSeverity scores a cluster on five factors and sets SeverityScore, SeverityFactors and Severity.
+//
+//   - sentiment:  the cluster sentiment
+//   - volume:     total skeets of the cluster
+//   - spread:     number of locations
+//   - growth:     disaster skeets per hour in the short window over the 24h average
+//   - engagement: likes and reposts of the short window skeets
+//
+// Every factor gets a level from 0 to 3 (1 at its medium threshold, 2 at high, 3 at critical,
+// linear in between), the score is the weighted average level scaled to 0-100.
+func ScoreSeverity(d *types.DisasterData, cluster []*types.LocationData, t SeverityThresholds) {
+	var factors []types.SeverityFactor
+	add := func(name string, value, level, weight float64) {
+		if weight <= 0 {
+			return
+		}
+		factors = append(factors, types.SeverityFactor{
+			Name:   name,
+			Value:  math.Round(value*1000) / 1000,
+			Level:  level,
+			Weight: weight,
+		})
+	}
+
+	w := t.Weights
+	// Sentiment thresholds are negative, the level is how far below 0 the cluster is
+	add("sentiment", float64(d.ClusterSentiment),
+		thresholdLevel(-float64(d.ClusterSentiment), -float64(t.MediumSentiment), -float64(t.HighSentiment), -float64(t.CriticalSentiment)), w.Sentiment)
+	add("volume", float64(d.TotalSkeetsAmount),
+		thresholdLevel(float64(d.TotalSkeetsAmount), float64(t.MediumSkeets), float64(t.HighSkeets), float64(t.CriticalSkeets)), w.Volume)
+	add("spread", float64(d.LocationCount),
+		thresholdLevel(float64(d.LocationCount), float64(t.MediumLocations), float64(t.HighLocations), float64(t.CriticalLocations)), w.Spread)
+
+	// Growth and engagement come from the rolling windows, locations that don't have them yet are left out
+	var shortDisaster, dayDisaster, engagement int
+	windowed := false
+	for _, loc := range cluster {
+		short, okShort := loc.Window(types.ShortWindow)
+		day, okDay := loc.Window(types.RollingWindows[1])
+		if !okShort || !okDay {
+			continue
+		}
+		windowed = true
+		shortDisaster += short.DisasterCount.DisasterTotal()
+		dayDisaster += day.DisasterCount.DisasterTotal()
+		engagement += short.Engagement
+	}
+	if windowed {
+		if dayDisaster > 0 {
+			shortRate := float64(shortDisaster) / types.ShortWindow.Duration.Hours()
+			dayRate := float64(dayDisaster) / types.RollingWindows[1].Duration.Hours()
+			growth := shortRate / dayRate
+			add("growth", growth, thresholdLevel(growth, t.MediumGrowth, t.HighGrowth, t.CriticalGrowth), w.Growth)
+		}
+		add("engagement", float64(engagement),
+			thresholdLevel(float64(engagement), float64(t.MediumEngagement), float64(t.HighEngagement), float64(t.CriticalEngagement)), w.Engagement)
+	}
+
+	var weighted, totalWeight float64
+	for _, f := range factors {
+		weighted += f.Level * f.Weight
+		totalWeight += f.Weight
+	}
+	level := 0.0
+	if totalWeight > 0 {
+		level = weighted / totalWeight
+	}
+	for i := range factors {
+		factors[i].Weight = math.Round(factors[i].Weight/totalWeight*1000) / 1000
+		factors[i].Level = math.Round(factors[i].Level*100) / 100
+	}
+
+	d.SeverityFactors = factors
+	d.SeverityScore = math.Round(level/3*1000) / 10
+	switch {
+	case level >= criticalSeverityLevel:
+		d.Severity = types.Critical
+	case level >= highSeverityLevel:
+		d.Severity = types.High
+	case level >= mediumSeverityLevel:
+		d.Severity = types.Medium
+	default:
+		d.Severity = types.Low
+	}
+}
+
+// thresholdLevel maps value onto 0-3: 0 at 0, 1 at medium, 2 at high, 3 at critical and above.
+func thresholdLevel(value, medium, high, critical float64) float64 {
+	switch {
+	case value <= 0:
+		return 0
+	case value < medium:
+		return value / medium
+	case value < high:
+		return 1 + (value-medium)/(high-medium)
+	case value < critical:
+		return 2 + (value-high)/(critical-high)
+	default:
+		return 3
+	}
+}
+
+// validate checks every threshold triple is increasing and the weights are usable.
+func (t SeverityThresholds) validate() error {
+	triples := []struct {
+		name                   string
+		medium, high, critical float64
+	}{
+		{"sentiment", -float64(t.MediumSentiment), -float64(t.HighSentiment), -float64(t.CriticalSentiment)},
+		{"skeets", float64(t.MediumSkeets), float64(t.HighSkeets), float64(t.CriticalSkeets)},
+		{"locations", float64(t.MediumLocations), float64(t.HighLocations), float64(t.CriticalLocations)},
+		{"growth", t.MediumGrowth, t.HighGrowth, t.CriticalGrowth},
+		{"engagement", float64(t.MediumEngagement), float64(t.HighEngagement), float64(t.CriticalEngagement)},
+	}
+	for _, tr := range triples {
+		if !(0 < tr.medium && tr.medium < tr.high && tr.high < tr.critical) {
+			return fmt.Errorf("severity %s thresholds must be strictly increasing in severity, medium < high < critical", tr.name)
+		}
+	}
+	w := t.Weights
+	if w.Sentiment < 0 || w.Volume < 0 || w.Spread < 0 || w.Growth < 0 || w.Engagement < 0 {
+		return fmt.Errorf("severity weights can't be negative")
+	}
+	if w.Sentiment+w.Volume+w.Spread+w.Growth+w.Engagement == 0 {
+		return fmt.Errorf("at least one severity weight must be positive")
+	}
+	return nil
+}
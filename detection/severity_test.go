@@ -0,0 +1,185 @@
+package detection
+
+import (
+	"go-firebird/types"
+	"math"
+	"testing"
+)
+
+func TestThresholdLevel(t *testing.T) {
+	cases := []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{"negative", -5, 0},
+		{"zero", 0, 0},
+		{"half way to medium", 5, 0.5},
+		{"at medium", 10, 1},
+		{"between medium and high", 15, 1.5},
+		{"at high", 20, 2},
+		{"between high and critical", 30, 2.25},
+		{"at critical", 60, 3},
+		{"above critical", 1000, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := thresholdLevel(c.value, 10, 20, 60); math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("thresholdLevel(%v) = %v, want %v", c.value, got, c.want)
+			}
+		})
+	}
+}
+
+// windowed gives a location short and 24h windows with the given disaster skeets and engagement.
+func windowed(shortDisaster, dayDisaster, engagement int) *types.LocationData {
+	return &types.LocationData{Windows: map[string]types.LocationWindow{
+		types.ShortWindow.Name: {
+			DisasterCount: types.DisasterCount{types.Wildfire: shortDisaster, types.NonDisaster: 50},
+			Engagement:    engagement,
+		},
+		types.RollingWindows[1].Name: {
+			DisasterCount: types.DisasterCount{types.Wildfire: dayDisaster, types.NonDisaster: 50},
+		},
+	}}
+}
+
+func TestScoreSeverity(t *testing.T) {
+	cases := []struct {
+		name        string
+		sentiment   float32
+		skeets      int
+		locations   int
+		cluster     []*types.LocationData
+		weights     *SeverityWeights
+		wantFactors []string
+		wantScore   float64
+		want        types.Severity
+	}{
+		{
+			name:      "quiet cluster",
+			sentiment: 0.1, skeets: 15, locations: 1,
+			cluster:     []*types.LocationData{{}},
+			wantFactors: []string{"sentiment", "volume", "spread"},
+			// (0*0.35 + 0.5*0.25 + 0.2*0.15) / 0.75 = 0.2067
+			wantScore: 6.9,
+			want:      types.Low,
+		},
+		{
+			name:      "every factor at medium, no windows",
+			sentiment: -0.43, skeets: 30, locations: 5,
+			cluster:     []*types.LocationData{{}},
+			wantFactors: []string{"sentiment", "volume", "spread"},
+			wantScore:   33.3,
+			want:        types.Medium,
+		},
+		{
+			name:      "every factor at high",
+			sentiment: -0.53, skeets: 100, locations: 10,
+			// 10/h in the last 6h against 4/h over the day is a growth of 2.5
+			cluster:     []*types.LocationData{windowed(60, 96, 250)},
+			wantFactors: []string{"sentiment", "volume", "spread", "growth", "engagement"},
+			wantScore:   66.7,
+			want:        types.High,
+		},
+		{
+			name:      "every factor critical",
+			sentiment: -0.8, skeets: 400, locations: 30,
+			cluster:     []*types.LocationData{windowed(40, 30, 600), windowed(20, 30, 600)},
+			wantFactors: []string{"sentiment", "volume", "spread", "growth", "engagement"},
+			wantScore:   100,
+			want:        types.Critical,
+		},
+		{
+			name:      "no disaster skeets over the day leaves growth out",
+			sentiment: -0.63, skeets: 150, locations: 20,
+			cluster:     []*types.LocationData{windowed(0, 0, 1000)},
+			wantFactors: []string{"sentiment", "volume", "spread", "engagement"},
+			wantScore:   100,
+			want:        types.Critical,
+		},
+		{
+			name:      "locations without windows are left out of growth and engagement",
+			sentiment: -0.63, skeets: 150, locations: 20,
+			cluster:     []*types.LocationData{{}, windowed(60, 96, 1000)},
+			wantFactors: []string{"sentiment", "volume", "spread", "growth", "engagement"},
+			// growth 2.5 is level 2: (3*0.85 + 2*0.15) / 1 = 2.85
+			wantScore: 95,
+			want:      types.Critical,
+		},
+		{
+			name:      "zero weight leaves a factor out",
+			sentiment: -0.63, skeets: 0, locations: 1,
+			cluster:     []*types.LocationData{{}},
+			weights:     &SeverityWeights{Sentiment: 1},
+			wantFactors: []string{"sentiment"},
+			wantScore:   100,
+			want:        types.Critical,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			thresholds := DefaultConfig().Severity
+			if c.weights != nil {
+				thresholds.Weights = *c.weights
+			}
+			d := types.DisasterData{ClusterSentiment: c.sentiment, TotalSkeetsAmount: c.skeets, LocationCount: c.locations}
+			ScoreSeverity(&d, c.cluster, thresholds)
+
+			if d.Severity != c.want || math.Abs(d.SeverityScore-c.wantScore) > 0.05 {
+				t.Errorf("severity = %s (%.1f), want %s (%.1f)", d.Severity, d.SeverityScore, c.want, c.wantScore)
+			}
+			if len(d.SeverityFactors) != len(c.wantFactors) {
+				t.Fatalf("factors = %+v, want %v", d.SeverityFactors, c.wantFactors)
+			}
+			var weights float64
+			for i, f := range d.SeverityFactors {
+				if f.Name != c.wantFactors[i] {
+					t.Errorf("factor %d = %s, want %s", i, f.Name, c.wantFactors[i])
+				}
+				if f.Level < 0 || f.Level > 3 {
+					t.Errorf("factor %s level = %v", f.Name, f.Level)
+				}
+				weights += f.Weight
+			}
+			if math.Abs(weights-1) > 0.01 {
+				t.Errorf("factor weights sum to %v, want 1", weights)
+			}
+		})
+	}
+}
+
+func TestCreateDisasterFromClusterUsesWindows(t *testing.T) {
+	location := func(id string, lat float64, allTime int, window types.LocationWindow) *types.LocationData {
+		return &types.LocationData{
+			ID:                  id,
+			Lat:                 lat,
+			Long:                -118,
+			LatestSkeetsAmount:  allTime,
+			LatestSentiment:     0.3,
+			LatestDisasterCount: types.DisasterCount{types.NonDisaster: allTime},
+			Windows:             map[string]types.LocationWindow{types.ShortWindow.Name: window},
+		}
+	}
+	cluster := []*types.LocationData{
+		location("a", 34, 900, types.LocationWindow{SkeetsAmount: 20, AverageSentiment: -0.6, DisasterCount: types.DisasterCount{types.Wildfire: 12, types.NonDisaster: 8}}),
+		location("b", 34.1, 100, types.LocationWindow{SkeetsAmount: 10, AverageSentiment: -0.4, DisasterCount: types.DisasterCount{types.Wildfire: 4, types.Flood: 1, types.NonDisaster: 5}}),
+	}
+	d := createDisasterFromCluster(cluster, types.Wildfire, DefaultConfig().Severity)
+
+	if d.TotalSkeetsAmount != 30 || d.AllTimeSkeetsAmount != 1000 {
+		t.Errorf("skeets = %d, all time %d, want 30 and 1000", d.TotalSkeetsAmount, d.AllTimeSkeetsAmount)
+	}
+	if math.Abs(float64(d.ClusterSentiment)+0.5) > 1e-6 {
+		t.Errorf("cluster sentiment = %v, want the window average -0.5", d.ClusterSentiment)
+	}
+	want := types.DisasterCount{types.Wildfire: 16, types.Flood: 1, types.NonDisaster: 13}
+	if len(d.ClusterCounts) != len(want) {
+		t.Fatalf("cluster counts = %v, want %v", d.ClusterCounts, want)
+	}
+	for category, count := range want {
+		if d.ClusterCounts[category] != count {
+			t.Errorf("cluster counts = %v, want %v", d.ClusterCounts, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package detection
+
+import (
+	"go-firebird/types"
+	"math"
+	"sort"
+)
+
+// km per degree of latitude (and of longitude at the equator)
+const kmPerDegree = math.Pi * earthRadiusKM / 180
+
+// neighborFinder returns the indices of the locations within radiusKM of locations[i], i excluded,
+// in ascending order.
+type neighborFinder interface {
+	neighbors(i int, radiusKM float64) []int
+}
+
+// linearScan checks every location, O(n) per query. Kept as the reference the index is measured against.
+type linearScan struct {
+	locations []types.LocationData
+}
+
+func (s linearScan) neighbors(i int, radiusKM float64) []int {
+	var out []int
+	for j := range s.locations {
+		if j == i {
+			continue
+		}
+		if haversineDistance(s.locations[i].Lat, s.locations[i].Long, s.locations[j].Lat, s.locations[j].Long) <= radiusKM {
+			out = append(out, j)
+		}
+	}
+	return out
+}
+
+// SpatialIndex buckets locations into a lat/long grid so a radius query only checks the cells around
+// the point instead of every location.
+//
+// Cells are cellKM tall and cellKM wide at the equator, narrower towards the poles, so a query looks
+// at more columns at high latitudes. Queries wrap around the antimeridian.
+type SpatialIndex struct {
+	locations []types.LocationData
+	cellDeg   float64
+	numCols   int
+	cells     map[int]map[int][]int // row -> column -> location indices
+}
+
+// NewSpatialIndex indexes the locations. Queries are cheapest when cellKM is about the query radius.
+func NewSpatialIndex(locations []types.LocationData, cellKM float64) *SpatialIndex {
+	if cellKM <= 0 {
+		cellKM = distanceThresholdKM
+	}
+	idx := &SpatialIndex{
+		locations: locations,
+		cellDeg:   cellKM / kmPerDegree,
+		cells:     make(map[int]map[int][]int),
+	}
+	idx.numCols = int(math.Ceil(360 / idx.cellDeg))
+
+	for i := range locations {
+		row, col := idx.row(locations[i].Lat), idx.col(locations[i].Long)
+		if idx.cells[row] == nil {
+			idx.cells[row] = make(map[int][]int)
+		}
+		idx.cells[row][col] = append(idx.cells[row][col], i)
+	}
+	return idx
+}
+
+func (idx *SpatialIndex) row(lat float64) int {
+	return int(math.Floor(lat / idx.cellDeg))
+}
+
+func (idx *SpatialIndex) col(lon float64) int {
+	// normalize to [-180, 180)
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	col := int(math.Floor(lon / idx.cellDeg))
+	if col >= idx.numCols {
+		col = idx.numCols - 1
+	}
+	return col
+}
+
+// Within returns the indices of the indexed locations within radiusKM of the point, in ascending order.
+func (idx *SpatialIndex) Within(lat, lon, radiusKM float64) []int {
+	return idx.within(lat, lon, radiusKM, -1)
+}
+
+func (idx *SpatialIndex) neighbors(i int, radiusKM float64) []int {
+	return idx.within(idx.locations[i].Lat, idx.locations[i].Long, radiusKM, i)
+}
+
+func (idx *SpatialIndex) within(lat, lon, radiusKM float64, skip int) []int {
+	dLat := radiusKM / kmPerDegree
+
+	// Longitude span of the radius at the latitude in the band farthest from the equator
+	maxLat := math.Min(math.Abs(lat)+dLat, 90)
+	fullRow := maxLat >= 89.9
+	var dLon float64
+	if !fullRow {
+		dLon = dLat / math.Cos(maxLat*math.Pi/180)
+		fullRow = 2*dLon >= 360-idx.cellDeg
+	}
+
+	var out []int
+	check := func(indices []int) {
+		for _, j := range indices {
+			if j == skip {
+				continue
+			}
+			if haversineDistance(lat, lon, idx.locations[j].Lat, idx.locations[j].Long) <= radiusKM {
+				out = append(out, j)
+			}
+		}
+	}
+
+	for row := idx.row(lat - dLat); row <= idx.row(lat+dLat); row++ {
+		cols := idx.cells[row]
+		if cols == nil {
+			continue
+		}
+		if fullRow {
+			for _, indices := range cols {
+				check(indices)
+			}
+			continue
+		}
+		lo, hi := idx.col(lon-dLon), idx.col(lon+dLon)
+		if lo <= hi {
+			for col := lo; col <= hi; col++ {
+				check(cols[col])
+			}
+		} else {
+			// wraps around the antimeridian
+			for col := lo; col < idx.numCols; col++ {
+				check(cols[col])
+			}
+			for col := 0; col <= hi; col++ {
+				check(cols[col])
+			}
+		}
+	}
+
+	sort.Ints(out)
+	return out
+}
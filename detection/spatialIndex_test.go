@@ -0,0 +1,205 @@
+package detection
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scatter places n locations around (lat, lon), up to latSpread and lonSpread degrees away.
+func scatter(r *rand.Rand, n int, lat, lon, latSpread, lonSpread float64) []types.LocationData {
+	locations := make([]types.LocationData, n)
+	for i := range locations {
+		pLat := lat + (r.Float64()*2-1)*latSpread
+		if pLat > 90 {
+			pLat = 180 - pLat
+		}
+		if pLat < -90 {
+			pLat = -180 - pLat
+		}
+		pLon := lon + (r.Float64()*2-1)*lonSpread
+		if pLon >= 180 {
+			pLon -= 360
+		}
+		if pLon < -180 {
+			pLon += 360
+		}
+		locations[i] = types.LocationData{ID: fmt.Sprintf("loc-%d", i), Lat: pLat, Long: pLon}
+	}
+	return locations
+}
+
+func TestSpatialIndexMatchesScan(t *testing.T) {
+	cases := []struct {
+		name                 string
+		lat, lon             float64
+		latSpread, lonSpread float64
+	}{
+		{"equator", 0, 0, 2, 2},
+		{"mid latitude", 34, -118, 2, 2},
+		{"high latitude", 70, 20, 2, 6},
+		{"antimeridian", -17, 179.8, 1.5, 1.5},
+		{"antimeridian far north", 65, -179.9, 2, 5},
+		{"north pole", 89.3, 0, 0.7, 180},
+		{"south pole", -89.3, 45, 0.7, 180},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			locations := scatter(rand.New(rand.NewSource(1)), 400, c.lat, c.lon, c.latSpread, c.lonSpread)
+			scan := linearScan{locations: locations}
+			for _, radiusKM := range []float64{10, 50, 200} {
+				idx := NewSpatialIndex(locations, radiusKM)
+				pairs := 0
+				for i := range locations {
+					want, got := scan.neighbors(i, radiusKM), idx.neighbors(i, radiusKM)
+					if len(want) == 0 && len(got) == 0 {
+						continue
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Fatalf("radius %.0f km, location %d (%.3f, %.3f): grid found %v, scan %v",
+							radiusKM, i, locations[i].Lat, locations[i].Long, got, want)
+					}
+					pairs += len(want)
+				}
+				if pairs == 0 {
+					t.Fatalf("no neighbors within %.0f km, the case checks nothing", radiusKM)
+				}
+			}
+		})
+	}
+}
+
+func TestSpatialIndexWithin(t *testing.T) {
+	locations := []types.LocationData{
+		{ID: "fiji", Lat: -17.8, Long: 178.4},
+		{ID: "fiji-east", Lat: -17.8, Long: -179.9},
+		{ID: "auckland", Lat: -36.8, Long: 174.8},
+	}
+	idx := NewSpatialIndex(locations, 50)
+	if got := idx.Within(-17.8, 179.9, 200); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("Within across the antimeridian = %v, want [0 1]", got)
+	}
+	if got := idx.Within(-17.8, -179.9, 10); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("Within = %v, want the point itself", got)
+	}
+}
+
+// syntheticLocations spreads locations over the contiguous US, a third of them around 40 hotspots, and
+// makes seedShare of them disaster seeds.
+func syntheticLocations(n int, seedShare float64, r *rand.Rand) []types.LocationData {
+	type point struct{ lat, lon float64 }
+	hotspots := make([]point, 40)
+	for i := range hotspots {
+		hotspots[i] = point{25 + r.Float64()*24, -124 + r.Float64()*57}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	locations := make([]types.LocationData, n)
+	for i := range locations {
+		p := point{25 + r.Float64()*24, -124 + r.Float64()*57}
+		if i%3 == 0 {
+			h := hotspots[r.Intn(len(hotspots))]
+			p = point{h.lat + r.NormFloat64()*0.3, h.lon + r.NormFloat64()*0.3}
+		}
+
+		window := types.LocationWindow{
+			UpdatedAt:        now,
+			SkeetsAmount:     10,
+			AverageSentiment: 0.2,
+			DisasterCount:    types.DisasterCount{types.NonDisaster: 10},
+		}
+		if r.Float64() < seedShare {
+			window.AverageSentiment = -0.5
+			window.DisasterCount = types.DisasterCount{types.Wildfire: 5, types.NonDisaster: 5}
+		}
+		locations[i] = types.LocationData{
+			ID:                  fmt.Sprintf("loc-%d", i),
+			LocationName:        fmt.Sprintf("Location %d", i),
+			Lat:                 p.lat,
+			Long:                p.lon,
+			LatestSkeetsAmount:  window.SkeetsAmount,
+			LatestSentiment:     window.AverageSentiment,
+			LatestDisasterCount: window.DisasterCount,
+			Windows:             map[string]types.LocationWindow{types.ShortWindow.Name: window},
+		}
+	}
+	return locations
+}
+
+// discardStdout drops the detection progress output until the test ends.
+func discardStdout(tb testing.TB) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		tb.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	tb.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+func benchConfig(mode, index string) DetectionConfig {
+	cfg := DefaultConfig()
+	cfg.Regions = nil
+	cfg.Clustering = ClusterConfig{Mode: mode, EpsKM: distanceThresholdKM, MinPts: defaultMinPts, Index: index}
+	return cfg
+}
+
+func clusterKeys(disasters []types.DisasterData) []string {
+	keys := make([]string, len(disasters))
+	for i, d := range disasters {
+		keys[i] = strings.Join(d.LocationIDs, ",")
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestDetectGridMatchesScan(t *testing.T) {
+	discardStdout(t)
+	locations := syntheticLocations(3000, 0.05, rand.New(rand.NewSource(3000)))
+	for _, mode := range []string{ModeSeeded, ModeDBSCAN} {
+		grid, err := DetectDisasters(locations, benchConfig(mode, IndexGrid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scan, err := DetectDisasters(locations, benchConfig(mode, IndexScan))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grid) == 0 {
+			t.Fatalf("%s: no clusters found", mode)
+		}
+		if !reflect.DeepEqual(clusterKeys(grid), clusterKeys(scan)) {
+			t.Errorf("%s: grid found %d clusters, scan %d, or they differ", mode, len(grid), len(scan))
+		}
+	}
+}
+
+func benchmarkDetect(b *testing.B, index string) {
+	discardStdout(b)
+	for _, n := range []int{1000, 5000, 20000} {
+		locations := syntheticLocations(n, 0.05, rand.New(rand.NewSource(int64(n))))
+		for _, mode := range []string{ModeSeeded, ModeDBSCAN} {
+			cfg := benchConfig(mode, index)
+			b.Run(fmt.Sprintf("%s/%d", mode, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := DetectDisasters(locations, cfg); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// go test ./detection -run '^$' -bench Detect
+func BenchmarkDetectGrid(b *testing.B) { benchmarkDetect(b, IndexGrid) }
+func BenchmarkDetectScan(b *testing.B) { benchmarkDetect(b, IndexScan) }
@@ -0,0 +1,208 @@
+// Package events is an in-process publish/subscribe bus for what the pipeline produces: saved skeets,
+// location sentiment updates and disaster changes. Clients subscribe to it over Server-Sent Events or a
+// websocket, see handlers.StreamEvents and handlers.EventsWebSocket.
+package events
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Topic is the kind of an event. Subscribing to the part before the dot ("disaster") gets every topic
+// starting with it.
+type Topic string
+
+const (
+	SkeetSaved        Topic = "skeet.saved"        // SkeetEvent, category is the skeet's top category
+	LocationSentiment Topic = "location.sentiment" // LocationEvent, category is the dominant disaster category
+	DisasterCreated   Topic = "disaster.created"   // DisasterEvent, category is the disaster type
+	DisasterUpdated   Topic = "disaster.updated"   // DisasterEvent
+	DisasterStatus    Topic = "disaster.status"    // DisasterEvent with PreviousStatus set
+	DemoTweets        Topic = "demo.tweets"        // {"tweets": [...]} from the demo simulation
+)
+
+// Topics are every topic published.
+var Topics = []Topic{SkeetSaved, LocationSentiment, DisasterCreated, DisasterUpdated, DisasterStatus, DemoTweets}
+
+// Event is one message on the bus.
+type Event struct {
+	ID       uint64         `json:"id"` // increasing for the life of the process
+	Topic    Topic          `json:"topic"`
+	Category types.Category `json:"category,omitempty"`
+	At       string         `json:"at"`
+	Data     interface{}    `json:"data"`
+}
+
+// Bus fans events out to the subscriptions whose filter matches. Publishing never blocks: a
+// subscription whose buffer is full misses the event, see Subscription.Dropped.
+// A nil *Bus drops everything, so callers without subscribers can pass nil.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	nextID atomic.Uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish sends an event to every matching subscription.
+func (b *Bus) Publish(topic Topic, category types.Category, data interface{}) {
+	if b == nil {
+		return
+	}
+	e := Event{
+		ID:       b.nextID.Add(1),
+		Topic:    topic,
+		Category: category,
+		At:       time.Now().UTC().Format(time.RFC3339),
+		Data:     data,
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if !s.Filter().Matches(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribers is the number of open subscriptions.
+func (b *Bus) Subscribers() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Subscribe opens a subscription buffering up to buffer events. Callers must Close it.
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	ch := make(chan Event, buffer)
+	s := &Subscription{C: ch, ch: ch, bus: b}
+	s.SetFilter(filter)
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Subscription receives the matching events on C until it is closed.
+type Subscription struct {
+	C       <-chan Event
+	ch      chan Event
+	bus     *Bus
+	filter  atomic.Pointer[Filter]
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+// Filter returns the current filter.
+func (s *Subscription) Filter() Filter {
+	return *s.filter.Load()
+}
+
+// SetFilter replaces the filter, events published afterwards use the new one.
+func (s *Subscription) SetFilter(f Filter) {
+	s.filter.Store(&f)
+}
+
+// Dropped is the number of events missed because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close removes the subscription from the bus and closes C.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// Filter selects events by topic and category. Empty fields don't filter.
+type Filter struct {
+	Topics     []Topic          `json:"topics"`
+	Categories []types.Category `json:"categories"`
+}
+
+// Matches reports whether the event passes the filter. With categories set, events without a category
+// are left out.
+func (f Filter) Matches(e Event) bool {
+	if len(f.Topics) > 0 {
+		found := false
+		for _, t := range f.Topics {
+			if e.Topic == t || strings.HasPrefix(string(e.Topic), string(t)+".") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Categories) > 0 {
+		for _, c := range f.Categories {
+			if e.Category == c {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ParseFilter reads ?topics=disaster,skeet.saved&category=wildfire,flood.
+func ParseFilter(query url.Values) (Filter, error) {
+	var f Filter
+	for _, t := range strings.Split(query.Get("topics"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Topics = append(f.Topics, Topic(t))
+		}
+	}
+	for _, c := range strings.Split(query.Get("category"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			f.Categories = append(f.Categories, types.Category(c))
+		}
+	}
+	return f, f.Validate()
+}
+
+// Validate checks every topic is a topic or a topic prefix, and normalizes the categories.
+func (f Filter) Validate() error {
+	for _, t := range f.Topics {
+		known := false
+		for _, topic := range Topics {
+			if t == topic || strings.HasPrefix(string(topic), string(t)+".") {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown topic %q", t)
+		}
+	}
+	for i, c := range f.Categories {
+		category, ok := types.ParseCategory(string(c))
+		if !ok {
+			return fmt.Errorf("unknown category %q", c)
+		}
+		f.Categories[i] = category
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"go-firebird/types"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilterMatches(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{"empty filter", Filter{}, Event{Topic: SkeetSaved}, true},
+		{"topic", Filter{Topics: []Topic{SkeetSaved}}, Event{Topic: SkeetSaved}, true},
+		{"other topic", Filter{Topics: []Topic{SkeetSaved}}, Event{Topic: DisasterCreated}, false},
+		{"prefix", Filter{Topics: []Topic{"disaster"}}, Event{Topic: DisasterStatus}, true},
+		{"prefix of another word", Filter{Topics: []Topic{"disast"}}, Event{Topic: DisasterStatus}, false},
+		{"one of the topics", Filter{Topics: []Topic{SkeetSaved, "disaster"}}, Event{Topic: DisasterUpdated}, true},
+		{"category", Filter{Categories: []types.Category{types.Wildfire}}, Event{Topic: SkeetSaved, Category: types.Wildfire}, true},
+		{"other category", Filter{Categories: []types.Category{types.Wildfire}}, Event{Topic: SkeetSaved, Category: types.Flood}, false},
+		{"no category", Filter{Categories: []types.Category{types.Wildfire}}, Event{Topic: DemoTweets}, false},
+		{"topic and category", Filter{Topics: []Topic{"disaster"}, Categories: []types.Category{types.Flood}}, Event{Topic: SkeetSaved, Category: types.Flood}, false},
+	}
+	for _, c := range cases {
+		if got := c.filter.Matches(c.event); got != c.want {
+			t.Errorf("%s: Matches = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(url.Values{"topics": {" disaster, skeet.saved,"}, "category": {"Fire,non_disaster"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Topics) != 2 || f.Topics[0] != "disaster" || f.Topics[1] != SkeetSaved {
+		t.Errorf("topics = %v", f.Topics)
+	}
+	if len(f.Categories) != 2 || f.Categories[0] != types.Wildfire || f.Categories[1] != types.NonDisaster {
+		t.Errorf("categories = %v, want them normalized", f.Categories)
+	}
+
+	for _, bad := range []url.Values{
+		{"topics": {"disast"}},        // only whole parts are prefixes
+		{"topics": {"skeet.saved.x"}}, // longer than a topic
+		{"topics": {"skeets"}},
+		{"category": {"meteor"}},
+	} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("ParseFilter(%v) accepted an unknown topic or category", bad)
+		}
+	}
+}
+
+func TestPublishDropsWhenBufferFull(t *testing.T) {
+	b := NewBus()
+	slow := b.Subscribe(Filter{}, 2)
+	defer slow.Close()
+	other := b.Subscribe(Filter{Topics: []Topic{"disaster"}}, 1)
+	defer other.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5; i++ {
+			b.Publish(SkeetSaved, types.Wildfire, i)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscription")
+	}
+
+	if slow.Dropped() != 3 {
+		t.Errorf("dropped %d, want 3", slow.Dropped())
+	}
+	// The buffer keeps the oldest events, ids increase
+	for want := 0; want < 2; want++ {
+		e := <-slow.C
+		if e.Data != want || e.ID != uint64(want+1) || e.Topic != SkeetSaved || e.Category != types.Wildfire || e.At == "" {
+			t.Errorf("event %d = %+v", want, e)
+		}
+	}
+	// Events the filter leaves out aren't dropped ones
+	if other.Dropped() != 0 || len(other.C) != 0 {
+		t.Errorf("other subscription got %d events and dropped %d", len(other.C), other.Dropped())
+	}
+
+	// A new filter applies to the next event
+	slow.SetFilter(Filter{Topics: []Topic{DisasterCreated}})
+	b.Publish(SkeetSaved, "", nil)
+	b.Publish(DisasterCreated, "", nil)
+	if e := <-slow.C; e.Topic != DisasterCreated {
+		t.Errorf("after SetFilter got %s", e.Topic)
+	}
+}
+
+func TestCloseWhilePublishing(t *testing.T) {
+	b := NewBus()
+	// A send on a closed channel would panic, Close waits for the publishes holding the bus
+	for i := 0; i < 10; i++ {
+		s := b.Subscribe(Filter{}, 1)
+		stop := make(chan struct{})
+		var publishers sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			publishers.Add(1)
+			go func() {
+				defer publishers.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						b.Publish(DisasterUpdated, types.Flood, nil)
+					}
+				}
+			}()
+		}
+		<-s.C
+		<-s.C
+		s.Close()
+		s.Close() // twice is fine
+		close(stop)
+		publishers.Wait()
+		for range s.C {
+		}
+	}
+	if n := b.Subscribers(); n != 0 {
+		t.Errorf("%d subscribers left", n)
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Publish(SkeetSaved, types.Wildfire, nil)
+	if b.Subscribers() != 0 {
+		t.Error("a nil bus has subscribers")
+	}
+}
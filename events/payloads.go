@@ -0,0 +1,68 @@
+package events
+
+import "go-firebird/types"
+
+// SkeetEvent is a newly saved skeet.
+type SkeetEvent struct {
+	ID             string          `json:"id"`
+	UID            string          `json:"uid"`
+	Handle         string          `json:"handle"`
+	Content        string          `json:"content"`
+	Timestamp      string          `json:"timestamp"`
+	Category       types.Category  `json:"category"`
+	Classification []float64       `json:"classification"`
+	Sentiment      types.Sentiment `json:"sentiment"`
+	Locations      []string        `json:"locations"` // LOCATION and ADDRESS entities
+}
+
+// LocationEvent is a location whose average sentiment was recomputed with new skeets.
+type LocationEvent struct {
+	ID                string              `json:"id"`
+	Name              string              `json:"name"`
+	FormattedAddress  string              `json:"formattedAddress"`
+	Lat               float64             `json:"lat"`
+	Long              float64             `json:"long"`
+	Sentiment         float32             `json:"sentiment"`
+	PreviousSentiment *float32            `json:"previousSentiment,omitempty"` // missing for the first average
+	SkeetsAmount      int                 `json:"skeetsAmount"`
+	NewSkeets         int                 `json:"newSkeets"`
+	Counts            types.DisasterCount `json:"counts"`
+	UpdatedAt         string              `json:"updatedAt"`
+}
+
+// DisasterEvent is a disaster saved by a detection run.
+type DisasterEvent struct {
+	ID             string         `json:"id"`
+	Type           types.Category `json:"type"`
+	Status         types.Status   `json:"status"`
+	PreviousStatus types.Status   `json:"previousStatus,omitempty"` // disaster.status only
+	Severity       types.Severity `json:"severity"`
+	SeverityScore  float64        `json:"severityScore"`
+	Lat            float64        `json:"lat"`
+	Long           float64        `json:"long"`
+	LocationCount  int            `json:"locationCount"`
+	TotalSkeets    int            `json:"totalSkeets"`
+	AreaKM2        float64        `json:"areaKm2"`
+	ReportedDate   string         `json:"reportedDate"`
+	LastUpdate     string         `json:"lastUpdate"`
+	Summary        string         `json:"summary,omitempty"`
+}
+
+// NewDisasterEvent is the event payload of a disaster.
+func NewDisasterEvent(d types.DisasterData) DisasterEvent {
+	return DisasterEvent{
+		ID:            d.ID,
+		Type:          d.DisasterType,
+		Status:        d.Status,
+		Severity:      d.Severity,
+		SeverityScore: d.SeverityScore,
+		Lat:           d.Lat,
+		Long:          d.Long,
+		LocationCount: d.LocationCount,
+		TotalSkeets:   d.TotalSkeetsAmount,
+		AreaKM2:       d.AreaKM2,
+		ReportedDate:  d.ReportedDate,
+		LastUpdate:    d.LastUpdate,
+		Summary:       d.Summary,
+	}
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const AtomContentType = "application/atom+xml"
+
+// AtomFeed is an Atom index of CAP alerts, one entry per current message with a link to the alert.
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  AtomPerson  `xml:"author"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// AtomEntry carries the main CAP fields too, so readers can filter without fetching every alert.
+type AtomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary"`
+	Links      []AtomLink     `xml:"link"`
+	Categories []AtomCategory `xml:"category"`
+
+	Event     string   `xml:"urn:oasis:names:tc:emergency:cap:1.2 event"`
+	MsgType   string   `xml:"urn:oasis:names:tc:emergency:cap:1.2 msgType"`
+	Urgency   string   `xml:"urn:oasis:names:tc:emergency:cap:1.2 urgency"`
+	Severity  string   `xml:"urn:oasis:names:tc:emergency:cap:1.2 severity"`
+	Certainty string   `xml:"urn:oasis:names:tc:emergency:cap:1.2 certainty"`
+	AreaDesc  string   `xml:"urn:oasis:names:tc:emergency:cap:1.2 areaDesc"`
+	Polygons  []string `xml:"urn:oasis:names:tc:emergency:cap:1.2 polygon,omitempty"`
+	Circles   []string `xml:"urn:oasis:names:tc:emergency:cap:1.2 circle,omitempty"`
+}
+
+// AlertEntry is the feed entry of a CAP alert served at alertURL. The entry ID is made from the alert
+// identifier, so every Update or Cancel is a new entry.
+func AlertEntry(alert CAPAlert, sent time.Time, alertURL string) AtomEntry {
+	entry := AtomEntry{
+		ID:      "urn:firebird:cap:" + alert.Identifier,
+		Updated: sent.UTC().Format(time.RFC3339),
+		Links:   []AtomLink{{Rel: "alternate", Type: CAPContentType, Href: alertURL}},
+		MsgType: alert.MsgType,
+	}
+	if len(alert.Info) > 0 {
+		info := alert.Info[0]
+		entry.Title = info.Headline
+		entry.Summary = info.Description
+		entry.Categories = []AtomCategory{{Term: info.Event}}
+		entry.Event = info.Event
+		entry.Urgency = info.Urgency
+		entry.Severity = info.Severity
+		entry.Certainty = info.Certainty
+		if len(info.Areas) > 0 {
+			entry.AreaDesc = info.Areas[0].AreaDesc
+			entry.Polygons = info.Areas[0].Polygons
+			entry.Circles = info.Areas[0].Circles
+		}
+	}
+	return entry
+}
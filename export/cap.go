@@ -0,0 +1,289 @@
+package export
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go-firebird/types"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CAP 1.2, see https://docs.oasis-open.org/emergency/cap/v1.2/CAP-v1.2-os.html
+const (
+	CAPNamespace   = "urn:oasis:names:tc:emergency:cap:1.2"
+	CAPContentType = "application/cap+xml"
+)
+
+// ErrUndated is returned for a disaster without a FirstDetected, ReportedDate or LastUpdate that parses,
+// there is no time to send its alert at.
+var ErrUndated = errors.New("disaster has no detection or report date")
+
+// CAP message types. Every disaster is an Alert when first detected, an Update on every status change
+// or new detection run and a Cancel when it ends, see DisasterAlert.
+const (
+	MsgAlert  = "Alert"
+	MsgUpdate = "Update"
+	MsgCancel = "Cancel"
+)
+
+type CAPAlert struct {
+	XMLName    xml.Name  `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Scope      string    `xml:"scope"`
+	References string    `xml:"references,omitempty"`
+	Info       []CAPInfo `xml:"info"`
+}
+
+type CAPInfo struct {
+	Language     string         `xml:"language"`
+	Category     []string       `xml:"category"`
+	Event        string         `xml:"event"`
+	ResponseType string         `xml:"responseType"`
+	Urgency      string         `xml:"urgency"`
+	Severity     string         `xml:"severity"`
+	Certainty    string         `xml:"certainty"`
+	Onset        string         `xml:"onset,omitempty"`
+	SenderName   string         `xml:"senderName,omitempty"`
+	Headline     string         `xml:"headline"`
+	Description  string         `xml:"description"`
+	Web          string         `xml:"web,omitempty"`
+	Parameters   []CAPParameter `xml:"parameter"`
+	Areas        []CAPArea      `xml:"area"`
+}
+
+type CAPParameter struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+type CAPArea struct {
+	AreaDesc string   `xml:"areaDesc"`
+	Polygons []string `xml:"polygon,omitempty"`
+	Circles  []string `xml:"circle,omitempty"`
+}
+
+// CAPOptions are what a disaster doesn't say about its alert.
+type CAPOptions struct {
+	Sender     string // unique ID of the sender, e.g. firebird@example.org
+	SenderName string
+	Web        string // page of the disaster
+	AreaDesc   string // names of the disaster's locations, the centroid and location count when empty
+}
+
+// capMessage is one message of a disaster's alert history.
+type capMessage struct {
+	identifier string
+	sent       time.Time
+	msgType    string
+}
+
+// DisasterAlert renders the current CAP message of a disaster: event type from DisasterType, severity
+// from Severity, the hull polygon as area (a circle of the affected area for fewer than 3 locations)
+// and the summary as description. Earlier messages of the disaster are its references.
+func DisasterAlert(d types.DisasterData, opts CAPOptions) (CAPAlert, error) {
+	messages := disasterMessages(d)
+	if len(messages) == 0 {
+		return CAPAlert{}, ErrUndated
+	}
+	current := messages[len(messages)-1]
+
+	// Referencing starts over at the last Alert, a disaster active again after it ended is a new alert
+	first := 0
+	for i, m := range messages {
+		if m.msgType == MsgAlert {
+			first = i
+		}
+	}
+	var references []string
+	for _, m := range messages[first : len(messages)-1] {
+		references = append(references, fmt.Sprintf("%s,%s,%s", opts.Sender, m.identifier, capTime(m.sent)))
+	}
+
+	urgency := "Immediate"
+	if d.Status != types.Active {
+		urgency = "Past"
+	}
+	headline := fmt.Sprintf("%s reported", capitalize(d.DisasterType.Label()))
+	switch current.msgType {
+	case MsgUpdate:
+		headline = fmt.Sprintf("%s update", capitalize(d.DisasterType.Label()))
+	case MsgCancel:
+		headline = fmt.Sprintf("%s no longer active", capitalize(d.DisasterType.Label()))
+	}
+	description := strings.TrimSpace(d.Summary)
+	if description == "" {
+		description = fmt.Sprintf("%s from %s in the last %s report a %s.",
+			plural(d.TotalSkeetsAmount, "social media post"), plural(d.LocationCount, "location"), types.ShortWindow.Name, d.DisasterType.Label())
+	}
+	areaDesc := opts.AreaDesc
+	if areaDesc == "" {
+		areaDesc = fmt.Sprintf("%s around %.4f,%.4f", plural(d.LocationCount, "location"), d.Lat, d.Long)
+	}
+
+	info := CAPInfo{
+		Language:     "en-US",
+		Category:     []string{capCategory(d.DisasterType)},
+		Event:        capitalize(d.DisasterType.Label()),
+		ResponseType: "Monitor",
+		Urgency:      urgency,
+		Severity:     capSeverity(d.Severity),
+		Certainty:    "Likely", // inferred from social media posts, not confirmed
+		SenderName:   opts.SenderName,
+		Headline:     headline,
+		Description:  description,
+		Web:          opts.Web,
+		Parameters: []CAPParameter{
+			{ValueName: "status", Value: string(d.Status)},
+			{ValueName: "severityScore", Value: fmt.Sprintf("%.1f", d.SeverityScore)},
+			{ValueName: "totalSkeets", Value: fmt.Sprint(d.TotalSkeetsAmount)},
+			{ValueName: "locationCount", Value: fmt.Sprint(d.LocationCount)},
+			{ValueName: "areaKm2", Value: fmt.Sprintf("%.1f", d.AreaKM2)},
+		},
+		Areas: []CAPArea{capArea(d, areaDesc)},
+	}
+	if onset, err := time.Parse(time.RFC3339, d.ReportedDate); err == nil {
+		info.Onset = capTime(onset)
+	}
+
+	return CAPAlert{
+		Identifier: current.identifier,
+		Sender:     opts.Sender,
+		Sent:       capTime(current.sent),
+		Status:     "Actual",
+		MsgType:    current.msgType,
+		Scope:      "Public",
+		References: strings.Join(references, " "),
+		Info:       []CAPInfo{info},
+	}, nil
+}
+
+// disasterMessages derives a disaster's messages from its lifecycle, oldest first: the Alert when it was
+// first detected, an Update or Cancel for every status change after that and an Update for the last
+// detection run if it came later. There are none when the disaster has no date to send the Alert at.
+func disasterMessages(d types.DisasterData) []capMessage {
+	var messages []capMessage
+	add := func(at, msgType string) {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return
+		}
+		if n := len(messages); n > 0 && !t.After(messages[n-1].sent) {
+			return // one message per instant, identifiers have to be unique
+		}
+		messages = append(messages, capMessage{
+			identifier: fmt.Sprintf("firebird-disaster-%s-%d", d.ID, t.Unix()),
+			sent:       t.UTC(),
+			msgType:    msgType,
+		})
+	}
+
+	// The Alert is sent at the first of these that parses
+	for _, first := range []string{d.FirstDetected, d.ReportedDate, d.LastUpdate} {
+		add(first, MsgAlert)
+		if len(messages) > 0 {
+			break
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	transitions := append([]types.StatusTransition(nil), d.StatusHistory...)
+	sort.SliceStable(transitions, func(i, j int) bool { return transitions[i].At < transitions[j].At })
+	for _, t := range transitions {
+		switch {
+		case t.From == "":
+			continue // the first detection, already the Alert
+		case t.To == types.Not_Active:
+			add(t.At, MsgCancel)
+		case t.From == types.Not_Active:
+			add(t.At, MsgAlert)
+		default:
+			add(t.At, MsgUpdate)
+		}
+	}
+	if d.Status != types.Not_Active {
+		add(d.LastDetected, MsgUpdate)
+	}
+	return messages
+}
+
+// CAPSent is when the disaster's current message was sent, ErrUndated when it has none.
+func CAPSent(d types.DisasterData) (time.Time, error) {
+	messages := disasterMessages(d)
+	if len(messages) == 0 {
+		return time.Time{}, ErrUndated
+	}
+	return messages[len(messages)-1].sent, nil
+}
+
+// capArea is the hull as a CAP polygon ("lat,lon" pairs, closed), or a circle around the centroid with
+// the radius of the affected area for fewer than 3 hull points.
+func capArea(d types.DisasterData, areaDesc string) CAPArea {
+	area := CAPArea{AreaDesc: areaDesc}
+	if len(d.Hull) >= 3 {
+		points := make([]string, 0, len(d.Hull)+1)
+		for _, p := range d.Hull {
+			points = append(points, fmt.Sprintf("%.5f,%.5f", p.Lat, p.Long))
+		}
+		area.Polygons = []string{strings.Join(append(points, points[0]), " ")}
+		return area
+	}
+	radius := math.Max(math.Sqrt(d.AreaKM2/math.Pi), 1)
+	area.Circles = []string{fmt.Sprintf("%.5f,%.5f %.1f", d.Lat, d.Long, radius)}
+	return area
+}
+
+func capSeverity(s types.Severity) string {
+	switch s {
+	case types.Low:
+		return "Minor"
+	case types.Medium:
+		return "Moderate"
+	case types.High:
+		return "Severe"
+	case types.Critical:
+		return "Extreme"
+	}
+	return "Unknown"
+}
+
+func capCategory(c types.Category) string {
+	switch c {
+	case types.Wildfire:
+		return "Fire"
+	case types.Earthquake, types.Tsunami, types.VolcanicEruption, types.Landslide:
+		return "Geo"
+	case types.Hurricane, types.Flood, types.Tornado, types.Blizzard:
+		return "Met"
+	case types.Drought:
+		return "Env"
+	}
+	return "Other"
+}
+
+// capTime formats a time the way CAP wants it: no fractions and -00:00 instead of Z for UTC.
+func capTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05") + "-00:00"
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
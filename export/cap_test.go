@@ -0,0 +1,90 @@
+package export
+
+import (
+	"errors"
+	"go-firebird/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisasterMessages(t *testing.T) {
+	cases := []struct {
+		name     string
+		disaster types.DisasterData
+		want     []string // msgType@sent
+	}{
+		{
+			name:     "undated",
+			disaster: types.DisasterData{ID: "d", Status: types.Active},
+		},
+		{
+			name:     "unparseable dates",
+			disaster: types.DisasterData{ID: "d", Status: types.Active, FirstDetected: "yesterday", ReportedDate: "2025-01-07", LastUpdate: "soon"},
+		},
+		{
+			name:     "reported date when first detected doesn't parse",
+			disaster: types.DisasterData{ID: "d", Status: types.Active, FirstDetected: "yesterday", ReportedDate: "2025-01-07T18:00:00Z"},
+			want:     []string{"Alert@2025-01-07T18:00:00Z"},
+		},
+		{
+			name:     "last update only",
+			disaster: types.DisasterData{ID: "d", Status: types.Active, LastUpdate: "2025-01-07T21:00:00Z"},
+			want:     []string{"Alert@2025-01-07T21:00:00Z"},
+		},
+		{
+			name: "detected again",
+			disaster: types.DisasterData{
+				ID: "d", Status: types.Active,
+				FirstDetected: "2025-01-07T18:00:00Z",
+				LastDetected:  "2025-01-07T21:00:00Z",
+				StatusHistory: []types.StatusTransition{{To: types.Active, At: "2025-01-07T18:00:00Z"}},
+			},
+			want: []string{"Alert@2025-01-07T18:00:00Z", "Update@2025-01-07T21:00:00Z"},
+		},
+		{
+			name: "whole lifecycle",
+			disaster: types.DisasterData{
+				ID: "d", Status: types.Not_Active,
+				FirstDetected: "2025-01-07T18:00:00Z",
+				LastDetected:  "2025-01-08T03:00:00Z",
+				StatusHistory: []types.StatusTransition{
+					{From: types.Recovery, To: types.Not_Active, At: "2025-01-11T03:00:00Z"},
+					{To: types.Active, At: "2025-01-07T18:00:00Z"},
+					{From: types.Active, To: types.Recovery, At: "2025-01-08T03:00:00Z"},
+				},
+			},
+			want: []string{"Alert@2025-01-07T18:00:00Z", "Update@2025-01-08T03:00:00Z", "Cancel@2025-01-11T03:00:00Z"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			for _, m := range disasterMessages(c.disaster) {
+				got = append(got, m.msgType+"@"+m.sent.Format(time.RFC3339))
+			}
+			if strings.Join(got, " ") != strings.Join(c.want, " ") {
+				t.Fatalf("messages = %v, want %v", got, c.want)
+			}
+
+			alert, err := DisasterAlert(c.disaster, CAPOptions{Sender: "firebird@example.org"})
+			sent, sentErr := CAPSent(c.disaster)
+			if len(c.want) == 0 {
+				if !errors.Is(err, ErrUndated) || !errors.Is(sentErr, ErrUndated) {
+					t.Errorf("DisasterAlert: %v, CAPSent: %v, want ErrUndated", err, sentErr)
+				}
+				return
+			}
+			if err != nil || sentErr != nil {
+				t.Fatalf("DisasterAlert: %v, CAPSent: %v", err, sentErr)
+			}
+			current := c.want[len(c.want)-1]
+			if alert.MsgType+"@"+sent.Format(time.RFC3339) != current {
+				t.Errorf("current message = %s at %s, want %s", alert.MsgType, sent, current)
+			}
+			if refs := strings.Fields(alert.References); len(refs) != len(c.want)-1 {
+				t.Errorf("references = %q, want the %d earlier messages", alert.References, len(c.want)-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Package export writes locations and disasters in formats GIS tools read directly.
+package export
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects what an export includes. Zero fields don't filter.
+type Filter struct {
+	Categories []types.Category // locations with skeets in any of them, disasters of any of them
+	Since      time.Time        // active at or after: lastSkeetTimestamp / lastUpdate
+	Until      time.Time        // active at or before: firstSkeetTimestamp / reportedDate
+	BBox       *types.Region    // location or disaster centroid inside
+}
+
+// ParseFilter reads ?category=wildfire,flood&since=<RFC3339>&until=<RFC3339>&bbox=minLon,minLat,maxLon,maxLat.
+// The bbox is in GeoJSON order, minLon > maxLon crosses the antimeridian.
+func ParseFilter(query url.Values) (Filter, error) {
+	var f Filter
+	for _, raw := range strings.Split(query.Get("category"), ",") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		category, ok := types.ParseCategory(raw)
+		if !ok {
+			return f, fmt.Errorf("unknown category %q", raw)
+		}
+		f.Categories = append(f.Categories, category)
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC3339 timestamp: %w", name, err)
+			}
+			*dst = t
+		}
+	}
+	if !f.Since.IsZero() && !f.Until.IsZero() && f.Until.Before(f.Since) {
+		return f, fmt.Errorf("until is before since")
+	}
+
+	if v := query.Get("bbox"); v != "" {
+		parts := strings.Split(v, ",")
+		if len(parts) != 4 {
+			return f, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat")
+		}
+		var n [4]float64
+		for i, p := range parts {
+			x, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+				return f, fmt.Errorf("bbox must be minLon,minLat,maxLon,maxLat: %w", err)
+			}
+			n[i] = x
+		}
+		bbox := types.Region{Name: "bbox", MinLon: n[0], MinLat: n[1], MaxLon: n[2], MaxLat: n[3]}
+		if err := bbox.Validate(); err != nil {
+			return f, err
+		}
+		f.BBox = &bbox
+	}
+	return f, nil
+}
+
+func (f Filter) MatchLocation(l types.LocationData) bool {
+	if len(f.Categories) > 0 {
+		found := false
+		for _, c := range f.Categories {
+			if l.LatestDisasterCount[c] > 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.inTimeRange(l.FirstSkeetTimestamp, l.LastSkeetTimestamp) {
+		return false
+	}
+	return f.BBox == nil || f.BBox.Contains(l.Lat, l.Long)
+}
+
+func (f Filter) MatchDisaster(d types.DisasterData) bool {
+	if len(f.Categories) > 0 {
+		found := false
+		for _, c := range f.Categories {
+			if d.DisasterType == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.inTimeRange(d.ReportedDate, d.LastUpdate) {
+		return false
+	}
+	return f.BBox == nil || f.BBox.Contains(d.Lat, d.Long)
+}
+
+// inTimeRange reports whether [first, last] overlaps [Since, Until]. Records without the timestamps
+// are left out once a time range is given.
+func (f Filter) inTimeRange(first, last string) bool {
+	if !f.Since.IsZero() {
+		t, err := time.Parse(time.RFC3339, last)
+		if err != nil || t.Before(f.Since) {
+			return false
+		}
+	}
+	if !f.Until.IsZero() {
+		t, err := time.Parse(time.RFC3339, first)
+		if err != nil || t.After(f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// InTimeRange reports whether an RFC3339 timestamp is in [Since, Until]. A timestamp that doesn't parse
+// only matches when there is no time range.
+func (f Filter) InTimeRange(timestamp string) bool {
+	return f.inTimeRange(timestamp, timestamp)
+}
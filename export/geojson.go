@@ -0,0 +1,169 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"go-firebird/types"
+	"io"
+)
+
+// Geometry is a GeoJSON geometry, coordinates are [long, lat].
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Feature is a GeoJSON feature. Properties are flat so GIS tools show every one as a column.
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	BBox       []float64              `json:"bbox,omitempty"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollectionWriter streams a GeoJSON FeatureCollection, one feature at a time.
+type FeatureCollectionWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func NewFeatureCollectionWriter(w io.Writer) (*FeatureCollectionWriter, error) {
+	fw := &FeatureCollectionWriter{w: bufio.NewWriter(w)}
+	_, err := fw.w.WriteString(`{"type":"FeatureCollection","features":[`)
+	return fw, err
+}
+
+func (fw *FeatureCollectionWriter) Write(f Feature) error {
+	if fw.count > 0 {
+		if err := fw.w.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.w.Write(data); err != nil {
+		return err
+	}
+	fw.count++
+	return nil
+}
+
+// Count is the number of features written so far.
+func (fw *FeatureCollectionWriter) Count() int {
+	return fw.count
+}
+
+// Flush sends the buffered features on.
+func (fw *FeatureCollectionWriter) Flush() error {
+	return fw.w.Flush()
+}
+
+// Close ends the collection and flushes it.
+func (fw *FeatureCollectionWriter) Close() error {
+	if _, err := fw.w.WriteString("]}"); err != nil {
+		return err
+	}
+	return fw.w.Flush()
+}
+
+// LocationFeature is a Point with the location's latest sentiment and counts, its short window and
+// its burst score. Counts are one count_<category> property per category.
+func LocationFeature(l types.LocationData) Feature {
+	props := map[string]interface{}{
+		"id":                  l.ID,
+		"name":                l.LocationName,
+		"formattedAddress":    l.FormattedAddress,
+		"type":                l.Type,
+		"latestSentiment":     l.LatestSentiment,
+		"latestSkeetsAmount":  l.LatestSkeetsAmount,
+		"dominantCategory":    l.LatestDisasterCount.Dominant(),
+		"firstSkeetTimestamp": l.FirstSkeetTimestamp,
+		"lastSkeetTimestamp":  l.LastSkeetTimestamp,
+		"anomalyScore":        l.Anomaly.Score,
+	}
+	for _, c := range types.ClassificationOrder {
+		props["count_"+string(c)] = l.LatestDisasterCount[c]
+	}
+	if w, ok := l.Window(types.ShortWindow); ok {
+		props["skeets_"+types.ShortWindow.Name] = w.SkeetsAmount
+		props["sentiment_"+types.ShortWindow.Name] = w.AverageSentiment
+		props["disasterSkeets_"+types.ShortWindow.Name] = w.DisasterCount.DisasterTotal()
+	}
+
+	return Feature{
+		Type:       "Feature",
+		ID:         l.ID,
+		Geometry:   Geometry{Type: "Point", Coordinates: []float64{l.Long, l.Lat}},
+		Properties: props,
+	}
+}
+
+// Disaster geometries
+const (
+	GeometryHull     = "hull"     // the hull polygon, the bbox for disasters with fewer than 3 hull points
+	GeometryBBox     = "bbox"     // the bounding box polygon
+	GeometryCentroid = "centroid" // the weighted centroid point
+)
+
+// DisasterFeature is the disaster with the chosen geometry (see GeometryHull), the bounding box as
+// the feature bbox and its details as properties.
+func DisasterFeature(d types.DisasterData, geometry string) Feature {
+	box := d.BoundingBox
+	props := map[string]interface{}{
+		"id":                d.ID,
+		"disasterType":      d.DisasterType,
+		"severity":          d.Severity,
+		"severityScore":     d.SeverityScore,
+		"status":            d.Status,
+		"reportedDate":      d.ReportedDate,
+		"lastUpdate":        d.LastUpdate,
+		"firstDetected":     d.FirstDetected,
+		"lastDetected":      d.LastDetected,
+		"locationCount":     d.LocationCount,
+		"totalSkeetsAmount": d.TotalSkeetsAmount,
+		"clusterSentiment":  d.ClusterSentiment,
+		"anomalyScore":      d.AnomalyScore,
+		"areaKm2":           d.AreaKM2,
+		"centroidLat":       d.Lat,
+		"centroidLong":      d.Long,
+		"summary":           d.Summary,
+	}
+
+	var g Geometry
+	switch {
+	case geometry == GeometryCentroid:
+		g = Geometry{Type: "Point", Coordinates: []float64{d.Long, d.Lat}}
+	case geometry == GeometryHull && len(d.Hull) >= 3:
+		g = Geometry{Type: "Polygon", Coordinates: [][][]float64{ring(d.Hull)}}
+	case box.MinLat == box.MaxLat && box.MinLon == box.MaxLon:
+		// a single location has no area
+		g = Geometry{Type: "Point", Coordinates: []float64{box.MinLon, box.MinLat}}
+	default:
+		g = Geometry{Type: "Polygon", Coordinates: [][][]float64{ring([]types.GeoPoint{
+			{Lat: box.MinLat, Long: box.MinLon},
+			{Lat: box.MinLat, Long: box.MaxLon},
+			{Lat: box.MaxLat, Long: box.MaxLon},
+			{Lat: box.MaxLat, Long: box.MinLon},
+		})}}
+	}
+
+	return Feature{
+		Type:       "Feature",
+		ID:         d.ID,
+		BBox:       []float64{box.MinLon, box.MinLat, box.MaxLon, box.MaxLat},
+		Geometry:   g,
+		Properties: props,
+	}
+}
+
+// ring closes a counterclockwise list of points into a GeoJSON linear ring.
+func ring(points []types.GeoPoint) [][]float64 {
+	coords := make([][]float64, 0, len(points)+1)
+	for _, p := range points {
+		coords = append(coords, []float64{p.Long, p.Lat})
+	}
+	return append(coords, coords[0])
+}
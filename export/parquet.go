@@ -0,0 +1,128 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// rowGroupSize is how many rows are buffered before they're written as a row group.
+const rowGroupSize = 10000
+
+// ParquetWriter writes an uncompressed Parquet file with parquet-go. Strings, doubles and int64s are
+// required columns, timestamps are optional TIMESTAMP(MILLIS) columns so a zero time is null. Every
+// rowGroupSize rows are written as a row group and the footer on Close, nothing is written out of order
+// so the file can be streamed.
+type ParquetWriter struct {
+	w       *parquet.Writer
+	columns []Column
+	row     parquet.Row
+}
+
+func NewParquetWriter(w io.Writer, columns []Column) (*ParquetWriter, error) {
+	schema := parquet.NewSchema("firebird", parquetSchema(columns))
+	return &ParquetWriter{
+		w:       parquet.NewWriter(w, schema, parquet.MaxRowsPerRowGroup(rowGroupSize)),
+		columns: columns,
+		row:     make(parquet.Row, len(columns)),
+	}, nil
+}
+
+// parquetSchema is the schema of the columns, in their order. parquet.Group sorts its fields by name.
+func parquetSchema(columns []Column) parquet.Node {
+	group := columnGroup{Group: make(parquet.Group, len(columns))}
+	for _, c := range columns {
+		var node parquet.Node
+		switch c.Kind {
+		case KindDouble:
+			node = parquet.Leaf(parquet.DoubleType)
+		case KindInt64:
+			node = parquet.Leaf(parquet.Int64Type)
+		case KindTimestamp:
+			node = parquet.Optional(parquet.Timestamp(parquet.Millisecond))
+		default:
+			node = parquet.String()
+		}
+		group.Group[c.Name] = node
+		group.fields = append(group.fields, columnField{Node: node, name: c.Name})
+	}
+	return group
+}
+
+// columnGroup is a parquet.Group that keeps its fields in the order of the exported columns.
+type columnGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g columnGroup) Fields() []parquet.Field { return g.fields }
+
+type columnField struct {
+	parquet.Node
+	name string
+}
+
+func (f columnField) Name() string { return f.name }
+
+func (f columnField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
+
+func (pw *ParquetWriter) WriteRow(row []interface{}) error {
+	if len(row) != len(pw.columns) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(pw.columns))
+	}
+	for i, v := range row {
+		var value parquet.Value
+		var expected string
+		switch pw.columns[i].Kind {
+		case KindString:
+			if s, ok := v.(string); ok {
+				value = parquet.ByteArrayValue([]byte(s)).Level(0, 0, i)
+			} else {
+				expected = "a string"
+			}
+		case KindDouble:
+			if f, ok := v.(float64); ok {
+				value = parquet.DoubleValue(f).Level(0, 0, i)
+			} else {
+				expected = "a float64"
+			}
+		case KindInt64:
+			if n, ok := v.(int64); ok {
+				value = parquet.Int64Value(n).Level(0, 0, i)
+			} else {
+				expected = "an int64"
+			}
+		case KindTimestamp:
+			t, ok := v.(time.Time)
+			switch {
+			case !ok:
+				expected = "a time.Time"
+			case t.IsZero():
+				value = parquet.NullValue().Level(0, 0, i)
+			default:
+				value = parquet.Int64Value(t.UnixMilli()).Level(0, 1, i)
+			}
+		}
+		if expected != "" {
+			return fmt.Errorf("column %s: expected %s, got %T", pw.columns[i].Name, expected, v)
+		}
+		pw.row[i] = value
+	}
+	_, err := pw.w.WriteRows([]parquet.Row{pw.row})
+	return err
+}
+
+// Flush does nothing, rows are only written once a row group is full.
+func (pw *ParquetWriter) Flush() error {
+	return nil
+}
+
+// Close writes the last row group and the footer.
+func (pw *ParquetWriter) Close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,146 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+func TestParquetWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "uri", Kind: KindString},
+		{Name: "sentiment", Kind: KindDouble},
+		{Name: "likes", Kind: KindInt64},
+		{Name: "timestamp", Kind: KindTimestamp},
+	}
+	base := time.Date(2025, 1, 7, 18, 0, 0, 0, time.UTC)
+	numRows := 2*rowGroupSize + 123
+	rows := make([][]interface{}, numRows)
+	for i := range rows {
+		ts := base.Add(time.Duration(i) * time.Second)
+		// nulls both scattered and in a long run across the first row group boundary
+		if i%7 == 0 || (i >= rowGroupSize-50 && i < rowGroupSize+50) {
+			ts = time.Time{}
+		}
+		rows[i] = []interface{}{fmt.Sprintf("at://skeet/%d", i), float64(i%200)/100 - 1, int64(i * 3), ts}
+	}
+
+	var buf bytes.Buffer
+	pw, err := NewParquetWriter(&buf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := pw.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if file.NumRows() != int64(numRows) {
+		t.Fatalf("%d rows, want %d", file.NumRows(), numRows)
+	}
+
+	// The columns keep their order, with the types readers map to string, double, int64 and timestamp[ms]
+	schema := file.Metadata().Schema
+	if len(schema) != len(columns)+1 {
+		t.Fatalf("schema = %+v", schema)
+	}
+	for i, c := range columns {
+		el := schema[i+1]
+		if el.Name != c.Name {
+			t.Fatalf("column %d is %s, want %s", i, el.Name, c.Name)
+		}
+		wantRepetition := format.Required
+		if c.Kind == KindTimestamp {
+			wantRepetition = format.Optional
+		}
+		if el.RepetitionType == nil || *el.RepetitionType != wantRepetition {
+			t.Errorf("column %s repetition = %v, want %v", c.Name, el.RepetitionType, wantRepetition)
+		}
+		switch c.Kind {
+		case KindString:
+			if el.Type == nil || *el.Type != format.ByteArray || el.LogicalType == nil || el.LogicalType.UTF8 == nil {
+				t.Errorf("column %s = %+v, want a UTF8 byte array", c.Name, el)
+			}
+		case KindDouble:
+			if el.Type == nil || *el.Type != format.Double {
+				t.Errorf("column %s = %+v, want a double", c.Name, el)
+			}
+		case KindInt64:
+			if el.Type == nil || *el.Type != format.Int64 || (el.LogicalType != nil && (el.LogicalType.Integer == nil || !el.LogicalType.Integer.IsSigned)) {
+				t.Errorf("column %s = %+v, want a signed int64", c.Name, el)
+			}
+		case KindTimestamp:
+			ts := el.LogicalType
+			if el.Type == nil || *el.Type != format.Int64 || ts == nil || ts.Timestamp == nil || ts.Timestamp.Unit.Millis == nil || !ts.Timestamp.IsAdjustedToUTC {
+				t.Errorf("column %s = %+v, want a UTC millisecond timestamp", c.Name, el)
+			}
+		}
+	}
+
+	groups := file.RowGroups()
+	wantGroups := []int64{rowGroupSize, rowGroupSize, 123}
+	if len(groups) != len(wantGroups) {
+		t.Fatalf("%d row groups, want %d", len(groups), len(wantGroups))
+	}
+	for g, group := range groups {
+		if group.NumRows() != wantGroups[g] {
+			t.Errorf("row group %d has %d rows, want %d", g, group.NumRows(), wantGroups[g])
+		}
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	read := make([]parquet.Row, 1000)
+	i := 0
+	for {
+		n, err := reader.ReadRows(read)
+		for _, row := range read[:n] {
+			want := rows[i]
+			got := []interface{}{string(row[0].ByteArray()), row[1].Double(), row[2].Int64(), time.Time{}}
+			if !row[3].IsNull() {
+				got[3] = time.UnixMilli(row[3].Int64()).UTC()
+			}
+			for c := range columns {
+				if got[c] != want[c] {
+					t.Fatalf("row %d column %s = %v, want %v", i, columns[c].Name, got[c], want[c])
+				}
+			}
+			i++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadRows after %d rows: %v", i, err)
+		}
+	}
+	if i != numRows {
+		t.Errorf("read %d rows, want %d", i, numRows)
+	}
+}
+
+func TestParquetWriterRejectsWrongTypes(t *testing.T) {
+	pw, err := NewParquetWriter(&bytes.Buffer{}, []Column{{Name: "likes", Kind: KindInt64}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteRow([]interface{}{3}); err == nil {
+		t.Error("an int was written to an int64 column")
+	}
+	if err := pw.WriteRow([]interface{}{int64(3), int64(4)}); err == nil {
+		t.Error("a row with too many values was written")
+	}
+}
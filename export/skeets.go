@@ -0,0 +1,126 @@
+package export
+
+import (
+	"go-firebird/db"
+	"go-firebird/types"
+	"strings"
+	"time"
+)
+
+// SkeetColumns are the columns of a skeet export: the skeet, its classification (the top category and
+// one prob_<category> column per category in ClassificationOrder), its sentiment and the LOCATION and
+// ADDRESS entities found in it, "; " separated.
+func SkeetColumns() []Column {
+	columns := []Column{
+		{"id", KindString},
+		{"uid", KindString},
+		{"timestamp", KindTimestamp},
+		{"handle", KindString},
+		{"content", KindString},
+		{"category", KindString},
+		{"classifiedBy", KindString},
+	}
+	for _, c := range types.ClassificationOrder {
+		columns = append(columns, Column{"prob_" + string(c), KindDouble})
+	}
+	return append(columns,
+		Column{"sentimentScore", KindDouble},
+		Column{"sentimentMagnitude", KindDouble},
+		Column{"locations", KindString},
+		Column{"addresses", KindString},
+		Column{"likeCount", KindInt64},
+		Column{"repostCount", KindInt64},
+	)
+}
+
+// SkeetRow is the row of a skeet in SkeetColumns order. Skeets classified before a category was added
+// have 0 for it. A timestamp that doesn't parse is null.
+func SkeetRow(r types.SkeetRecord) []interface{} {
+	s := r.Skeet
+	timestamp, _ := time.Parse(time.RFC3339, s.Timestamp)
+	dist := types.DistributionFromVector(s.Classification)
+
+	row := []interface{}{r.ID, s.UID, timestamp, s.Handle, s.Content, string(skeetCategory(s)), s.ClassifiedBy}
+	for _, c := range types.ClassificationOrder {
+		row = append(row, dist[c])
+	}
+	return append(row,
+		float64(s.Sentiment.Score),
+		float64(s.Sentiment.Magnitude),
+		entityNames(r.Locations),
+		entityNames(r.Addresses),
+		int64(s.LikeCount),
+		int64(s.RepostCount),
+	)
+}
+
+// skeetCategory is the most likely category of a skeet, empty if it was never classified.
+func skeetCategory(s types.Skeet) types.Category {
+	if len(s.Classification) == 0 {
+		return ""
+	}
+	return types.DistributionFromVector(s.Classification).Top()
+}
+
+func entityNames(entities []types.Entity) string {
+	names := make([]string, len(entities))
+	for i, e := range entities {
+		names[i] = e.Name
+	}
+	return strings.Join(names, "; ")
+}
+
+// MatchSkeet reports whether the skeet's top category is one of Categories and its timestamp is in
+// [Since, Until]. BBox doesn't apply, skeets have no coordinates.
+func (f Filter) MatchSkeet(r types.SkeetRecord) bool {
+	if len(f.Categories) > 0 {
+		category := skeetCategory(r.Skeet)
+		found := false
+		for _, c := range f.Categories {
+			if category == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return f.inTimeRange(r.Skeet.Timestamp, r.Skeet.Timestamp)
+}
+
+// StoreRange is the [since, until) timestamp range to read from the store, see db.Store.EachSkeet. It's
+// one second wider than the filter, stored timestamps have varying precision and MatchSkeet is exact.
+func (f Filter) StoreRange() (since, until string) {
+	if !f.Since.IsZero() {
+		since = f.Since.Add(-time.Second).UTC().Format(time.RFC3339)
+	}
+	if !f.Until.IsZero() {
+		until = f.Until.Add(time.Second).UTC().Format(time.RFC3339)
+	}
+	return since, until
+}
+
+// flushRows is how many rows WriteSkeets writes between flushes.
+const flushRows = 500
+
+// WriteSkeets writes every stored skeet matching the filter, in timestamp order, and returns how many
+// were written. The table is not closed.
+func WriteSkeets(store db.Store, f Filter, tw TableWriter) (int, error) {
+	since, until := f.StoreRange()
+	written := 0
+	err := store.EachSkeet(since, until, func(r types.SkeetRecord) error {
+		if !f.MatchSkeet(r) {
+			return nil
+		}
+		if err := tw.WriteRow(SkeetRow(r)); err != nil {
+			return err
+		}
+		written++
+		if written%flushRows == 0 {
+			return tw.Flush()
+		}
+		return nil
+	})
+	return written, err
+}
@@ -0,0 +1,99 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Kind is the type of a table column.
+type Kind int
+
+const (
+	KindString    Kind = iota
+	KindDouble         // float64
+	KindInt64          // int64
+	KindTimestamp      // time.Time, the zero time is null
+)
+
+// Column is a column of an exported table.
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// TableWriter writes rows, one value per column in the column's Kind.
+type TableWriter interface {
+	WriteRow(row []interface{}) error
+	// Flush sends on what is buffered, a Parquet writer can only do that at the end of a row group
+	Flush() error
+	// Close finishes the table, the underlying writer is not closed
+	Close() error
+}
+
+// Table formats
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// NewTableWriter returns a writer for the format, FormatCSV or FormatParquet.
+func NewTableWriter(format string, w io.Writer, columns []Column) (TableWriter, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w, columns)
+	case FormatParquet:
+		return NewParquetWriter(w, columns)
+	}
+	return nil, fmt.Errorf("unknown format %q, expected %s or %s", format, FormatCSV, FormatParquet)
+}
+
+// CSVWriter writes a header line and one line per row. Timestamps are RFC3339, nulls are empty.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []Column
+	record  []string
+}
+
+func NewCSVWriter(w io.Writer, columns []Column) (*CSVWriter, error) {
+	cw := &CSVWriter{w: csv.NewWriter(w), columns: columns, record: make([]string, len(columns))}
+	for i, c := range columns {
+		cw.record[i] = c.Name
+	}
+	return cw, cw.w.Write(cw.record)
+}
+
+func (cw *CSVWriter) WriteRow(row []interface{}) error {
+	if len(row) != len(cw.columns) {
+		return fmt.Errorf("row has %d values, expected %d", len(row), len(cw.columns))
+	}
+	for i, v := range row {
+		switch v := v.(type) {
+		case string:
+			cw.record[i] = v
+		case float64:
+			cw.record[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		case int64:
+			cw.record[i] = strconv.FormatInt(v, 10)
+		case time.Time:
+			cw.record[i] = ""
+			if !v.IsZero() {
+				cw.record[i] = v.UTC().Format(time.RFC3339Nano)
+			}
+		default:
+			return fmt.Errorf("column %s: unsupported value %T", cw.columns[i].Name, v)
+		}
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *CSVWriter) Close() error {
+	return cw.Flush()
+}
@@ -0,0 +1,281 @@
+package feeds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/types"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigPath = "./data/feeds.yaml"
+	defaultPageSize   = 50
+	maxPageSize       = 100
+)
+
+var (
+	ErrNotFound = errors.New("feed not found")
+	validID     = regexp.MustCompile(`^[a-z0-9_-]+$`)
+)
+
+// ConfigPath returns FEEDS_CONFIG or the bundled ./data/feeds.yaml.
+func ConfigPath() string {
+	if p := os.Getenv("FEEDS_CONFIG"); p != "" {
+		return p
+	}
+	return defaultConfigPath
+}
+
+type configFile struct {
+	Feeds []types.FeedConfig `json:"feeds" yaml:"feeds"`
+}
+
+// LoadConfig reads a feeds file, JSON if the extension is .json and YAML otherwise.
+func LoadConfig(path string) ([]types.FeedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg configFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = yaml.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for i := range cfg.Feeds {
+		if err := Normalize(&cfg.Feeds[i]); err != nil {
+			return nil, fmt.Errorf("%s: feed %d: %w", path, i, err)
+		}
+	}
+	return cfg.Feeds, nil
+}
+
+// Normalize fills in defaults and checks a feed is usable.
+func Normalize(feed *types.FeedConfig) error {
+	feed.ID = strings.TrimSpace(feed.ID)
+	feed.URI = strings.TrimSpace(feed.URI)
+	if !validID.MatchString(feed.ID) {
+		return fmt.Errorf("id %q must be lowercase letters, digits, - or _", feed.ID)
+	}
+	if !strings.HasPrefix(feed.URI, "at://") || !strings.Contains(feed.URI, "/app.bsky.feed.generator/") {
+		return fmt.Errorf("uri %q is not a feed generator at:// URI", feed.URI)
+	}
+	if feed.Category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if !feed.Category.IsKnown() {
+		return fmt.Errorf("unknown category %q", feed.Category)
+	}
+	if feed.Name == "" {
+		feed.Name = feed.ID
+	}
+	if feed.PageSize == 0 {
+		feed.PageSize = defaultPageSize
+	}
+	if feed.PageSize < 1 || feed.PageSize > maxPageSize {
+		return fmt.Errorf("pageSize must be between 1 and %d", maxPageSize)
+	}
+	if feed.Schedule == "" {
+		return fmt.Errorf("schedule is required")
+	}
+	if _, err := cron.ParseStandard(feed.Schedule); err != nil {
+		return fmt.Errorf("schedule %q: %w", feed.Schedule, err)
+	}
+	return nil
+}
+
+// Registry is the set of feeds, kept in the store so admin changes survive restarts.
+type Registry struct {
+	store     db.Store
+	mu        sync.RWMutex
+	feeds     map[string]types.FeedConfig
+	listeners []func()
+}
+
+func NewRegistry(store db.Store) *Registry {
+	return &Registry{
+		store: store,
+		feeds: make(map[string]types.FeedConfig),
+	}
+}
+
+// InitRegistry loads the registry from the store, adding any feed from the config file (ConfigPath) it doesn't have yet.
+// A missing config file is not an error, the registry then only has what the store has.
+func InitRegistry(store db.Store) (*Registry, error) {
+	r := NewRegistry(store)
+
+	configured, err := LoadConfig(ConfigPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		log.Printf("No feeds config at %s, using stored feeds only", ConfigPath())
+	}
+
+	stored, err := store.GetFeedConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("loading feeds: %w", err)
+	}
+	for _, feed := range stored {
+		r.feeds[feed.ID] = feed
+	}
+
+	for _, feed := range configured {
+		if _, ok := r.feeds[feed.ID]; ok {
+			continue
+		}
+		if err := store.SaveFeedConfig(feed); err != nil {
+			return nil, fmt.Errorf("saving feed %s: %w", feed.ID, err)
+		}
+		r.feeds[feed.ID] = feed
+		log.Printf("Added feed %s from %s", feed.ID, ConfigPath())
+	}
+	return r, nil
+}
+
+// OnChange registers fn to be called after every Put or Delete.
+func (r *Registry) OnChange(fn func()) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, fn)
+	r.mu.Unlock()
+}
+
+func (r *Registry) notify() {
+	r.mu.RLock()
+	listeners := append([]func(){}, r.listeners...)
+	r.mu.RUnlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+// List returns every feed sorted by ID.
+func (r *Registry) List() []types.FeedConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]types.FeedConfig, 0, len(r.feeds))
+	for _, feed := range r.feeds {
+		list = append(list, feed)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Enabled returns the feeds the scheduler should poll.
+func (r *Registry) Enabled() []types.FeedConfig {
+	var enabled []types.FeedConfig
+	for _, feed := range r.List() {
+		if feed.Enabled {
+			enabled = append(enabled, feed)
+		}
+	}
+	return enabled
+}
+
+func (r *Registry) Get(id string) (types.FeedConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	feed, ok := r.feeds[id]
+	return feed, ok
+}
+
+// Resolve finds a feed by ID, alias or URI. Any other at:// URI is returned as an unregistered feed,
+// so one-off feeds can still be fetched or backfilled.
+func (r *Registry) Resolve(name string) (types.FeedConfig, bool) {
+	for _, feed := range r.List() {
+		if feed.ID == name || feed.URI == name {
+			return feed, true
+		}
+		for _, alias := range feed.Aliases {
+			if alias == name {
+				return feed, true
+			}
+		}
+	}
+	if strings.HasPrefix(name, "at://") {
+		return types.FeedConfig{ID: name, Name: name, URI: name, PageSize: defaultPageSize}, true
+	}
+	return types.FeedConfig{}, false
+}
+
+// ForCategory returns the first enabled feed expected to carry posts of the category.
+func (r *Registry) ForCategory(category types.Category) (types.FeedConfig, bool) {
+	for _, feed := range r.Enabled() {
+		if feed.Category == category {
+			return feed, true
+		}
+	}
+	return types.FeedConfig{}, false
+}
+
+// Put adds or replaces a feed.
+func (r *Registry) Put(feed types.FeedConfig) (types.FeedConfig, error) {
+	if err := Normalize(&feed); err != nil {
+		return feed, err
+	}
+
+	r.mu.RLock()
+	for _, other := range r.feeds {
+		if other.ID == feed.ID {
+			continue
+		}
+		for _, alias := range feed.Aliases {
+			if alias == other.ID || contains(other.Aliases, alias) {
+				r.mu.RUnlock()
+				return feed, fmt.Errorf("alias %q is already used by feed %s", alias, other.ID)
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	if err := r.store.SaveFeedConfig(feed); err != nil {
+		return feed, err
+	}
+	r.mu.Lock()
+	r.feeds[feed.ID] = feed
+	r.mu.Unlock()
+
+	r.notify()
+	return feed, nil
+}
+
+func (r *Registry) Delete(id string) error {
+	if _, ok := r.Get(id); !ok {
+		return ErrNotFound
+	}
+	if err := r.store.DeleteFeedConfig(id); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.feeds, id)
+	r.mu.Unlock()
+
+	r.notify()
+	return nil
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package gazetteer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const defaultPath = "./data/gazetteer.tsv"
+
+// Place is one row of the gazetteer file.
+type Place struct {
+	Name           string
+	Feature        string // country, state or city
+	CountryCode    string
+	Admin1         string // state code for US places
+	Lat            float64
+	Lng            float64
+	Population     int
+	AlternateNames []string
+}
+
+// Gazetteer is an in-memory list of places indexed by normalized name and alternate names.
+type Gazetteer struct {
+	Places   []Place
+	byName   map[string][]int
+	maxWords int
+}
+
+// Path returns GAZETTEER_PATH or the bundled ./data/gazetteer.tsv.
+func Path() string {
+	if p := os.Getenv("GAZETTEER_PATH"); p != "" {
+		return p
+	}
+	return defaultPath
+}
+
+// Normalize lowercases a name and turns punctuation into single spaces, so "St. Louis" and "st louis" match.
+func Normalize(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+func New(places []Place) *Gazetteer {
+	g := &Gazetteer{
+		Places: places,
+		byName: make(map[string][]int),
+	}
+	for i, p := range places {
+		names := append([]string{p.Name}, p.AlternateNames...)
+		for _, name := range names {
+			key := Normalize(name)
+			if key == "" {
+				continue
+			}
+			g.byName[key] = append(g.byName[key], i)
+			if words := len(strings.Fields(key)); words > g.maxWords {
+				g.maxWords = words
+			}
+		}
+	}
+	return g
+}
+
+// Load reads a tab separated file with the columns
+// name, feature, countryCode, admin1, lat, lng, population, alternateNames (comma separated).
+// Lines starting with # are comments.
+func Load(path string) (*Gazetteer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var places []Place
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) < 6 {
+			return nil, fmt.Errorf("%s:%d: expected at least 6 columns, got %d", path, lineNumber, len(cols))
+		}
+
+		lat, err := strconv.ParseFloat(cols[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad lat: %w", path, lineNumber, err)
+		}
+		lng, err := strconv.ParseFloat(cols[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad lng: %w", path, lineNumber, err)
+		}
+
+		place := Place{
+			Name:        cols[0],
+			Feature:     cols[1],
+			CountryCode: cols[2],
+			Admin1:      cols[3],
+			Lat:         lat,
+			Lng:         lng,
+		}
+		if len(cols) > 6 && cols[6] != "" {
+			place.Population, _ = strconv.Atoi(cols[6])
+		}
+		if len(cols) > 7 && cols[7] != "" {
+			for _, alt := range strings.Split(cols[7], ",") {
+				if alt = strings.TrimSpace(alt); alt != "" {
+					place.AlternateNames = append(place.AlternateNames, alt)
+				}
+			}
+		}
+		places = append(places, place)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return New(places), nil
+}
+
+// MaxWords is the longest name in the gazetteer counted in words.
+func (g *Gazetteer) MaxWords() int {
+	return g.maxWords
+}
+
+// Candidates returns every place with the given (already normalized) name.
+func (g *Gazetteer) Candidates(normalizedName string) []Place {
+	idxs := g.byName[normalizedName]
+	out := make([]Place, len(idxs))
+	for i, idx := range idxs {
+		out[i] = g.Places[idx]
+	}
+	return out
+}
+
+// Lookup returns the most populous place with the given name.
+func (g *Gazetteer) Lookup(name string) (Place, bool) {
+	candidates := g.Candidates(Normalize(name))
+	if len(candidates) == 0 {
+		return Place{}, false
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.Population > best.Population {
+			best = c
+		}
+	}
+	return best, true
+}
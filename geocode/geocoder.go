@@ -0,0 +1,241 @@
+package geocode
+
+import (
+	"context"
+	"fmt"
+	"go-firebird/gazetteer"
+	"go-firebird/types"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"googlemaps.github.io/maps"
+)
+
+// Geocoder resolves a location name to coordinates.
+// A name with no match is not an error, it returns a result with Found set to false.
+// Errors are only for failures worth retrying (network, quota...).
+type Geocoder interface {
+	Name() string
+	Geocode(address string) (types.GeocodeResult, error)
+}
+
+// GoogleGeocoder uses the Google Maps Geocoding API.
+type GoogleGeocoder struct {
+	Client *maps.Client
+}
+
+func (g *GoogleGeocoder) Name() string { return "google" }
+
+func (g *GoogleGeocoder) Geocode(address string) (types.GeocodeResult, error) {
+	result := types.GeocodeResult{Query: address, Source: g.Name()}
+
+	results, err := g.Client.Geocode(context.Background(), &maps.GeocodingRequest{Address: address})
+	if err != nil {
+		return result, err
+	}
+	if len(results) == 0 {
+		return result, nil
+	}
+
+	result.Found = true
+	result.FormattedAddress = results[0].FormattedAddress
+	result.Lat = results[0].Geometry.Location.Lat
+	result.Long = results[0].Geometry.Location.Lng
+	return result, nil
+}
+
+// OfflineGeocoder resolves names against the bundled gazetteer, no network needed.
+// It understands plain names ("Tokyo") and qualified ones ("Springfield, IL", "Paris, France").
+type OfflineGeocoder struct {
+	Gazetteer *gazetteer.Gazetteer
+}
+
+func NewOfflineGeocoder(g *gazetteer.Gazetteer) *OfflineGeocoder {
+	return &OfflineGeocoder{Gazetteer: g}
+}
+
+func (o *OfflineGeocoder) Name() string { return "offline" }
+
+func (o *OfflineGeocoder) Geocode(address string) (types.GeocodeResult, error) {
+	result := types.GeocodeResult{Query: address, Source: o.Name()}
+
+	place, ok := o.Gazetteer.Lookup(address)
+	if !ok {
+		parts := strings.Split(address, ",")
+		if len(parts) > 1 {
+			place, ok = o.lookupQualified(strings.TrimSpace(parts[0]), parts[1:])
+		}
+	}
+	if !ok {
+		return result, nil
+	}
+
+	result.Found = true
+	result.FormattedAddress = o.formatAddress(place)
+	result.Lat = place.Lat
+	result.Long = place.Lng
+	return result, nil
+}
+
+// lookupQualified picks the candidate for name whose state or country matches one of the qualifiers.
+func (o *OfflineGeocoder) lookupQualified(name string, qualifiers []string) (gazetteer.Place, bool) {
+	candidates := o.Gazetteer.Candidates(gazetteer.Normalize(name))
+	if len(candidates) == 0 {
+		return gazetteer.Place{}, false
+	}
+
+	recognized := false
+	for _, q := range qualifiers {
+		q = gazetteer.Normalize(q)
+		if q == "" {
+			continue
+		}
+		// the qualifier itself can be a gazetteer place, e.g. "Illinois" -> admin1 IL, "Japan" -> country JP
+		qualifierPlace, qualifierKnown := o.Gazetteer.Lookup(q)
+		if qualifierKnown && (qualifierPlace.Feature == "state" || qualifierPlace.Feature == "country") || o.isRegionCode(q) {
+			recognized = true
+		}
+		for _, c := range candidates {
+			if strings.EqualFold(c.Admin1, q) || strings.EqualFold(c.CountryCode, q) {
+				return c, true
+			}
+			if !qualifierKnown {
+				continue
+			}
+			switch qualifierPlace.Feature {
+			case "state":
+				if c.CountryCode == qualifierPlace.CountryCode && c.Admin1 == qualifierPlace.Admin1 {
+					return c, true
+				}
+			case "country":
+				if c.CountryCode == qualifierPlace.CountryCode {
+					return c, true
+				}
+			}
+		}
+	}
+
+	// "Portland, ME" when only Portland, OR is known is a different place, not a match
+	if recognized {
+		return gazetteer.Place{}, false
+	}
+	// Qualifier didn't mean anything (a street, a neighborhood...), fall back to the most populous candidate
+	return o.Gazetteer.Lookup(name)
+}
+
+// isRegionCode reports whether code is a state or country code used in the gazetteer, e.g. "me" or "jp".
+func (o *OfflineGeocoder) isRegionCode(code string) bool {
+	for _, p := range o.Gazetteer.Places {
+		if (p.Feature == "state" && strings.EqualFold(p.Admin1, code)) ||
+			(p.Feature == "country" && strings.EqualFold(p.CountryCode, code)) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAddress mimics Google's "City, ST, USA" / "City, Country" style.
+func (o *OfflineGeocoder) formatAddress(p gazetteer.Place) string {
+	if p.Feature == "country" {
+		return p.Name
+	}
+
+	parts := []string{p.Name}
+	if p.Feature == "city" && p.Admin1 != "" {
+		parts = append(parts, p.Admin1)
+	}
+	if country, ok := o.countryName(p.CountryCode); ok {
+		if p.CountryCode == "US" {
+			country = "USA"
+		}
+		parts = append(parts, country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (o *OfflineGeocoder) countryName(code string) (string, bool) {
+	for _, p := range o.Gazetteer.Places {
+		if p.Feature == "country" && p.CountryCode == code {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+// Cache stores geocode results by normalized location name. db.Store implements it.
+type Cache interface {
+	GetGeocode(key string) (types.GeocodeResult, bool, error)
+	SaveGeocode(key string, result types.GeocodeResult) error
+}
+
+// CachedGeocoder checks the cache before calling Backend and saves everything Backend returns,
+// including names it could not find. A cached negative from a different backend is ignored,
+// so switching from offline to google retries names the gazetteer doesn't know.
+type CachedGeocoder struct {
+	Backend Geocoder
+	Cache   Cache
+}
+
+// CacheKey is the cache key for a location name, so "New York" and "new york!" share an entry.
+func CacheKey(address string) string {
+	return gazetteer.Normalize(address)
+}
+
+func (c *CachedGeocoder) Name() string { return c.Backend.Name() }
+
+func (c *CachedGeocoder) Geocode(address string) (types.GeocodeResult, error) {
+	key := CacheKey(address)
+	if key == "" {
+		return types.GeocodeResult{Query: address, Source: c.Backend.Name()}, nil
+	}
+
+	cached, ok, err := c.Cache.GetGeocode(key)
+	if err != nil {
+		log.Printf("Geocode cache read failed for %s: %v", address, err)
+	} else if ok && (cached.Found || cached.Source == c.Backend.Name()) {
+		return cached, nil
+	}
+
+	result, err := c.Backend.Geocode(address)
+	if err != nil {
+		return result, err
+	}
+
+	result.CachedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := c.Cache.SaveGeocode(key, result); err != nil {
+		log.Printf("Geocode cache write failed for %s: %v", address, err)
+	}
+	return result, nil
+}
+
+// InitGeocoder builds the geocoder chosen by the GEOCODER env variable:
+//   - "google" (default): Google Maps Geocoding API, needs MAPS_CREDENTIALS
+//   - "offline": the bundled gazetteer (GAZETTEER_PATH), no network needed
+//
+// Results are cached in cache unless GEOCODE_CACHE=off. cache can be nil to disable caching.
+func InitGeocoder(cache Cache) (Geocoder, error) {
+	var backend Geocoder
+	switch os.Getenv("GEOCODER") {
+	case "", "google":
+		client, err := InitMapsClient()
+		if err != nil {
+			return nil, err
+		}
+		backend = &GoogleGeocoder{Client: client}
+	case "offline":
+		g, err := gazetteer.Load(gazetteer.Path())
+		if err != nil {
+			return nil, fmt.Errorf("loading gazetteer: %w", err)
+		}
+		backend = NewOfflineGeocoder(g)
+	default:
+		return nil, fmt.Errorf("unknown GEOCODER %q, expected google or offline", os.Getenv("GEOCODER"))
+	}
+
+	if cache == nil || os.Getenv("GEOCODE_CACHE") == "off" {
+		return backend, nil
+	}
+	return &CachedGeocoder{Backend: backend, Cache: cache}, nil
+}
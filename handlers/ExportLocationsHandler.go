@@ -12,16 +12,15 @@ import (
 	// "strings" // Not needed
 	// "sync" // Not needed
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 )
 
 // ExportLocationsHandler fetches all valid locations and saves them to a local JSON file.
-func ExportLocationsHandler(c *gin.Context, firestoreClient *firestore.Client) {
+func ExportLocationsHandler(c *gin.Context, store db.Store) {
 	log.Println("Received request to export locations...")
 
 	// 1. Fetch all valid locations
-	validLocations, err := db.GetValidLocations(firestoreClient)
+	validLocations, err := store.GetValidLocations()
 	if err != nil {
 		log.Printf("Error fetching valid locations for export: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"go-firebird/auth"
+	"go-firebird/db"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WhoAmI returns the principal of the request, to check a key or token.
+func WhoAmI(c *gin.Context) {
+	principal, _ := auth.PrincipalFrom(c)
+	c.JSON(http.StatusOK, principal)
+}
+
+func ListAPIKeys(c *gin.Context, authn *auth.Authenticator) {
+	keys, err := authn.ListKeys()
+	if err != nil {
+		log.Printf("Error listing API keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// CreateAPIKey makes a key with the name, role and optional expiresAt of the body. The response is
+// the only time the key is returned.
+func CreateAPIKey(c *gin.Context, authn *auth.Authenticator) {
+	var req auth.KeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	principal, _ := auth.PrincipalFrom(c)
+
+	plain, key, err := authn.CreateKey(req, principal.ID)
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("API key %s (%s, %s) created by %s", key.ID, key.Name, key.Role, principal.ID)
+	c.JSON(http.StatusCreated, gin.H{"key": plain, "apiKey": key})
+}
+
+func RevokeAPIKey(c *gin.Context, authn *auth.Authenticator) {
+	key, err := authn.RevokeKey(c.Param("id"))
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking API key %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// ListAuditEntries is the audit log of operator and admin requests, most recent first.
+// Query: actor (API key ID or JWT subject) and limit (1-500, default 100).
+func ListAuditEntries(c *gin.Context, store db.Store) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	entries, err := store.GetAuditEntries(c.Query("actor"), limit)
+	if err != nil {
+		log.Printf("Error getting audit entries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "count": len(entries)})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"go-firebird/db"
+	"go-firebird/events"
 	"go-firebird/processor"
 	"go-firebird/types"
 	"log"
@@ -9,23 +10,22 @@ import (
 	"strings"
 	"sync"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 )
 
-func TestLocationSentimentUpdate(c *gin.Context, firestoreClient *firestore.Client) {
+func TestLocationSentimentUpdate(c *gin.Context, store db.Store, bus *events.Bus) {
 	goodSaving := make([]string, 0)
 	failureSaving := make([]string, 0)
 
 	testLocationId := strings.TrimSpace(c.Query("docId"))
 	if testLocationId != "" {
-		locData, e := db.GetValidLocation(firestoreClient, testLocationId)
+		locData, e := store.GetValidLocation(testLocationId)
 		if e != nil {
-			log.Printf("Error fetching the test location doc", e)
+			log.Printf("Error fetching the test location doc: %v", e)
 			failureSaving = append(failureSaving, testLocationId)
 		}
 
-		err := processor.ProcessLocationAvgSentiment(firestoreClient, testLocationId, locData)
+		err := processor.ProcessLocationAvgSentiment(store, bus, testLocationId, locData)
 		if err != nil {
 			log.Printf("Error processing the location average sentiment save: %v", err)
 			failureSaving = append(failureSaving, testLocationId)
@@ -35,7 +35,7 @@ func TestLocationSentimentUpdate(c *gin.Context, firestoreClient *firestore.Clie
 	} else {
 
 		// get all the valid locations
-		validLocations, err := db.GetValidLocations(firestoreClient)
+		validLocations, err := store.GetValidLocations()
 		if err != nil {
 			log.Printf("Error fetching valid locations: %v", err)
 			return
@@ -51,7 +51,7 @@ func TestLocationSentimentUpdate(c *gin.Context, firestoreClient *firestore.Clie
 				defer wg.Done()
 
 				docId := db.HashString(locData.LocationName)
-				if err := processor.ProcessLocationAvgSentiment(firestoreClient, docId, locData); err != nil {
+				if err := processor.ProcessLocationAvgSentiment(store, bus, docId, locData); err != nil {
 					log.Printf("Error processing the location average sentiment save: %v", err)
 					mu.Lock()
 					failureSaving = append(failureSaving, docId)
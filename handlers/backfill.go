@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"go-firebird/backfill"
+	"go-firebird/bluesky"
+	"go-firebird/feeds"
+	"go-firebird/processor"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartBackfill starts a backfill of a feed in the background and returns right away,
+// poll GetBackfillStatus for progress. It runs on ctx rather than the request, so it outlives the request
+// and stops with the server.
+//
+// Query params: feed (a registered feed ID or alias, or a feed URI), since (RFC3339), maxPages, pageSize,
+// restart=t to ignore unfinished progress, stopAtSeen=f to keep going past already saved posts.
+func StartBackfill(c *gin.Context, ctx context.Context, pipeline *processor.Pipeline, registry *feeds.Registry) {
+	feed, ok := resolveFeed(c, registry)
+	if !ok {
+		return
+	}
+	opts := backfill.Options{
+		FeedURI:    feed.URI,
+		StopAtSeen: c.DefaultQuery("stopAtSeen", "t") == "t",
+		Restart:    c.Query("restart") == "t",
+		PageDelay:  time.Second,
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC3339 timestamp"})
+			return
+		}
+		opts.Since = t
+	}
+	if v := c.Query("maxPages"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "maxPages must be a positive number"})
+			return
+		}
+		opts.MaxPages = n
+	}
+	if v := c.Query("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > bluesky.MaxFeedLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pageSize must be between 1 and 100"})
+			return
+		}
+		opts.PageSize = n
+	}
+
+	if err := backfill.Start(ctx, pipeline, opts); err != nil {
+		if errors.Is(err, backfill.ErrAlreadyRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error starting backfill: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start backfill"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"feedUri": opts.FeedURI,
+		"started": true,
+	})
+}
+
+func GetBackfillStatus(c *gin.Context, pipeline *processor.Pipeline, registry *feeds.Registry) {
+	feed, ok := resolveFeed(c, registry)
+	if !ok {
+		return
+	}
+	feedURI := feed.URI
+
+	progress, running, err := backfill.GetProgress(pipeline, feedURI)
+	if err != nil {
+		log.Printf("Error getting backfill progress: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get backfill progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feedUri":  feedURI,
+		"running":  running,
+		"progress": progress,
+	})
+}
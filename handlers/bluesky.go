@@ -1,40 +1,33 @@
 package handlers
 
 import (
-	"context"
+	"go-firebird/bluesky"
+	"go-firebird/feeds"
 	"go-firebird/processor"
 	"go-firebird/types"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/firestore"
-	language "cloud.google.com/go/language/apiv2"
-	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/gin-gonic/gin"
 )
 
-// documentation: https://github.com/bluesky-social/indigo/blob/2503553ea604ea7f0bfa6a021b284b371ac2ac96/xrpc/xrpc.go#L114
-const (
-	feedMethod = "app.bsky.feed.getFeed"
-)
-
-func FetchBlueskyHandler(c *gin.Context, firestoreClient *firestore.Client, nlpClient *language.Client) {
+func FetchBlueskyHandler(c *gin.Context, pipeline *processor.Pipeline, registry *feeds.Registry) {
 	// Determine which feed to use based on query parameter "feed"
-	feedParam := c.DefaultQuery("feed", "f")
-	var feedAtURI string
-	switch feedParam {
-	case "e":
-		feedAtURI = "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejxlobe474" // earthquake feed
-	case "h":
-		feedAtURI = "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejwgffwqky" // hurricane feed
-	default:
-		feedAtURI = "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejsyozb6iq" // fire feed
+	feed, ok := resolveFeed(c, registry)
+	if !ok {
+		return
 	}
+	feedAtURI := feed.URI
 
 	// Read other query parameters.
-	limit := c.DefaultQuery("limit", "10")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > bluesky.MaxFeedLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
 	cursor := c.Query("cursor")
 	if cursor != "" {
 		cursor = strings.ReplaceAll(cursor, " ", "+")
@@ -66,30 +59,16 @@ func FetchBlueskyHandler(c *gin.Context, firestoreClient *firestore.Client, nlpC
 		}
 		log.Println("Using mock test data")
 	} else {
-		params := map[string]interface{}{
-			"feed":   feedAtURI,
-			"limit":  limit,
-			"cursor": cursor,
-		}
-
-		// Set up the xrpc client.
-		client := &xrpc.Client{
-			Client:    &http.Client{Timeout: 10 * time.Second},
-			Host:      "https://public.api.bsky.app",
-			UserAgent: nil,
-		}
-
 		// Call the Bluesky API.
-		err := client.Do(context.Background(), xrpc.Query, "json", feedMethod, params, nil, &out)
+		out, err = bluesky.GetFeed(c.Request.Context(), feedAtURI, limit, cursor)
 		if err != nil {
-			log.Printf("Error fetching feed via xrpc: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		log.Printf("Fetched feed from /api/firebird/blusky using feed: %s", feedAtURI)
 	}
 
-	resultsList := processor.SaveFeed(out, firestoreClient, nlpClient)
+	resultsList := processor.SaveFeed(out, pipeline)
 	// c.JSON(http.StatusOK, resultsList)
 	c.JSON(http.StatusOK, gin.H{
 		"resultList": resultsList,
@@ -97,3 +76,14 @@ func FetchBlueskyHandler(c *gin.Context, firestoreClient *firestore.Client, nlpC
 	})
 
 }
+
+// resolveFeed looks up the "feed" query param (a feed ID, alias like f/e/h, or at:// URI, default "f")
+// in the registry and responds with 400 if it is unknown.
+func resolveFeed(c *gin.Context, registry *feeds.Registry) (types.FeedConfig, bool) {
+	feedParam := c.DefaultQuery("feed", "f")
+	feed, ok := registry.Resolve(feedParam)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown feed: " + feedParam})
+	}
+	return feed, ok
+}
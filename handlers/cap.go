@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/export"
+	"go-firebird/types"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// capCancelWindow is how long an ended disaster's Cancel message stays in the Atom feed.
+	capCancelWindow = 7 * 24 * time.Hour
+	// capAreaNames is how many location names describe a disaster's area.
+	capAreaNames  = 3
+	capSenderName = "Firebird"
+)
+
+// GetDisasterCAP returns the current CAP 1.2 message of a disaster, see export.DisasterAlert.
+func GetDisasterCAP(c *gin.Context, store db.Store) {
+	disaster, err := store.GetDisasterByID(c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "disaster not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting disaster %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	alert, err := export.DisasterAlert(disaster, capOptions(c, disaster, locationNames(store, disaster)))
+	if errors.Is(err, export.ErrUndated) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	writeXML(c, export.CAPContentType, alert)
+}
+
+// DisastersAtom is an Atom feed of the current CAP message of every active or recovering disaster, and
+// of the ones that ended in the last 7 days so their Cancel gets out. Newest message first. Disasters
+// without a date have no message and are left out. Only those disasters are read, and their area names
+// in one batch.
+// Query: type (comma separated) and bbox=minLon,minLat,maxLon,maxLat (disasters by centroid).
+func DisastersAtom(c *gin.Context, store db.Store) {
+	query := c.Request.URL.Query()
+	query.Del("category")
+	if t := query.Get("type"); t != "" {
+		query.Set("category", t)
+	}
+	query.Del("since")
+	query.Del("until")
+	filter, err := export.ParseFilter(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	type current struct {
+		disaster types.DisasterData
+		sent     time.Time
+	}
+	var alerts []current
+	cutoff := time.Now().Add(-capCancelWindow)
+	open, err := store.GetOpenDisasters()
+	if err != nil {
+		log.Printf("Error listing open disasters for the CAP feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ended, err := store.GetDisastersEndedSince(cutoff)
+	if err != nil {
+		log.Printf("Error listing ended disasters for the CAP feed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, d := range append(open, ended...) {
+		if !filter.MatchDisaster(d) {
+			continue
+		}
+		sent, err := export.CAPSent(d)
+		if err != nil {
+			log.Printf("Warning: Leaving disaster %s out of the CAP feed: %v", d.ID, err)
+			continue
+		}
+		if d.Status == types.Not_Active && sent.Before(cutoff) {
+			continue
+		}
+		alerts = append(alerts, current{disaster: d, sent: sent})
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].sent.After(alerts[j].sent) })
+
+	base := baseURL(c)
+	feed := export.AtomFeed{
+		ID:      base + "/api/cap/alerts.atom",
+		Title:   "Firebird disaster alerts",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  export.AtomPerson{Name: capSenderName},
+		Links:   []export.AtomLink{{Rel: "self", Type: export.AtomContentType, Href: base + c.Request.URL.RequestURI()}},
+		Entries: []export.AtomEntry{},
+	}
+	if len(alerts) > 0 {
+		feed.Updated = alerts[0].sent.Format(time.RFC3339)
+	}
+	disasters := make([]types.DisasterData, len(alerts))
+	for i, a := range alerts {
+		disasters[i] = a.disaster
+	}
+	names := locationNames(store, disasters...)
+	for _, a := range alerts {
+		alert, err := export.DisasterAlert(a.disaster, capOptions(c, a.disaster, names))
+		if err != nil {
+			continue // not reached, CAPSent already left the undated disasters out
+		}
+		feed.Entries = append(feed.Entries, export.AlertEntry(alert, a.sent, base+"/api/cap/alerts/"+a.disaster.ID))
+	}
+	writeXML(c, export.AtomContentType, feed)
+}
+
+// capOptions fill in what a CAP alert needs besides the disaster. The sender is CAP_SENDER, or
+// firebird@<host> when it isn't set.
+func capOptions(c *gin.Context, d types.DisasterData, names map[string]string) export.CAPOptions {
+	sender := os.Getenv("CAP_SENDER")
+	if sender == "" {
+		sender = "firebird@" + c.Request.Host
+	}
+	return export.CAPOptions{
+		Sender:     sender,
+		SenderName: capSenderName,
+		Web:        baseURL(c) + "/api/firebird/disasters/" + d.ID,
+		AreaDesc:   areaDescription(d, names),
+	}
+}
+
+// locationNames reads the names of the first capAreaNames locations of every disaster in one batch,
+// by location ID.
+func locationNames(store db.Store, disasters ...types.DisasterData) map[string]string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, d := range disasters {
+		for i, id := range d.LocationIDs {
+			if i == capAreaNames {
+				break
+			}
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	locations, err := store.GetLocationsByIDs(ids)
+	if err != nil {
+		log.Printf("Warning: Failed to get the locations of %d disasters: %v", len(disasters), err)
+	}
+	names := make(map[string]string, len(locations))
+	for _, location := range locations {
+		if location.LocationName != "" {
+			names[location.ID] = location.LocationName
+		}
+	}
+	return names
+}
+
+// areaDescription names the first capAreaNames locations of a disaster, e.g. "Pasadena, Altadena and 4 more".
+// names are from locationNames, locations without one are left out.
+func areaDescription(d types.DisasterData, locationNames map[string]string) string {
+	var names []string
+	for _, id := range d.LocationIDs {
+		if len(names) == capAreaNames {
+			break
+		}
+		if name := locationNames[id]; name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	if rest := d.LocationCount - len(names); rest > 0 {
+		return fmt.Sprintf("%s and %d more", strings.Join(names, ", "), rest)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// baseURL is the scheme and host the request came in on, behind a proxy from X-Forwarded-Proto.
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func writeXML(c *gin.Context, contentType string, v interface{}) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding %s: %v", contentType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, contentType+"; charset=utf-8", append([]byte(xml.Header), out...))
+}
@@ -4,11 +4,9 @@ import (
 	"encoding/csv"
 	"fmt"
 
-	"cloud.google.com/go/firestore"
-	language "cloud.google.com/go/language/apiv2"
-
-	// "go-firebird/processor"
 	"go-firebird/db"
+	"go-firebird/feeds"
+	"go-firebird/processor"
 	"go-firebird/types"
 	"io"
 	"net/http"
@@ -31,9 +29,6 @@ const (
 	placeholderAvatar     = ""
 	placeholderRecordType = "app.bsky.feed.post"
 	placeholderLabelSrc   = placeholderDid
-	fireURI               = "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejsyozb6iq"
-	earthQuakeURI         = "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejxlobe474"
-	hurricaneURI          = "at://did:plc:qiknc4t5rq7yngvz7g4aezq7/app.bsky.feed.generator/aaaejwgffwqky"
 )
 
 var placeholderAuthor = types.Author{
@@ -46,20 +41,14 @@ var placeholderAuthor = types.Author{
 }
 
 // Made an LLM write this
-func convertToFeedResponse(records []CsvRecord) types.FeedResponse {
+func convertToFeedResponse(records []CsvRecord, registry *feeds.Registry) types.FeedResponse {
 	feedEntries := make([]types.FeedEntry, 0, len(records))
 
 	for i, rec := range records {
 		// Generate unique-ish placeholders for URI and CID for this demo
 		// In a real system, these would come from the database or be generated properly
-		postUri := fireURI                                // default
+		postUri := demoFeedURI(registry, rec.Prediction)
 		postCid := fmt.Sprintf("bafyreiplaceholder%d", i) // Example CID format
-		if rec.Prediction == "earthquake" {
-			postUri = earthQuakeURI
-		}
-		if rec.Prediction == "hurricane" {
-			postUri = hurricaneURI
-		}
 
 		// Create the Label based on the prediction
 		postLabels := []types.Label{
@@ -114,7 +103,17 @@ func convertToFeedResponse(records []CsvRecord) types.FeedResponse {
 	return response
 }
 
-func AddDisasterDemoData(c *gin.Context, firestoreClient *firestore.Client, nlpClient *language.Client) {
+// demoFeedURI is the URI of the registered feed for a csv prediction, the wildfire feed by default.
+func demoFeedURI(registry *feeds.Registry, prediction string) string {
+	category, _ := types.ParseCategory(prediction)
+	if feed, ok := registry.ForCategory(category); ok {
+		return feed.URI
+	}
+	feed, _ := registry.ForCategory(types.Wildfire)
+	return feed.URI
+}
+
+func AddDisasterDemoData(c *gin.Context, pipeline *processor.Pipeline, registry *feeds.Registry) {
 	fmt.Println("Making Data Good")
 
 	// Read csv file
@@ -148,7 +147,7 @@ func AddDisasterDemoData(c *gin.Context, firestoreClient *firestore.Client, nlpC
 		}
 
 		if len(rec) != 3 {
-			fmt.Println("Skipping record with incorrect number of fields: expected 3, got %d. Record: %v", len(rec), rec)
+			fmt.Printf("Skipping record with incorrect number of fields: expected 3, got %d. Record: %v\n", len(rec), rec)
 			continue // skip bad
 		}
 
@@ -164,7 +163,7 @@ func AddDisasterDemoData(c *gin.Context, firestoreClient *firestore.Client, nlpC
 
 	}
 
-	feedData := convertToFeedResponse(disasterRecords)
+	feedData := convertToFeedResponse(disasterRecords, registry)
 
 	f1 := make([]types.FeedEntry, 0, 1)
 	f1 = append(f1, feedData.Feed[0])
@@ -177,20 +176,20 @@ func AddDisasterDemoData(c *gin.Context, firestoreClient *firestore.Client, nlpC
 
 	// TODO:
 	// We only want demo data, so only get
-	// processor.SaveFeed(testFeed, firestoreClient, nlpClient)
+	// processor.SaveFeed(testFeed, pipeline)
 	// then use the specified value to delete the skeet
 
 	// WARNING: If I delete the data before the cron job at 12, the location wont update the sentiment
 	// will have to copy some of the logic over to a new function in order to update it
 
-	// result := processor.SaveFeed(testFeed, firestoreClient, nlpClient)
+	// result := processor.SaveFeed(testFeed, pipeline)
 
 	c.JSON(http.StatusOK, gin.H{"uwu": feedData})
 
 }
 
-func DeleteDisasterDemoData(c *gin.Context, firestoreClient *firestore.Client) {
-	amountDeleted, err := db.DeleteAllTestSkeets(firestoreClient)
+func DeleteDisasterDemoData(c *gin.Context, store db.Store) {
+	amountDeleted, err := store.DeleteAllTestSkeets()
 	if err != nil {
 		fmt.Println(err)
 
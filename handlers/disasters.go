@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/export"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDisasters returns the disasters matching the query, most recently updated first.
+//
+// Query: status, type and severity (comma separated), since and until (RFC3339, disasters active in that
+// range), bbox=minLon,minLat,maxLon,maxLat (disasters by centroid), limit (1-200, default 50) and cursor,
+// the nextCursor of the previous page.
+func ListDisasters(c *gin.Context, store db.Store) {
+	query := c.Request.URL.Query()
+	if t := query.Get("type"); t != "" {
+		query.Set("category", t)
+	}
+	filter, err := export.ParseFilter(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses := make(map[types.Status]bool)
+	for _, s := range splitList(c.Query("status")) {
+		status := types.Status(s)
+		if status != types.Active && status != types.Recovery && status != types.Not_Active {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown status %q", s)})
+			return
+		}
+		statuses[status] = true
+	}
+	severities := make(map[types.Severity]bool)
+	for _, s := range splitList(c.Query("severity")) {
+		severity := types.Severity(s)
+		if severity != types.Low && severity != types.Medium && severity != types.High && severity != types.Critical {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown severity %q", s)})
+			return
+		}
+		severities[severity] = true
+	}
+
+	limit, after, ok := pageParams(c, 50)
+	if !ok {
+		return
+	}
+
+	matched := []types.DisasterData{}
+	err = store.EachDisaster(func(d types.DisasterData) error {
+		if len(statuses) > 0 && !statuses[d.Status] {
+			return nil
+		}
+		if len(severities) > 0 && !severities[d.Severity] {
+			return nil
+		}
+		if filter.MatchDisaster(d) {
+			matched = append(matched, d)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error listing disasters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// most recently updated first
+	page, nextCursor := paginate(matched, func(d types.DisasterData) pageCursor {
+		return pageCursor{key: d.LastUpdate, id: d.ID}
+	}, true, after, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"disasters":  page,
+		"count":      len(page),
+		"total":      len(matched),
+		"nextCursor": nextCursor,
+	})
+}
+
+// GetDisaster returns a disaster with its locations and up to skeets (default 10, at most 50)
+// representative skeets, see processor.RepresentativeSkeets.
+func GetDisaster(c *gin.Context, store db.Store) {
+	n, err := strconv.Atoi(c.DefaultQuery("skeets", "10"))
+	if err != nil || n < 0 || n > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "skeets must be between 0 and 50"})
+		return
+	}
+
+	disaster, err := store.GetDisasterByID(c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "disaster not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting disaster %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	locations := make([]types.LocationData, 0, len(disaster.LocationIDs))
+	for _, id := range disaster.LocationIDs {
+		location, err := store.GetValidLocation(id)
+		if err != nil {
+			log.Printf("Warning: Failed to get location %s of disaster %s: %v", id, disaster.ID, err)
+			continue
+		}
+		locations = append(locations, location)
+	}
+
+	skeets := []types.RepresentativeSkeet{}
+	if n > 0 {
+		picked, err := processor.RepresentativeSkeets(store, disaster, n)
+		if err != nil {
+			log.Printf("Warning: No representative skeets for disaster %s: %v", disaster.ID, err)
+		} else if picked != nil {
+			skeets = picked
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disaster":  disaster,
+		"locations": locations,
+		"skeets":    skeets,
+	})
+}
+
+// splitList splits a comma separated query value, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
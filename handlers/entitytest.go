@@ -12,11 +12,9 @@ import (
 
 	"go-firebird/nlp"
 	"go-firebird/types"
-
-	language "cloud.google.com/go/language/apiv2"
 )
 
-func TestEntity(c *gin.Context, nlpClient *language.Client) {
+func TestEntity(c *gin.Context, entityExtractor nlp.EntityExtractor) {
 	mockParam := c.Query("mock")
 
 	// JSON response struct
@@ -37,7 +35,7 @@ func TestEntity(c *gin.Context, nlpClient *language.Client) {
 		}
 
 		// Analyze entities using the mock text.
-		entities, err := nlp.AnalyzeEntities(nlpClient, mockContent)
+		entities, err := entityExtractor.AnalyzeEntities(mockContent)
 		if err != nil {
 			log.Printf("Error analyzing entities (mock mode): %v", err)
 			c.JSON(http.StatusOK, gin.H{
@@ -105,7 +103,7 @@ func TestEntity(c *gin.Context, nlpClient *language.Client) {
 	}
 
 	// Run NLP analysis on the content
-	entities, err := nlp.AnalyzeEntities(nlpClient, responseData.Content)
+	entities, err := entityExtractor.AnalyzeEntities(responseData.Content)
 	if err != nil {
 		log.Printf("Error analyzing entities: %v", err)
 		// Return partial data if NLP fails
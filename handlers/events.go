@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-firebird/events"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	eventBuffer    = 64 // events buffered per client, a slower client misses events
+	heartbeatEvery = 30 * time.Second
+	pongWait       = 2 * heartbeatEvery
+	writeWait      = 10 * time.Second
+)
+
+// StreamEvents streams the event bus as Server-Sent Events, one "event: <topic>" message per event with
+// the event as JSON data and a comment line every heartbeatEvery to keep proxies from closing it.
+// Query: topics (topics or topic prefixes like "disaster", comma separated) and category (comma separated).
+func StreamEvents(c *gin.Context, bus *events.Bus) {
+	filter, err := events.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub := bus.Subscribe(filter, eventBuffer)
+	defer sub.Close()
+
+	if origin := c.GetHeader("Origin"); origin != "" && origin == os.Getenv("CLIENT_URL") {
+		c.Header("Access-Control-Allow-Origin", origin)
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx would buffer the stream otherwise
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatEvery)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		case e := <-sub.C:
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("Error encoding event %d: %v", e.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Topic, data); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+var eventsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true // not a browser
+		}
+		if clientURL := os.Getenv("CLIENT_URL"); clientURL != "" && origin == clientURL {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	},
+}
+
+// EventsWebSocket streams the event bus over a websocket, one JSON event per text message. The query
+// takes the same filter as StreamEvents, the client can replace it by sending
+// {"topics": [...], "categories": [...]}, an invalid one is answered with {"error": "..."}.
+func EventsWebSocket(c *gin.Context, bus *events.Bus) {
+	filter, err := events.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := eventsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Error upgrading events websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := bus.Subscribe(filter, eventBuffer)
+	defer sub.Close()
+
+	// Writes happen on this goroutine only, the reader hands filter errors over
+	replies := make(chan gin.H, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var f events.Filter
+			if err := json.Unmarshal(message, &f); err != nil {
+				select {
+				case replies <- gin.H{"error": "invalid filter: " + err.Error()}:
+				default:
+				}
+				continue
+			}
+			if err := f.Validate(); err != nil {
+				select {
+				case replies <- gin.H{"error": err.Error()}:
+				default:
+				}
+				continue
+			}
+			sub.SetFilter(f)
+		}
+	}()
+
+	ping := time.NewTicker(heartbeatEvery)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-c.Request.Context().Done(): // the server is shutting down
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(writeWait))
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case reply := <-replies:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(reply); err != nil {
+				return
+			}
+		case e := <-sub.C:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+	}
+}
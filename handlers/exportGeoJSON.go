@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"go-firebird/db"
+	"go-firebird/export"
+	"go-firebird/types"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flushEvery is how many features are buffered before they're sent to the client.
+const flushEvery = 500
+
+// ExportLocationsGeoJSON streams the valid locations matching the filter as a GeoJSON FeatureCollection.
+// Query: category, since, until, bbox, see export.ParseFilter.
+func ExportLocationsGeoJSON(c *gin.Context, store db.Store) {
+	filter, err := export.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	streamGeoJSON(c, "locations.geojson", func(write func(export.Feature) error) error {
+		return store.EachValidLocation(func(l types.LocationData) error {
+			if !filter.MatchLocation(l) {
+				return nil
+			}
+			return write(export.LocationFeature(l))
+		})
+	})
+}
+
+// ExportDisastersGeoJSON streams the disasters matching the filter as a GeoJSON FeatureCollection.
+// Query: category, since, until, bbox (matched against the centroid), see export.ParseFilter, and
+// geometry=hull|bbox|centroid, hull by default.
+func ExportDisastersGeoJSON(c *gin.Context, store db.Store) {
+	filter, err := export.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	geometry := c.DefaultQuery("geometry", export.GeometryHull)
+	if geometry != export.GeometryHull && geometry != export.GeometryBBox && geometry != export.GeometryCentroid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "geometry must be hull, bbox or centroid"})
+		return
+	}
+
+	streamGeoJSON(c, "disasters.geojson", func(write func(export.Feature) error) error {
+		return store.EachDisaster(func(d types.DisasterData) error {
+			if !filter.MatchDisaster(d) {
+				return nil
+			}
+			return write(export.DisasterFeature(d, geometry))
+		})
+	})
+}
+
+// streamGeoJSON writes the features produced by each as they come. Once the response has started the
+// status can't change, an error then aborts the connection so the client sees a failed download.
+func streamGeoJSON(c *gin.Context, filename string, each func(write func(export.Feature) error) error) {
+	c.Header("Content-Type", "application/geo+json")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	fw, err := export.NewFeatureCollectionWriter(c.Writer)
+	if err != nil {
+		log.Printf("Error starting %s export: %v", filename, err)
+		abortExport()
+	}
+	err = each(func(f export.Feature) error {
+		if err := fw.Write(f); err != nil {
+			return err
+		}
+		if fw.Count()%flushEvery == 0 {
+			if err := fw.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error exporting %s after %d features: %v", filename, fw.Count(), err)
+		abortExport()
+	}
+	if err := fw.Close(); err != nil {
+		log.Printf("Error finishing %s export: %v", filename, err)
+		abortExport()
+	}
+	log.Printf("Exported %d features to %s", fw.Count(), filename)
+}
+
+// abortExport drops the connection of an export that failed after its response started. Closing the
+// collection or table would end a truncated file like a complete one, so it is left unfinished and
+// the client sees a failed transfer instead. The router lets http.ErrAbortHandler through to net/http.
+func abortExport() {
+	panic(http.ErrAbortHandler)
+}
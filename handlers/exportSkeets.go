@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"go-firebird/db"
+	"go-firebird/export"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportSkeets streams the saved skeets as a CSV or Parquet table, see export.SkeetColumns.
+// Query: category (the top category), since, until, see export.ParseFilter.
+func ExportSkeets(c *gin.Context, store db.Store, format string) {
+	filter, err := export.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.BBox != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "skeets have no coordinates, bbox is not supported"})
+		return
+	}
+
+	contentType := "text/csv"
+	if format == export.FormatParquet {
+		contentType = "application/vnd.apache.parquet"
+	}
+	filename := "skeets." + format
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	tw, err := export.NewTableWriter(format, c.Writer, export.SkeetColumns())
+	if err != nil {
+		log.Printf("Error starting %s export: %v", filename, err)
+		abortExport()
+	}
+	written, err := export.WriteSkeets(store, filter, tw)
+	if err != nil {
+		log.Printf("Error exporting %s after %d rows: %v", filename, written, err)
+		abortExport()
+	}
+	if err := tw.Close(); err != nil {
+		log.Printf("Error finishing %s export: %v", filename, err)
+		abortExport()
+	}
+	log.Printf("Exported %d skeets to %s", written, filename)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"go-firebird/feeds"
+	"go-firebird/types"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListFeeds(c *gin.Context, registry *feeds.Registry) {
+	c.JSON(http.StatusOK, gin.H{"feeds": registry.List()})
+}
+
+func GetFeed(c *gin.Context, registry *feeds.Registry) {
+	feed, ok := registry.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": feeds.ErrNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, feed)
+}
+
+// PutFeed creates or replaces the feed with the id in the path. The scheduler picks up the change right away.
+func PutFeed(c *gin.Context, registry *feeds.Registry) {
+	var feed types.FeedConfig
+	if err := c.ShouldBindJSON(&feed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	feed.ID = c.Param("id")
+
+	saved, err := registry.Put(feed)
+	if err != nil {
+		log.Printf("Error saving feed %s: %v", feed.ID, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+func DeleteFeed(c *gin.Context, registry *feeds.Registry) {
+	err := registry.Delete(c.Param("id"))
+	if errors.Is(err, feeds.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting feed %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete feed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func TestGeocode(c *gin.Context) {
+func TestGeocode(c *gin.Context, geocoder geocode.Geocoder) {
 	locationParam := c.Query("location")
 	if locationParam == "" {
 		locationParam = "Colorado"
@@ -17,30 +17,34 @@ func TestGeocode(c *gin.Context) {
 
 	// JSON response struct
 	type LocationResponse struct {
-		Location  string  `json:"location"`
-		Longitude float64 `json:"longitude"`
-		Latitude  float64 `json:"latitude"`
+		Location         string  `json:"location"`
+		FormattedAddress string  `json:"formattedAddress"`
+		Longitude        float64 `json:"longitude"`
+		Latitude         float64 `json:"latitude"`
+		Source           string  `json:"source"`
 	}
 
 	responseData := LocationResponse{
 		Location: locationParam,
 	}
 
-	results, err := geocode.GeocodeAddress(locationParam)
+	result, err := geocoder.Geocode(locationParam)
 	if err != nil {
-		log.Fatalf("Error geocoding address: %v", err)
+		log.Printf("Error geocoding address: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to geocode location"})
+		return
 	}
-	if len(results) == 0 {
+	if !result.Found {
 		log.Println("No geocoding results found")
 	} else {
 		// Print the formatted address and its latitude and longitude
-		location := results[0].Geometry.Location
-		fmt.Printf("Geocoding result for 'Colorado': %s\nLatitude: %f, Longitude: %f\n",
-			results[0].FormattedAddress, location.Lat, location.Lng)
-		responseData.Longitude = location.Lng
-		responseData.Latitude = location.Lat
+		fmt.Printf("Geocoding result for '%s': %s\nLatitude: %f, Longitude: %f\n",
+			locationParam, result.FormattedAddress, result.Lat, result.Long)
+		responseData.FormattedAddress = result.FormattedAddress
+		responseData.Longitude = result.Long
+		responseData.Latitude = result.Lat
 	}
+	responseData.Source = result.Source
 
-	// Return JSON response with mock data and entities.
 	c.JSON(http.StatusOK, responseData)
 }
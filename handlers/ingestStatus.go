@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"go-firebird/ingest"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestStatus reports the streaming ingest counters, consumer is nil when INGEST is not set.
+func IngestStatus(c *gin.Context, consumer *ingest.Consumer) {
+	if consumer == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"stats":   consumer.Stats(),
+	})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"go-firebird/db"
+	"go-firebird/export"
+	"go-firebird/types"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// latestTimestamp is above every stored RFC3339 timestamp, the end of an open time range.
+const latestTimestamp = "9999-12-31T23:59:59Z"
+
+// ListLocations returns the geocoded locations matching the query sorted by name, without their
+// sentiment history (see GetLocationHistory).
+//
+// Query: q (part of the name or formatted address), bbox=minLon,minLat,maxLon,maxLat, category (locations
+// with skeets in any of them, comma separated), since and until (RFC3339, locations with skeets in that
+// range), limit (1-200, default 50) and cursor, the nextCursor of the previous page.
+func ListLocations(c *gin.Context, store db.Store) {
+	filter, err := export.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	search := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	limit, after, ok := pageParams(c, 50)
+	if !ok {
+		return
+	}
+
+	matched := []types.LocationData{}
+	err = store.EachValidLocation(func(l types.LocationData) error {
+		if search != "" &&
+			!strings.Contains(strings.ToLower(l.LocationName), search) &&
+			!strings.Contains(strings.ToLower(l.FormattedAddress), search) {
+			return nil
+		}
+		if filter.MatchLocation(l) {
+			l.AvgSentimentList = nil
+			matched = append(matched, l)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error listing locations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, nextCursor := paginate(matched, func(l types.LocationData) pageCursor {
+		return pageCursor{key: strings.ToLower(l.LocationName), id: l.ID}
+	}, false, after, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"locations":  page,
+		"count":      len(page),
+		"total":      len(matched),
+		"nextCursor": nextCursor,
+	})
+}
+
+// GetLocation returns a geocoded location.
+func GetLocation(c *gin.Context, store db.Store) {
+	location, ok := validLocation(c, store)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"location": location})
+}
+
+// sentimentPoint is one entry of a location's sentiment history.
+type sentimentPoint struct {
+	Timestamp string              `json:"timestamp"`
+	Skeets    int                 `json:"skeets"`
+	Sentiment float32             `json:"sentiment"`
+	Counts    types.DisasterCount `json:"counts"`
+}
+
+// GetLocationHistory returns a location's sentiment and category counts per update, oldest first.
+// Query: since and until (RFC3339).
+func GetLocationHistory(c *gin.Context, store db.Store) {
+	filter, err := export.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	location, ok := validLocation(c, store)
+	if !ok {
+		return
+	}
+
+	points := []sentimentPoint{}
+	for _, avg := range location.AvgSentimentList {
+		if !filter.InTimeRange(avg.TimeStamp) {
+			continue
+		}
+		points = append(points, sentimentPoint{
+			Timestamp: avg.TimeStamp,
+			Skeets:    avg.SkeetsAmount,
+			Sentiment: avg.AverageSentiment,
+			Counts:    avg.DisasterCount,
+		})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":     location.ID,
+		"name":   location.LocationName,
+		"points": points,
+	})
+}
+
+// GetLocationSkeets pages through the skeets saved under a location, newest first.
+// Query: since and until (RFC3339), limit (1-200, default 50) and cursor.
+func GetLocationSkeets(c *gin.Context, store db.Store) {
+	filter, err := export.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, after, ok := pageParams(c, 50)
+	if !ok {
+		return
+	}
+	location, ok := validLocation(c, store)
+	if !ok {
+		return
+	}
+
+	start, end := filter.StoreRange()
+	if end == "" {
+		end = latestTimestamp
+	}
+	skeets, err := store.GetSkeetsSubCollection(location.ID, start, end)
+	if err != nil {
+		log.Printf("Error getting skeets of location %s: %v", location.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	matched := []types.SkeetSubDoc{}
+	for _, s := range skeets {
+		if filter.InTimeRange(s.SkeetData.Timestamp) {
+			matched = append(matched, s)
+		}
+	}
+	page, nextCursor := paginate(matched, func(s types.SkeetSubDoc) pageCursor {
+		return pageCursor{key: s.SkeetData.Timestamp, id: s.SkeetData.UID}
+	}, true, after, limit)
+
+	c.JSON(http.StatusOK, gin.H{
+		"skeets":     page,
+		"count":      len(page),
+		"total":      len(matched),
+		"nextCursor": nextCursor,
+	})
+}
+
+// validLocation gets the :id location, writing a 404 if it doesn't exist or was never geocoded.
+func validLocation(c *gin.Context, store db.Store) (types.LocationData, bool) {
+	location, err := store.GetValidLocation(c.Param("id"))
+	if errors.Is(err, db.ErrNotFound) || (err == nil && location.FormattedAddress == "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "location not found"})
+		return location, false
+	}
+	if err != nil {
+		log.Printf("Error getting location %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return location, false
+	}
+	return location, true
+}
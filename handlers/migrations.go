@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"go-firebird/db"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MigrateDisasterCounts rewrites locations and disasters still holding the old
+// fireCount/hurricaneCount/earthquakeCount/nonDisasterCount fields. Safe to run more than once.
+func MigrateDisasterCounts(c *gin.Context, store db.Store) {
+	locations, disasters, err := db.MigrateDisasterCounts(store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":             err.Error(),
+			"migratedLocations": locations,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"migratedLocations": locations,
+		"migratedDisasters": disasters,
+	})
+}
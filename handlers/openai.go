@@ -1,18 +1,22 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"go-firebird/limits"
 	"go-firebird/types"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
-func CallOpenAI(c *gin.Context) {
+// CallOpenAI classifies one tweet with OpenAI. It answers 429 when the OpenAI budget is spent.
+func CallOpenAI(c *gin.Context, limiter *limits.Limiter) {
 	var request struct {
 		Input string `json:"input"`
 	}
@@ -28,7 +32,7 @@ func CallOpenAI(c *gin.Context) {
 	}
 
 	client := openai.NewClient(apiKey)
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
 	// Generate JSON schema from Go struct
 	var result types.TweetAnalysis
@@ -37,6 +41,17 @@ func CallOpenAI(c *gin.Context) {
 		log.Fatalf("GenerateSchemaForType error: %v", err)
 	}
 
+	call, err := limiter.Acquire(ctx, limits.OpenAI)
+	if errors.Is(err, limits.ErrBudgetExhausted) {
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(limits.ResetAt(time.Now())).Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
 	// OpenAI API Request
 	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
 		Model: openai.GPT4oMini,
@@ -65,12 +80,20 @@ func CallOpenAI(c *gin.Context) {
 		MaxTokens: 2000,
 	})
 
-	log.Printf("Raw OpenAI Response: %s", resp.Choices[0].Message.Content)
-
+	if resp.Usage.TotalTokens > 0 {
+		call.DoneTokens(resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	} else {
+		call.Done(err)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(resp.Choices) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "OpenAI returned no choices"})
+		return
+	}
+	log.Printf("Raw OpenAI Response: %s", resp.Choices[0].Message.Content)
 
 	// Unmarshal structured response
 	err = schema.Unmarshal(resp.Choices[0].Message.Content, &result)
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageCursor is the position of an item in a list sorted by key, then by ID. It's sent to clients as
+// an opaque nextCursor and comes back as ?cursor=.
+type pageCursor struct {
+	key string
+	id  string
+}
+
+// before reports whether a comes before b, with keys in descending order if desc.
+func (a pageCursor) before(b pageCursor, desc bool) bool {
+	if a.key != b.key {
+		return (a.key < b.key) != desc
+	}
+	return a.id < b.id
+}
+
+func (a pageCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(a.key + "\n" + a.id))
+}
+
+func parsePageCursor(v string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(v)
+	key, id, ok := strings.Cut(string(raw), "\n")
+	if err != nil || !ok {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	return pageCursor{key: key, id: id}, nil
+}
+
+// pageParams reads ?limit= (1-200, defaultLimit if missing) and ?cursor=, writing a 400 if either is invalid.
+func pageParams(c *gin.Context, defaultLimit int) (int, *pageCursor, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return 0, nil, false
+	}
+	v := c.Query("cursor")
+	if v == "" {
+		return limit, nil, true
+	}
+	cursor, err := parsePageCursor(v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, nil, false
+	}
+	return limit, &cursor, true
+}
+
+// paginate sorts items and returns up to limit of them after the cursor, with the cursor of the next
+// page, empty on the last one.
+func paginate[T any](items []T, cursorOf func(T) pageCursor, desc bool, after *pageCursor, limit int) ([]T, string) {
+	sort.Slice(items, func(i, j int) bool {
+		return cursorOf(items[i]).before(cursorOf(items[j]), desc)
+	})
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool { return after.before(cursorOf(items[i]), desc) })
+	}
+	page := items[start:]
+	if len(page) <= limit {
+		return page, ""
+	}
+	page = page[:limit]
+	return page, cursorOf(page[limit-1]).String()
+}
@@ -12,11 +12,9 @@ import (
 
 	"go-firebird/nlp"
 	"go-firebird/types"
-
-	language "cloud.google.com/go/language/apiv2"
 )
 
-func TestSentiment(c *gin.Context, nlpClient *language.Client) {
+func TestSentiment(c *gin.Context, sentimentAnalyzer nlp.SentimentAnalyzer) {
 	mockParam := c.Query("mock")
 
 	// JSON response struct
@@ -37,7 +35,7 @@ func TestSentiment(c *gin.Context, nlpClient *language.Client) {
 		}
 
 		// Analyze entities using the mock text.
-		sentiment, err := nlp.AnalyzeSentiment(nlpClient, mockContent)
+		sentiment, err := sentimentAnalyzer.AnalyzeSentiment(mockContent)
 		if err != nil {
 			log.Printf("Error analyzing entities (mock mode): %v", err)
 			c.JSON(http.StatusOK, gin.H{
@@ -105,7 +103,7 @@ func TestSentiment(c *gin.Context, nlpClient *language.Client) {
 	}
 
 	// Analyze entities using the mock text.
-	sentiment, err := nlp.AnalyzeSentiment(nlpClient, responseData.Content)
+	sentiment, err := sentimentAnalyzer.AnalyzeSentiment(responseData.Content)
 	if err != nil {
 		log.Printf("Error analyzing entities (mock mode): %v", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -1,89 +1,93 @@
 package handlers
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"go-firebird/db"
 	"go-firebird/detection"
-	"go-firebird/summarization"
+	"go-firebird/events"
+	"go-firebird/limits"
+	"go-firebird/processor"
 	"go-firebird/types"
 	"log"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"github.com/sashabaranov/go-openai"
 )
 
-// RunDisasterDetection fetches candidate locations, runs detection, generates summaries, and saves results.
-func RunDisasterDetection(c *gin.Context, firestoreClient *firestore.Client) {
-	const detectionSentimentThreshold float32 = 0.0 // -0.3
+// detectionRequest is the optional POST body of RunDisasterDetection. Config overrides fields of the
+// profile, e.g. {"profile": "global-sensitive", "config": {"regions": [{"name": "japan", ...}]}}.
+type detectionRequest struct {
+	Profile string          `json:"profile"`
+	Config  json.RawMessage `json:"config"`
+}
 
+// RunDisasterDetection triggers a detection run by hand, the same one the cron job runs after the
+// location sentiment update. It waits for the run to finish and returns its record.
+// The profile comes from ?profile= or the POST body, the default profile when neither is given.
+func RunDisasterDetection(c *gin.Context, store db.Store, profiles *detection.Profiles, bus *events.Bus, limiter *limits.Limiter) {
 	log.Println("Handler: Starting disaster detection process...")
 
-	// 1. Fetch candidate locations
-	locations, err := db.GetLocationsForDisasterCheck(firestoreClient, detectionSentimentThreshold)
-	if err != nil {
-		log.Printf("ERROR fetching locations for disaster check: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve locations for analysis"})
-		return
+	request := detectionRequest{Profile: c.Query("profile")}
+	if c.Request.Method == http.MethodPost && c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
 	}
-	if len(locations) == 0 {
-		log.Println("Handler: No locations found meeting the disaster check criteria.")
-		c.JSON(http.StatusOK, gin.H{"message": "No locations met criteria", "disasters": []types.DisasterData{}})
+	cfg, err := profiles.Resolve(request.Profile, request.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Handler: Running detection logic on %d locations...", len(locations))
-
-	// 2. Run the detection logic
-	disasters, detectErr := detection.DetectDisastersFromList(locations)
-	if detectErr != nil {
-		log.Printf("ERROR during disaster detection logic: %v", detectErr)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "An error occurred during disaster analysis"})
+	run, disasters, err := processor.RunDisasterDetection(store, bus, limiter, "manual", cfg)
+	if errors.Is(err, processor.ErrDetectionRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
-	if len(disasters) == 0 {
-		log.Println("Handler: Detection logic identified 0 disaster clusters.")
-		c.JSON(http.StatusOK, gin.H{"message": "No disaster clusters identified", "disasters": []types.DisasterData{}})
+	if disasters == nil {
+		disasters = []types.DisasterData{}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":     err.Error(),
+			"run":       run,
+			"disasters": disasters,
+		})
 		return
 	}
 
-	log.Printf("Handler: Detection complete. Found %d disaster clusters. Proceeding to summarization...", len(disasters))
-
-	// 3. Generate Summaries (if disasters were found)
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Println("Warning: OPENAI_API_KEY environment variable not set. Skipping summary generation.")
-	} else {
-		openaiClient := openai.NewClient(apiKey)
-		ctxSummarize, cancelSummarize := context.WithTimeout(context.Background(), 2*time.Minute) // Use background context for potentially long task
-		defer cancelSummarize()
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Processed %d locations, created %d disasters and updated %d.",
+			run.LocationsChecked, len(run.DisastersCreated), len(run.DisastersUpdated)),
+		"run":       run,
+		"disasters": disasters,
+	})
+}
 
-		err = summarization.GenerateSummaries(ctxSummarize, disasters, firestoreClient, openaiClient)
-		if err != nil {
-			// Log the error but continue, some summaries might be missing
-			log.Printf("Warning: Error occurred during summary generation: %v", err)
-		}
+// GetDetectionRuns lists the most recent detection runs, ?limit= (default 20).
+func GetDetectionRuns(c *gin.Context, store db.Store) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
 	}
 
-	// 4. Save the detected disasters (with or without summaries)
-	log.Printf("Handler: Saving %d detected disasters to the database...", len(disasters))
-	err = db.SaveDisasters(firestoreClient, disasters)
+	runs, err := store.GetDetectionRuns(limit)
 	if err != nil {
-		log.Printf("ERROR saving disasters to database: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":     "Failed to save detected disasters",
-			"disasters": disasters,
-		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
 
-	log.Println("Handler: Process complete. Disasters saved.")
+// GetDetectionProfiles lists the detection profiles and which one the cron job uses.
+func GetDetectionProfiles(c *gin.Context, profiles *detection.Profiles) {
 	c.JSON(http.StatusOK, gin.H{
-		"message":   fmt.Sprintf("Processed %d locations, identified and saved %d disasters.", len(locations), len(disasters)),
-		"disasters": disasters,
+		"default":  profiles.Default,
+		"profiles": profiles.List(),
 	})
 }
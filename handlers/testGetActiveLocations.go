@@ -6,13 +6,12 @@ import (
 	"log"
 	"net/http"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 )
 
-func TestGetActiveLocation(c *gin.Context, firestoreClient *firestore.Client) {
+func TestGetActiveLocation(c *gin.Context, store db.Store) {
 	limit := 10
-	locDocs, err := db.GetTopLocationsBySkeetAmount(firestoreClient, limit)
+	locDocs, err := store.GetTopLocationsBySkeetAmount(limit)
 	if err != nil {
 		log.Printf("ERROR fetching top locations: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
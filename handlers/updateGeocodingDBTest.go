@@ -3,17 +3,17 @@ package handlers
 import (
 	"fmt"
 	"go-firebird/db"
+	"go-firebird/geocode"
 	"go-firebird/types"
 	"log"
 	"net/http"
 	"sync"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 )
 
-func TestUpdateGeocodingDBTest(c *gin.Context, firestoreClient *firestore.Client) {
-	newLocations, err := db.GetNewLocations(firestoreClient)
+func TestUpdateGeocodingDBTest(c *gin.Context, store db.Store, geocoder geocode.Geocoder) {
+	newLocations, err := store.GetNewLocations()
 	if err != nil {
 		log.Printf("Error fetching new locations: %v", err)
 		return
@@ -29,7 +29,7 @@ func TestUpdateGeocodingDBTest(c *gin.Context, firestoreClient *firestore.Client
 		go func(location types.LocationData) {
 			defer wg.Done()
 			log.Printf("Updating geocode for location hash: %s", location.LocationName)
-			db.UpdateLocationGeocoding(firestoreClient, location.LocationName)
+			db.UpdateLocationGeocoding(store, geocoder, location.LocationName)
 		}(loc)
 	}
 	wg.Wait() // Wait for all updates to finish
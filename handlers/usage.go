@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"go-firebird/db"
+	"go-firebird/limits"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUsage returns today's calls and spend of every paid provider with its limits, budget left and
+// the calls in flight or waiting. ?day=2006-01-02 returns the recorded usage of an earlier day.
+func GetUsage(c *gin.Context, limiter *limits.Limiter, store db.Store) {
+	day := c.Query("day")
+	if day == "" || day == limiter.Day() {
+		c.JSON(http.StatusOK, gin.H{
+			"day":       limiter.Day(),
+			"resetAt":   limits.ResetAt(time.Now()).Format(time.RFC3339),
+			"providers": limiter.Usage(),
+		})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "day must look like 2006-01-02"})
+		return
+	}
+
+	usage, err := store.GetProviderUsage(day)
+	if err != nil {
+		log.Printf("Error getting usage of %s: %v", day, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"day": day, "providers": usage})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"go-firebird/db"
+	"go-firebird/types"
+	"go-firebird/webhooks"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// withoutSecret hides the signing secret, it is only returned when a webhook is created.
+func withoutSecret(hook types.Webhook) types.Webhook {
+	hook.Secret = ""
+	return hook
+}
+
+func ListWebhooks(c *gin.Context, dispatcher *webhooks.Dispatcher) {
+	list := dispatcher.List()
+	for i := range list {
+		list[i] = withoutSecret(list[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": list})
+}
+
+func GetWebhook(c *gin.Context, dispatcher *webhooks.Dispatcher) {
+	hook, ok := dispatcher.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": webhooks.ErrNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, withoutSecret(hook))
+}
+
+// CreateWebhook registers a webhook. Without a secret in the body one is generated, the response is the
+// only time it is returned.
+func CreateWebhook(c *gin.Context, dispatcher *webhooks.Dispatcher) {
+	var hook types.Webhook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hook.ID = uuid.NewString()
+
+	saved, err := dispatcher.Create(hook)
+	if err != nil {
+		log.Printf("Error creating webhook: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, saved)
+}
+
+// PutWebhook replaces the webhook with the id in the path, keeping its secret unless a new one is given.
+func PutWebhook(c *gin.Context, dispatcher *webhooks.Dispatcher) {
+	var hook types.Webhook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := dispatcher.Update(c.Param("id"), hook)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving webhook %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, withoutSecret(saved))
+}
+
+func DeleteWebhook(c *gin.Context, dispatcher *webhooks.Dispatcher) {
+	err := dispatcher.Delete(c.Param("id"))
+	if errors.Is(err, webhooks.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting webhook %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
+
+// ListWebhookDeliveries is the delivery log, most recent first: the :id (or ?webhook=) webhook's
+// deliveries, every webhook's without one. Query: status (pending, delivered or dead, the dead-letter
+// queue) and limit (1-200, default 50).
+func ListWebhookDeliveries(c *gin.Context, store db.Store) {
+	status := types.DeliveryStatus(c.Query("status"))
+	if status != "" && status != types.DeliveryPending && status != types.DeliveryDelivered && status != types.DeliveryDead {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or dead"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	webhookID := c.Param("id")
+	if webhookID == "" {
+		webhookID = c.Query("webhook")
+	}
+	deliveries, err := store.GetWebhookDeliveries(webhookID, status, limit)
+	if err != nil {
+		log.Printf("Error getting webhook deliveries: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "count": len(deliveries)})
+}
+
+func GetWebhookDelivery(c *gin.Context, store db.Store) {
+	delivery, err := store.GetWebhookDelivery(c.Param("deliveryId"))
+	if errors.Is(err, db.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting webhook delivery %s: %v", c.Param("deliveryId"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhookDelivery sends a dead (or delivered) delivery again with a new round of retries.
+func RedeliverWebhookDelivery(c *gin.Context, dispatcher *webhooks.Dispatcher) {
+	delivery, err := dispatcher.Redeliver(c.Param("deliveryId"))
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "delivery not found"})
+	case errors.Is(err, webhooks.ErrDeliveryPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error redelivering webhook delivery %s: %v", c.Param("deliveryId"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, delivery)
+	}
+}
@@ -0,0 +1,419 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-firebird/limits"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	readTimeout       = 60 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 2 * time.Minute
+)
+
+// Config for a Consumer, see ConfigFromEnv for the defaults.
+type Config struct {
+	URL        string
+	CursorName string // name the cursor is saved under in the store
+	QueueSize  int
+	Workers    int
+	Filter     Filter
+	// look up handle, display name and avatar of every saved post's author
+	ResolveProfiles bool
+	// if set, every raw frame is appended to this file so it can be replayed later (see ReplayServer)
+	RecordPath     string
+	CursorInterval time.Duration
+}
+
+// ConfigFromEnv reads JETSTREAM_URL, INGEST_QUEUE_SIZE, INGEST_WORKERS, INGEST_RESOLVE_PROFILES,
+// INGEST_RECORD_PATH and the filter variables (see FilterFromEnv).
+func ConfigFromEnv() Config {
+	cfg := Config{
+		URL:             DefaultJetstreamURL,
+		CursorName:      "jetstream",
+		QueueSize:       1000,
+		Workers:         4,
+		Filter:          FilterFromEnv(),
+		ResolveProfiles: true,
+		RecordPath:      os.Getenv("INGEST_RECORD_PATH"),
+		CursorInterval:  5 * time.Second,
+	}
+	if v := os.Getenv("JETSTREAM_URL"); v != "" {
+		cfg.URL = v
+	}
+	if n, err := strconv.Atoi(os.Getenv("INGEST_QUEUE_SIZE")); err == nil && n > 0 {
+		cfg.QueueSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("INGEST_WORKERS")); err == nil && n > 0 {
+		cfg.Workers = n
+	}
+	if v := os.Getenv("INGEST_RESOLVE_PROFILES"); v != "" {
+		resolve, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Printf("Invalid INGEST_RESOLVE_PROFILES %q, resolving profiles", v)
+		} else {
+			cfg.ResolveProfiles = resolve
+		}
+	}
+	return cfg
+}
+
+// Stats are counters since the consumer started.
+type Stats struct {
+	Connected    bool   `json:"connected"`
+	Cursor       int64  `json:"cursor"`
+	Received     int64  `json:"received"` // every frame
+	Posts        int64  `json:"posts"`    // newly created posts
+	Matched      int64  `json:"matched"`  // posts that passed the filter and were queued
+	Queued       int64  `json:"queued"`   // currently waiting or being processed
+	Saved        int64  `json:"saved"`
+	AlreadyExist int64  `json:"alreadyExist"`
+	Failed       int64  `json:"failed"`
+	Skipped      int64  `json:"skipped"` // not analyzed, the NLP budget was spent
+	Reconnects   int64  `json:"reconnects"`
+	LastError    string `json:"lastError,omitempty"`
+}
+
+type job struct {
+	event Event
+	post  PostRecord
+}
+
+// Consumer reads posts from a Jetstream websocket and saves the ones passing the filter through processor.SaveSkeet.
+//
+// Matched posts go through a bounded queue to a fixed number of workers. When the queue is full the reader
+// blocks, Jetstream disconnects consumers that fall too far behind and we resume from the saved cursor,
+// so nothing is lost. The saved cursor never goes past a post that hasn't finished processing, and the posts
+// replayed after a reconnect that are still queued or being processed are skipped rather than queued twice.
+type Consumer struct {
+	cfg      Config
+	pipeline *processor.Pipeline
+	queue    chan job
+	profiles *profileResolver
+
+	mu        sync.Mutex
+	inflight  map[int64]int   // time_us -> posts in the queue or being processed
+	queued    map[string]bool // URIs of the posts in the queue or being processed
+	lastSeen  int64
+	lastSaved int64
+	connected bool
+	lastError string
+
+	received, posts, matched, saved, alreadyExist, failed, skipped, reconnects atomic.Int64
+}
+
+func NewConsumer(pipeline *processor.Pipeline, cfg Config) *Consumer {
+	c := &Consumer{
+		cfg:      cfg,
+		pipeline: pipeline,
+		queue:    make(chan job, cfg.QueueSize),
+		inflight: make(map[int64]int),
+		queued:   make(map[string]bool),
+	}
+	if cfg.ResolveProfiles {
+		c.profiles = newProfileResolver()
+	}
+	return c
+}
+
+func (c *Consumer) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	queued := 0
+	for _, n := range c.inflight {
+		queued += n
+	}
+	return Stats{
+		Connected:    c.connected,
+		Cursor:       c.safeCursorLocked(),
+		Received:     c.received.Load(),
+		Posts:        c.posts.Load(),
+		Matched:      c.matched.Load(),
+		Queued:       int64(queued),
+		Saved:        c.saved.Load(),
+		AlreadyExist: c.alreadyExist.Load(),
+		Failed:       c.failed.Load(),
+		Skipped:      c.skipped.Load(),
+		Reconnects:   c.reconnects.Load(),
+		LastError:    c.lastError,
+	}
+}
+
+// Run consumes the stream until ctx is cancelled, reconnecting with backoff.
+// On return the queue has been drained and the final cursor saved.
+func (c *Consumer) Run(ctx context.Context) error {
+	store := c.pipeline.Store
+
+	saved, ok, err := store.GetCursor(c.cfg.CursorName)
+	if err != nil {
+		return fmt.Errorf("loading cursor %s: %w", c.cfg.CursorName, err)
+	}
+	if ok {
+		if cursor, err := strconv.ParseInt(saved.Value, 10, 64); err == nil {
+			c.mu.Lock()
+			c.lastSeen = cursor
+			c.lastSaved = cursor
+			c.mu.Unlock()
+			log.Printf("Ingest: resuming from cursor %d (saved %s)", cursor, saved.UpdatedAt)
+		}
+	}
+
+	var record *os.File
+	if c.cfg.RecordPath != "" {
+		record, err = os.OpenFile(c.cfg.RecordPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("opening record file: %w", err)
+		}
+		defer record.Close()
+	}
+
+	var workers sync.WaitGroup
+	for i := 0; i < c.cfg.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range c.queue {
+				c.process(ctx, j)
+			}
+		}()
+	}
+
+	saverDone := make(chan struct{})
+	go func() {
+		defer close(saverDone)
+		ticker := time.NewTicker(c.cfg.CursorInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.saveCursor()
+			}
+		}
+	}()
+
+	var delay time.Duration
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := c.consume(ctx, record)
+		if ctx.Err() != nil {
+			break
+		}
+
+		if err != nil {
+			c.mu.Lock()
+			c.lastError = err.Error()
+			c.mu.Unlock()
+		}
+		c.reconnects.Add(1)
+
+		delay = reconnectDelay(delay, time.Since(started))
+		log.Printf("Ingest: disconnected (%v), reconnecting in %s", err, delay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+
+	close(c.queue)
+	workers.Wait()
+	<-saverDone
+	c.saveCursor()
+	log.Printf("Ingest: stopped, %+v", c.Stats())
+	return nil
+}
+
+// reconnectDelay is the wait before reconnecting: doubled after every failed connection up to
+// maxReconnectDelay, back to minReconnectDelay after one that stayed up for a while.
+func reconnectDelay(last, uptime time.Duration) time.Duration {
+	if last == 0 || uptime > maxReconnectDelay {
+		return minReconnectDelay
+	}
+	return min(last*2, maxReconnectDelay)
+}
+
+// consume reads one websocket connection until it fails or ctx is cancelled.
+func (c *Consumer) consume(ctx context.Context, record *os.File) error {
+	u, err := url.Parse(c.cfg.URL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("wantedCollections", postCollection)
+	c.mu.Lock()
+	if cursor := c.safeCursorLocked(); cursor > 0 {
+		q.Set("cursor", strconv.FormatInt(cursor, 10))
+	}
+	c.mu.Unlock()
+	u.RawQuery = q.Encode()
+
+	log.Printf("Ingest: connecting to %s", u.String())
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// unblock ReadMessage when we are shutting down
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c.setConnected(true)
+	defer c.setConnected(false)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		c.received.Add(1)
+
+		if record != nil {
+			if _, err := record.Write(append(frame, '\n')); err != nil {
+				log.Printf("Ingest: failed to record frame: %v", err)
+			}
+		}
+
+		var event Event
+		if err := json.Unmarshal(frame, &event); err != nil {
+			log.Printf("Ingest: skipping bad frame: %v", err)
+			continue
+		}
+
+		post, ok := event.Post()
+		if ok {
+			c.posts.Add(1)
+		}
+		if !ok || !c.cfg.Filter.Match(post) {
+			c.seen(event.TimeUS)
+			continue
+		}
+
+		// Resuming before the oldest unfinished post replays the ones still queued
+		uri := event.URI()
+		c.mu.Lock()
+		if c.queued[uri] {
+			c.mu.Unlock()
+			c.seen(event.TimeUS)
+			continue
+		}
+		c.queued[uri] = true
+		c.inflight[event.TimeUS]++
+		c.mu.Unlock()
+
+		// Seen once queued, a post dropped on shutdown must stay ahead of the saved cursor
+		j := job{event: event, post: post}
+		select {
+		case c.queue <- j:
+			c.matched.Add(1)
+			c.seen(event.TimeUS)
+		case <-ctx.Done():
+			c.done(j)
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Consumer) process(ctx context.Context, j job) {
+	defer c.done(j)
+
+	var profile Profile
+	if c.profiles != nil {
+		var err error
+		profile, err = c.profiles.resolve(ctx, j.event.Did)
+		if err != nil {
+			log.Printf("Ingest: could not resolve profile %s: %v", j.event.Did, err)
+		}
+	}
+
+	result, err := processor.SaveSkeet(toSkeet(j.event, j.post, profile), c.pipeline)
+	switch {
+	case errors.Is(err, limits.ErrBudgetExhausted):
+		c.skipped.Add(1) // logged once by the limiter
+	case err != nil:
+		log.Printf("Ingest: error saving %s: %v", j.event.URI(), err)
+		c.failed.Add(1)
+	case result.AlreadyExist:
+		c.alreadyExist.Add(1)
+	default:
+		c.saved.Add(1)
+	}
+}
+
+func (c *Consumer) setConnected(connected bool) {
+	c.mu.Lock()
+	c.connected = connected
+	c.mu.Unlock()
+}
+
+func (c *Consumer) seen(timeUS int64) {
+	c.mu.Lock()
+	if timeUS > c.lastSeen {
+		c.lastSeen = timeUS
+	}
+	c.mu.Unlock()
+}
+
+func (c *Consumer) done(j job) {
+	timeUS := j.event.TimeUS
+	c.mu.Lock()
+	c.inflight[timeUS]--
+	if c.inflight[timeUS] <= 0 {
+		delete(c.inflight, timeUS)
+	}
+	delete(c.queued, j.event.URI())
+	c.mu.Unlock()
+}
+
+// safeCursorLocked is the cursor to resume from: just before the oldest unfinished post,
+// or the latest event seen if nothing is in flight. Callers must hold c.mu.
+func (c *Consumer) safeCursorLocked() int64 {
+	cursor := c.lastSeen
+	for timeUS := range c.inflight {
+		if timeUS-1 < cursor {
+			cursor = timeUS - 1
+		}
+	}
+	return cursor
+}
+
+func (c *Consumer) saveCursor() {
+	c.mu.Lock()
+	cursor := c.safeCursorLocked()
+	if cursor <= 0 || cursor == c.lastSaved {
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	err := c.pipeline.Store.SaveCursor(types.Cursor{
+		Name:      c.cfg.CursorName,
+		Value:     strconv.FormatInt(cursor, 10),
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Printf("Ingest: failed to save cursor: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	c.lastSaved = cursor
+	c.mu.Unlock()
+}
@@ -0,0 +1,339 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const firstTimeUS = 1735689600000000
+
+// testFrames are Jetstream frames, every fifth an identity event and every fourth post off topic.
+// matching maps the URIs of the posts passing DefaultKeywords to their time_us.
+func testFrames(t *testing.T, n int) (frames [][]byte, matching map[string]int64) {
+	t.Helper()
+	matching = make(map[string]int64)
+	for i := 0; i < n; i++ {
+		event := Event{Did: fmt.Sprintf("did:plc:test%d", i%3), TimeUS: firstTimeUS + int64(i)*1000, Kind: "identity"}
+		if i%5 != 4 {
+			text := fmt.Sprintf("Wildfire near Paradise, evacuate now (%d)", i)
+			if i%4 == 3 {
+				text = fmt.Sprintf("Great coffee this morning (%d)", i)
+			}
+			record, _ := json.Marshal(PostRecord{Type: postCollection, Text: text, CreatedAt: "2025-01-01T00:00:00Z", Langs: []string{"en"}})
+			event.Kind = "commit"
+			event.Commit = &Commit{Operation: "create", Collection: postCollection, RKey: fmt.Sprintf("post%d", i), Record: record}
+			if i%4 != 3 {
+				matching[event.URI()] = event.TimeUS
+			}
+		}
+		frame, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, matching
+}
+
+func writeFrames(t *testing.T, frames [][]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "frames.jsonl")
+	var lines []string
+	for _, frame := range frames {
+		lines = append(lines, string(frame))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// recordingStore counts the saved skeets and checks every saved cursor against them.
+type recordingStore struct {
+	db.Store
+	t        *testing.T
+	matching map[string]int64
+	gate     chan struct{} // when set, SaveCompleteSkeet waits until it is closed
+	delay    time.Duration
+
+	mu      sync.Mutex
+	saves   map[string]int
+	cursors []int64
+}
+
+func (s *recordingStore) SaveCompleteSkeet(data types.SaveCompleteSkeetType) ([]string, error) {
+	if s.gate != nil {
+		<-s.gate
+	}
+	time.Sleep(s.delay)
+	locations, err := s.Store.SaveCompleteSkeet(data)
+	s.mu.Lock()
+	s.saves[data.NewSkeet.UID]++
+	s.mu.Unlock()
+	return locations, err
+}
+
+func (s *recordingStore) SaveCursor(cursor types.Cursor) error {
+	value, err := strconv.ParseInt(cursor.Value, 10, 64)
+	if err != nil {
+		s.t.Errorf("cursor %q isn't a time_us", cursor.Value)
+	}
+	s.mu.Lock()
+	for uri, timeUS := range s.matching {
+		if timeUS <= value && s.saves[uri] == 0 {
+			s.t.Errorf("cursor %d saved before %s at %d was", value, uri, timeUS)
+		}
+	}
+	if n := len(s.cursors); n > 0 && value < s.cursors[n-1] {
+		s.t.Errorf("cursor went back from %d to %d", s.cursors[n-1], value)
+	}
+	s.cursors = append(s.cursors, value)
+	s.mu.Unlock()
+	return s.Store.SaveCursor(cursor)
+}
+
+func (s *recordingStore) check(t *testing.T) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uri := range s.matching {
+		if s.saves[uri] != 1 {
+			t.Errorf("%s saved %d times, want once", uri, s.saves[uri])
+		}
+	}
+	if len(s.saves) != len(s.matching) {
+		t.Errorf("%d posts saved, want the %d matching ones", len(s.saves), len(s.matching))
+	}
+}
+
+// testPipeline is the offline pipeline on a MemoryStore, its store wrapped in a recordingStore.
+func testPipeline(t *testing.T, matching map[string]int64) (*processor.Pipeline, *recordingStore) {
+	t.Helper()
+	t.Setenv("STORE", "memory")
+	t.Setenv("NLP", "local")
+	t.Setenv("GEOCODER", "offline")
+	t.Setenv("CLASSIFIER", "local")
+	t.Setenv("GAZETTEER_PATH", "../data/gazetteer.tsv")
+	t.Setenv("SENTIMENT_LEXICON_PATH", "../data/sentiment_lexicon.tsv")
+	t.Setenv("CLASSIFIER_TRAINING_DATA", "../demo_data.csv")
+	t.Setenv("DETECTION_CONFIG", "../data/detection.yaml")
+	t.Setenv("DETECTION_PROFILE", "")
+	t.Setenv("LIMITS_CONFIG", "../data/limits.yaml")
+
+	p, err := processor.InitPipeline()
+	if err != nil {
+		t.Fatalf("InitPipeline: %v", err)
+	}
+	store := &recordingStore{Store: p.Store, t: t, matching: matching, saves: make(map[string]int)}
+	p.Store = store
+	return p, store
+}
+
+func testConsumer(p *processor.Pipeline, url string, queueSize int) *Consumer {
+	return NewConsumer(p, Config{
+		URL:            url,
+		CursorName:     "test",
+		QueueSize:      queueSize,
+		Workers:        2,
+		Filter:         Filter{Keywords: DefaultKeywords, Languages: []string{"en"}},
+		CursorInterval: time.Millisecond,
+	})
+}
+
+func websocketURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// start runs the consumer until the returned stop is called, stop returns once Run did.
+func start(t *testing.T, c *Consumer) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Run: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Run didn't return after the cancel")
+		}
+	}
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func savedCursor(t *testing.T, store db.Store) int64 {
+	t.Helper()
+	cursor, ok, err := store.GetCursor("test")
+	if err != nil || !ok {
+		t.Fatalf("no saved cursor: %v", err)
+	}
+	value, _ := strconv.ParseInt(cursor.Value, 10, 64)
+	return value
+}
+
+func TestConsumerResumes(t *testing.T) {
+	frames, matching := testFrames(t, 60)
+	p, store := testPipeline(t, matching)
+	store.delay = 2 * time.Millisecond
+	server := httptest.NewServer(NewReplayServer(writeFrames(t, frames), 0))
+	defer server.Close()
+
+	// Stopped halfway: the queue is drained and the cursor saved before Run returns
+	first := testConsumer(p, websocketURL(server), 4)
+	stop := start(t, first)
+	waitUntil(t, "the first posts", func() bool { return first.Stats().Saved >= 10 })
+	stop()
+	stats := first.Stats()
+	if stats.Queued != 0 || stats.Saved != stats.Matched {
+		t.Fatalf("stopped with %+v, want the queue drained", stats)
+	}
+	if savedCursor(t, store) != stats.Cursor {
+		t.Errorf("saved cursor %d, want the final %d", savedCursor(t, store), stats.Cursor)
+	}
+
+	second := testConsumer(p, websocketURL(server), 4)
+	stop = start(t, second)
+	waitUntil(t, "the remaining posts", func() bool { return int(stats.Saved+second.Stats().Saved) == len(matching) })
+	stop()
+	if s := second.Stats(); s.AlreadyExist != 0 || s.Failed != 0 {
+		t.Errorf("resumed run: %+v, want no post read twice", s)
+	}
+	store.check(t)
+	if last := firstTimeUS + int64(len(frames)-1)*1000; savedCursor(t, store) != last {
+		t.Errorf("final cursor %d, want the last event %d", savedCursor(t, store), last)
+	}
+}
+
+func TestConsumerReconnectSkipsQueuedPosts(t *testing.T) {
+	frames, matching := testFrames(t, 40)
+	p, store := testPipeline(t, matching)
+	store.gate = make(chan struct{})
+
+	// The first connection drops after 10 frames, while their posts are still queued or being
+	// processed. The reconnect resumes before the oldest of them, so the replay sends all 40.
+	replay := NewReplayServer(writeFrames(t, frames), 0)
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if connections.Add(1) > 1 {
+			replay.ServeHTTP(w, r)
+			return
+		}
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+			t.Errorf("first connection asked for cursor %s", cursor)
+		}
+		var upgrader websocket.Upgrader
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, frame := range frames[:10] {
+			conn.WriteMessage(websocket.TextMessage, frame)
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	c := testConsumer(p, websocketURL(server), 100)
+	stop := start(t, c)
+	defer stop()
+	waitUntil(t, "the replay", func() bool { return c.Stats().Received == 50 })
+	if s := c.Stats(); s.Reconnects != 1 || s.Cursor != firstTimeUS-1 {
+		t.Errorf("after the reconnect: %+v, want one reconnect and the cursor before the first post", s)
+	}
+	close(store.gate)
+
+	waitUntil(t, "the posts", func() bool {
+		s := c.Stats()
+		return s.Queued == 0 && int(s.Saved+s.AlreadyExist) >= len(matching)
+	})
+	if s := c.Stats(); int(s.Matched) != len(matching) || s.AlreadyExist != 0 {
+		t.Errorf("stats = %+v, want %d posts queued once each", s, len(matching))
+	}
+	store.check(t)
+}
+
+func TestSafeCursor(t *testing.T) {
+	c := &Consumer{inflight: make(map[int64]int), queued: make(map[string]bool)}
+	post := func(timeUS int64, rkey string) job {
+		return job{event: Event{Did: "did:plc:test", TimeUS: timeUS, Commit: &Commit{Collection: postCollection, RKey: rkey}}}
+	}
+	queue := func(j job) {
+		c.inflight[j.event.TimeUS]++
+		c.queued[j.event.URI()] = true
+		c.seen(j.event.TimeUS)
+	}
+	cursor := func() int64 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.safeCursorLocked()
+	}
+
+	c.seen(100)
+	if got := cursor(); got != 100 {
+		t.Fatalf("nothing in flight: cursor %d, want the last seen 100", got)
+	}
+	a, b, b2 := post(200, "a"), post(300, "b"), post(300, "b2") // two posts in the same microsecond
+	queue(a)
+	queue(b)
+	queue(b2)
+	c.seen(400)
+	if got := cursor(); got != 199 {
+		t.Fatalf("cursor %d, want 199 before the oldest post in flight", got)
+	}
+	c.done(b)
+	c.done(a)
+	if got := cursor(); got != 299 {
+		t.Fatalf("cursor %d, want 299 while the other post at 300 is in flight", got)
+	}
+	c.done(b2)
+	if got := cursor(); got != 400 || len(c.inflight) != 0 || len(c.queued) != 0 {
+		t.Fatalf("cursor %d with %v in flight and %v queued, want 400 and nothing left", got, c.inflight, c.queued)
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	var delays []time.Duration
+	delay := time.Duration(0)
+	for i := 0; i < 9; i++ {
+		delay = reconnectDelay(delay, time.Second)
+		delays = append(delays, delay)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second,
+		32 * time.Second, 64 * time.Second, maxReconnectDelay, maxReconnectDelay}
+	if fmt.Sprint(delays) != fmt.Sprint(want) {
+		t.Errorf("delays = %v, want %v", delays, want)
+	}
+	if got := reconnectDelay(maxReconnectDelay, 10*time.Minute); got != minReconnectDelay {
+		t.Errorf("after a long connection: %s, want %s", got, minReconnectDelay)
+	}
+}
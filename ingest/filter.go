@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// DefaultKeywords keeps the stream close to what the disaster feeds used to return.
+var DefaultKeywords = []string{
+	"wildfire", "wildfires", "fire", "fires", "blaze", "evacuation", "evacuate", "evacuated",
+	"hurricane", "hurricanes", "tropical storm", "landfall", "storm surge", "cyclone", "typhoon",
+	"earthquake", "earthquakes", "quake", "aftershock", "tremor", "seismic",
+	"flood", "flooding", "tornado", "tsunami", "eruption", "volcano", "landslide", "mudslide",
+	"blizzard", "snowstorm", "drought",
+}
+
+// Filter drops posts before they are queued, everything that passes costs a classifier and NLP call.
+type Filter struct {
+	// a post must contain at least one keyword, matched on whole words, case insensitive.
+	// Multi word keywords match as a phrase. Empty means every post passes.
+	Keywords []string
+	// a post must be tagged with one of these languages. Posts with no language tag pass. Empty means any language.
+	Languages []string
+}
+
+// FilterFromEnv reads INGEST_KEYWORDS and INGEST_LANGS (comma separated), defaulting to DefaultKeywords and "en".
+func FilterFromEnv() Filter {
+	f := Filter{
+		Keywords:  DefaultKeywords,
+		Languages: []string{"en"},
+	}
+	if v := os.Getenv("INGEST_KEYWORDS"); v != "" {
+		f.Keywords = splitList(v)
+	}
+	if v, ok := os.LookupEnv("INGEST_LANGS"); ok {
+		f.Languages = splitList(v)
+	}
+	return f
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (f Filter) Match(post PostRecord) bool {
+	return f.matchLanguage(post.Langs) && f.matchKeywords(post.Text)
+}
+
+func (f Filter) matchLanguage(langs []string) bool {
+	if len(f.Languages) == 0 || len(langs) == 0 {
+		return true
+	}
+	for _, lang := range langs {
+		// "en-US" counts as "en"
+		base := strings.ToLower(strings.SplitN(lang, "-", 2)[0])
+		for _, want := range f.Languages {
+			if strings.EqualFold(base, want) || strings.EqualFold(lang, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f Filter) matchKeywords(text string) bool {
+	if len(f.Keywords) == 0 {
+		return true
+	}
+	// padded with spaces so keywords only match whole words, "#Wildfire" -> " wildfire "
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ") + " "
+	for _, keyword := range f.Keywords {
+		if strings.Contains(words, " "+strings.ToLower(keyword)+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package ingest
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	f := Filter{Keywords: []string{"wildfire", "storm surge"}, Languages: []string{"en", "pt-BR"}}
+	cases := []struct {
+		text  string
+		langs []string
+		want  bool
+	}{
+		{"Wildfire near the town", []string{"en"}, true},
+		{"#WILDFIRE update", []string{"en"}, true},
+		{"wildfires everywhere", []string{"en"}, false}, // whole words only
+		{"the storm surge is coming", []string{"en"}, true},
+		{"a storm, then a surge", []string{"en"}, false}, // phrases match as a phrase
+		{"wildfire", []string{"en-US"}, true},
+		{"wildfire", []string{"pt-BR"}, true},
+		{"wildfire", []string{"pt-PT"}, false},
+		{"wildfire", []string{"de", "en"}, true},
+		{"wildfire", []string{"de"}, false},
+		{"wildfire", nil, true}, // no language tag
+		{"nothing to see", []string{"en"}, false},
+	}
+	for _, c := range cases {
+		if got := f.Match(PostRecord{Text: c.text, Langs: c.langs}); got != c.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", c.text, c.langs, got, c.want)
+		}
+	}
+
+	if !(Filter{}).Match(PostRecord{Text: "anything", Langs: []string{"ja"}}) {
+		t.Error("an empty filter dropped a post")
+	}
+}
@@ -0,0 +1,75 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-firebird/types"
+)
+
+const (
+	// DefaultJetstreamURL is one of the public Jetstream instances run by Bluesky.
+	DefaultJetstreamURL = "wss://jetstream2.us-east.bsky.network/subscribe"
+	postCollection      = "app.bsky.feed.post"
+)
+
+// Event is one Jetstream message. Only commit events carry posts, identity and account events are skipped.
+type Event struct {
+	Did    string  `json:"did"`
+	TimeUS int64   `json:"time_us"` // also the cursor to resume from
+	Kind   string  `json:"kind"`    // commit, identity or account
+	Commit *Commit `json:"commit,omitempty"`
+}
+
+type Commit struct {
+	Rev        string          `json:"rev"`
+	Operation  string          `json:"operation"` // create, update or delete
+	Collection string          `json:"collection"`
+	RKey       string          `json:"rkey"`
+	Record     json.RawMessage `json:"record,omitempty"`
+	CID        string          `json:"cid"`
+}
+
+// PostRecord is the part of an app.bsky.feed.post record we use.
+type PostRecord struct {
+	Type      string   `json:"$type"`
+	Text      string   `json:"text"`
+	CreatedAt string   `json:"createdAt"`
+	Langs     []string `json:"langs,omitempty"`
+}
+
+// Post returns the post record of a newly created post, ok is false for every other event.
+func (e Event) Post() (PostRecord, bool) {
+	var post PostRecord
+	if e.Kind != "commit" || e.Commit == nil {
+		return post, false
+	}
+	if e.Commit.Operation != "create" || e.Commit.Collection != postCollection {
+		return post, false
+	}
+	if err := json.Unmarshal(e.Commit.Record, &post); err != nil {
+		return post, false
+	}
+	return post, true
+}
+
+// URI is the at:// URI of the post, the same value the feed endpoints return as Post.URI.
+func (e Event) URI() string {
+	return fmt.Sprintf("at://%s/%s/%s", e.Did, e.Commit.Collection, e.Commit.RKey)
+}
+
+// toSkeet builds the skeet saved by processor.SaveSkeet. Jetstream only has the author DID,
+// so the profile fields come from the resolver and stay empty if it fails.
+func toSkeet(e Event, post PostRecord, profile Profile) types.Skeet {
+	handle := profile.Handle
+	if handle == "" {
+		handle = e.Did
+	}
+	return types.Skeet{
+		Avatar:      profile.Avatar,
+		Content:     post.Text,
+		Timestamp:   post.CreatedAt,
+		Handle:      handle,
+		DisplayName: profile.DisplayName,
+		UID:         e.URI(),
+	}
+}
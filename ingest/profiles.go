@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/xrpc"
+)
+
+const (
+	profileMethod = "app.bsky.actor.getProfile"
+	// the cache is cleared once it gets this big, authors that post often are re-fetched quickly
+	maxCachedProfiles = 10000
+)
+
+// Profile is the author info the feed endpoints return with every post but Jetstream doesn't.
+type Profile struct {
+	Handle      string `json:"handle"`
+	DisplayName string `json:"displayName"`
+	Avatar      string `json:"avatar"`
+}
+
+// profileResolver looks up authors on the public API and caches them by DID, failed lookups included.
+type profileResolver struct {
+	client *xrpc.Client
+	mu     sync.Mutex
+	cache  map[string]Profile
+}
+
+func newProfileResolver() *profileResolver {
+	return &profileResolver{
+		client: &xrpc.Client{
+			Client: &http.Client{Timeout: 10 * time.Second},
+			Host:   "https://public.api.bsky.app", // public endpoint for unauthenticated requests.
+		},
+		cache: make(map[string]Profile),
+	}
+}
+
+func (r *profileResolver) resolve(ctx context.Context, did string) (Profile, error) {
+	r.mu.Lock()
+	profile, ok := r.cache[did]
+	r.mu.Unlock()
+	if ok {
+		return profile, nil
+	}
+
+	params := map[string]interface{}{"actor": did}
+	err := r.client.Do(ctx, xrpc.Query, "json", profileMethod, params, nil, &profile)
+
+	r.mu.Lock()
+	if len(r.cache) >= maxCachedProfiles {
+		r.cache = make(map[string]Profile)
+	}
+	r.cache[did] = profile
+	r.mu.Unlock()
+
+	return profile, err
+}
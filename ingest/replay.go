@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ReplayServer serves recorded Jetstream frames (one JSON event per line, the format written
+// with INGEST_RECORD_PATH) over a websocket, so the ingest can run against a fixed input.
+// It honors the cursor and wantedCollections query parameters like the real Jetstream.
+// After the last frame the connection stays open, like a live stream with no new posts.
+type ReplayServer struct {
+	Path     string
+	Interval time.Duration // pause between frames
+	upgrader websocket.Upgrader
+}
+
+func NewReplayServer(path string, interval time.Duration) *ReplayServer {
+	return &ReplayServer{Path: path, Interval: interval}
+}
+
+func (s *ReplayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		http.Error(w, "could not open replay file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	var cursor int64
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, _ = strconv.ParseInt(v, 10, 64)
+	}
+	wanted := make(map[string]bool)
+	for _, v := range r.URL.Query()["wantedCollections"] {
+		for _, collection := range strings.Split(v, ",") {
+			wanted[collection] = true
+		}
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Replay: upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// the client closing is only noticed while reading
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	sent := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			continue
+		}
+		if event.TimeUS <= cursor {
+			continue
+		}
+		if len(wanted) > 0 && event.Commit != nil && !wanted[event.Commit.Collection] {
+			continue
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, line); err != nil {
+			return
+		}
+		sent++
+
+		if s.Interval > 0 {
+			select {
+			case <-closed:
+				return
+			case <-time.After(s.Interval):
+			}
+		}
+	}
+	log.Printf("Replay: sent %d frames from %s (cursor %d)", sent, s.Path, cursor)
+
+	<-closed
+}
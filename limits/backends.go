@@ -0,0 +1,104 @@
+package limits
+
+import (
+	"context"
+	"go-firebird/geocode"
+	"go-firebird/mlmodel"
+	"go-firebird/nlp"
+	"go-firebird/types"
+)
+
+// Sentiment and Entities limit the NLP calls of Backend as GoogleNLP calls.
+type Sentiment struct {
+	Backend nlp.SentimentAnalyzer
+	Limiter *Limiter
+}
+
+func (s *Sentiment) AnalyzeSentiment(text string) (types.Sentiment, error) {
+	call, err := s.Limiter.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		return types.Sentiment{}, err
+	}
+	sentiment, err := s.Backend.AnalyzeSentiment(text)
+	call.Done(err)
+	return sentiment, err
+}
+
+type Entities struct {
+	Backend nlp.EntityExtractor
+	Limiter *Limiter
+}
+
+func (e *Entities) AnalyzeEntities(text string) ([]types.Entity, error) {
+	call, err := e.Limiter.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		return nil, err
+	}
+	entities, err := e.Backend.AnalyzeEntities(text)
+	call.Done(err)
+	return entities, err
+}
+
+// Classifier limits Backend as MLModel calls, one per batch.
+type Classifier struct {
+	Backend mlmodel.Classifier
+	Limiter *Limiter
+}
+
+func (c *Classifier) Name() string { return c.Backend.Name() }
+
+func (c *Classifier) Classify(inputs mlmodel.MLRequest) (map[string]types.CategoryDistribution, error) {
+	call, err := c.Limiter.Acquire(context.Background(), MLModel)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.Backend.Classify(inputs)
+	call.Done(err)
+	return out, err
+}
+
+// Geocoder limits Backend as GoogleMaps calls. It goes under the cache, hits are free.
+type Geocoder struct {
+	Backend geocode.Geocoder
+	Limiter *Limiter
+}
+
+func (g *Geocoder) Name() string { return g.Backend.Name() }
+
+func (g *Geocoder) Geocode(address string) (types.GeocodeResult, error) {
+	call, err := g.Limiter.Acquire(context.Background(), GoogleMaps)
+	if err != nil {
+		return types.GeocodeResult{Query: address, Source: g.Backend.Name()}, err
+	}
+	result, err := g.Backend.Geocode(address)
+	call.Done(err)
+	return result, err
+}
+
+// Wrap puts the limiter in front of the backends that call a paid API: Google NLP, the remote
+// classifier (its local fallback stays unlimited) and the Google geocoder. Local ones are returned as is.
+func Wrap(l *Limiter, sentiment nlp.SentimentAnalyzer, entities nlp.EntityExtractor, classifier mlmodel.Classifier,
+	geocoder geocode.Geocoder) (nlp.SentimentAnalyzer, nlp.EntityExtractor, mlmodel.Classifier, geocode.Geocoder) {
+	if _, ok := sentiment.(*nlp.GoogleNLP); ok {
+		sentiment = &Sentiment{Backend: sentiment, Limiter: l}
+	}
+	if _, ok := entities.(*nlp.GoogleNLP); ok {
+		entities = &Entities{Backend: entities, Limiter: l}
+	}
+	if fallback, ok := classifier.(*mlmodel.FallbackClassifier); ok {
+		if _, remote := fallback.Primary.(*mlmodel.HTTPClassifier); remote {
+			fallback.Primary = &Classifier{Backend: fallback.Primary, Limiter: l}
+		}
+	} else if _, remote := classifier.(*mlmodel.HTTPClassifier); remote {
+		classifier = &Classifier{Backend: classifier, Limiter: l}
+	}
+	switch g := geocoder.(type) {
+	case *geocode.CachedGeocoder:
+		if _, google := g.Backend.(*geocode.GoogleGeocoder); google {
+			g.Backend = &Geocoder{Backend: g.Backend, Limiter: l}
+		}
+	case *geocode.GoogleGeocoder:
+		geocoder = &Geocoder{Backend: g, Limiter: l}
+	}
+	return sentiment, entities, classifier, geocoder
+}
@@ -0,0 +1,122 @@
+package limits
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provider is a paid upstream API.
+type Provider string
+
+const (
+	GoogleNLP  Provider = "google_nlp"  // sentiment and entity analysis, two calls per skeet
+	MLModel    Provider = "ml_model"    // the hosted classifier
+	GoogleMaps Provider = "google_maps" // geocoding of new locations, cache misses only
+	OpenAI     Provider = "openai"      // disaster summaries and /api/firebird/classify
+)
+
+const defaultConfigPath = "./data/limits.yaml"
+
+// ProviderConfig limits one provider. Zero rate, concurrency or budget means no limit.
+type ProviderConfig struct {
+	RatePerSecond   float64 `yaml:"ratePerSecond" json:"ratePerSecond"`
+	Burst           int     `yaml:"burst" json:"burst"`             // calls that can be made at once after a quiet spell
+	Concurrency     int     `yaml:"concurrency" json:"concurrency"` // calls in flight at once
+	DailyBudget     float64 `yaml:"dailyBudget" json:"dailyBudget"` // USD per UTC day
+	CallCost        float64 `yaml:"callCost" json:"callCost"`       // USD per successful call, and what a call reserves of the budget
+	InputTokenCost  float64 `yaml:"inputTokenCost" json:"inputTokenCost,omitempty"`
+	OutputTokenCost float64 `yaml:"outputTokenCost" json:"outputTokenCost,omitempty"` // USD per million tokens
+}
+
+type Config map[Provider]ProviderConfig
+
+// DefaultConfig stays inside the providers' default quotas, with list prices as costs.
+func DefaultConfig() Config {
+	return Config{
+		GoogleNLP:  {RatePerSecond: 10, Burst: 20, Concurrency: 10, DailyBudget: 5, CallCost: 0.001},
+		MLModel:    {RatePerSecond: 20, Burst: 40, Concurrency: 8},
+		GoogleMaps: {RatePerSecond: 25, Burst: 50, Concurrency: 10, DailyBudget: 5, CallCost: 0.005},
+		OpenAI: {RatePerSecond: 1, Burst: 5, Concurrency: 4, DailyBudget: 2, CallCost: 0.001,
+			InputTokenCost: 0.15, OutputTokenCost: 0.60},
+	}
+}
+
+// ConfigPath returns LIMITS_CONFIG or the bundled ./data/limits.yaml.
+func ConfigPath() string {
+	if p := os.Getenv("LIMITS_CONFIG"); p != "" {
+		return p
+	}
+	return defaultConfigPath
+}
+
+// LoadConfig reads a limits file, JSON if the extension is .json and YAML otherwise. Every provider
+// starts from its defaults, so the file only lists what it changes. A missing file is not an error,
+// the defaults are used then.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("No limits at %s, using the defaults\n", path)
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var file struct {
+			Providers map[Provider]json.RawMessage `json:"providers"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for name, raw := range file.Providers {
+			provider := cfg[name]
+			if err := json.Unmarshal(raw, &provider); err != nil {
+				return nil, fmt.Errorf("%s: provider %s: %w", path, name, err)
+			}
+			cfg[name] = provider
+		}
+	} else {
+		var file struct {
+			Providers map[Provider]yaml.Node `yaml:"providers"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", path, err)
+		}
+		for name, node := range file.Providers {
+			provider := cfg[name]
+			if err := node.Decode(&provider); err != nil {
+				return nil, fmt.Errorf("%s: provider %s: %w", path, name, err)
+			}
+			cfg[name] = provider
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := c[Provider(name)]
+		if p.RatePerSecond < 0 || p.Burst < 0 || p.Concurrency < 0 || p.DailyBudget < 0 ||
+			p.CallCost < 0 || p.InputTokenCost < 0 || p.OutputTokenCost < 0 {
+			return fmt.Errorf("provider %s: limits and costs can't be negative", name)
+		}
+		if p.RatePerSecond > 0 && p.Burst == 0 {
+			return fmt.Errorf("provider %s: a rate needs a burst of at least 1", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+// Package limits keeps the paid upstream APIs (Google NLP, the ML model, Google Maps, OpenAI) inside
+// their quotas and our budget: every call takes a token from its provider's bucket and a concurrency
+// slot, and is charged to the provider's daily budget. Calls past the budget fail fast with
+// ErrBudgetExhausted so callers can skip them. Usage is kept in the store per provider and UTC day.
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-firebird/types"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// flushEvery is how often usage is written to the store, it is also written when a budget runs out.
+	flushEvery = 30 * time.Second
+	// budgetSlack keeps float rounding from refusing the last call that fits the budget.
+	budgetSlack = 1e-9
+)
+
+var ErrBudgetExhausted = errors.New("daily budget exhausted")
+
+// UsageStore keeps the daily usage. db.Store implements it.
+type UsageStore interface {
+	GetProviderUsage(day string) ([]types.ProviderUsage, error)
+	SaveProviderUsage(usage types.ProviderUsage) error
+}
+
+// Limiter is shared by everything calling the providers. A nil Limiter doesn't limit anything.
+type Limiter struct {
+	store UsageStore
+	now   func() time.Time
+
+	mu        sync.Mutex
+	providers map[Provider]*provider
+	day       string
+	lastFlush time.Time
+	saving    sync.WaitGroup // the flushes and rollover saves running in the background
+}
+
+type provider struct {
+	cfg   ProviderConfig
+	slots chan struct{} // nil without a concurrency cap
+
+	tokens   float64 // may go negative, the calls waiting for their token
+	refilled time.Time
+
+	usage    types.ProviderUsage
+	reserved float64 // CallCost of the calls in flight
+	inFlight int
+	waiting  int
+	dirty    bool
+}
+
+func New(cfg Config, store UsageStore) *Limiter {
+	return newLimiter(cfg, store, time.Now)
+}
+
+func newLimiter(cfg Config, store UsageStore, now func() time.Time) *Limiter {
+	l := &Limiter{
+		store:     store,
+		now:       now,
+		providers: make(map[Provider]*provider, len(cfg)),
+	}
+	start := now()
+	l.day = day(start)
+	l.lastFlush = start
+	for name, c := range cfg {
+		p := &provider{cfg: c, tokens: float64(c.Burst), refilled: start, usage: newUsage(name, l.day)}
+		if c.Concurrency > 0 {
+			p.slots = make(chan struct{}, c.Concurrency)
+		}
+		l.providers[name] = p
+	}
+	return l
+}
+
+// InitLimiter loads the limits from ConfigPath and today's usage from the store, so a restart doesn't
+// reset the budgets.
+func InitLimiter(store UsageStore) (*Limiter, error) {
+	cfg, err := LoadConfig(ConfigPath())
+	if err != nil {
+		return nil, fmt.Errorf("loading limits: %w", err)
+	}
+	l := New(cfg, store)
+
+	stored, err := store.GetProviderUsage(l.day)
+	if err != nil {
+		return nil, fmt.Errorf("loading today's usage: %w", err)
+	}
+	for _, u := range stored {
+		if p, ok := l.providers[Provider(u.Provider)]; ok {
+			p.usage = u
+		}
+	}
+	return l, nil
+}
+
+// Call is a call Acquire let through, Done or DoneTokens has to be called when it returns.
+type Call struct {
+	l        *Limiter
+	p        *provider
+	reserved float64
+}
+
+// Acquire waits for a concurrency slot and a token of the provider, at most until ctx is done. It
+// fails right away with ErrBudgetExhausted when today's spend and the calls in flight leave no room
+// for another call. Providers without limits are let through.
+func (l *Limiter) Acquire(ctx context.Context, name Provider) (*Call, error) {
+	if l == nil {
+		return &Call{}, nil
+	}
+	l.mu.Lock()
+	p, ok := l.providers[name]
+	if !ok {
+		l.mu.Unlock()
+		return &Call{}, nil
+	}
+	l.rollover()
+	if p.cfg.DailyBudget > 0 && p.usage.Cost+p.reserved+p.cfg.CallCost > p.cfg.DailyBudget+budgetSlack {
+		p.usage.Skipped++
+		p.dirty = true
+		err := fmt.Errorf("%w: %s spent $%.4f of $%.2f on %s", ErrBudgetExhausted, name, p.usage.Cost, p.cfg.DailyBudget, l.day)
+		first := p.usage.Skipped == 1
+		l.mu.Unlock()
+		if first {
+			log.Printf("Budget of %s exhausted, skipping its calls until midnight UTC", name)
+			l.background(l.Flush)
+		}
+		return nil, err
+	}
+	p.reserved += p.cfg.CallCost
+	p.waiting++
+	l.mu.Unlock()
+
+	call := &Call{l: l, p: p, reserved: p.cfg.CallCost}
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			l.abandon(call, false)
+			return nil, ctx.Err()
+		}
+	}
+	if wait := l.take(p); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.abandon(call, true)
+			return nil, ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	p.waiting--
+	p.inFlight++
+	l.mu.Unlock()
+	return call, nil
+}
+
+// take takes a token from the bucket, refilled at RatePerSecond up to Burst, and returns how long to
+// wait for it when the bucket is empty.
+func (l *Limiter) take(p *provider) time.Duration {
+	if p.cfg.RatePerSecond <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	p.tokens += now.Sub(p.refilled).Seconds() * p.cfg.RatePerSecond
+	if p.tokens > float64(p.cfg.Burst) {
+		p.tokens = float64(p.cfg.Burst)
+	}
+	p.refilled = now
+	p.tokens--
+	if p.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-p.tokens / p.cfg.RatePerSecond * float64(time.Second))
+}
+
+// abandon undoes Acquire for a call given up while waiting, holding is whether it already had its
+// slot and token.
+func (l *Limiter) abandon(c *Call, holding bool) {
+	l.mu.Lock()
+	c.p.reserved -= c.reserved
+	c.p.waiting--
+	if holding && c.p.cfg.RatePerSecond > 0 {
+		c.p.tokens++
+	}
+	l.mu.Unlock()
+	if holding && c.p.slots != nil {
+		<-c.p.slots
+	}
+}
+
+// Done charges a call CallCost, nothing when it failed.
+func (c *Call) Done(err error) {
+	cost := 0.0
+	if c.p != nil && err == nil {
+		cost = c.p.cfg.CallCost
+	}
+	c.finish(cost, 0, 0, err)
+}
+
+// DoneTokens charges a successful call by the tokens it used.
+func (c *Call) DoneTokens(inputTokens, outputTokens int) {
+	cost := 0.0
+	if c.p != nil {
+		cost = (float64(inputTokens)*c.p.cfg.InputTokenCost + float64(outputTokens)*c.p.cfg.OutputTokenCost) / 1e6
+	}
+	c.finish(cost, inputTokens, outputTokens, nil)
+}
+
+func (c *Call) finish(cost float64, inputTokens, outputTokens int, err error) {
+	if c.p == nil {
+		return
+	}
+	l, p := c.l, c.p
+	c.p = nil // Done twice is a no-op
+
+	l.mu.Lock()
+	l.rollover()
+	p.reserved -= c.reserved
+	p.inFlight--
+	p.usage.Calls++
+	if err != nil {
+		p.usage.Errors++
+	}
+	p.usage.Cost += cost
+	p.usage.InputTokens += inputTokens
+	p.usage.OutputTokens += outputTokens
+	p.dirty = true
+	flush := l.now().Sub(l.lastFlush) >= flushEvery
+	if flush {
+		l.lastFlush = l.now()
+	}
+	l.mu.Unlock()
+
+	if p.slots != nil {
+		<-p.slots
+	}
+	if flush {
+		l.background(l.Flush)
+	}
+}
+
+// rollover starts a new day's usage after midnight UTC, the last day's is flushed first. Needs l.mu.
+func (l *Limiter) rollover() {
+	today := day(l.now())
+	if today == l.day {
+		return
+	}
+	var finished []types.ProviderUsage
+	for name, p := range l.providers {
+		if p.dirty {
+			finished = append(finished, p.usage)
+		}
+		p.usage = newUsage(name, today)
+		p.dirty = false
+	}
+	l.day = today
+	l.background(func() { l.save(finished) })
+}
+
+// Flush writes the usage that changed since the last flush to the store.
+func (l *Limiter) Flush() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	var changed []types.ProviderUsage
+	for _, p := range l.providers {
+		if p.dirty {
+			changed = append(changed, p.usage)
+			p.dirty = false
+		}
+	}
+	l.lastFlush = l.now()
+	l.mu.Unlock()
+	l.save(changed)
+}
+
+// Close flushes the usage and waits for the background writes, so the spend of the last calls isn't
+// lost on shutdown. Call it once the callers stopped and before the store is closed.
+func (l *Limiter) Close() {
+	if l == nil {
+		return
+	}
+	l.Flush()
+	l.saving.Wait()
+}
+
+func (l *Limiter) background(write func()) {
+	l.saving.Add(1)
+	go func() {
+		defer l.saving.Done()
+		write()
+	}()
+}
+
+func (l *Limiter) save(usage []types.ProviderUsage) {
+	for _, u := range usage {
+		u.UpdatedAt = l.now().UTC().Format(time.RFC3339)
+		if err := l.store.SaveProviderUsage(u); err != nil {
+			log.Printf("Error saving usage of %s on %s: %v", u.Provider, u.Day, err)
+		}
+	}
+}
+
+// Status is a provider's usage today with its limits and the calls in flight or waiting.
+type Status struct {
+	types.ProviderUsage
+	Limits    ProviderConfig `json:"limits"`
+	Remaining *float64       `json:"remaining,omitempty"` // of the budget, absent without one
+	Exhausted bool           `json:"exhausted"`
+	InFlight  int            `json:"inFlight"`
+	Waiting   int            `json:"waiting"`
+}
+
+// Usage returns every provider's status, by provider name.
+func (l *Limiter) Usage() []Status {
+	if l == nil {
+		return []Status{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollover()
+
+	statuses := make([]Status, 0, len(l.providers))
+	for _, p := range l.providers {
+		status := Status{ProviderUsage: p.usage, Limits: p.cfg, InFlight: p.inFlight, Waiting: p.waiting}
+		if p.cfg.DailyBudget > 0 {
+			remaining := p.cfg.DailyBudget - p.usage.Cost
+			if remaining < 0 {
+				remaining = 0
+			}
+			status.Remaining = &remaining
+			status.Exhausted = p.usage.Cost+p.reserved+p.cfg.CallCost > p.cfg.DailyBudget+budgetSlack
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// Day is the UTC day the usage is counted for.
+func (l *Limiter) Day() string {
+	if l == nil {
+		return day(time.Now())
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollover()
+	return l.day
+}
+
+// ResetAt is when the budgets start over, the next midnight UTC.
+func ResetAt(now time.Time) time.Time {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func newUsage(name Provider, day string) types.ProviderUsage {
+	return types.ProviderUsage{ID: string(name) + "_" + day, Provider: string(name), Day: day}
+}
+
+func day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
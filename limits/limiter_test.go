@@ -0,0 +1,218 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"go-firebird/db"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// clock is a settable now for the limiter, read from the background saves too.
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+func testLimiter(cfg ProviderConfig) (*Limiter, *clock, *db.MemoryStore) {
+	c := &clock{t: time.Date(2025, 1, 7, 23, 0, 0, 0, time.UTC)}
+	store := db.NewMemoryStore()
+	return newLimiter(Config{GoogleNLP: cfg}, store, c.now), c, store
+}
+
+func status(t *testing.T, l *Limiter) Status {
+	t.Helper()
+	statuses := l.Usage()
+	if len(statuses) != 1 {
+		t.Fatalf("%d statuses, want 1", len(statuses))
+	}
+	return statuses[0]
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, c, _ := testLimiter(ProviderConfig{RatePerSecond: 2, Burst: 3})
+	p := l.providers[GoogleNLP]
+
+	steps := []struct {
+		advance time.Duration
+		want    time.Duration
+	}{
+		{0, 0}, {0, 0}, {0, 0}, // the burst
+		{0, 500 * time.Millisecond},
+		{0, time.Second}, // queued behind the previous call
+		{time.Second, 500 * time.Millisecond},
+		{time.Hour, 0}, // refilled up to Burst, not 7200 tokens
+		{0, 0},
+		{0, 0},
+		{0, 500 * time.Millisecond},
+	}
+	for i, s := range steps {
+		c.advance(s.advance)
+		if got := l.take(p); math.Abs(float64(got-s.want)) > float64(time.Millisecond) {
+			t.Fatalf("take %d after %s = %s, want %s", i, s.advance, got, s.want)
+		}
+	}
+}
+
+func TestAcquireWaitsForToken(t *testing.T) {
+	// A real clock, the wait is short
+	l := New(Config{GoogleNLP: {RatePerSecond: 20, Burst: 1}}, db.NewMemoryStore())
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		call, err := l.Acquire(context.Background(), GoogleNLP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		call.Done(nil)
+		if waited := time.Since(start); i == 1 && waited < 40*time.Millisecond {
+			t.Errorf("second call waited %s, want about 50ms", waited)
+		}
+	}
+
+	// Giving up on the token returns it
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, GoogleNLP); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire = %v, want the deadline", err)
+	}
+	if s := status(t, l); s.Waiting != 0 || s.InFlight != 0 || s.Calls != 2 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestConcurrencyCap(t *testing.T) {
+	l, _, _ := testLimiter(ProviderConfig{Concurrency: 2})
+	first, err := l.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, GoogleNLP); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third call: %v, want the deadline", err)
+	}
+	if s := status(t, l); s.InFlight != 2 || s.Waiting != 0 {
+		t.Fatalf("status = %+v, want 2 in flight and none waiting", s)
+	}
+
+	acquired := make(chan *Call)
+	go func() {
+		call, err := l.Acquire(context.Background(), GoogleNLP)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- call
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("third call got a slot while two were in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	first.Done(nil)
+	select {
+	case third := <-acquired:
+		third.Done(nil)
+	case <-time.After(time.Second):
+		t.Fatal("third call didn't get the slot the first one freed")
+	}
+	second.Done(errors.New("upstream error"))
+
+	if s := status(t, l); s.InFlight != 0 || s.Calls != 3 || s.Errors != 1 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestBudgetRollover(t *testing.T) {
+	l, c, store := testLimiter(ProviderConfig{DailyBudget: 0.003, CallCost: 0.001})
+	today := l.Day()
+
+	var calls []*Call
+	for i := 0; i < 3; i++ {
+		call, err := l.Acquire(context.Background(), GoogleNLP)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		calls = append(calls, call)
+	}
+	// The calls in flight reserve the budget
+	if _, err := l.Acquire(context.Background(), GoogleNLP); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("fourth call: %v, want ErrBudgetExhausted", err)
+	}
+	// A failed call isn't charged, so its share can be spent again
+	calls[0].Done(errors.New("upstream error"))
+	call, err := l.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		t.Fatalf("call after a failure: %v", err)
+	}
+	calls = append(calls[1:], call)
+	for _, call := range calls {
+		call.Done(nil)
+	}
+	if _, err := l.Acquire(context.Background(), GoogleNLP); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("call past the budget: %v, want ErrBudgetExhausted", err)
+	}
+	s := status(t, l)
+	if !s.Exhausted || *s.Remaining > budgetSlack || s.Calls != 4 || s.Errors != 1 || s.Skipped != 2 {
+		t.Fatalf("status = %+v", s)
+	}
+
+	// After midnight UTC the budget starts over and the finished day is saved, after the flush the
+	// exhausted budget started so that it can't overwrite the day's totals
+	l.saving.Wait()
+	c.advance(2 * time.Hour)
+	call, err = l.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		t.Fatalf("call on the next day: %v", err)
+	}
+	call.Done(nil)
+	if tomorrow := l.Day(); tomorrow == today {
+		t.Fatalf("day is still %s", today)
+	}
+	if s := status(t, l); s.Exhausted || s.Calls != 1 || s.Skipped != 0 || math.Abs(s.Cost-0.001) > budgetSlack {
+		t.Errorf("next day's status = %+v", s)
+	}
+
+	l.Close()
+	for d, want := range map[string]int{today: 4, l.Day(): 1} {
+		usage, err := store.GetProviderUsage(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(usage) != 1 || usage[0].Calls != want || usage[0].UpdatedAt == "" {
+			t.Errorf("usage saved for %s = %+v, want %d calls", d, usage, want)
+		}
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	call, err := l.Acquire(context.Background(), GoogleNLP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call.Done(nil)
+	l.Flush()
+	l.Close()
+	if len(l.Usage()) != 0 {
+		t.Error("a nil limiter has usage")
+	}
+}
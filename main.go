@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/joho/godotenv"
+	"go-firebird/auth"
+	"go-firebird/backfill"
 	"go-firebird/cronjobs"
-	"go-firebird/db"
-	"go-firebird/geocode"
-	"go-firebird/nlp"
+	"go-firebird/feeds"
+	"go-firebird/ingest"
+	"go-firebird/processor"
 	"go-firebird/routes"
+	"go-firebird/webhooks"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
+// shutdownTimeout is how long open requests and event streams get to finish on SIGINT or SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Load .env file
 	err := godotenv.Load()
@@ -28,34 +44,93 @@ func main() {
 	clientURL := os.Getenv("CLIENT_URL")
 	fmt.Println("CLIENT_URL: ", clientURL)
 
-	// Init geocode
-	geocodeClient, err := geocode.InitMapsClient()
+	// Init store, geocoder, nlp and classifier
+	pipeline, err := processor.InitPipeline()
 	if err != nil {
-		log.Fatalf("Failed to initialize nlp: %v", err)
+		log.Fatalf("Failed to initialize pipeline: %v", err)
 	}
+	defer processor.ClosePipeline()
+
+	// The server, the event streams, the backfills, the webhook dispatcher and the ingest consumer stop on
+	// SIGINT or SIGTERM. background is waited for before the store is closed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
 
-	// Init firestore
-	firestoreClient, err := db.InitFirestore()
+	// Feeds polled by the cron jobs, from FEEDS_CONFIG and the store
+	registry, err := feeds.InitRegistry(pipeline.Store)
 	if err != nil {
-		log.Fatalf("Failed to initialize Firestore: %v", err)
+		log.Fatalf("Failed to initialize feed registry: %v", err)
 	}
-	defer db.CloseFirestore() // Firestore client is closed on exit
 
-	// Init language client
-	languageClient, err := nlp.InitLanguageClient()
+	// Webhook subscribers get the matching events of the pipeline's event bus
+	dispatcher, err := webhooks.InitDispatcher(pipeline.Store, webhooks.ConfigFromEnv())
 	if err != nil {
-		log.Fatalf("Failed to initialize nlp: %v", err)
+		log.Fatalf("Failed to initialize webhooks: %v", err)
+	}
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		dispatcher.Run(ctx, pipeline.Events)
+	}()
+
+	// INGEST=jetstream streams posts continuously instead of polling the feeds every 4 hours
+	var consumer *ingest.Consumer
+	streaming := os.Getenv("INGEST") == "jetstream"
+	if streaming {
+		consumer = ingest.NewConsumer(pipeline, ingest.ConfigFromEnv())
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			if err := consumer.Run(ctx); err != nil {
+				log.Printf("Ingest stopped: %v", err)
+			}
+		}()
 	}
-	defer nlp.CloseLanguageClient()
 
 	// Init cron jobs if in production
+	var scheduler *cron.Cron
 	productionCheck := os.Getenv("PRODUCTION")
 	if productionCheck == "t" {
-		cronjobs.InitCronJobs(firestoreClient, languageClient)
+		scheduler = cronjobs.InitCronJobs(pipeline, registry, !streaming)
 	}
 
-	r := routes.SetupRouter(firestoreClient, languageClient, geocodeClient)
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// API keys from the store, AUTH_ADMIN_KEY and JWTs signed with AUTH_JWT_SECRET
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Bad auth configuration: %v", err)
+	}
+	authn := auth.New(pipeline.Store, authConfig)
+
+	r := routes.SetupRouter(ctx, pipeline, registry, consumer, dispatcher, authn)
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: r,
+		// Requests are cancelled on shutdown, so the event streams end instead of holding Shutdown up
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	if scheduler != nil {
+		<-scheduler.Stop().Done()
 	}
+	// The consumer drains its queue and the dispatcher saves its deliveries, both still call the paid
+	// APIs and the store
+	background.Wait()
+	// Backfills started over the API stop after their current page and save their progress
+	backfill.Wait()
+	// The spend of the last calls, before ClosePipeline closes the store
+	pipeline.Limits.Close()
 }
@@ -0,0 +1,253 @@
+package mlmodel
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go-firebird/types"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// keywords gives the local classifier something to go on before (or without) training,
+// and is the only signal for NonDisaster since the training data only has disaster posts.
+var keywords = map[types.Category][]string{
+	types.Wildfire:         {"fire", "fires", "wildfire", "wildfires", "blaze", "smoke", "flames", "burning", "burned", "acres", "firefighters", "evacuation", "evacuate", "containment", "embers"},
+	types.Hurricane:        {"hurricane", "hurricanes", "storm", "landfall", "surge", "cyclone", "typhoon", "winds", "flooding", "category", "eyewall", "tropical"},
+	types.Earthquake:       {"earthquake", "earthquakes", "quake", "magnitude", "tremor", "tremors", "aftershock", "aftershocks", "seismic", "epicenter", "richter", "shaking"},
+	types.Flood:            {"flood", "floods", "flooded", "flooding", "floodwaters", "flashflood", "inundated", "overflowing", "levee", "submerged"},
+	types.Tornado:          {"tornado", "tornadoes", "twister", "funnel", "touchdown", "supercell", "ef1", "ef2", "ef3", "ef4", "ef5"},
+	types.Tsunami:          {"tsunami", "tsunamis", "tidal", "inundation"},
+	types.VolcanicEruption: {"volcano", "volcanoes", "volcanic", "eruption", "erupting", "erupted", "lava", "magma", "ashfall", "pyroclastic"},
+	types.Landslide:        {"landslide", "landslides", "mudslide", "mudslides", "rockslide", "landslip"},
+	types.Blizzard:         {"blizzard", "blizzards", "snowstorm", "snow", "whiteout", "snowfall", "drifts", "frostbite", "icy"},
+	types.Drought:          {"drought", "droughts", "parched", "rainfall", "reservoir", "reservoirs", "crops", "famine", "irrigation"},
+}
+
+const (
+	// weight of the keyword share when blending with the naive Bayes posterior
+	keywordWeight = 0.5
+	// probability given to NonDisaster when a post has no disaster keyword at all
+	noKeywordNonDisasterProb = 0.85
+)
+
+// LocalClassifier is a multinomial naive Bayes model combined with keyword matching.
+// It runs fully in process, so it is used when the hosted model is down or unavailable.
+type LocalClassifier struct {
+	mu          sync.RWMutex
+	wordCounts  map[types.Category]map[string]int
+	totalWords  map[types.Category]int
+	docCounts   map[types.Category]int
+	totalDocs   int
+	vocabulary  map[string]bool
+	keywordSets map[types.Category]map[string]bool
+}
+
+// TrainingSample is one labeled post.
+type TrainingSample struct {
+	Text     string
+	Category types.Category
+}
+
+func NewLocalClassifier() *LocalClassifier {
+	l := &LocalClassifier{
+		wordCounts:  make(map[types.Category]map[string]int),
+		totalWords:  make(map[types.Category]int),
+		docCounts:   make(map[types.Category]int),
+		vocabulary:  make(map[string]bool),
+		keywordSets: make(map[types.Category]map[string]bool),
+	}
+	for category, words := range keywords {
+		set := make(map[string]bool, len(words))
+		for _, w := range words {
+			set[w] = true
+		}
+		l.keywordSets[category] = set
+	}
+	return l
+}
+
+func (l *LocalClassifier) Name() string { return "local" }
+
+// tokenize lowercases text and splits it on anything that isn't a letter or digit, so #Earthquake -> earthquake.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Train adds samples to the model. It can be called more than once.
+func (l *LocalClassifier) Train(samples []TrainingSample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, sample := range samples {
+		if sample.Category == "" {
+			continue
+		}
+		counts, ok := l.wordCounts[sample.Category]
+		if !ok {
+			counts = make(map[string]int)
+			l.wordCounts[sample.Category] = counts
+		}
+		for _, token := range tokenize(sample.Text) {
+			counts[token]++
+			l.totalWords[sample.Category]++
+			l.vocabulary[token] = true
+		}
+		l.docCounts[sample.Category]++
+		l.totalDocs++
+	}
+}
+
+// parseLabel maps the prediction column of the training csv to a category.
+func parseLabel(label string) types.Category {
+	category, _ := types.ParseCategory(label)
+	return category
+}
+
+// TrainFromCSV trains on a file in the demo_data.csv format: text,createdAt,prediction with a header row.
+func (l *LocalClassifier) TrainFromCSV(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true // scraped posts are full of stray quotes
+
+	// skip the header
+	if _, err := reader.Read(); err != nil {
+		return fmt.Errorf("reading header of %s: %w", path, err)
+	}
+
+	var samples []TrainingSample
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue // skip malformed rows like AddDisasterDemoData does
+		}
+		if len(rec) != 3 {
+			continue
+		}
+		samples = append(samples, TrainingSample{Text: rec[0], Category: parseLabel(rec[2])})
+	}
+
+	if len(samples) == 0 {
+		return fmt.Errorf("no training samples in %s", path)
+	}
+	l.Train(samples)
+	return nil
+}
+
+func (l *LocalClassifier) Classify(inputs MLRequest) (map[string]types.CategoryDistribution, error) {
+	out := make(map[string]types.CategoryDistribution, len(inputs))
+	for id, text := range inputs {
+		out[id] = l.classifyText(text)
+	}
+	return out, nil
+}
+
+func (l *LocalClassifier) classifyText(text string) types.CategoryDistribution {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	tokens := tokenize(text)
+
+	// Keyword share per disaster category
+	keywordHits := make(map[types.Category]float64)
+	totalHits := 0.0
+	for _, token := range tokens {
+		for category, set := range l.keywordSets {
+			if set[token] {
+				keywordHits[category]++
+				totalHits++
+			}
+		}
+	}
+
+	posterior := l.posterior(tokens)
+
+	dist := make(types.CategoryDistribution)
+	switch {
+	case totalHits == 0 && posterior[types.NonDisaster] == 0:
+		// Nothing disaster related and no trained NonDisaster class to say otherwise.
+		dist[types.NonDisaster] = noKeywordNonDisasterProb
+		for category, p := range posterior {
+			dist[category] += p * (1 - noKeywordNonDisasterProb)
+		}
+		if len(posterior) == 0 {
+			dist[types.NonDisaster] = 1
+		}
+	case len(posterior) == 0:
+		for category, hits := range keywordHits {
+			dist[category] = hits / totalHits
+		}
+	default:
+		for category, p := range posterior {
+			dist[category] += (1 - keywordWeight) * p
+		}
+		if totalHits > 0 {
+			for category, hits := range keywordHits {
+				dist[category] += keywordWeight * hits / totalHits
+			}
+		} else {
+			for category, p := range posterior {
+				dist[category] += keywordWeight * p
+			}
+		}
+	}
+
+	// Every category the stored vector knows about gets an entry
+	for _, category := range types.ClassificationOrder {
+		if _, ok := dist[category]; !ok {
+			dist[category] = 0
+		}
+	}
+	return dist
+}
+
+// posterior returns P(category | tokens) for every trained category, nil if the model is untrained.
+func (l *LocalClassifier) posterior(tokens []string) map[types.Category]float64 {
+	if l.totalDocs == 0 {
+		return nil
+	}
+
+	vocabSize := float64(len(l.vocabulary))
+	logProbs := make(map[types.Category]float64, len(l.docCounts))
+	maxLog := math.Inf(-1)
+	for category, docs := range l.docCounts {
+		logP := math.Log(float64(docs) / float64(l.totalDocs))
+		denominator := float64(l.totalWords[category]) + vocabSize
+		for _, token := range tokens {
+			if !l.vocabulary[token] {
+				continue // unseen words carry no information
+			}
+			logP += math.Log((float64(l.wordCounts[category][token]) + 1) / denominator)
+		}
+		logProbs[category] = logP
+		if logP > maxLog {
+			maxLog = logP
+		}
+	}
+
+	// softmax in log space to avoid underflow
+	sum := 0.0
+	probs := make(map[types.Category]float64, len(logProbs))
+	for category, logP := range logProbs {
+		probs[category] = math.Exp(logP - maxLog)
+		sum += probs[category]
+	}
+	for category := range probs {
+		probs[category] /= sum
+	}
+	return probs
+}
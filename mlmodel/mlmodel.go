@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"go-firebird/types"
+	"log"
 	"net/http"
+	"os"
+	"time"
 )
 
 type MLRequest map[string]string
@@ -12,19 +17,29 @@ type MLResponse map[string][]float64
 
 const mlURL = "https://firebirdmodel-165032778338.us-central1.run.app/tweets/"
 
+// Classifier labels a batch of texts, keyed by the caller's ID (the skeet URI), with a distribution over categories.
+type Classifier interface {
+	Name() string
+	Classify(inputs MLRequest) (map[string]types.CategoryDistribution, error)
+}
+
 func CallModel(inputs MLRequest) (MLResponse, error) {
+	return callModelURL(http.DefaultClient, mlURL, inputs)
+}
+
+func callModelURL(client *http.Client, url string, inputs MLRequest) (MLResponse, error) {
 	payloadBytes, err := json.Marshal(inputs)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, mlURL, bytes.NewBuffer(payloadBytes))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -41,3 +56,113 @@ func CallModel(inputs MLRequest) (MLResponse, error) {
 
 	return mlResp, nil
 }
+
+// HTTPClassifier calls the hosted ML model. Its output vector is in types.ClassificationOrder.
+type HTTPClassifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPClassifier(url string) *HTTPClassifier {
+	if url == "" {
+		url = mlURL
+	}
+	return &HTTPClassifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (h *HTTPClassifier) Name() string { return "remote" }
+
+func (h *HTTPClassifier) Classify(inputs MLRequest) (map[string]types.CategoryDistribution, error) {
+	mlResp, err := callModelURL(h.Client, h.URL, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]types.CategoryDistribution, len(mlResp))
+	for id, vector := range mlResp {
+		out[id] = types.DistributionFromVector(vector)
+	}
+	for id := range inputs {
+		if _, ok := out[id]; !ok {
+			return nil, fmt.Errorf("ML model response is missing %s", id)
+		}
+	}
+	return out, nil
+}
+
+// FallbackClassifier uses Primary and switches to Fallback when Primary errors.
+type FallbackClassifier struct {
+	Primary  Classifier
+	Fallback Classifier
+}
+
+func (f *FallbackClassifier) Name() string {
+	return f.Primary.Name() + "+" + f.Fallback.Name()
+}
+
+func (f *FallbackClassifier) Classify(inputs MLRequest) (map[string]types.CategoryDistribution, error) {
+	out, _, err := f.classify(inputs)
+	return out, err
+}
+
+// classify also reports which backend produced the result.
+func (f *FallbackClassifier) classify(inputs MLRequest) (map[string]types.CategoryDistribution, string, error) {
+	out, err := f.Primary.Classify(inputs)
+	if err == nil {
+		return out, f.Primary.Name(), nil
+	}
+	log.Printf("Classifier %s failed, falling back to %s: %v", f.Primary.Name(), f.Fallback.Name(), err)
+	out, err = f.Fallback.Classify(inputs)
+	return out, f.Fallback.Name(), err
+}
+
+// ClassifyOne is a helper for the common single text case.
+// It also returns the name of the backend that produced the result.
+func ClassifyOne(c Classifier, id, text string) (types.CategoryDistribution, string, error) {
+	inputs := MLRequest{id: text}
+
+	var out map[string]types.CategoryDistribution
+	var err error
+	source := c.Name()
+	if f, ok := c.(*FallbackClassifier); ok {
+		out, source, err = f.classify(inputs)
+	} else {
+		out, err = c.Classify(inputs)
+	}
+	if err != nil {
+		return nil, source, err
+	}
+	return out[id], source, nil
+}
+
+// InitClassifier builds the classifier chosen by the CLASSIFIER env variable:
+//   - "remote" (default): hosted ML model, falling back to the local model when it is unreachable
+//   - "local": only the local keyword/naive Bayes model
+//
+// The local model is trained from CLASSIFIER_TRAINING_DATA (default ./demo_data.csv).
+func InitClassifier() (Classifier, error) {
+	trainingPath := os.Getenv("CLASSIFIER_TRAINING_DATA")
+	if trainingPath == "" {
+		trainingPath = "./demo_data.csv"
+	}
+
+	local := NewLocalClassifier()
+	if err := local.TrainFromCSV(trainingPath); err != nil {
+		log.Printf("Local classifier could not be trained from %s, using keywords only: %v", trainingPath, err)
+	}
+
+	switch os.Getenv("CLASSIFIER") {
+	case "local":
+		return local, nil
+	case "", "remote":
+		return &FallbackClassifier{
+			Primary:  NewHTTPClassifier(os.Getenv("ML_MODEL_URL")),
+			Fallback: local,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown CLASSIFIER %q, expected remote or local", os.Getenv("CLASSIFIER"))
+	}
+}
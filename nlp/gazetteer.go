@@ -0,0 +1,81 @@
+package nlp
+
+import (
+	"go-firebird/gazetteer"
+	"go-firebird/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GazetteerExtractor finds LOCATION entities by matching place names from a gazetteer.
+// Only capitalized spans or hashtags are considered, so "turn in the fire" doesn't match a place called "Fire".
+type GazetteerExtractor struct {
+	Gazetteer *gazetteer.Gazetteer
+}
+
+func NewGazetteerExtractor(g *gazetteer.Gazetteer) *GazetteerExtractor {
+	return &GazetteerExtractor{Gazetteer: g}
+}
+
+func (g *GazetteerExtractor) AnalyzeEntities(text string) ([]types.Entity, error) {
+	tokens := tokenize(text)
+
+	var entities []types.Entity
+	byName := make(map[string]int) // canonical name -> index in entities
+
+	for i := 0; i < len(tokens); {
+		if !startsCapitalized(tokens[i]) {
+			i++
+			continue
+		}
+
+		// Longest match first so "New York City" wins over "New York"
+		matched := 0
+		var place gazetteer.Place
+		for n := min(g.Gazetteer.MaxWords(), len(tokens)-i); n > 0; n-- {
+			words := make([]string, n)
+			for k := 0; k < n; k++ {
+				words[k] = tokens[i+k].text
+			}
+			if p, ok := g.Gazetteer.Lookup(strings.Join(words, " ")); ok {
+				matched = n
+				place = p
+				break
+			}
+		}
+		if matched == 0 {
+			i++
+			continue
+		}
+
+		last := tokens[i+matched-1]
+		mention := types.EntityMention{
+			Content:     text[tokens[i].offset : last.offset+len(last.text)],
+			BeginOffset: int32(tokens[i].offset),
+			Probability: 1,
+		}
+		if idx, ok := byName[place.Name]; ok {
+			entities[idx].Mentions = append(entities[idx].Mentions, mention)
+		} else {
+			byName[place.Name] = len(entities)
+			entities = append(entities, types.Entity{
+				Name:     place.Name,
+				Type:     "LOCATION",
+				Metadata: map[string]string{},
+				Mentions: []types.EntityMention{mention},
+			})
+		}
+		i += matched
+	}
+
+	return entities, nil
+}
+
+func startsCapitalized(t token) bool {
+	if t.hashed {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(t.text)
+	return unicode.IsUpper(r)
+}
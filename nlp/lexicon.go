@@ -0,0 +1,131 @@
+package nlp
+
+import (
+	"bufio"
+	"fmt"
+	"go-firebird/types"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLexiconPath = "./data/sentiment_lexicon.tsv"
+	maxValence         = 5.0
+	// normalization constant from VADER, maps an unbounded sum of valences into (-1, 1)
+	normalizationAlpha = 15.0
+	negationWindow     = 3
+	negationScalar     = -0.74
+	boosterScalar      = 0.293
+	exclamationBoost   = 0.292
+	maxExclamations    = 4
+)
+
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "none": true, "nobody": true, "nothing": true,
+	"neither": true, "nor": true, "cannot": true, "cant": true, "dont": true, "doesnt": true,
+	"didnt": true, "isnt": true, "wasnt": true, "arent": true, "werent": true, "wont": true, "without": true,
+}
+
+var boosters = map[string]float64{
+	"very": 1, "extremely": 1, "so": 1, "really": 1, "incredibly": 1, "totally": 1, "absolutely": 1, "completely": 1,
+	"slightly": -1, "somewhat": -1, "barely": -1, "kinda": -1, "little": -1,
+}
+
+// LexiconSentiment scores text with a word list, producing the same score/magnitude shape as
+// Google Natural Language: score in [-1, 1], magnitude >= 0 growing with the amount of emotional words.
+type LexiconSentiment struct {
+	valences map[string]float64
+}
+
+// LexiconPath returns SENTIMENT_LEXICON_PATH or the bundled lexicon.
+func LexiconPath() string {
+	if p := os.Getenv("SENTIMENT_LEXICON_PATH"); p != "" {
+		return p
+	}
+	return defaultLexiconPath
+}
+
+// LoadLexiconSentiment reads a "word<TAB>valence" file, valence from -5 to 5. Lines starting with # are comments.
+func LoadLexiconSentiment(path string) (*LexiconSentiment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	valences := make(map[string]float64)
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Fields(line)
+		if len(cols) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected word and valence", path, lineNumber)
+		}
+		v, err := strconv.ParseFloat(cols[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad valence: %w", path, lineNumber, err)
+		}
+		valences[strings.ToLower(cols[0])] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &LexiconSentiment{valences: valences}, nil
+}
+
+func (l *LexiconSentiment) AnalyzeSentiment(text string) (types.Sentiment, error) {
+	var sentiment types.Sentiment
+	tokens := tokenize(text)
+
+	sum := 0.0
+	magnitude := 0.0
+	for i, tok := range tokens {
+		word := strings.ReplaceAll(tok.lower, "'", "")
+		v, ok := l.valences[word]
+		if !ok {
+			continue
+		}
+
+		// booster right before the word, e.g. "very bad"
+		if i > 0 {
+			if b, ok := boosters[strings.ReplaceAll(tokens[i-1].lower, "'", "")]; ok {
+				if v > 0 {
+					v += b * boosterScalar * maxValence / 4
+				} else {
+					v -= b * boosterScalar * maxValence / 4
+				}
+			}
+		}
+
+		// negation in the few words before, e.g. "not safe"
+		for j := i - 1; j >= 0 && j >= i-negationWindow; j-- {
+			if negations[strings.ReplaceAll(tokens[j].lower, "'", "")] {
+				v *= negationScalar
+				break
+			}
+		}
+
+		sum += v
+		magnitude += math.Abs(v)
+	}
+
+	if sum != 0 {
+		exclamations := math.Min(float64(strings.Count(text, "!")), maxExclamations)
+		if sum > 0 {
+			sum += exclamations * exclamationBoost
+		} else {
+			sum -= exclamations * exclamationBoost
+		}
+	}
+
+	sentiment.Score = float32(sum / math.Sqrt(sum*sum+normalizationAlpha))
+	sentiment.Magnitude = float32(magnitude / maxValence)
+	return sentiment, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"go-firebird/gazetteer"
 	"go-firebird/types"
 	"log"
 	"os"
@@ -14,6 +15,56 @@ import (
 	"google.golang.org/api/option"
 )
 
+// SentimentAnalyzer scores the overall sentiment of a text.
+type SentimentAnalyzer interface {
+	AnalyzeSentiment(text string) (types.Sentiment, error)
+}
+
+// EntityExtractor finds named entities (only LOCATION and ADDRESS are used downstream) in a text.
+type EntityExtractor interface {
+	AnalyzeEntities(text string) ([]types.Entity, error)
+}
+
+// GoogleNLP implements both interfaces with the Cloud Natural Language API.
+type GoogleNLP struct {
+	Client *language.Client
+}
+
+func (g *GoogleNLP) AnalyzeSentiment(text string) (types.Sentiment, error) {
+	return AnalyzeSentiment(g.Client, text)
+}
+
+func (g *GoogleNLP) AnalyzeEntities(text string) ([]types.Entity, error) {
+	return AnalyzeEntities(g.Client, text)
+}
+
+// InitAnalyzers builds the analyzers chosen by the NLP env variable:
+//   - "google" (default): Cloud Natural Language API, needs NATURAL_LANGUAGE_CREDENTIALS
+//   - "local": lexicon based sentiment (SENTIMENT_LEXICON_PATH) and gazetteer based entities (GAZETTEER_PATH)
+func InitAnalyzers() (SentimentAnalyzer, EntityExtractor, error) {
+	switch os.Getenv("NLP") {
+	case "", "google":
+		client, err := InitLanguageClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		google := &GoogleNLP{Client: client}
+		return google, google, nil
+	case "local":
+		lexicon, err := LoadLexiconSentiment(LexiconPath())
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading sentiment lexicon: %w", err)
+		}
+		g, err := gazetteer.Load(gazetteer.Path())
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading gazetteer: %w", err)
+		}
+		return lexicon, NewGazetteerExtractor(g), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown NLP %q, expected google or local", os.Getenv("NLP"))
+	}
+}
+
 // languageClient a singleton languageClient instance.
 var (
 	languageClient *language.Client
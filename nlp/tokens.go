@@ -0,0 +1,59 @@
+package nlp
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a word in the original text with its byte offset, which is what
+// Google Natural Language reports as BeginOffset for UTF8 requests.
+type token struct {
+	text   string
+	lower  string
+	offset int
+	hashed bool // preceded by '#'
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, newToken(text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, newToken(text, start, len(text)))
+	}
+	return tokens
+}
+
+func newToken(text string, start, end int) token {
+	word := strings.Trim(text[start:end], "'")
+	if word != text[start:end] && strings.HasPrefix(text[start:end], "'") {
+		start += len(text[start:end]) - len(strings.TrimLeft(text[start:end], "'"))
+	}
+	hashed := false
+	if start > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		hashed = prev == '#'
+	}
+	return token{
+		text:   word,
+		lower:  strings.ToLower(word),
+		offset: start,
+		hashed: hashed,
+	}
+}
@@ -0,0 +1,222 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/detection"
+	"go-firebird/events"
+	"go-firebird/limits"
+	"go-firebird/summarization"
+	"go-firebird/types"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sashabaranov/go-openai"
+)
+
+const summarizeTimeout = 2 * time.Minute
+
+// ErrDetectionRunning is returned when a detection run is started while another one is still going.
+var ErrDetectionRunning = errors.New("disaster detection is already running")
+
+var detectionMu sync.Mutex
+
+// RunDisasterDetection fetches candidate locations, detects disaster clusters, matches them against the
+// stored disasters, generates summaries for the ones whose cluster changed in this run and saves everything.
+// Only one run happens at a time, a second one returns ErrDetectionRunning right away.
+// Every run that starts is recorded in the store, the record is returned with the saved disasters.
+// cfg sets the thresholds and the regions checked, see detection.Profiles. The saved disasters are
+// published on bus, which may be nil. The summaries' OpenAI calls go through limiter.
+func RunDisasterDetection(store db.Store, bus *events.Bus, limiter *limits.Limiter, trigger string, cfg detection.DetectionConfig) (types.DetectionRun, []types.DisasterData, error) {
+	if !detectionMu.TryLock() {
+		return types.DetectionRun{}, nil, ErrDetectionRunning
+	}
+	defer detectionMu.Unlock()
+
+	now := time.Now().UTC()
+	run := types.DetectionRun{
+		ID:                 uuid.NewString(),
+		Trigger:            trigger,
+		StartedAt:          now.Format(time.RFC3339),
+		Profile:            cfg.Profile,
+		Regions:            cfg.Regions,
+		SentimentThreshold: cfg.CandidateSentiment,
+		DisastersCreated:   []string{},
+		DisastersUpdated:   []string{},
+		Errors:             []string{},
+	}
+
+	disasters, err := detectAndSave(store, bus, limiter, &run, cfg, now)
+	if err != nil {
+		run.Errors = append(run.Errors, err.Error())
+	}
+	run.Succeeded = err == nil
+	run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if saveErr := store.SaveDetectionRun(run); saveErr != nil {
+		log.Printf("Error saving detection run %s: %v", run.ID, saveErr)
+	}
+	log.Printf("Detection run %s (%s, profile %s) finished: %d locations, %d clusters, %d created, %d updated, %d summaries, %d errors",
+		run.ID, trigger, cfg.Profile, run.LocationsChecked, run.ClustersFound, len(run.DisastersCreated), len(run.DisastersUpdated),
+		run.SummariesGenerated, len(run.Errors))
+	return run, disasters, err
+}
+
+func detectAndSave(store db.Store, bus *events.Bus, limiter *limits.Limiter, run *types.DetectionRun, cfg detection.DetectionConfig, now time.Time) ([]types.DisasterData, error) {
+	// 1. Fetch candidate locations
+	locations, err := store.GetLocationsForDisasterCheck(cfg.CandidateSentiment, cfg.Regions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve locations for analysis: %w", err)
+	}
+	run.LocationsChecked = len(locations)
+
+	// 2. Run the detection logic
+	clusters, err := detection.DetectDisasters(locations, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("disaster analysis failed: %w", err)
+	}
+	run.ClustersFound = len(clusters)
+
+	// 3. Match the clusters against the open disasters, disasters that were not found again wind down
+	existing, err := store.GetOpenDisasters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve existing disasters: %w", err)
+	}
+	// TrackDisasters updates the existing disasters in place, keep what they were to tell what changed
+	previous := make(map[string]types.DisasterData, len(existing))
+	for _, d := range existing {
+		d.LocationIDs = append([]string(nil), d.LocationIDs...)
+		previous[d.ID] = d
+	}
+
+	disasters := detection.TrackDisasters(existing, clusters, now)
+	if len(disasters) == 0 {
+		log.Println("No disasters detected or changed.")
+		return disasters, nil
+	}
+
+	// Only the disasters seen in this run whose cluster changed have new skeets to summarize
+	detectedAt := now.Format(time.RFC3339)
+	var toSummarize, unchanged []types.DisasterData
+	for _, d := range disasters {
+		prev, known := previous[d.ID]
+		if d.LastDetected == detectedAt && (!known || clusterChanged(prev, d)) {
+			toSummarize = append(toSummarize, d)
+		} else {
+			unchanged = append(unchanged, d)
+		}
+	}
+
+	// 4. Generate summaries
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		log.Println("Warning: OPENAI_API_KEY environment variable not set. Skipping summary generation.")
+	} else if len(toSummarize) > 0 {
+		openaiClient := openai.NewClient(apiKey)
+		ctx, cancel := context.WithTimeout(context.Background(), summarizeTimeout)
+		defer cancel()
+
+		generated, err := summarization.GenerateSummaries(ctx, toSummarize, store, openaiClient, limiter)
+		run.SummariesGenerated = generated
+		if err != nil {
+			// Some summaries might be missing, the disasters are saved anyway
+			log.Printf("Warning: Error occurred during summary generation: %v", err)
+			run.Errors = append(run.Errors, err.Error())
+		}
+	}
+	disasters = append(toSummarize, unchanged...)
+
+	// 5. Save the disasters (with or without summaries)
+	if err := store.SaveDisasters(disasters); err != nil {
+		return disasters, fmt.Errorf("failed to save disasters: %w", err)
+	}
+	for _, d := range disasters {
+		event := events.NewDisasterEvent(d)
+		prev, known := previous[d.ID]
+		if !known {
+			run.DisastersCreated = append(run.DisastersCreated, d.ID)
+			bus.Publish(events.DisasterCreated, d.DisasterType, event)
+			continue
+		}
+		run.DisastersUpdated = append(run.DisastersUpdated, d.ID)
+		if d.LastDetected != detectedAt && prev.Status == d.Status {
+			continue // only its count of missed runs changed, nothing to tell subscribers
+		}
+		bus.Publish(events.DisasterUpdated, d.DisasterType, event)
+		if prev.Status != d.Status {
+			event.PreviousStatus = prev.Status
+			bus.Publish(events.DisasterStatus, d.DisasterType, event)
+		}
+	}
+	return disasters, nil
+}
+
+// clusterChanged reports whether a disaster gained skeets or locations since it was stored, or still
+// has no summary. Unchanged clusters keep their summary, they would only spend OpenAI quota on the same skeets.
+func clusterChanged(before, after types.DisasterData) bool {
+	if after.Summary == "" || before.SkeetsSeen() != after.SkeetsSeen() || len(before.LocationIDs) != len(after.LocationIDs) {
+		return true
+	}
+	ids := make(map[string]bool, len(before.LocationIDs))
+	for _, id := range before.LocationIDs {
+		ids[id] = true
+	}
+	for _, id := range after.LocationIDs {
+		if !ids[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// RepresentativeSkeets picks up to n skeets posted at the disaster's locations between its ReportedDate
+// and LastUpdate: the ones most likely of the disaster's type, then the most liked and reposted. A skeet
+// mentioning several of the locations is only picked once.
+func RepresentativeSkeets(store db.Store, disaster types.DisasterData, n int) ([]types.RepresentativeSkeet, error) {
+	if disaster.ReportedDate == "" || disaster.LastUpdate == "" {
+		return nil, fmt.Errorf("disaster %s is missing ReportedDate or LastUpdate", disaster.ID)
+	}
+
+	var candidates []types.RepresentativeSkeet
+	seen := make(map[string]bool)
+	for _, locID := range disaster.LocationIDs {
+		skeets, err := store.GetSkeetsSubCollection(locID, disaster.ReportedDate, disaster.LastUpdate)
+		if err != nil {
+			log.Printf("Warning: Failed to get skeets for location %s in disaster %s: %v", locID, disaster.ID, err)
+			continue
+		}
+		for _, s := range skeets {
+			if s.SkeetData.Content == "" || seen[s.SkeetData.UID] {
+				continue
+			}
+			seen[s.SkeetData.UID] = true
+			candidates = append(candidates, types.RepresentativeSkeet{
+				LocationID:   locID,
+				LocationName: s.LocationName,
+				Relevance:    types.DistributionFromVector(s.SkeetData.Classification)[disaster.DisasterType],
+				Skeet:        s.SkeetData,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Relevance != b.Relevance {
+			return a.Relevance > b.Relevance
+		}
+		if a.Skeet.Engagement() != b.Skeet.Engagement() {
+			return a.Skeet.Engagement() > b.Skeet.Engagement()
+		}
+		return a.Skeet.Timestamp > b.Skeet.Timestamp
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"go-firebird/types"
+	"testing"
+)
+
+func TestClusterChanged(t *testing.T) {
+	before := types.DisasterData{ID: "d1", TotalSkeetsAmount: 20, LocationIDs: []string{"a", "b"}, Summary: "Wildfire near Paradise"}
+
+	cases := []struct {
+		name   string
+		change func(d *types.DisasterData)
+		want   bool
+	}{
+		{"unchanged", func(d *types.DisasterData) {}, false},
+		{"only the status changed", func(d *types.DisasterData) { d.Status = types.Recovery }, false},
+		{"more skeets", func(d *types.DisasterData) { d.TotalSkeetsAmount = 25 }, true},
+		{"location added", func(d *types.DisasterData) { d.LocationIDs = []string{"a", "b", "c"} }, true},
+		{"location swapped", func(d *types.DisasterData) { d.LocationIDs = []string{"a", "c"} }, true},
+		{"same locations, other order", func(d *types.DisasterData) { d.LocationIDs = []string{"b", "a"} }, false},
+		{"no summary yet", func(d *types.DisasterData) { d.Summary = "" }, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			after := before
+			after.LocationIDs = append([]string(nil), before.LocationIDs...)
+			c.change(&after)
+			if got := clusterChanged(before, after); got != c.want {
+				t.Errorf("clusterChanged = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package processor
 
 import (
-	"cloud.google.com/go/firestore"
 	"fmt"
 	"go-firebird/db"
+	"go-firebird/detection"
+	"go-firebird/events"
 	"go-firebird/nlp"
 	"go-firebird/types"
 	"log"
@@ -11,7 +12,9 @@ import (
 	"time"
 )
 
-func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID string, locationData types.LocationData) error {
+// ProcessLocationAvgSentiment adds a new average sentiment entry to a location when it has new skeets and
+// recomputes its rolling windows and anomaly score. Every new entry is published on bus, which may be nil.
+func ProcessLocationAvgSentiment(store db.Store, bus *events.Bus, locationID string, locationData types.LocationData) error {
 	// NOTE: this right here is my religion
 	var logBuilder strings.Builder
 	addLog := func(format string, args ...interface{}) {
@@ -20,13 +23,25 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 	}
 
 	start := "1970-01-01T00:00:00Z" // big bang of computers
-	end := time.Now().UTC().Format(time.RFC3339)
+	now := time.Now().UTC()
+	end := now.Format(time.RFC3339)
 
 	addLog("Running avg sentiment on docId %v | Location name: %v", locationID, locationData.FormattedAddress)
 
+	// Rewrite legacy counts first, AddNewLocSentimentAvg would not recognize the converted entries
+	// as already being in the list and add them a second time.
+	if locationData.CountsMigrated {
+		if err := store.ReplaceLocationDisasterCounts(locationID, locationData); err != nil {
+			addLog("Failed to migrate legacy disaster counts: %v", err)
+			log.Println(logBuilder.String())
+			return err
+		}
+		addLog("Migrated legacy disaster counts")
+	}
+
 	if len(locationData.AvgSentimentList) == 0 {
 		addLog("Sentiment list is empty. Will INIT")
-		skeets, err := db.GetSkeetsSubCollection(firestoreClient, locationID, start, end)
+		skeets, err := store.GetSkeetsSubCollection(locationID, start, end)
 		if err != nil {
 			addLog("Error fetching skeets subcollection: %v", err)
 			log.Println(logBuilder.String())
@@ -35,24 +50,8 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 		addLog("Fetched %d skeets", len(skeets))
 
 		// compute the total count and add it to the field
-		dCount := types.DisasterCount{}
-		for _, v := range skeets {
-			switch GetCategory(v.SkeetData) {
-			case types.Wildfire:
-				dCount.FireCount += 1
-			case types.Earthquake:
-				dCount.EarthquakeCount += 1
-			case types.Hurricane:
-				dCount.HurricaneCount += 1
-			case types.NonDisaster:
-				dCount.NonDisasterCount += 1
-			}
-		}
-
-		addLog("FireCount: %v", dCount.FireCount)
-		addLog("EarthquakeCount: %v", dCount.EarthquakeCount)
-		addLog("HurricaneCount: %v", dCount.HurricaneCount)
-		addLog("NonDisasterCount: %v", dCount.NonDisasterCount)
+		dCount := CountCategories(skeets)
+		logDisasterCount(addLog, dCount)
 
 		avg := nlp.ComputeSimpleAverageSentiment(skeets)
 		newSentiment := types.AvgLocationSentiment{
@@ -66,7 +65,7 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 		addLog("Skeets amount is: %v", newSentiment.SkeetsAmount)
 		addLog("Average is: %v", newSentiment.AverageSentiment)
 
-		newLocationErr := db.InitLocationSentiment(firestoreClient, locationID, newSentiment)
+		newLocationErr := store.InitLocationSentiment(locationID, newSentiment)
 		if newLocationErr != nil {
 			addLog("Error adding new location sentiment: %v", newLocationErr)
 			log.Println(logBuilder.String())
@@ -74,7 +73,7 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 		}
 
 		// Update top level fields
-		errUpdate := db.UpdateLocationFields(firestoreClient, locationID, map[string]interface{}{
+		errUpdate := store.UpdateLocationFields(locationID, map[string]interface{}{
 			"latestSkeetsAmount":  newSentiment.SkeetsAmount,
 			"latestDisasterCount": newSentiment.DisasterCount,
 			"latestSentiment":     newSentiment.AverageSentiment,
@@ -86,11 +85,12 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 		} else {
 			addLog("Updated latestSkeetsAmount after init.")
 		}
+		publishLocationSentiment(bus, locationID, locationData, newSentiment, nil, len(skeets))
 
 	} else {
 		addLog("Sentiment list is not empty")
 		latestSentiment := locationData.AvgSentimentList[len(locationData.AvgSentimentList)-1]
-		newSkeets, err := db.GetSkeetsSubCollection(firestoreClient, locationID, latestSentiment.TimeStamp, end)
+		newSkeets, err := store.GetSkeetsSubCollection(locationID, latestSentiment.TimeStamp, end)
 		if err != nil {
 			addLog("Failed to get skeets subcollection: %v", err)
 			log.Println(logBuilder.String())
@@ -99,35 +99,21 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 
 		// since this is a new field, you would have to check if the latestSentiment has the newfield, if it does not, fetch all the skeets.
 		newDisasterCount := latestSentiment.DisasterCount
-		if newDisasterCount.FireCount == 0 && newDisasterCount.EarthquakeCount == 0 && newDisasterCount.HurricaneCount == 0 && newDisasterCount.NonDisasterCount == 0 {
+		if newDisasterCount.Total() == 0 {
 			addLog("This location does not have count field. Fetching all skeets. ")
-			allSkeets, err := db.GetSkeetsSubCollection(firestoreClient, locationID, start, end)
+			allSkeets, err := store.GetSkeetsSubCollection(locationID, start, end)
 			if err != nil {
 				addLog("Failed to get all skeets subcollection: %v", err)
 				return err
 			}
 
 			// compute the total count and add it to the field
-			dCount := types.DisasterCount{}
-			for _, v := range allSkeets {
-				switch GetCategory(v.SkeetData) {
-				case types.Wildfire:
-					dCount.FireCount += 1
-				case types.Earthquake:
-					dCount.EarthquakeCount += 1
-				case types.Hurricane:
-					dCount.HurricaneCount += 1
-				case types.NonDisaster:
-					dCount.NonDisasterCount += 1
-				}
-			}
-
-			newDisasterCount = dCount
+			newDisasterCount = CountCategories(allSkeets)
 
 			// update the document
 			latestSentiment.DisasterCount = newDisasterCount
 			locationData.AvgSentimentList[len(locationData.AvgSentimentList)-1] = latestSentiment
-			e := db.UpdateLocationSentimentList(firestoreClient, locationID, locationData.AvgSentimentList)
+			e := store.UpdateLocationSentimentList(locationID, locationData.AvgSentimentList)
 			if e != nil {
 				addLog("Failed to update location sentiment list with new count: %v", e)
 				log.Println(logBuilder.String())
@@ -137,24 +123,14 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 
 		} else {
 			addLog("This location does have count field. Caculating new count")
-			for _, v := range newSkeets {
-				switch GetCategory(v.SkeetData) {
-				case types.Wildfire:
-					newDisasterCount.FireCount += 1
-				case types.Earthquake:
-					newDisasterCount.EarthquakeCount += 1
-				case types.Hurricane:
-					newDisasterCount.HurricaneCount += 1
-				case types.NonDisaster:
-					newDisasterCount.NonDisasterCount += 1
-				}
-			}
+			// copy so the count stored in the previous entry is left alone
+			counts := make(types.DisasterCount, len(newDisasterCount))
+			counts.Merge(newDisasterCount)
+			counts.Merge(CountCategories(newSkeets))
+			newDisasterCount = counts
 		}
 
-		addLog("FireCount: %v", newDisasterCount.FireCount)
-		addLog("EarthquakeCount: %v", newDisasterCount.EarthquakeCount)
-		addLog("HurricaneCount: %v", newDisasterCount.HurricaneCount)
-		addLog("NonDisasterCount: %v", newDisasterCount.NonDisasterCount)
+		logDisasterCount(addLog, newDisasterCount)
 
 		if len(newSkeets) > 0 {
 			addLog("New skeets! Adding new entry")
@@ -167,7 +143,7 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 				AverageSentiment: newAvg,
 				DisasterCount:    newDisasterCount,
 			}
-			totalDisasterCount := newDisasterCount.FireCount + newDisasterCount.HurricaneCount + newDisasterCount.EarthquakeCount + newDisasterCount.NonDisasterCount
+			totalDisasterCount := newDisasterCount.Total()
 			if newSentiment.SkeetsAmount < (totalDisasterCount) {
 				newSentiment.SkeetsAmount = totalDisasterCount
 				addLog("Skeets Amount is behind totalDisaster. Updating")
@@ -178,7 +154,7 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 			addLog("Total Disaster count is: %v", totalDisasterCount)
 
 			locationData.AvgSentimentList = append(locationData.AvgSentimentList, newSentiment)
-			e := db.AddNewLocSentimentAvg(firestoreClient, locationID, locationData.AvgSentimentList)
+			e := store.AddNewLocSentimentAvg(locationID, locationData.AvgSentimentList)
 			if e != nil {
 				addLog("Failed to add new avgLocation: %v", e)
 				log.Println(logBuilder.String())
@@ -186,7 +162,7 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 			}
 			addLog("Added new average to list")
 
-			errUpdate := db.UpdateLocationFields(firestoreClient, locationID, map[string]interface{}{
+			errUpdate := store.UpdateLocationFields(locationID, map[string]interface{}{
 				"latestSkeetsAmount":  newSentiment.SkeetsAmount,
 				"latestDisasterCount": newSentiment.DisasterCount,
 				"latestSentiment":     newSentiment.AverageSentiment,
@@ -197,19 +173,21 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 				log.Println(logBuilder.String())
 				return errUpdate
 			}
+			previous := latestSentiment.AverageSentiment
+			publishLocationSentiment(bus, locationID, locationData, newSentiment, &previous, len(newSkeets))
 
 		} else {
 			addLog("No new skeets")
 			addLog("Updating timestamp of latest sentiment")
 
-			e := db.UpdateLocSentimentTimestampWithData(firestoreClient, locationData, locationID, end)
+			e := store.UpdateLocSentimentTimestampWithData(locationData, locationID, end)
 			if e != nil {
 				addLog("Failed to update avgLocation field with new timestamp: %v", e)
 				log.Println(logBuilder.String())
 				return err
 			}
 
-			errUpdate := db.UpdateLocationFields(firestoreClient, locationID, map[string]interface{}{
+			errUpdate := store.UpdateLocationFields(locationID, map[string]interface{}{
 				"lastSkeetTimestamp": end,
 			})
 			if errUpdate != nil {
@@ -220,7 +198,61 @@ func ProcessLocationAvgSentiment(firestoreClient *firestore.Client, locationID s
 
 		}
 	}
+
+	// Rolling windows are recomputed every time, skeets move out of them even when no new ones came in
+	windows, err := UpdateLocationWindows(store, locationID, now)
+	if err != nil {
+		addLog("Failed to update rolling windows: %v", err)
+		log.Println(logBuilder.String())
+		return err
+	}
+	for _, w := range types.RollingWindows {
+		addLog("Window %s: %v skeets, average %v", w.Name, windows[w.Name].SkeetsAmount, windows[w.Name].AverageSentiment)
+	}
+
+	// Score the short window against the location's own history
+	anomaly := detection.ScoreBurst(locationData.AvgSentimentList, windows[types.ShortWindow.Name], types.ShortWindow.Duration, now)
+	if err := store.UpdateLocationFields(locationID, map[string]interface{}{"anomaly": anomaly}); err != nil {
+		addLog("Failed to update anomaly score: %v", err)
+		log.Println(logBuilder.String())
+		return err
+	}
+	addLog("Anomaly: %v disaster skeets in %s, %.2f expected, score %.2f", anomaly.Observed, anomaly.Window, anomaly.Expected, anomaly.Score)
+
 	log.Println(logBuilder.String())
 	return nil
 
 }
+
+func publishLocationSentiment(bus *events.Bus, locationID string, l types.LocationData, avg types.AvgLocationSentiment, previous *float32, newSkeets int) {
+	bus.Publish(events.LocationSentiment, avg.DisasterCount.Dominant(), events.LocationEvent{
+		ID:                locationID,
+		Name:              l.LocationName,
+		FormattedAddress:  l.FormattedAddress,
+		Lat:               l.Lat,
+		Long:              l.Long,
+		Sentiment:         avg.AverageSentiment,
+		PreviousSentiment: previous,
+		SkeetsAmount:      avg.SkeetsAmount,
+		NewSkeets:         newSkeets,
+		Counts:            avg.DisasterCount,
+		UpdatedAt:         avg.TimeStamp,
+	})
+}
+
+// CountCategories counts the skeets per classified category.
+func CountCategories(skeets []types.SkeetSubDoc) types.DisasterCount {
+	counts := make(types.DisasterCount)
+	for _, v := range skeets {
+		counts.Add(GetCategory(v.SkeetData), 1)
+	}
+	return counts
+}
+
+func logDisasterCount(addLog func(format string, args ...interface{}), counts types.DisasterCount) {
+	for _, category := range types.ClassificationOrder {
+		if counts[category] > 0 {
+			addLog("%s count: %v", category, counts[category])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/detection"
+	"go-firebird/events"
+	"go-firebird/geocode"
+	"go-firebird/limits"
+	"go-firebird/mlmodel"
+	"go-firebird/nlp"
+	"os"
+)
+
+// Pipeline holds the backends a skeet is run through on its way into the store.
+type Pipeline struct {
+	Store      db.Store
+	Sentiment  nlp.SentimentAnalyzer
+	Entities   nlp.EntityExtractor
+	Classifier mlmodel.Classifier
+	Geocoder   geocode.Geocoder
+	Detection  *detection.Profiles
+	Events     *events.Bus     // saved skeets, location updates and disaster changes for the event stream
+	Limits     *limits.Limiter // rate limits and daily budgets of the paid APIs, already in front of the backends
+}
+
+// InitPipeline builds every backend from the env (STORE, GEOCODER, NLP, CLASSIFIER), puts the limits
+// (LIMITS_CONFIG) in front of the paid ones and loads the detection profiles (DETECTION_CONFIG, DETECTION_PROFILE).
+// Callers should defer ClosePipeline.
+func InitPipeline() (*Pipeline, error) {
+	// Init store. STORE=memory keeps everything in process, useful for offline runs.
+	var store db.Store
+	if os.Getenv("STORE") == "memory" {
+		fmt.Println("Using in-memory store")
+		store = db.NewMemoryStore()
+	} else {
+		firestoreClient, err := db.InitFirestore()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Firestore: %w", err)
+		}
+		store = db.NewFirestoreStore(firestoreClient)
+	}
+
+	// Init geocoder (Google Maps unless GEOCODER=offline), results are cached in the store
+	geocoder, err := geocode.InitGeocoder(store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize geocoder: %w", err)
+	}
+
+	// Init sentiment and entity analyzers (Google Natural Language unless NLP=local)
+	sentimentAnalyzer, entityExtractor, err := nlp.InitAnalyzers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize nlp: %w", err)
+	}
+
+	// Init classifier (remote model with a local fallback by default)
+	classifier, err := mlmodel.InitClassifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize classifier: %w", err)
+	}
+
+	// Rate limits and daily budgets of the paid APIs, today's usage so far comes from the store
+	limiter, err := limits.InitLimiter(store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize limits: %w", err)
+	}
+	sentimentAnalyzer, entityExtractor, classifier, geocoder = limits.Wrap(limiter, sentimentAnalyzer, entityExtractor, classifier, geocoder)
+
+	// Detection thresholds and regions, per named profile
+	profiles, err := detection.InitProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load detection profiles: %w", err)
+	}
+
+	return &Pipeline{
+		Store:      store,
+		Sentiment:  sentimentAnalyzer,
+		Entities:   entityExtractor,
+		Classifier: classifier,
+		Geocoder:   geocoder,
+		Detection:  profiles,
+		Events:     events.NewBus(),
+		Limits:     limiter,
+	}, nil
+}
+
+// ClosePipeline closes the Firestore and Natural Language clients if they were opened.
+func ClosePipeline() {
+	db.CloseFirestore()
+	nlp.CloseLanguageClient()
+}
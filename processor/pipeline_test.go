@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"fmt"
+	"go-firebird/types"
+	"testing"
+	"time"
+)
+
+// offlinePipeline runs the pipeline on STORE=memory with the local backends and the bundled data files.
+func offlinePipeline(t *testing.T) *Pipeline {
+	t.Helper()
+	t.Setenv("STORE", "memory")
+	t.Setenv("NLP", "local")
+	t.Setenv("GEOCODER", "offline")
+	t.Setenv("CLASSIFIER", "local")
+	t.Setenv("GAZETTEER_PATH", "../data/gazetteer.tsv")
+	t.Setenv("SENTIMENT_LEXICON_PATH", "../data/sentiment_lexicon.tsv")
+	t.Setenv("CLASSIFIER_TRAINING_DATA", "../demo_data.csv")
+	t.Setenv("DETECTION_CONFIG", "../data/detection.yaml")
+	t.Setenv("DETECTION_PROFILE", "")
+	t.Setenv("LIMITS_CONFIG", "../data/limits.yaml")
+	t.Setenv("OPENAI_API_KEY", "")
+
+	p, err := InitPipeline()
+	if err != nil {
+		t.Fatalf("InitPipeline: %v", err)
+	}
+	return p
+}
+
+func feedEntry(uri, text string, createdAt time.Time) types.FeedEntry {
+	return types.FeedEntry{Post: types.Post{
+		URI:    uri,
+		Author: types.Author{Handle: "reporter.bsky.social", DisplayName: "Reporter"},
+		Record: types.Record{Text: text, CreatedAt: createdAt.UTC().Format(time.RFC3339)},
+	}}
+}
+
+func TestIngestToDetection(t *testing.T) {
+	p := offlinePipeline(t)
+	now := time.Now().UTC()
+
+	texts := []string{
+		"Terrible wildfire burning in Paradise, the smoke and flames are devastating",
+		"Homes destroyed by the fire in Paradise, firefighters cannot stop the blaze",
+		"Horrible wildfire in Paradise, everyone told to evacuate, so scared",
+		"Tragic scenes in Paradise as the wildfire spreads, terrible smoke everywhere",
+		"The Paradise fire is devastating, burning embers everywhere, evacuate now",
+	}
+	var feed types.FeedResponse
+	for i, text := range texts {
+		feed.Feed = append(feed.Feed, feedEntry(fmt.Sprintf("at://did:plc:test/app.bsky.feed.post/%d", i), text, now.Add(-time.Duration(i+1)*time.Minute)))
+	}
+
+	results := SaveFeed(feed, p)
+	if len(results) != len(texts) {
+		t.Fatalf("SaveFeed returned %d results, want %d", len(results), len(texts))
+	}
+	for _, r := range results {
+		if r.ErrorSaving {
+			t.Fatalf("skeet %q was not saved", r.Content)
+		}
+	}
+
+	// Saving the same feed again must not duplicate anything
+	for _, r := range SaveFeed(feed, p) {
+		if !r.AlreadyExist {
+			t.Errorf("skeet %q was saved twice", r.Content)
+		}
+	}
+
+	locations, err := p.Store.GetValidLocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locations) != 1 || locations[0].LocationName != "Paradise" {
+		t.Fatalf("valid locations = %+v, want only Paradise", locations)
+	}
+	for _, location := range locations {
+		if err := ProcessLocationAvgSentiment(p.Store, p.Events, location.ID, location); err != nil {
+			t.Fatalf("ProcessLocationAvgSentiment(%s): %v", location.LocationName, err)
+		}
+	}
+
+	paradise, err := p.Store.GetValidLocation(locations[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window, ok := paradise.Window(types.ShortWindow)
+	if !ok || window.SkeetsAmount != len(texts) {
+		t.Fatalf("6h window = %+v, want %d skeets", window, len(texts))
+	}
+	if window.DisasterCount[types.Wildfire] != len(texts) {
+		t.Errorf("6h wildfire count = %d, want %d", window.DisasterCount[types.Wildfire], len(texts))
+	}
+	if window.AverageSentiment >= 0 {
+		t.Errorf("6h sentiment = %v, want negative", window.AverageSentiment)
+	}
+
+	cfg, ok := p.Detection.Get("")
+	if !ok {
+		t.Fatal("no default detection profile")
+	}
+	run, disasters, err := RunDisasterDetection(p.Store, p.Events, p.Limits, "test", cfg)
+	if err != nil {
+		t.Fatalf("RunDisasterDetection: %v", err)
+	}
+	if !run.Succeeded || run.LocationsChecked != 1 || run.ClustersFound != 1 {
+		t.Errorf("run = %+v", run)
+	}
+	if len(disasters) != 1 {
+		t.Fatalf("detected %d disasters, want 1", len(disasters))
+	}
+	d := disasters[0]
+	if d.DisasterType != types.Wildfire || d.Status != types.Active {
+		t.Errorf("disaster is %s/%s, want wildfire/Active", d.DisasterType, d.Status)
+	}
+	if len(d.LocationIDs) != 1 || d.LocationIDs[0] != paradise.ID {
+		t.Errorf("disaster locations = %v, want [%s]", d.LocationIDs, paradise.ID)
+	}
+
+	stored, err := p.Store.GetDisasterByID(d.ID)
+	if err != nil {
+		t.Fatalf("disaster was not saved: %v", err)
+	}
+	if stored.DisasterType != types.Wildfire {
+		t.Errorf("stored disaster type = %s", stored.DisasterType)
+	}
+	runs, err := p.Store.GetDetectionRuns(10)
+	if err != nil || len(runs) != 1 || runs[0].ID != run.ID {
+		t.Errorf("detection runs = %+v, %v", runs, err)
+	}
+}
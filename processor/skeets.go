@@ -1,21 +1,17 @@
 package processor
 
 import (
-	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"go-firebird/db"
+	"go-firebird/events"
+	"go-firebird/limits"
 	"go-firebird/mlmodel"
-	"go-firebird/nlp"
 	"go-firebird/types"
 	"log"
 	"sync"
-
-	"cloud.google.com/go/firestore"
-	language "cloud.google.com/go/language/apiv2"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
 // HashString hashes a given string using SHA-256.
@@ -25,41 +21,26 @@ func HashString(s string) string {
 }
 
 func GetCategory(s types.Skeet) types.Category {
-	maxProb := 0.00
-	maxIdx := 0
-	for i, prob := range s.Classification {
-		if prob > maxProb {
-			maxProb = prob
-			maxIdx = i
-		}
-	}
-
-	c := types.NonDisaster
-	switch maxIdx {
-	case 0:
-		c = types.Wildfire
-	case 1:
-		c = types.Hurricane
-	case 2:
-		c = types.Earthquake
-	default:
-		c = types.NonDisaster
-	}
-
-	return c
-
+	return types.DistributionFromVector(s.Classification).Top()
 }
 
-func SaveFeed(out types.FeedResponse, firestoreClient *firestore.Client, nlpClient *language.Client) []types.SaveSkeetResult {
+// saveFeedWorkers is how many skeets of a feed are processed at once, each makes up to four upstream calls.
+const saveFeedWorkers = 16
+
+func SaveFeed(out types.FeedResponse, p *Pipeline) []types.SaveSkeetResult {
+	feedItems := make(chan types.FeedEntry)
 	resultsChan := make(chan types.SaveSkeetResult, len(out.Feed))
 	var wg sync.WaitGroup
 
-	for _, v := range out.Feed {
-		if v.Post.URI != "" {
-			wg.Add(1)
-			feedItem := v // capture variable for goroutine
-			go func() {
-				defer wg.Done()
+	workers := saveFeedWorkers
+	if len(out.Feed) < workers {
+		workers = len(out.Feed)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feedItem := range feedItems {
 				newSkeet := types.Skeet{
 					Avatar:      feedItem.Post.Author.Avatar,
 					Content:     feedItem.Post.Record.Text,
@@ -67,8 +48,10 @@ func SaveFeed(out types.FeedResponse, firestoreClient *firestore.Client, nlpClie
 					DisplayName: feedItem.Post.Author.DisplayName,
 					UID:         feedItem.Post.URI,
 					Timestamp:   feedItem.Post.Record.CreatedAt,
+					LikeCount:   feedItem.Post.LikeCount,
+					RepostCount: feedItem.Post.RepostCount,
 				}
-				savedSkeetResult, err := SaveSkeet(newSkeet, firestoreClient, nlpClient)
+				savedSkeetResult, err := SaveSkeet(newSkeet, p)
 				if err != nil {
 					savedSkeetResult = types.SaveSkeetResult{
 						SavedSkeetID:         feedItem.Post.URI,
@@ -79,12 +62,20 @@ func SaveFeed(out types.FeedResponse, firestoreClient *firestore.Client, nlpClie
 						AlreadyExist:         false,
 						Content:              feedItem.Post.Record.Text,
 						ErrorSaving:          true,
+						BudgetExhausted:      errors.Is(err, limits.ErrBudgetExhausted),
 					}
 				}
 				resultsChan <- savedSkeetResult
-			}()
+			}
+		}()
+	}
+
+	for _, v := range out.Feed {
+		if v.Post.URI != "" {
+			feedItems <- v
 		}
 	}
+	close(feedItems)
 
 	wg.Wait()
 	close(resultsChan)
@@ -98,8 +89,8 @@ func SaveFeed(out types.FeedResponse, firestoreClient *firestore.Client, nlpClie
 
 }
 
-func SaveSkeet(newSkeet types.Skeet, firestoreClient *firestore.Client, nlpClient *language.Client) (types.SaveSkeetResult, error) {
-	ctx := context.Background()
+func SaveSkeet(newSkeet types.Skeet, p *Pipeline) (types.SaveSkeetResult, error) {
+	store := p.Store
 	hashedSkeetID := db.HashString(newSkeet.UID)
 
 	var result types.SaveSkeetResult
@@ -109,25 +100,22 @@ func SaveSkeet(newSkeet types.Skeet, firestoreClient *firestore.Client, nlpClien
 	result.ErrorSaving = false
 
 	// Check if the skeet already exists.
-	_, err := firestoreClient.Collection("skeets").Doc(hashedSkeetID).Get(ctx)
-	if err == nil {
+	exists, err := store.SkeetExists(hashedSkeetID)
+	if err != nil {
+		result.ErrorSaving = true
+		return result, err
+	}
+	if exists {
 		fmt.Printf("Doc already exists for: %s. Hash: %s\n", newSkeet.UID, hashedSkeetID)
 		result.AlreadyExist = true
 		result.SavedSkeetID = hashedSkeetID
 		return result, nil
-	} else if status.Code(err) != codes.NotFound {
-		result.ErrorSaving = true
-		return result, err
-	}
-
-	// Prepare the ML input.
-	mlInputs := mlmodel.MLRequest{
-		newSkeet.UID: newSkeet.Content,
 	}
 
 	// Run ML model call, entity extraction, and sentiment analysis concurrently.
 	var (
-		classification         []float64
+		classification         types.CategoryDistribution
+		classifiedBy           string
 		nlpEntities            []types.Entity
 		sentiment              types.Sentiment
 		mlErr, nlpErr, sentErr error
@@ -137,18 +125,13 @@ func SaveSkeet(newSkeet types.Skeet, firestoreClient *firestore.Client, nlpClien
 
 	go func() {
 		defer wg.Done()
-		mlResp, err := mlmodel.CallModel(mlInputs)
-		if err != nil {
-			mlErr = err
-			return
-		}
-		classification = mlResp[newSkeet.UID]
+		classification, classifiedBy, mlErr = mlmodel.ClassifyOne(p.Classifier, newSkeet.UID, newSkeet.Content)
 	}()
 
 	go func() {
 		defer wg.Done()
 		var err error
-		nlpEntities, err = nlp.AnalyzeEntities(nlpClient, newSkeet.Content)
+		nlpEntities, err = p.Entities.AnalyzeEntities(newSkeet.Content)
 		if err != nil {
 			log.Printf("Error analyzing entities: %v", err)
 			nlpEntities = []types.Entity{}
@@ -159,7 +142,7 @@ func SaveSkeet(newSkeet types.Skeet, firestoreClient *firestore.Client, nlpClien
 	go func() {
 		defer wg.Done()
 		var err error
-		sentiment, err = nlp.AnalyzeSentiment(nlpClient, newSkeet.Content)
+		sentiment, err = p.Sentiment.AnalyzeSentiment(newSkeet.Content)
 		if err != nil {
 			log.Printf("Error analyzing sentiment: %v", err)
 			sentErr = err
@@ -167,47 +150,80 @@ func SaveSkeet(newSkeet types.Skeet, firestoreClient *firestore.Client, nlpClien
 	}()
 
 	wg.Wait()
-	_ = nlpErr
-	_ = sentErr
 
+	// Without entities or sentiment the skeet would be saved without its locations, so it isn't saved
+	// when the NLP budget is spent. The result says so: a backfill stops on the page and resumes it later,
+	// the cron poll and the ingest only count it.
+	for _, err := range []error{nlpErr, sentErr} {
+		if errors.Is(err, limits.ErrBudgetExhausted) {
+			result.ErrorSaving = true
+			result.BudgetExhausted = true
+			return result, fmt.Errorf("skipped %s: %w", newSkeet.UID, err)
+		}
+	}
+
+	// Saving without a classification would silently turn the post into a non-disaster,
+	// so leave it unsaved and let the next run pick it up again.
 	if mlErr != nil {
-		fmt.Printf("Error processing ML classification for hashedSkeetId: %s", hashedSkeetID)
+		log.Printf("Error processing ML classification for hashedSkeetId %s: %v", hashedSkeetID, mlErr)
+		result.ErrorSaving = true
+		return result, fmt.Errorf("classification failed for %s: %w", newSkeet.UID, mlErr)
 	}
 
-	result.Classification = classification
+	result.Classification = classification.Vector()
+	result.ClassifiedBy = classifiedBy
 	result.Sentiment = sentiment
 
 	data := types.SaveCompleteSkeetType{
 		NewSkeet:       newSkeet,
-		Classification: classification,
+		Classification: classification.Vector(),
+		ClassifiedBy:   classifiedBy,
 		Entities:       nlpEntities,
 		Sentiment:      sentiment,
 	}
 
-	newLocations, err := db.SaveCompleteSkeet(firestoreClient, data)
+	newLocations, err := store.SaveCompleteSkeet(data)
 	if err != nil {
 		result.ErrorSaving = true
 		return result, err
 	}
 	result.NewLocationNames = newLocations
 
-	count := 0
-	for _, entity := range data.Entities {
-		if entity.Type == "LOCATION" || entity.Type == "ADDRESS" {
-			count++
-		}
-	}
-	result.ProcessedEntityCount = count
+	names := locationNames(data.Entities)
+	result.ProcessedEntityCount = len(names)
+
+	p.Events.Publish(events.SkeetSaved, classification.Top(), events.SkeetEvent{
+		ID:             hashedSkeetID,
+		UID:            newSkeet.UID,
+		Handle:         newSkeet.Handle,
+		Content:        newSkeet.Content,
+		Timestamp:      newSkeet.Timestamp,
+		Category:       classification.Top(),
+		Classification: classification.Vector(),
+		Sentiment:      sentiment,
+		Locations:      names,
+	})
 
 	var geoWg sync.WaitGroup
 	for _, locationName := range newLocations {
 		geoWg.Add(1)
 		go func(loc string) {
 			defer geoWg.Done()
-			db.UpdateLocationGeocoding(firestoreClient, loc)
+			db.UpdateLocationGeocoding(store, p.Geocoder, loc)
 		}(locationName)
 	}
 	geoWg.Wait()
 
 	return result, nil
 }
+
+// locationNames are the names of the LOCATION and ADDRESS entities.
+func locationNames(entities []types.Entity) []string {
+	names := []string{}
+	for _, entity := range entities {
+		if entity.Type == "LOCATION" || entity.Type == "ADDRESS" {
+			names = append(names, entity.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"go-firebird/db"
+	"go-firebird/nlp"
+	"go-firebird/types"
+	"time"
+)
+
+// UpdateLocationWindows recomputes the rolling window aggregates of a location from its skeets
+// and saves them. Windows are counted back from now.
+func UpdateLocationWindows(store db.Store, locationID string, now time.Time) (map[string]types.LocationWindow, error) {
+	longest := types.RollingWindows[len(types.RollingWindows)-1]
+	since := now.Add(-longest.Duration).UTC().Format(time.RFC3339)
+	end := now.UTC().Format(time.RFC3339)
+
+	skeets, err := store.GetSkeetsSubCollection(locationID, since, end)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := ComputeWindows(skeets, now)
+	if err := store.UpdateLocationWindows(locationID, windows); err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// ComputeWindows aggregates skeets into every types.RollingWindows window ending at now.
+func ComputeWindows(skeets []types.SkeetSubDoc, now time.Time) map[string]types.LocationWindow {
+	windows := make(map[string]types.LocationWindow, len(types.RollingWindows))
+	for _, w := range types.RollingWindows {
+		start := now.Add(-w.Duration)
+
+		var inWindow []types.SkeetSubDoc
+		engagement := 0
+		for _, s := range skeets {
+			if postedAfter(s.SkeetData.Timestamp, start) {
+				inWindow = append(inWindow, s)
+				engagement += s.SkeetData.Engagement()
+			}
+		}
+
+		windows[w.Name] = types.LocationWindow{
+			Since:            start.UTC().Format(time.RFC3339),
+			UpdatedAt:        now.UTC().Format(time.RFC3339),
+			SkeetsAmount:     len(inWindow),
+			AverageSentiment: nlp.ComputeSimpleAverageSentiment(inWindow),
+			DisasterCount:    CountCategories(inWindow),
+			Engagement:       engagement,
+		}
+	}
+	return windows
+}
+
+// postedAfter compares as time when the timestamp parses, as string otherwise like the subcollection query does.
+func postedAfter(timestamp string, start time.Time) bool {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp >= start.UTC().Format(time.RFC3339)
+	}
+	return !t.Before(start)
+}
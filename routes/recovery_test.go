@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(recovery())
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/abort", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteString(`{"type":"FeatureCollection","features":[`)
+		c.Writer.Flush()
+		panic(http.ErrAbortHandler)
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("panic answered %d, want 500", resp.StatusCode)
+	}
+
+	// The stream started with a 200, the client has to see the transfer fail
+	resp, err = http.Get(server.URL + "/abort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || err == nil {
+		t.Errorf("aborted stream: status %d, body %q, read error %v, want a failed read", resp.StatusCode, body, err)
+	}
+}
@@ -1,17 +1,30 @@
 package routes
 
 import (
-	"cloud.google.com/go/firestore"
-	language "cloud.google.com/go/language/apiv2"
+	"context"
 	"github.com/gin-gonic/gin"
+	"go-firebird/auth"
+	"go-firebird/export"
+	"go-firebird/feeds"
 	"go-firebird/handlers"
-	"googlemaps.github.io/maps"
+	"go-firebird/ingest"
+	"go-firebird/processor"
+	"go-firebird/types"
+	"go-firebird/webhooks"
 )
 
-func SetupRouter(firestoreClient *firestore.Client, nlpClient *language.Client,
-	geocodeClient *maps.Client) *gin.Engine {
+// SetupRouter builds the API. Backfills started over it run on ctx, cancel it to stop them on shutdown.
+func SetupRouter(ctx context.Context, pipeline *processor.Pipeline, registry *feeds.Registry, consumer *ingest.Consumer, dispatcher *webhooks.Dispatcher, authn *auth.Authenticator) *gin.Engine {
+	store := pipeline.Store
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Logger(), recovery())
+
+	// Role each route needs. Readers get data out, operators run the pipeline and spend API quota,
+	// admins change configuration and delete data. Operator and admin requests are audited.
+	reader := authn.Require(types.RoleReader)
+	operator := authn.Require(types.RoleOperator)
+	admin := authn.Require(types.RoleAdmin)
 
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -19,62 +32,226 @@ func SetupRouter(firestoreClient *firestore.Client, nlpClient *language.Client,
 		})
 	})
 
-	r.GET("/api/firebird/bluesky", func(c *gin.Context) {
-		handlers.FetchBlueskyHandler(c, firestoreClient, nlpClient)
+	r.GET("/api/firebird/bluesky", operator, func(c *gin.Context) {
+		handlers.FetchBlueskyHandler(c, pipeline, registry)
+	})
+
+	r.POST("/api/backfill", operator, func(c *gin.Context) {
+		handlers.StartBackfill(c, ctx, pipeline, registry)
+	})
+
+	r.GET("/api/backfill", reader, func(c *gin.Context) {
+		handlers.GetBackfillStatus(c, pipeline, registry)
+	})
+
+	// Feed registry admin
+	r.GET("/api/admin/feeds", admin, func(c *gin.Context) {
+		handlers.ListFeeds(c, registry)
+	})
+
+	r.GET("/api/admin/feeds/:id", admin, func(c *gin.Context) {
+		handlers.GetFeed(c, registry)
+	})
+
+	r.PUT("/api/admin/feeds/:id", admin, func(c *gin.Context) {
+		handlers.PutFeed(c, registry)
+	})
+
+	r.DELETE("/api/admin/feeds/:id", admin, func(c *gin.Context) {
+		handlers.DeleteFeed(c, registry)
+	})
+
+	// Webhook subscriptions and their delivery log
+	r.GET("/api/admin/webhooks", admin, func(c *gin.Context) {
+		handlers.ListWebhooks(c, dispatcher)
+	})
+
+	r.POST("/api/admin/webhooks", admin, func(c *gin.Context) {
+		handlers.CreateWebhook(c, dispatcher)
+	})
+
+	r.GET("/api/admin/webhooks/:id", admin, func(c *gin.Context) {
+		handlers.GetWebhook(c, dispatcher)
+	})
+
+	r.PUT("/api/admin/webhooks/:id", admin, func(c *gin.Context) {
+		handlers.PutWebhook(c, dispatcher)
+	})
+
+	r.DELETE("/api/admin/webhooks/:id", admin, func(c *gin.Context) {
+		handlers.DeleteWebhook(c, dispatcher)
+	})
+
+	r.GET("/api/admin/webhooks/:id/deliveries", admin, func(c *gin.Context) {
+		handlers.ListWebhookDeliveries(c, store)
+	})
+
+	r.GET("/api/admin/webhookDeliveries", admin, func(c *gin.Context) {
+		handlers.ListWebhookDeliveries(c, store)
+	})
+
+	r.GET("/api/admin/webhookDeliveries/:deliveryId", admin, func(c *gin.Context) {
+		handlers.GetWebhookDelivery(c, store)
+	})
+
+	r.POST("/api/admin/webhookDeliveries/:deliveryId/redeliver", admin, func(c *gin.Context) {
+		handlers.RedeliverWebhookDelivery(c, dispatcher)
+	})
+
+	// API keys and the audit log
+	r.GET("/api/auth/whoami", reader, handlers.WhoAmI)
+
+	r.GET("/api/admin/keys", admin, func(c *gin.Context) {
+		handlers.ListAPIKeys(c, authn)
+	})
+
+	r.POST("/api/admin/keys", admin, func(c *gin.Context) {
+		handlers.CreateAPIKey(c, authn)
+	})
+
+	r.DELETE("/api/admin/keys/:id", admin, func(c *gin.Context) {
+		handlers.RevokeAPIKey(c, authn)
+	})
+
+	r.GET("/api/admin/audit", admin, func(c *gin.Context) {
+		handlers.ListAuditEntries(c, store)
+	})
+
+	// Calls and spend of the paid upstream APIs against their limits
+	r.GET("/api/admin/usage", admin, func(c *gin.Context) {
+		handlers.GetUsage(c, pipeline.Limits, store)
+	})
+
+	r.POST("/api/admin/migrations/disasterCounts", admin, func(c *gin.Context) {
+		handlers.MigrateDisasterCounts(c, store)
+	})
+
+	r.GET("/api/ingest/status", reader, func(c *gin.Context) {
+		handlers.IngestStatus(c, consumer)
 	})
 
 	// Testing routes
-	r.GET("/api/testing/entity", func(c *gin.Context) {
-		handlers.TestEntity(c, nlpClient)
+	r.GET("/api/testing/entity", operator, func(c *gin.Context) {
+		handlers.TestEntity(c, pipeline.Entities)
 	})
 
-	r.GET("/api/testing/sentiment", func(c *gin.Context) {
-		handlers.TestSentiment(c, nlpClient)
+	r.GET("/api/testing/sentiment", operator, func(c *gin.Context) {
+		handlers.TestSentiment(c, pipeline.Sentiment)
 	})
 
-	r.GET("/api/testing/classification", func(c *gin.Context) {
+	r.GET("/api/testing/classification", operator, func(c *gin.Context) {
 		handlers.ClassifyLiveTest(c)
 	})
 
-	r.GET("/api/testing/geocoding", func(c *gin.Context) {
-		handlers.TestGeocode(c)
+	r.GET("/api/testing/geocoding", operator, func(c *gin.Context) {
+		handlers.TestGeocode(c, pipeline.Geocoder)
+	})
+
+	r.GET("/api/testing/updateGeocoding", operator, func(c *gin.Context) {
+		handlers.TestUpdateGeocodingDBTest(c, store, pipeline.Geocoder)
+	})
+
+	r.GET("/api/testing/updateLocationSentiment", operator, func(c *gin.Context) {
+		handlers.TestLocationSentimentUpdate(c, store, pipeline.Events)
+	})
+
+	r.GET("/api/testing/getActiveLocations", operator, func(c *gin.Context) {
+		handlers.TestGetActiveLocation(c, store)
+	})
+
+	r.GET("/api/export/locations", reader, func(c *gin.Context) {
+		handlers.ExportLocationsHandler(c, store)
+	})
+
+	r.GET("/api/export/locations.geojson", reader, func(c *gin.Context) {
+		handlers.ExportLocationsGeoJSON(c, store)
+	})
+
+	r.GET("/api/export/disasters.geojson", reader, func(c *gin.Context) {
+		handlers.ExportDisastersGeoJSON(c, store)
 	})
 
-	r.GET("/api/testing/updateGeocoding", func(c *gin.Context) {
-		handlers.TestUpdateGeocodingDBTest(c, firestoreClient)
+	r.GET("/api/export/skeets.csv", reader, func(c *gin.Context) {
+		handlers.ExportSkeets(c, store, export.FormatCSV)
 	})
 
-	r.GET("/api/testing/updateLocationSentiment", func(c *gin.Context) {
-		handlers.TestLocationSentimentUpdate(c, firestoreClient)
+	r.GET("/api/export/skeets.parquet", reader, func(c *gin.Context) {
+		handlers.ExportSkeets(c, store, export.FormatParquet)
 	})
 
-	r.GET("/api/testing/getActiveLocations", func(c *gin.Context) {
-		handlers.TestGetActiveLocation(c, firestoreClient)
+	r.GET("/api/test/disasterDetection", operator, func(c *gin.Context) {
+		handlers.RunDisasterDetection(c, store, pipeline.Detection, pipeline.Events, pipeline.Limits)
 	})
 
-	r.GET("/api/export/locations", func(c *gin.Context) {
-		handlers.ExportLocationsHandler(c, firestoreClient)
+	r.POST("/api/test/disasterDetection", operator, func(c *gin.Context) {
+		handlers.RunDisasterDetection(c, store, pipeline.Detection, pipeline.Events, pipeline.Limits)
 	})
 
-	r.GET("/api/test/disasterDetection", func(c *gin.Context) {
-		handlers.RunDisasterDetection(c, firestoreClient)
+	r.GET("/api/detection/profiles", reader, func(c *gin.Context) {
+		handlers.GetDetectionProfiles(c, pipeline.Detection)
 	})
 
-	r.GET("/api/demo/disaster/add", func(c *gin.Context) {
-		handlers.AddDisasterDemoData(c, firestoreClient, nlpClient)
+	r.GET("/api/detection/runs", reader, func(c *gin.Context) {
+		handlers.GetDetectionRuns(c, store)
 	})
 
-	r.GET("/api/demo/disaster/delete", func(c *gin.Context) {
-		handlers.DeleteDisasterDemoData(c, firestoreClient)
+	r.GET("/api/demo/disaster/add", operator, func(c *gin.Context) {
+		handlers.AddDisasterDemoData(c, pipeline, registry)
+	})
+
+	r.GET("/api/demo/disaster/delete", admin, func(c *gin.Context) {
+		handlers.DeleteDisasterDemoData(c, store)
+	})
+
+	// Live events: saved skeets, location sentiment updates and disaster changes
+	r.GET("/api/events/stream", reader, func(c *gin.Context) {
+		handlers.StreamEvents(c, pipeline.Events)
+	})
+
+	r.GET("/api/events/ws", reader, func(c *gin.Context) {
+		handlers.EventsWebSocket(c, pipeline.Events)
+	})
+
+	// CAP 1.2 alerts of the disasters and their Atom index
+	r.GET("/api/cap/alerts.atom", reader, func(c *gin.Context) {
+		handlers.DisastersAtom(c, store)
+	})
+
+	r.GET("/api/cap/alerts/:id", reader, func(c *gin.Context) {
+		handlers.GetDisasterCAP(c, store)
 	})
 
 	// api routes
 	api := r.Group("/api/firebird")
 	{
-		api.POST("/classify", handlers.CallOpenAI)
-		api.GET("/demo", handlers.GetDemoData)
-		api.GET("/testhook", handlers.TestClientHook)
-		api.GET("/simulate", handlers.SimulateDisasterTweets)
+		api.POST("/classify", operator, func(c *gin.Context) {
+			handlers.CallOpenAI(c, pipeline.Limits)
+		})
+		api.GET("/demo", reader, handlers.GetDemoData)
+		api.GET("/testhook", operator, func(c *gin.Context) {
+			handlers.TestClientHook(c, pipeline.Events)
+		})
+		api.GET("/simulate", operator, func(c *gin.Context) {
+			handlers.SimulateDisasterTweets(c, pipeline.Events)
+		})
+		api.GET("/disasters", reader, func(c *gin.Context) {
+			handlers.ListDisasters(c, store)
+		})
+		api.GET("/disasters/:id", reader, func(c *gin.Context) {
+			handlers.GetDisaster(c, store)
+		})
+		api.GET("/locations", reader, func(c *gin.Context) {
+			handlers.ListLocations(c, store)
+		})
+		api.GET("/locations/:id", reader, func(c *gin.Context) {
+			handlers.GetLocation(c, store)
+		})
+		api.GET("/locations/:id/history", reader, func(c *gin.Context) {
+			handlers.GetLocationHistory(c, store)
+		})
+		api.GET("/locations/:id/skeets", reader, func(c *gin.Context) {
+			handlers.GetLocationSkeets(c, store)
+		})
 	}
 
 	return r
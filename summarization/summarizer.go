@@ -1,11 +1,12 @@
 package summarization
 
 import (
-	"cloud.google.com/go/firestore"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/sashabaranov/go-openai"
 	"go-firebird/db"
+	"go-firebird/limits"
 	"go-firebird/types"
 	"log"
 	"strings"
@@ -16,16 +17,22 @@ const maxSkeetsForSummary = 69
 const maxPromptLength = 15000 // Rough character limit for prompt
 
 // fetches skeets for each disaster and calls OpenAI for summarization.
-// It modifies the input slice directly.
+// It modifies the input slice directly and returns how many summaries were generated.
+// Failures don't stop the other disasters, they are joined into the returned error.
+// The OpenAI calls wait for the limiter, once the budget is spent the remaining disasters keep their summary.
 func GenerateSummaries(
 	ctx context.Context,
 	disasters []types.DisasterData,
-	firestoreClient *firestore.Client,
+	store db.Store,
 	openaiClient *openai.Client,
-) error {
+	limiter *limits.Limiter,
+) (int, error) {
 	log.Printf("Starting summary generation for %d disasters...", len(disasters))
 
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	generated := 0
 
 	for i := range disasters {
 		wg.Add(1)
@@ -36,9 +43,12 @@ func GenerateSummaries(
 			log.Printf("Fetching skeets for disaster ID %s (%s)", disaster.ID, disaster.DisasterType)
 
 			// 1. Fetch relevant skeets for all locations in the cluster
-			combinedSkeetText, err := fetchSkeetsForDisaster(ctx, disaster, firestoreClient)
+			combinedSkeetText, err := fetchSkeetsForDisaster(ctx, disaster, store)
 			if err != nil {
 				log.Printf("Error fetching skeets for disaster %s: %v. Skipping summary.", disaster.ID, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("disaster %s: %w", disaster.ID, err))
+				mu.Unlock()
 				return
 			}
 
@@ -48,16 +58,35 @@ func GenerateSummaries(
 			}
 
 			// 2. Call OpenAI for summary
+			call, err := limiter.Acquire(ctx, limits.OpenAI)
+			if err != nil {
+				log.Printf("Skipping summary for disaster %s: %v", disaster.ID, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("disaster %s: %w", disaster.ID, err))
+				mu.Unlock()
+				return
+			}
 			log.Printf("Requesting summary from OpenAI for disaster %s...", disaster.ID)
-			summary, err := callOpenAISummary(ctx, combinedSkeetText, disaster.DisasterType, openaiClient)
+			summary, usage, err := callOpenAISummary(ctx, combinedSkeetText, disaster.DisasterType, openaiClient)
+			if usage.TotalTokens > 0 {
+				call.DoneTokens(usage.PromptTokens, usage.CompletionTokens)
+			} else {
+				call.Done(err)
+			}
 			if err != nil {
 				log.Printf("Error getting summary from OpenAI for disaster %s: %v. Skipping summary.", disaster.ID, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("disaster %s: %w", disaster.ID, err))
+				mu.Unlock()
 				return
 			}
 
 			// 3. Update the disaster object
 			log.Printf("Received summary for disaster %s.", disaster.ID)
 			disaster.Summary = summary
+			mu.Lock()
+			generated++
+			mu.Unlock()
 
 		}(i) // Pass index to the goroutine
 	}
@@ -65,14 +94,14 @@ func GenerateSummaries(
 	wg.Wait() // Wait for all goroutines to finish
 
 	log.Println("Summary generation finished.")
-	return nil
+	return generated, errors.Join(errs...)
 }
 
 // fetchSkeetsForDisaster retrieves skeets for all locations within a disaster's timeframe.
 func fetchSkeetsForDisaster(
 	ctx context.Context,
 	disaster *types.DisasterData,
-	firestoreClient *firestore.Client,
+	store db.Store,
 ) (string, error) {
 	var allSkeetsContent []string
 	totalSkeetsFetched := 0
@@ -99,7 +128,7 @@ func fetchSkeetsForDisaster(
 			break
 		}
 
-		skeets, err := db.GetSkeetsSubCollection(firestoreClient, locID, startDate, endDate)
+		skeets, err := store.GetSkeetsSubCollection(locID, startDate, endDate)
 		if err != nil {
 			log.Printf("Warning: Failed to get skeets for location %s in disaster %s: %v", locID, disaster.ID, err)
 			continue
@@ -133,14 +162,14 @@ func fetchSkeetsForDisaster(
 	return combined, nil
 }
 
-// callOpenAISummary sends text to OpenAI and requests a summary.
+// callOpenAISummary sends text to OpenAI and requests a summary, it also returns the tokens used.
 func callOpenAISummary(
 	ctx context.Context,
 	skeetText string,
 	disasterType types.Category,
 	client *openai.Client,
-) (string, error) {
-	prompt := fmt.Sprintf("Summarize the following collection of social media posts related to a potential %s event. Focus on the key impacts, locations mentioned, and overall situation described. If a tweet feels incongruent to the disaster type or location, disregard the tweet from the summary. Provide a concise summary (2-3 sentences maximum):\n\n---\n%s\n---\n\nSummary:", disasterType, skeetText)
+) (string, openai.Usage, error) {
+	prompt := fmt.Sprintf("Summarize the following collection of social media posts related to a potential %s event. Focus on the key impacts, locations mentioned, and overall situation described. If a tweet feels incongruent to the disaster type or location, disregard the tweet from the summary. Provide a concise summary (2-3 sentences maximum):\n\n---\n%s\n---\n\nSummary:", disasterType.Label(), skeetText)
 
 	resp, err := client.CreateChatCompletion(
 		ctx,
@@ -164,13 +193,13 @@ func callOpenAISummary(
 	)
 
 	if err != nil {
-		return "", fmt.Errorf("openai chat completion error: %w", err)
+		return "", resp.Usage, fmt.Errorf("openai chat completion error: %w", err)
 	}
 
 	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
-		return "", fmt.Errorf("openai returned empty response or choices")
+		return "", resp.Usage, fmt.Errorf("openai returned empty response or choices")
 	}
 
 	// Return the content of the first choice
-	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+	return strings.TrimSpace(resp.Choices[0].Message.Content), resp.Usage, nil
 }
@@ -0,0 +1,53 @@
+package types
+
+// Role is what a caller of the API may do, every role can do what the ones below it can.
+type Role string
+
+const (
+	RoleReader   Role = "reader"   // read disasters, locations, exports and the event streams
+	RoleOperator Role = "operator" // run detection, ingest and the demo and testing routes, spend NLP/OpenAI quota
+	RoleAdmin    Role = "admin"    // manage feeds, webhooks and API keys, delete data
+)
+
+// Rank orders the roles, 0 for an unknown one.
+func (r Role) Rank() int {
+	switch r {
+	case RoleReader:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// APIKey is a key callers send in the X-API-Key header, see package auth. Only the SHA-256 of the key
+// is stored, the key itself is returned once when it is created.
+type APIKey struct {
+	ID        string `firestore:"-" json:"id"`
+	Name      string `firestore:"name" json:"name"`
+	Role      Role   `firestore:"role" json:"role"`
+	Hash      string `firestore:"hash" json:"-"`
+	Prefix    string `firestore:"prefix" json:"prefix"` // start of the key, to tell keys apart
+	CreatedAt string `firestore:"createdAt" json:"createdAt"`
+	CreatedBy string `firestore:"createdBy" json:"createdBy"`
+	ExpiresAt string `firestore:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	RevokedAt string `firestore:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// AuditEntry records a request to an operator or admin route: who made it, what it was and how it ended.
+type AuditEntry struct {
+	ID         string `firestore:"-" json:"id"`
+	At         string `firestore:"at" json:"at"`
+	Actor      string `firestore:"actor" json:"actor"` // API key ID, JWT subject or "anonymous"
+	ActorName  string `firestore:"actorName,omitempty" json:"actorName,omitempty"`
+	AuthMethod string `firestore:"authMethod" json:"authMethod"` // api_key, jwt, admin_key or anonymous
+	Role       Role   `firestore:"role,omitempty" json:"role,omitempty"`
+	Method     string `firestore:"method" json:"method"`
+	Route      string `firestore:"route" json:"route"` // the route pattern, e.g. /api/admin/feeds/:id
+	Path       string `firestore:"path" json:"path"`   // with the query
+	Status     int    `firestore:"status" json:"status"`
+	IP         string `firestore:"ip" json:"ip"`
+	UserAgent  string `firestore:"userAgent,omitempty" json:"userAgent,omitempty"`
+}
@@ -0,0 +1,18 @@
+package types
+
+// BackfillProgress is the saved state of a feed backfill, one per feed.
+type BackfillProgress struct {
+	FeedURI      string `firestore:"feedUri" json:"feedUri"`
+	Cursor       string `firestore:"cursor" json:"cursor"` // next page to fetch, empty before the first page
+	Since        string `firestore:"since,omitempty" json:"since,omitempty"`
+	Pages        int    `firestore:"pages" json:"pages"`
+	Saved        int    `firestore:"saved" json:"saved"`
+	AlreadyExist int    `firestore:"alreadyExist" json:"alreadyExist"`
+	Failed       int    `firestore:"failed" json:"failed"`
+	Skipped      int    `firestore:"skipped" json:"skipped"`                           // older than Since
+	OldestPost   string `firestore:"oldestPost,omitempty" json:"oldestPost,omitempty"` // createdAt of the oldest post seen
+	StartedAt    string `firestore:"startedAt" json:"startedAt"`
+	UpdatedAt    string `firestore:"updatedAt" json:"updatedAt"`
+	Done         bool   `firestore:"done" json:"done"`
+	StopReason   string `firestore:"stopReason,omitempty" json:"stopReason,omitempty"`
+}
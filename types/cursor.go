@@ -0,0 +1,9 @@
+package types
+
+// Cursor is the saved position of a long-running reader (the Jetstream ingest, a feed backfill...)
+// so it can resume where it stopped after a restart.
+type Cursor struct {
+	Name      string `firestore:"-" json:"name"`
+	Value     string `firestore:"value" json:"value"`
+	UpdatedAt string `firestore:"updatedAt" json:"updatedAt"` // RFC3339
+}
@@ -0,0 +1,19 @@
+package types
+
+// DetectionRun records one run of disaster detection, summarization and save.
+type DetectionRun struct {
+	ID                 string   `firestore:"-" json:"id"`
+	Trigger            string   `firestore:"trigger" json:"trigger"` // "cron" or "manual"
+	StartedAt          string   `firestore:"startedAt" json:"startedAt"`
+	FinishedAt         string   `firestore:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	Profile            string   `firestore:"profile" json:"profile"`
+	Regions            []Region `firestore:"regions" json:"regions"` // empty for everywhere
+	SentimentThreshold float32  `firestore:"sentimentThreshold" json:"sentimentThreshold"`
+	LocationsChecked   int      `firestore:"locationsChecked" json:"locationsChecked"`
+	ClustersFound      int      `firestore:"clustersFound" json:"clustersFound"`
+	DisastersCreated   []string `firestore:"disastersCreated" json:"disastersCreated"`
+	DisastersUpdated   []string `firestore:"disastersUpdated" json:"disastersUpdated"` // matched again or changed status
+	SummariesGenerated int      `firestore:"summariesGenerated" json:"summariesGenerated"`
+	Errors             []string `firestore:"errors" json:"errors"`
+	Succeeded          bool     `firestore:"succeeded" json:"succeeded"` // false if the run stopped early, see Errors
+}
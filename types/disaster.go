@@ -9,6 +9,21 @@ const (
 	Critical Severity = "critical"
 )
 
+// Rank orders the severities from 1 (low) to 4 (critical), 0 for an unknown one.
+func (s Severity) Rank() int {
+	switch s {
+	case Low:
+		return 1
+	case Medium:
+		return 2
+	case High:
+		return 3
+	case Critical:
+		return 4
+	}
+	return 0
+}
+
 type Status string
 
 const (
@@ -20,24 +35,104 @@ const (
 type DisasterData struct {
 	// Fields representing the *cluster*
 	ID            string      `firestore:"-"`
-	Lat           float64     `firestore:"lat"`         // Centroid Latitude
-	Long          float64     `firestore:"long"`        // Centroid Longitude
+	Lat           float64     `firestore:"lat"`         // Centroid Latitude, weighted by skeets
+	Long          float64     `firestore:"long"`        // Centroid Longitude, weighted by skeets
 	LocationIDs   []string    `firestore:"locationIDs"` // IDs of locations included in this disaster
 	LocationCount int         `firestore:"locationCount"`
 	BoundingBox   BoundingBox `firestore:"boundingBox"`
+	Hull          []GeoPoint  `firestore:"hull,omitempty"` // Convex hull of the locations, counterclockwise, not closed
+	AreaKM2       float64     `firestore:"areaKm2"`        // Approximate affected area in km², the hull plus a margin around every location
 
 	// Aggregated/Derived Disaster Info
-	DisasterType Category `firestore:"disasterType"` // Dominant or seed type
-	Severity     Severity `firestore:"severity,omitempty"`
+	DisasterType Category `firestore:"disasterType"`       // Dominant or seed type
+	Severity     Severity `firestore:"severity,omitempty"` // Level of SeverityScore
 	Status       Status   `firestore:"status,omitempty"`
 	ReportedDate string   `firestore:"reportedDate"`      // Earliest firstSkeetTimestamp in the cluster
 	LastUpdate   string   `firestore:"lastUpdate"`        // Latest lastSkeetTimestamp in the cluster
 	Summary      string   `firestore:"summary,omitempty"` // To be filled later by LLM
 
-	// Aggregated counts/sentiment for the cluster
-	TotalSkeetsAmount int           `firestore:"totalSkeetsAmount"`
-	ClusterSentiment  float32       `firestore:"clusterSentiment"`
-	ClusterCounts     DisasterCount `firestore:"clusterCounts"`
+	// Aggregated counts/sentiment of the cluster's short (6h) windows
+	TotalSkeetsAmount   int           `firestore:"totalSkeetsAmount"`
+	ClusterSentiment    float32       `firestore:"clusterSentiment"`
+	ClusterCounts       DisasterCount `firestore:"clusterCounts"`
+	AllTimeSkeetsAmount int           `firestore:"allTimeSkeetsAmount,omitempty"` // every skeet of the cluster's locations, what lifecycle trends are measured on
+	AnomalyScore        float64       `firestore:"anomalyScore"`                  // highest burst score of the cluster's locations
+
+	// Severity model, see detection.ScoreSeverity
+	SeverityScore   float64          `firestore:"severityScore"` // 0-100
+	SeverityFactors []SeverityFactor `firestore:"severityFactors,omitempty"`
+
+	// Lifecycle across detection runs, see detection.TrackDisasters
+	FirstDetected string             `firestore:"firstDetected,omitempty"` // First run the cluster was detected in
+	LastDetected  string             `firestore:"lastDetected,omitempty"`  // Last run the cluster was detected in
+	LastActivity  string             `firestore:"lastActivity,omitempty"`  // Last run the cluster had new skeets
+	MissedRuns    int                `firestore:"missedRuns,omitempty"`    // Runs in a row the cluster was not detected in since LastDetected
+	EndedAt       string             `firestore:"endedAt,omitempty"`       // When it went Not_Active, empty while open
+	Observations  []ClusterSnapshot  `firestore:"observations,omitempty"`  // Cluster totals per run it was detected in, oldest first
+	StatusHistory []StatusTransition `firestore:"statusHistory,omitempty"`
+
+	// CountsMigrated is set when the document was read with legacy cluster counts
+	CountsMigrated bool `firestore:"-" json:"-"`
+}
+
+// SkeetsSeen is the cluster's all time skeet count, see ClusterSnapshot.SkeetsSeen.
+func (d DisasterData) SkeetsSeen() int {
+	return skeetsSeen(d.AllTimeSkeetsAmount, d.TotalSkeetsAmount)
+}
+
+func skeetsSeen(allTime, total int) int {
+	if allTime > 0 {
+		return allTime
+	}
+	return total
+}
+
+// ClusterSnapshot is the state of a disaster's cluster in one detection run.
+type ClusterSnapshot struct {
+	At                  string  `firestore:"at"`
+	TotalSkeetsAmount   int     `firestore:"totalSkeetsAmount"`
+	AllTimeSkeetsAmount int     `firestore:"allTimeSkeetsAmount,omitempty"`
+	ClusterSentiment    float32 `firestore:"clusterSentiment"`
+	LocationCount       int     `firestore:"locationCount"`
+	SeverityScore       float64 `firestore:"severityScore,omitempty"`
+}
+
+// SkeetsSeen is the cluster's all time skeet count. Snapshots taken before the cluster totals were
+// windowed only have TotalSkeetsAmount, which was the all time count then.
+func (s ClusterSnapshot) SkeetsSeen() int {
+	return skeetsSeen(s.AllTimeSkeetsAmount, s.TotalSkeetsAmount)
+}
+
+// SeverityFactor is one input of a disaster's SeverityScore.
+type SeverityFactor struct {
+	Name   string  `firestore:"name" json:"name"`     // sentiment, volume, spread, growth or engagement
+	Value  float64 `firestore:"value" json:"value"`   // what was measured, in the factor's own unit
+	Level  float64 `firestore:"level" json:"level"`   // 0 to 3: 1 at the medium threshold, 2 at high, 3 at critical
+	Weight float64 `firestore:"weight" json:"weight"` // share of the score, the weights of a disaster sum to 1
+}
+
+// StatusTransition is one change of a disaster's Status. From is empty for the first one.
+type StatusTransition struct {
+	From   Status `firestore:"from"`
+	To     Status `firestore:"to"`
+	At     string `firestore:"at"`
+	Reason string `firestore:"reason"`
+}
+
+// MigrateDisasterCounts converts legacy cluster counts in place and reports whether there were any.
+func (d *DisasterData) MigrateDisasterCounts() bool {
+	counts, ok := d.ClusterCounts.Migrated()
+	if ok {
+		d.ClusterCounts = counts
+		d.CountsMigrated = true
+	}
+	return ok
+}
+
+// GeoPoint is a point of a disaster's hull.
+type GeoPoint struct {
+	Lat  float64 `firestore:"lat" json:"lat"`
+	Long float64 `firestore:"long" json:"long"`
 }
 
 type BoundingBox struct {
@@ -47,3 +142,10 @@ type BoundingBox struct {
 	MaxLon float64 `firestore:"maxLon"`
 }
 
+// RepresentativeSkeet is a skeet of one of a disaster's locations, see processor.RepresentativeSkeets.
+type RepresentativeSkeet struct {
+	LocationID   string  `json:"locationId"`
+	LocationName string  `json:"locationName"`
+	Relevance    float64 `json:"relevance"` // classification probability of the disaster's type
+	Skeet        Skeet   `json:"skeet"`
+}
@@ -0,0 +1,14 @@
+package types
+
+// FeedConfig is one feed generator the scheduler polls, see data/feeds.yaml.
+type FeedConfig struct {
+	ID       string   `firestore:"-" json:"id" yaml:"id"`
+	Name     string   `firestore:"name" json:"name" yaml:"name"`
+	URI      string   `firestore:"uri" json:"uri" yaml:"uri"`
+	Category Category `firestore:"category" json:"category" yaml:"category"` // what the feed is expected to be about
+	Schedule string   `firestore:"schedule" json:"schedule" yaml:"schedule"` // standard 5 field cron spec
+	PageSize int      `firestore:"pageSize" json:"pageSize" yaml:"pageSize"`
+	Enabled  bool     `firestore:"enabled" json:"enabled" yaml:"enabled"`
+	// short names accepted wherever a feed is picked by query param, e.g. "f" for the fire feed
+	Aliases []string `firestore:"aliases" json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
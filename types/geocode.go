@@ -0,0 +1,13 @@
+package types
+
+// GeocodeResult is what a geocoder resolved a location name to.
+// Found is false when the backend had no result, those are cached too so the name isn't looked up again.
+type GeocodeResult struct {
+	Query            string  `firestore:"query" json:"query"`
+	FormattedAddress string  `firestore:"formattedAddress" json:"formattedAddress"`
+	Lat              float64 `firestore:"lat" json:"lat"`
+	Long             float64 `firestore:"long" json:"long"`
+	Found            bool    `firestore:"found" json:"found"`
+	Source           string  `firestore:"source" json:"source"`                         // backend that produced the result: google or offline
+	CachedAt         string  `firestore:"cachedAt,omitempty" json:"cachedAt,omitempty"` // RFC3339, set by the cache
+}
@@ -1,25 +1,134 @@
 package types
 
+import "time"
+
 type LocationData struct {
-	ID                  string                 `firestore:"-"` // tell firestore to ignore
-	LocationName        string                 `firestore:"locationName"`
-	FormattedAddress    string                 `firestore:"formattedAddress"`
-	Lat                 float64                `firestore:"lat"`
-	Long                float64                `firestore:"long"`
-	Type                string                 `firestore:"type"`
-	AvgSentimentList    []AvgLocationSentiment `firestore:"avgSentimentList"`
-	LatestSkeetsAmount  int                    `firestore:"latestSkeetsAmount"`
-	LatestDisasterCount DisasterCount          `firestore:"latestDisasterCount"`
-	LatestSentiment     float32                `firestore:"latestSentiment"`
-	FirstSkeetTimestamp string                 `firestore:"firstSkeetTimestamp,omitempty"`
-	LastSkeetTimestamp  string                 `firestore:"lastSkeetTimestamp,omitempty"`
-}
-
-type DisasterCount struct {
-	FireCount        int `firestore:"fireCount"`
-	HurricaneCount   int `firestore:"hurricaneCount"`
-	EarthquakeCount  int `firestore:"earthquakeCount"`
-	NonDisasterCount int `firestore:"nonDisasterCount"`
+	ID                  string                    `firestore:"-"` // tell firestore to ignore
+	LocationName        string                    `firestore:"locationName"`
+	FormattedAddress    string                    `firestore:"formattedAddress"`
+	Lat                 float64                   `firestore:"lat"`
+	Long                float64                   `firestore:"long"`
+	Type                string                    `firestore:"type"`
+	AvgSentimentList    []AvgLocationSentiment    `firestore:"avgSentimentList"`
+	LatestSkeetsAmount  int                       `firestore:"latestSkeetsAmount"`
+	LatestDisasterCount DisasterCount             `firestore:"latestDisasterCount"`
+	LatestSentiment     float32                   `firestore:"latestSentiment"`
+	FirstSkeetTimestamp string                    `firestore:"firstSkeetTimestamp,omitempty"`
+	LastSkeetTimestamp  string                    `firestore:"lastSkeetTimestamp,omitempty"`
+	Windows             map[string]LocationWindow `firestore:"windows,omitempty"` // rolling aggregates keyed by RollingWindow.Name
+	Anomaly             LocationAnomaly           `firestore:"anomaly"`
+
+	// CountsMigrated is set when the document was read with legacy disaster counts, see MigrateDisasterCounts
+	CountsMigrated bool `firestore:"-" json:"-"`
+}
+
+// MigrateDisasterCounts converts legacy disaster counts in place and reports whether there were any.
+func (l *LocationData) MigrateDisasterCounts() bool {
+	migrated := false
+	if counts, ok := l.LatestDisasterCount.Migrated(); ok {
+		l.LatestDisasterCount = counts
+		migrated = true
+	}
+	for i := range l.AvgSentimentList {
+		if counts, ok := l.AvgSentimentList[i].DisasterCount.Migrated(); ok {
+			l.AvgSentimentList[i].DisasterCount = counts
+			migrated = true
+		}
+	}
+	if migrated {
+		l.CountsMigrated = true
+	}
+	return migrated
+}
+
+// DisasterCount is the number of skeets per classified category.
+// Categories with no skeets may be missing from the map.
+type DisasterCount map[Category]int
+
+// legacyCountFields are the field names DisasterCount had when it was a struct
+// with one field per category. Documents written before that still have them.
+var legacyCountFields = map[Category]Category{
+	"fireCount":        Wildfire,
+	"hurricaneCount":   Hurricane,
+	"earthquakeCount":  Earthquake,
+	"nonDisasterCount": NonDisaster,
+}
+
+// Add adds n skeets to a category, allocating the map if needed.
+func (d *DisasterCount) Add(category Category, n int) {
+	if *d == nil {
+		*d = make(DisasterCount)
+	}
+	(*d)[category] += n
+}
+
+// Merge adds every count of other.
+func (d *DisasterCount) Merge(other DisasterCount) {
+	for category, n := range other {
+		d.Add(category, n)
+	}
+}
+
+// Total is the number of skeets over all categories, NonDisaster included.
+func (d DisasterCount) Total() int {
+	total := 0
+	for _, n := range d {
+		total += n
+	}
+	return total
+}
+
+// DisasterTotal is the number of skeets in disaster categories, NonDisaster left out.
+func (d DisasterCount) DisasterTotal() int {
+	return d.Total() - d[NonDisaster]
+}
+
+// Dominant returns the disaster category with the highest count, NonDisaster if no disaster has any.
+// Ties go to the category that comes first in ClassificationOrder.
+func (d DisasterCount) Dominant() Category {
+	dominant := NonDisaster
+	maxCount := 0
+	for _, category := range ClassificationOrder {
+		if category == NonDisaster {
+			continue
+		}
+		if d[category] > maxCount {
+			maxCount = d[category]
+			dominant = category
+		}
+	}
+	return dominant
+}
+
+// Migrated converts the legacy fireCount/hurricaneCount/earthquakeCount/nonDisasterCount keys to
+// categories and reports whether there were any. A legacy key is dropped if the category is already
+// present, that only happens when new counts were merged into an old document.
+func (d DisasterCount) Migrated() (DisasterCount, bool) {
+	legacy := false
+	for key := range d {
+		if _, ok := legacyCountFields[key]; ok {
+			legacy = true
+			break
+		}
+	}
+	if !legacy {
+		return d, false
+	}
+
+	out := make(DisasterCount, len(d))
+	for key, n := range d {
+		if _, ok := legacyCountFields[key]; !ok {
+			out[key] = n
+		}
+	}
+	for key, n := range d {
+		if category, ok := legacyCountFields[key]; ok {
+			if _, present := out[category]; !present {
+				out[category] = n
+			}
+		}
+	}
+	return out, true
 }
 
 type AvgLocationSentiment struct {
@@ -29,6 +138,50 @@ type AvgLocationSentiment struct {
 	DisasterCount    DisasterCount `firestore:"disasterCount"`
 }
 
+// RollingWindow is a period counted back from the time a location is updated.
+type RollingWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// ShortWindow is the window detection seeds from, it reacts to an event within hours.
+var ShortWindow = RollingWindow{"6h", 6 * time.Hour}
+
+// RollingWindows are the windows computed for every location, shortest first.
+var RollingWindows = []RollingWindow{
+	ShortWindow,
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+}
+
+// LocationWindow aggregates the skeets of a location posted in one rolling window.
+type LocationWindow struct {
+	Since            string        `firestore:"since"` // start of the window, UpdatedAt - the window duration
+	UpdatedAt        string        `firestore:"updatedAt"`
+	SkeetsAmount     int           `firestore:"skeetsAmount"`
+	AverageSentiment float32       `firestore:"averageSentiment"`
+	DisasterCount    DisasterCount `firestore:"disasterCount"`
+	Engagement       int           `firestore:"engagement"` // likes and reposts of the skeets, see Skeet.Engagement
+}
+
+// LocationAnomaly is how unusual the disaster skeet volume of a location's short window is
+// compared to the location's own history, see detection.ScoreBurst.
+type LocationAnomaly struct {
+	Window       string   `firestore:"window"`
+	Observed     int      `firestore:"observed"`     // disaster skeets in the window
+	Expected     float64  `firestore:"expected"`     // disaster skeets expected in the window from the baseline
+	BaselineRate float64  `firestore:"baselineRate"` // disaster skeets per hour
+	Score        float64  `firestore:"score"`        // -log10 of the chance of seeing at least Observed, 3 means 1 in 1000
+	Category     Category `firestore:"category"`     // dominant disaster category in the window
+	UpdatedAt    string   `firestore:"updatedAt"`
+}
+
+// Window returns the aggregate of a rolling window, ok is false if it was never computed.
+func (l LocationData) Window(w RollingWindow) (LocationWindow, bool) {
+	window, ok := l.Windows[w.Name]
+	return window, ok
+}
+
 type NewLocationMetaData struct {
 	LocationName string
 	Type         string
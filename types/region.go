@@ -0,0 +1,38 @@
+package types
+
+import "fmt"
+
+// Region is a lat/long box detection can be limited to. MinLon > MaxLon is a box crossing the antimeridian.
+type Region struct {
+	Name   string  `firestore:"name" json:"name" yaml:"name"`
+	MinLat float64 `firestore:"minLat" json:"minLat" yaml:"minLat"`
+	MaxLat float64 `firestore:"maxLat" json:"maxLat" yaml:"maxLat"`
+	MinLon float64 `firestore:"minLon" json:"minLon" yaml:"minLon"`
+	MaxLon float64 `firestore:"maxLon" json:"maxLon" yaml:"maxLon"`
+}
+
+// CrossesAntimeridian reports whether the box wraps from 180 to -180.
+func (r Region) CrossesAntimeridian() bool {
+	return r.MinLon > r.MaxLon
+}
+
+func (r Region) Contains(lat, lon float64) bool {
+	if lat < r.MinLat || lat > r.MaxLat {
+		return false
+	}
+	if r.CrossesAntimeridian() {
+		return lon >= r.MinLon || lon <= r.MaxLon
+	}
+	return lon >= r.MinLon && lon <= r.MaxLon
+}
+
+// Validate checks the bounds are real coordinates.
+func (r Region) Validate() error {
+	if r.MinLat < -90 || r.MaxLat > 90 || r.MinLat > r.MaxLat {
+		return fmt.Errorf("region %q: latitude must be -90 <= minLat <= maxLat <= 90", r.Name)
+	}
+	if r.MinLon < -180 || r.MinLon > 180 || r.MaxLon < -180 || r.MaxLon > 180 {
+		return fmt.Errorf("region %q: longitude must be between -180 and 180", r.Name)
+	}
+	return nil
+}
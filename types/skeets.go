@@ -1,25 +1,116 @@
 package types
 
+import "strings"
+
 type SaveSkeetResult struct {
 	SavedSkeetID         string    `json:"savedSkeetId"`
 	Content              string    `json:"content"`
 	NewLocationNames     []string  `json:"newLocationNames"`
 	ProcessedEntityCount int       `json:"processedEntityCount"`
 	Classification       []float64 `json:"classification"`
+	ClassifiedBy         string    `json:"classifiedBy"`
 	Sentiment            Sentiment `json:"sentiment"`
 	AlreadyExist         bool      `json:"alreadyExist"`
 	ErrorSaving          bool      `json:"errorSaving"`
+	BudgetExhausted      bool      `json:"budgetExhausted,omitempty"` // not analyzed, a paid API's daily budget was spent
 }
 
 type Category string
 
 const (
-	Wildfire    Category = "wildfire"
-	Hurricane   Category = "hurricane"
-	Earthquake  Category = "earthquake"
-	NonDisaster Category = "non-disaster"
+	Wildfire         Category = "wildfire"
+	Hurricane        Category = "hurricane"
+	Earthquake       Category = "earthquake"
+	NonDisaster      Category = "non-disaster"
+	Flood            Category = "flood"
+	Tornado          Category = "tornado"
+	Tsunami          Category = "tsunami"
+	VolcanicEruption Category = "volcanic-eruption"
+	Landslide        Category = "landslide"
+	Blizzard         Category = "blizzard"
+	Drought          Category = "drought"
 )
 
+// ClassificationOrder is the index order of the classification vector stored on every skeet.
+// It matches the output of the remote ML model, so it must only ever be appended to.
+// The remote model only knows the first four, older and remote vectors are shorter and
+// the missing categories read as 0.
+var ClassificationOrder = []Category{
+	Wildfire, Hurricane, Earthquake, NonDisaster,
+	Flood, Tornado, Tsunami, VolcanicEruption, Landslide, Blizzard, Drought,
+}
+
+// IsKnown reports whether the category is in ClassificationOrder.
+func (c Category) IsKnown() bool {
+	for _, known := range ClassificationOrder {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Label is the category as written in prose, e.g. "volcanic eruption".
+func (c Category) Label() string {
+	return strings.ReplaceAll(string(c), "-", " ")
+}
+
+// ParseCategory maps the labels used across the project (csv predictions like "fire",
+// Tweet.DisasterName like "Volcanic Eruption") to a category. ok is false if it is unknown.
+func ParseCategory(label string) (Category, bool) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	label = strings.NewReplacer(" ", "-", "_", "-").Replace(label)
+	switch label {
+	case "fire":
+		return Wildfire, true
+	case "nondisaster", "non-disaster", "none":
+		return NonDisaster, true
+	case "volcano", "eruption":
+		return VolcanicEruption, true
+	}
+	c := Category(label)
+	return c, c.IsKnown()
+}
+
+// CategoryDistribution is a probability per category returned by a classifier.
+type CategoryDistribution map[Category]float64
+
+// Vector flattens the distribution into the stored classification vector.
+func (d CategoryDistribution) Vector() []float64 {
+	if len(d) == 0 {
+		return nil
+	}
+	v := make([]float64, len(ClassificationOrder))
+	for i, c := range ClassificationOrder {
+		v[i] = d[c]
+	}
+	return v
+}
+
+// Top returns the most likely category, NonDisaster if the distribution is empty.
+func (d CategoryDistribution) Top() Category {
+	best := NonDisaster
+	bestProb := 0.0
+	for _, c := range ClassificationOrder {
+		if d[c] > bestProb {
+			bestProb = d[c]
+			best = c
+		}
+	}
+	return best
+}
+
+// DistributionFromVector labels a stored classification vector.
+func DistributionFromVector(v []float64) CategoryDistribution {
+	d := make(CategoryDistribution, len(v))
+	for i, prob := range v {
+		if i < len(ClassificationOrder) {
+			d[ClassificationOrder[i]] = prob
+		}
+	}
+	return d
+}
+
 // Skeet represents a post stored in Firestore
 type Skeet struct {
 	Avatar         string    `firestore:"avatar"`
@@ -29,13 +120,30 @@ type Skeet struct {
 	DisplayName    string    `firestore:"displayName"`
 	UID            string    `firestore:"uid"`
 	Classification []float64 `firestore:"classification" json:"classification"`
+	ClassifiedBy   string    `firestore:"classifiedBy,omitempty" json:"classifiedBy,omitempty"` // which classifier backend produced Classification
 	Sentiment      Sentiment `firestore:"sentiment" json:"sentiment"`
+	LikeCount      int       `firestore:"likeCount,omitempty" json:"likeCount,omitempty"`     // when the skeet was saved
+	RepostCount    int       `firestore:"repostCount,omitempty" json:"repostCount,omitempty"` // when the skeet was saved
+}
+
+// Engagement is the likes plus reposts the skeet had when it was saved.
+func (s Skeet) Engagement() int {
+	return s.LikeCount + s.RepostCount
 }
 
 // This was made out of necessity because of how long the parameters would have been lol
 type SaveCompleteSkeetType struct {
 	NewSkeet       Skeet
 	Classification []float64
+	ClassifiedBy   string
 	Entities       []Entity
 	Sentiment      Sentiment
 }
+
+// SkeetRecord is a document of the skeets collection: the skeet and the entities found in it.
+type SkeetRecord struct {
+	ID        string
+	Skeet     Skeet
+	Addresses []Entity // entity_ADDRESS
+	Locations []Entity // entity_LOCATION
+}
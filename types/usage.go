@@ -0,0 +1,15 @@
+package types
+
+// ProviderUsage is what one paid upstream API was used for on one UTC day, see package limits.
+type ProviderUsage struct {
+	ID           string  `firestore:"-" json:"-"` // <provider>_<day>
+	Provider     string  `firestore:"provider" json:"provider"`
+	Day          string  `firestore:"day" json:"day"` // 2006-01-02
+	Calls        int     `firestore:"calls" json:"calls"`
+	Errors       int     `firestore:"errors" json:"errors"`
+	Skipped      int     `firestore:"skipped" json:"skipped"` // not made, the budget was exhausted
+	Cost         float64 `firestore:"cost" json:"cost"`       // USD
+	InputTokens  int     `firestore:"inputTokens" json:"inputTokens"`
+	OutputTokens int     `firestore:"outputTokens" json:"outputTokens"`
+	UpdatedAt    string  `firestore:"updatedAt" json:"updatedAt"`
+}
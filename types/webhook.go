@@ -0,0 +1,47 @@
+package types
+
+// Webhook is a subscriber notified with a signed POST of every event matching its filters, see package webhooks.
+type Webhook struct {
+	ID          string `firestore:"-" json:"id"`
+	URL         string `firestore:"url" json:"url"`
+	Secret      string `firestore:"secret" json:"secret,omitempty"` // HMAC-SHA256 key, only returned when the webhook is created
+	Description string `firestore:"description" json:"description"`
+	Enabled     bool   `firestore:"enabled" json:"enabled"`
+
+	// Filters, empty ones don't filter
+	Topics      []string   `firestore:"topics" json:"topics"`                     // event topics or prefixes, every disaster topic when empty
+	Types       []Category `firestore:"types" json:"types"`                       // disaster types
+	MinSeverity Severity   `firestore:"minSeverity" json:"minSeverity,omitempty"` // disasters at least this severe
+	Region      *Region    `firestore:"region,omitempty" json:"region,omitempty"` // disasters with their centroid inside
+
+	CreatedAt string `firestore:"createdAt" json:"createdAt"`
+	UpdatedAt string `firestore:"updatedAt" json:"updatedAt"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // waiting for its next attempt
+	DeliveryDelivered DeliveryStatus = "delivered" // the subscriber answered 2xx
+	DeliveryDead      DeliveryStatus = "dead"      // out of attempts or rejected, in the dead-letter queue
+)
+
+// WebhookDelivery is one event sent to one webhook, with every attempt made so far.
+type WebhookDelivery struct {
+	ID            string            `firestore:"-" json:"id"`
+	WebhookID     string            `firestore:"webhookId" json:"webhookId"`
+	Topic         string            `firestore:"topic" json:"topic"`
+	Payload       string            `firestore:"payload" json:"payload"` // the signed JSON body
+	Status        DeliveryStatus    `firestore:"status" json:"status"`
+	Attempts      []DeliveryAttempt `firestore:"attempts" json:"attempts"`
+	CreatedAt     string            `firestore:"createdAt" json:"createdAt"`
+	NextAttemptAt string            `firestore:"nextAttemptAt,omitempty" json:"nextAttemptAt,omitempty"` // pending only
+	DeliveredAt   string            `firestore:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+}
+
+type DeliveryAttempt struct {
+	At         string `firestore:"at" json:"at"`
+	StatusCode int    `firestore:"statusCode" json:"statusCode"` // 0 when no response came back
+	Error      string `firestore:"error,omitempty" json:"error,omitempty"`
+	DurationMS int64  `firestore:"durationMs" json:"durationMs"`
+}
@@ -0,0 +1,360 @@
+// Package webhooks delivers events from the event bus to the subscribers' URLs as signed POSTs, retrying
+// failed deliveries with exponential backoff. Every delivery is kept in the store with its attempts, the
+// ones that ran out of attempts or were rejected are the dead-letter queue and can be redelivered.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-firebird/db"
+	"go-firebird/events"
+	"go-firebird/types"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers of every delivery. The signature is t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+const (
+	SignatureHeader = "X-Firebird-Signature"
+	EventHeader     = "X-Firebird-Event"
+	DeliveryHeader  = "X-Firebird-Delivery"
+)
+
+// clientWebhookID is the webhook created for CLIENT_URL, which used to get the demo tweets POSTed directly.
+const clientWebhookID = "client"
+
+var (
+	ErrNotFound = errors.New("webhook not found")
+
+	// ErrDeliveryPending is returned when redelivering a delivery that is still being retried.
+	ErrDeliveryPending = errors.New("delivery is still pending")
+)
+
+type Config struct {
+	MaxAttempts int           // attempts before a delivery goes to the dead-letter queue
+	BaseDelay   time.Duration // wait before the first retry, doubled for every further one
+	MaxDelay    time.Duration // longest wait between two attempts
+	Timeout     time.Duration // per attempt
+	Buffer      int           // events waiting to be dispatched before the bus drops them
+}
+
+// ConfigFromEnv reads WEBHOOK_MAX_ATTEMPTS, WEBHOOK_RETRY_BASE, WEBHOOK_RETRY_MAX and WEBHOOK_TIMEOUT
+// (Go durations like 30s).
+func ConfigFromEnv() Config {
+	cfg := Config{
+		MaxAttempts: 8,
+		BaseDelay:   30 * time.Second,
+		MaxDelay:    time.Hour,
+		Timeout:     10 * time.Second,
+		Buffer:      1024,
+	}
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && n > 0 {
+		cfg.MaxAttempts = n
+	}
+	for name, dst := range map[string]*time.Duration{
+		"WEBHOOK_RETRY_BASE": &cfg.BaseDelay,
+		"WEBHOOK_RETRY_MAX":  &cfg.MaxDelay,
+		"WEBHOOK_TIMEOUT":    &cfg.Timeout,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+			*dst = d
+		}
+	}
+	return cfg
+}
+
+// Dispatcher keeps the webhooks, loaded from the store so changes survive restarts, and delivers the
+// events matching them.
+type Dispatcher struct {
+	store  db.Store
+	cfg    Config
+	client *http.Client
+
+	mu         sync.RWMutex
+	hooks      map[string]types.Webhook
+	ctx        context.Context // of Run, attempts stop with it
+	deliveries sync.WaitGroup  // the deliver goroutines, Run waits for them
+}
+
+func NewDispatcher(store db.Store, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		hooks:  make(map[string]types.Webhook),
+		ctx:    context.Background(),
+	}
+}
+
+// InitDispatcher loads the webhooks from the store. When CLIENT_URL is set and there is no "client"
+// webhook yet, one is added for the demo tweets at CLIENT_URL/api/tweetHook.
+func InitDispatcher(store db.Store, cfg Config) (*Dispatcher, error) {
+	d := NewDispatcher(store, cfg)
+
+	stored, err := store.GetWebhooks()
+	if err != nil {
+		return nil, fmt.Errorf("loading webhooks: %w", err)
+	}
+	for _, hook := range stored {
+		d.hooks[hook.ID] = hook
+	}
+
+	if clientURL := os.Getenv("CLIENT_URL"); clientURL != "" {
+		if _, ok := d.hooks[clientWebhookID]; !ok {
+			hook := types.Webhook{
+				ID:          clientWebhookID,
+				URL:         strings.TrimRight(clientURL, "/") + "/api/tweetHook",
+				Description: "Demo tweets for the client (CLIENT_URL)",
+				Enabled:     true,
+				Topics:      []string{string(events.DemoTweets)},
+			}
+			if _, err := d.Create(hook); err != nil {
+				return nil, fmt.Errorf("adding the CLIENT_URL webhook: %w", err)
+			}
+			log.Printf("Added webhook %s for CLIENT_URL", clientWebhookID)
+		}
+	}
+	return d, nil
+}
+
+// Run dispatches the bus events until ctx is done. Deliveries left pending by the last run are resumed first.
+// It returns once the deliveries in progress stopped and saved their state, deliveries started after ctx
+// is done stay pending for the next Run.
+func (d *Dispatcher) Run(ctx context.Context, bus *events.Bus) {
+	d.mu.Lock()
+	d.ctx = ctx
+	d.mu.Unlock()
+
+	sub := bus.Subscribe(events.Filter{}, d.cfg.Buffer)
+	defer sub.Close()
+
+	pending, err := d.store.GetWebhookDeliveries("", types.DeliveryPending, -1)
+	if err != nil {
+		log.Printf("Error loading pending webhook deliveries: %v", err)
+	}
+	for _, delivery := range pending {
+		d.start(delivery, len(delivery.Attempts))
+	}
+	if len(pending) > 0 {
+		log.Printf("Resumed %d pending webhook deliveries", len(pending))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// start adds to the WaitGroup under d.mu only while ctx isn't done, so no Add races the Wait
+			d.mu.Lock()
+			d.mu.Unlock()
+			d.deliveries.Wait()
+			return
+		case e := <-sub.C:
+			d.Dispatch(e)
+		}
+	}
+}
+
+// Dispatch saves a pending delivery of the event for every enabled webhook it matches and starts them.
+func (d *Dispatcher) Dispatch(e events.Event) []types.WebhookDelivery {
+	var deliveries []types.WebhookDelivery
+	var payload []byte
+	for _, hook := range d.List() {
+		if !hook.Enabled || !Matches(hook, e) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(e); err != nil {
+				log.Printf("Error encoding event %d for webhooks: %v", e.ID, err)
+				return nil
+			}
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339)
+		delivery := types.WebhookDelivery{
+			ID:            uuid.NewString(),
+			WebhookID:     hook.ID,
+			Topic:         string(e.Topic),
+			Payload:       string(payload),
+			Status:        types.DeliveryPending,
+			Attempts:      []types.DeliveryAttempt{},
+			CreatedAt:     now,
+			NextAttemptAt: now,
+		}
+		if err := d.store.SaveWebhookDelivery(delivery); err != nil {
+			log.Printf("Error saving delivery of event %d to webhook %s: %v", e.ID, hook.ID, err)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+		d.start(delivery, 0)
+	}
+	return deliveries
+}
+
+// Redeliver gives a delivered or dead delivery another MaxAttempts attempts.
+func (d *Dispatcher) Redeliver(deliveryID string) (types.WebhookDelivery, error) {
+	delivery, err := d.store.GetWebhookDelivery(deliveryID)
+	if err != nil {
+		return delivery, err
+	}
+	if delivery.Status == types.DeliveryPending {
+		return delivery, ErrDeliveryPending
+	}
+	delivery.Status = types.DeliveryPending
+	delivery.NextAttemptAt = time.Now().UTC().Format(time.RFC3339)
+	delivery.DeliveredAt = ""
+	if err := d.store.SaveWebhookDelivery(delivery); err != nil {
+		return delivery, err
+	}
+	d.start(delivery, 0)
+	return delivery, nil
+}
+
+// start delivers in the background, unless Run is stopping: the delivery is saved as pending, so the next
+// Run resumes it.
+func (d *Dispatcher) start(delivery types.WebhookDelivery, made int) {
+	d.mu.Lock()
+	if d.ctx.Err() != nil {
+		d.mu.Unlock()
+		return
+	}
+	d.deliveries.Add(1)
+	d.mu.Unlock()
+	go func() {
+		defer d.deliveries.Done()
+		d.deliver(delivery, made)
+	}()
+}
+
+// deliver makes the attempts of a pending delivery, made is how many this round already had.
+func (d *Dispatcher) deliver(delivery types.WebhookDelivery, made int) {
+	d.mu.RLock()
+	ctx := d.ctx
+	d.mu.RUnlock()
+
+	var wait time.Duration
+	if next, err := time.Parse(time.RFC3339, delivery.NextAttemptAt); err == nil {
+		wait = time.Until(next)
+	}
+	for delivery.Status == types.DeliveryPending {
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return // still pending, the next Run resumes it
+			case <-timer.C:
+			}
+		}
+
+		hook, ok := d.Get(delivery.WebhookID)
+		if !ok || !hook.Enabled {
+			reason := "webhook was deleted"
+			if ok {
+				reason = "webhook is disabled"
+			}
+			delivery.Attempts = append(delivery.Attempts, types.DeliveryAttempt{
+				At:    time.Now().UTC().Format(time.RFC3339),
+				Error: reason,
+			})
+			delivery.Status = types.DeliveryDead
+		} else {
+			attempt, retry := d.attempt(ctx, hook, delivery)
+			delivery.Attempts = append(delivery.Attempts, attempt)
+			made++
+			switch {
+			case attempt.Error == "" && attempt.StatusCode/100 == 2:
+				delivery.Status = types.DeliveryDelivered
+				delivery.DeliveredAt = attempt.At
+			case retry && made < d.cfg.MaxAttempts:
+				wait = d.backoff(made)
+				delivery.NextAttemptAt = time.Now().UTC().Add(wait).Format(time.RFC3339)
+			default:
+				delivery.Status = types.DeliveryDead
+			}
+		}
+
+		if delivery.Status != types.DeliveryPending {
+			delivery.NextAttemptAt = ""
+		}
+		if delivery.Status == types.DeliveryDead {
+			log.Printf("Webhook delivery %s to %s failed after %d attempts, moved to the dead-letter queue",
+				delivery.ID, delivery.WebhookID, made)
+		}
+		if err := d.store.SaveWebhookDelivery(delivery); err != nil {
+			log.Printf("Error saving webhook delivery %s: %v", delivery.ID, err)
+		}
+	}
+}
+
+// attempt POSTs the payload once and reports whether a failure is worth retrying: network errors,
+// timeouts, 429 and 5xx are, any other answer is final.
+func (d *Dispatcher) attempt(ctx context.Context, hook types.Webhook, delivery types.WebhookDelivery) (types.DeliveryAttempt, bool) {
+	start := time.Now().UTC()
+	attempt := types.DeliveryAttempt{At: start.Format(time.RFC3339)}
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-firebird-webhooks")
+	req.Header.Set(EventHeader, delivery.Topic)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, start.Unix(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		attempt.DurationMS = time.Since(start).Milliseconds()
+		return attempt, true
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	if resp.StatusCode/100 == 2 {
+		return attempt, false
+	}
+	attempt.Error = resp.Status
+	return attempt, resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+}
+
+// backoff is the wait after the nth failed attempt: BaseDelay doubled n-1 times, at most MaxDelay,
+// with up to 10% jitter so retries of many deliveries don't line up.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.cfg.BaseDelay
+	for i := 1; i < n && delay < d.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.MaxDelay {
+		delay = d.cfg.MaxDelay
+	}
+	return delay + time.Duration(mathrand.Int63n(int64(delay)/10+1))
+}
+
+// Sign returns the signature header of a body sent at timestamp (unix seconds).
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
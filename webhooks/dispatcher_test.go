@@ -0,0 +1,273 @@
+package webhooks
+
+import (
+	"errors"
+	"go-firebird/db"
+	"go-firebird/events"
+	"go-firebird/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":1,"topic":"demo.tweets"}`)
+	want := "t=1700000000,v1=644060317320391fc66f883ecbf4523d83815f01fea0544e80fa6ae47eea7766"
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Fatalf("Sign = %s, want %s", got, want)
+	}
+
+	for name, other := range map[string]string{
+		"secret":    Sign("whsec_other", 1700000000, body),
+		"timestamp": Sign("whsec_test", 1700000001, body),
+		"body":      Sign("whsec_test", 1700000000, []byte(`{"id":2,"topic":"demo.tweets"}`)),
+	} {
+		if other[strings.Index(other, "v1="):] == want[strings.Index(want, "v1="):] {
+			t.Errorf("changing the %s didn't change the signature", name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(db.NewMemoryStore(), Config{BaseDelay: 30 * time.Second, MaxDelay: time.Hour})
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, c := range cases {
+		for i := 0; i < 20; i++ {
+			if got := d.backoff(c.attempt); got < c.want || got > c.want+c.want/10 {
+				t.Fatalf("backoff(%d) = %s, want %s plus at most 10%% jitter", c.attempt, got, c.want)
+			}
+		}
+	}
+}
+
+// receiver is a webhook endpoint answering with the given status codes in turn, the last one repeated.
+type receiver struct {
+	t      *testing.T
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	// Verify the signature the way a subscriber would
+	parts := strings.Split(r.Header.Get(SignatureHeader), ",")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") || !strings.HasPrefix(parts[1], "v1=") {
+		rc.t.Errorf("signature header = %q", r.Header.Get(SignatureHeader))
+	} else if ts, err := strconv.ParseInt(parts[0][2:], 10, 64); err != nil || Sign(rc.secret, ts, body) != r.Header.Get(SignatureHeader) {
+		rc.t.Errorf("signature %q doesn't verify", r.Header.Get(SignatureHeader))
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, string(body))
+	status := rc.statuses[0]
+	if len(rc.statuses) > 1 {
+		rc.statuses = rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) answer(statuses ...int) {
+	rc.mu.Lock()
+	rc.statuses = statuses
+	rc.mu.Unlock()
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+// testDispatcher returns a dispatcher with fast retries and one webhook for the demo tweets at rc.
+func testDispatcher(t *testing.T, statuses ...int) (*Dispatcher, *db.MemoryStore, *receiver) {
+	rc := &receiver{t: t, secret: "whsec_test", statuses: statuses}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+
+	store := db.NewMemoryStore()
+	d := NewDispatcher(store, Config{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond, Timeout: time.Second})
+	if _, err := d.Create(types.Webhook{
+		ID:      "hook",
+		URL:     server.URL,
+		Secret:  rc.secret,
+		Enabled: true,
+		Topics:  []string{string(events.DemoTweets)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return d, store, rc
+}
+
+func dispatchOne(t *testing.T, d *Dispatcher) types.WebhookDelivery {
+	t.Helper()
+	deliveries := d.Dispatch(events.Event{ID: 1, Topic: events.DemoTweets, Data: map[string]int{"tweets": 3}})
+	if len(deliveries) != 1 {
+		t.Fatalf("dispatched %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+// waitFor polls the delivery until it leaves pending.
+func waitFor(t *testing.T, store db.Store, id string) types.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		delivery, err := store.GetWebhookDelivery(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivery.Status != types.DeliveryPending {
+			return delivery
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery %s still pending after %d attempts", id, len(delivery.Attempts))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func statusCodes(delivery types.WebhookDelivery) []int {
+	codes := make([]int, len(delivery.Attempts))
+	for i, a := range delivery.Attempts {
+		codes[i] = a.StatusCode
+	}
+	return codes
+}
+
+func TestDeliverySigned(t *testing.T) {
+	d, store, rc := testDispatcher(t, http.StatusNoContent)
+	delivery := waitFor(t, store, dispatchOne(t, d).ID)
+
+	if delivery.Status != types.DeliveryDelivered || len(delivery.Attempts) != 1 || delivery.DeliveredAt == "" || delivery.NextAttemptAt != "" {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	r := rc.requests[0]
+	if r.Method != http.MethodPost || r.Header.Get(EventHeader) != string(events.DemoTweets) || r.Header.Get(DeliveryHeader) != delivery.ID {
+		t.Errorf("request = %s with headers %v", r.Method, r.Header)
+	}
+	if rc.bodies[0] != delivery.Payload || !strings.Contains(delivery.Payload, `"tweets":3`) {
+		t.Errorf("body = %s, payload = %s", rc.bodies[0], delivery.Payload)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	cases := []struct {
+		name       string
+		statuses   []int
+		wantStatus types.DeliveryStatus
+		wantCodes  []int
+	}{
+		{"delivered after retries", []int{503, 429, 200}, types.DeliveryDelivered, []int{503, 429, 200}},
+		{"dead after max attempts", []int{500}, types.DeliveryDead, []int{500, 500, 500}},
+		{"client errors are not retried", []int{400}, types.DeliveryDead, []int{400}},
+		{"request timeout is retried", []int{408, 201}, types.DeliveryDelivered, []int{408, 201}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, store, _ := testDispatcher(t, c.statuses...)
+			delivery := waitFor(t, store, dispatchOne(t, d).ID)
+
+			if delivery.Status != c.wantStatus {
+				t.Errorf("status = %s, want %s", delivery.Status, c.wantStatus)
+			}
+			if got := statusCodes(delivery); !reflect.DeepEqual(got, c.wantCodes) {
+				t.Errorf("attempts = %v, want %v", got, c.wantCodes)
+			}
+			if delivery.NextAttemptAt != "" {
+				t.Errorf("finished delivery still has nextAttemptAt %s", delivery.NextAttemptAt)
+			}
+
+			// Dead deliveries are the dead-letter queue
+			dead, err := store.GetWebhookDeliveries("hook", types.DeliveryDead, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inQueue := len(dead) == 1 && dead[0].ID == delivery.ID; inQueue != (c.wantStatus == types.DeliveryDead) {
+				t.Errorf("dead-letter queue = %v", dead)
+			}
+		})
+	}
+}
+
+func TestDeliveryToDisabledWebhook(t *testing.T) {
+	d, store, rc := testDispatcher(t, http.StatusOK)
+	delivery := dispatchOne(t, d)
+	delivery = waitFor(t, store, delivery.ID)
+
+	hook, _ := d.Get("hook")
+	hook.Enabled = false
+	if _, err := d.Update("hook", hook); err != nil {
+		t.Fatal(err)
+	}
+	if deliveries := d.Dispatch(events.Event{ID: 2, Topic: events.DemoTweets}); len(deliveries) != 0 {
+		t.Errorf("a disabled webhook got %d deliveries", len(deliveries))
+	}
+
+	// A delivery redelivered after its webhook was disabled dies without a request
+	if _, err := d.Redeliver(delivery.ID); err != nil {
+		t.Fatal(err)
+	}
+	delivery = waitFor(t, store, delivery.ID)
+	last := delivery.Attempts[len(delivery.Attempts)-1]
+	if delivery.Status != types.DeliveryDead || last.Error != "webhook is disabled" || rc.count() != 1 {
+		t.Errorf("delivery = %+v after %d requests", delivery, rc.count())
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	d, store, rc := testDispatcher(t, http.StatusInternalServerError)
+	delivery := waitFor(t, store, dispatchOne(t, d).ID)
+	if delivery.Status != types.DeliveryDead {
+		t.Fatalf("status = %s, want dead", delivery.Status)
+	}
+
+	rc.answer(http.StatusBadGateway, http.StatusOK)
+	redelivered, err := d.Redeliver(delivery.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redelivered.Status != types.DeliveryPending || redelivered.NextAttemptAt == "" {
+		t.Errorf("redelivered = %+v, want pending", redelivered)
+	}
+	// The 502 is retried after BaseDelay, until then the delivery is pending
+	if _, err := d.Redeliver(delivery.ID); !errors.Is(err, ErrDeliveryPending) {
+		t.Errorf("redelivering a pending delivery: %v, want ErrDeliveryPending", err)
+	}
+
+	delivery = waitFor(t, store, delivery.ID)
+	// The old attempts are kept, the redelivery gets MaxAttempts of its own
+	if want := []int{500, 500, 500, 502, 200}; !reflect.DeepEqual(statusCodes(delivery), want) {
+		t.Errorf("attempts = %v, want %v", statusCodes(delivery), want)
+	}
+	if delivery.Status != types.DeliveryDelivered || delivery.DeliveredAt == "" {
+		t.Errorf("delivery = %+v, want delivered", delivery)
+	}
+	if _, err := d.Redeliver("missing"); err == nil {
+		t.Error("redelivering an unknown delivery succeeded")
+	}
+}
@@ -0,0 +1,174 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go-firebird/events"
+	"go-firebird/types"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Matches reports whether the webhook wants the event. Type, severity and region filters only match
+// disaster events, except the type filter which also checks the category of other events.
+func Matches(hook types.Webhook, e events.Event) bool {
+	topics := make([]events.Topic, 0, len(hook.Topics))
+	for _, t := range hook.Topics {
+		topics = append(topics, events.Topic(t))
+	}
+	if len(topics) == 0 {
+		topics = []events.Topic{"disaster"}
+	}
+	if !(events.Filter{Topics: topics, Categories: hook.Types}).Matches(e) {
+		return false
+	}
+
+	if hook.MinSeverity == "" && hook.Region == nil {
+		return true
+	}
+	disaster, ok := e.Data.(events.DisasterEvent)
+	if !ok {
+		return false
+	}
+	if hook.MinSeverity != "" && disaster.Severity.Rank() < hook.MinSeverity.Rank() {
+		return false
+	}
+	return hook.Region == nil || hook.Region.Contains(disaster.Lat, disaster.Long)
+}
+
+// Normalize checks a webhook is usable and normalizes its filters.
+func Normalize(hook *types.Webhook) error {
+	hook.URL = strings.TrimSpace(hook.URL)
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute http or https URL", hook.URL)
+	}
+
+	filter := events.Filter{Categories: hook.Types}
+	for _, t := range hook.Topics {
+		filter.Topics = append(filter.Topics, events.Topic(strings.TrimSpace(t)))
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	for i, t := range filter.Topics {
+		hook.Topics[i] = string(t)
+	}
+	if hook.Topics == nil {
+		hook.Topics = []string{}
+	}
+	if hook.Types == nil {
+		hook.Types = []types.Category{}
+	}
+
+	if hook.MinSeverity != "" && hook.MinSeverity.Rank() == 0 {
+		return fmt.Errorf("unknown severity %q", hook.MinSeverity)
+	}
+	if hook.Region != nil {
+		if hook.Region.Name == "" {
+			hook.Region.Name = "webhook"
+		}
+		if err := hook.Region.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns every webhook sorted by ID.
+func (d *Dispatcher) List() []types.Webhook {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	list := make([]types.Webhook, 0, len(d.hooks))
+	for _, hook := range d.hooks {
+		list = append(list, hook)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (d *Dispatcher) Get(id string) (types.Webhook, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	hook, ok := d.hooks[id]
+	return hook, ok
+}
+
+// Create adds a webhook, with a random ID and secret unless they are given.
+func (d *Dispatcher) Create(hook types.Webhook) (types.Webhook, error) {
+	if err := Normalize(&hook); err != nil {
+		return hook, err
+	}
+	if hook.ID == "" {
+		hook.ID = uuid.NewString()
+	}
+	if _, ok := d.Get(hook.ID); ok {
+		return hook, fmt.Errorf("webhook %s already exists", hook.ID)
+	}
+	if hook.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return hook, err
+		}
+		hook.Secret = secret
+	}
+	hook.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	hook.UpdatedAt = hook.CreatedAt
+	return hook, d.save(hook)
+}
+
+// Update replaces a webhook's URL, description, filters and enabled flag. The secret is kept unless a
+// new one is given.
+func (d *Dispatcher) Update(id string, hook types.Webhook) (types.Webhook, error) {
+	old, ok := d.Get(id)
+	if !ok {
+		return hook, ErrNotFound
+	}
+	if err := Normalize(&hook); err != nil {
+		return hook, err
+	}
+	hook.ID = id
+	if hook.Secret == "" {
+		hook.Secret = old.Secret
+	}
+	hook.CreatedAt = old.CreatedAt
+	hook.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return hook, d.save(hook)
+}
+
+func (d *Dispatcher) Delete(id string) error {
+	if _, ok := d.Get(id); !ok {
+		return ErrNotFound
+	}
+	if err := d.store.DeleteWebhook(id); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	delete(d.hooks, id)
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Dispatcher) save(hook types.Webhook) error {
+	if err := d.store.SaveWebhook(hook); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.hooks[hook.ID] = hook
+	d.mu.Unlock()
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}